@@ -1,13 +1,36 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/cart"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/category"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/coupon"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/idempotency"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/inventory"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/job"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/invoice"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/notification"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/order"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/payment"
 	"github.com/mohammadahmadkhader/golang-ecommerce/service/product"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/promotion"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/recovery"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/returns"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/shipping"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/tax"
 	"github.com/mohammadahmadkhader/golang-ecommerce/service/user"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/warehouse"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/webhook"
+	"github.com/mohammadahmadkhader/golang-ecommerce/storage"
 )
 
 type APIServer struct {
@@ -26,14 +49,141 @@ func (s *APIServer) Run() error {
 	router := mux.NewRouter()
 	subRouter := router.PathPrefix("/api/v1").Subrouter()
 
+	idempotencyStore := idempotency.NewStore(s.db)
+	subRouter.Use(idempotency.Middleware(idempotencyStore, secondsSetting(config.Envs.IdempotencyKeyTTLInSeconds, 24*3600)))
+
+	blobStorage, err := storage.NewFromConfig(config.Envs)
+	if err != nil {
+		return err
+	}
+	if localStorage, ok := blobStorage.(*storage.LocalStorage); ok {
+		fileServer := http.FileServer(http.Dir(localStorage.BaseDir()))
+		router.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", fileServer)).Methods("GET")
+	}
+
 	userStore := user.NewStore(s.db)
 	userHandler := user.NewHandler(userStore)
 	userHandler.RegisterRoutes(subRouter)
 
+	warehouseStore := warehouse.NewStore(s.db)
+	warehouseHandler := warehouse.NewHandler(warehouseStore)
+	warehouseHandler.RegisterRoutes(subRouter)
+
 	productStore := product.NewStore(s.db)
-	productHandler := product.NewHandler(productStore)
+	productHandler := product.NewHandler(productStore, blobStorage, warehouseStore)
 	productHandler.RegisterRoutes(subRouter)
- 
+
+	inventoryStore := inventory.NewStore(s.db)
+	inventoryHandler := inventory.NewHandler(inventoryStore, inventoryStore)
+	inventoryHandler.RegisterRoutes(subRouter)
+
+	allocator, err := inventory.NewAllocationStrategy(config.Envs.AllocationStrategy)
+	if err != nil {
+		return err
+	}
+
+	categoryStore := category.NewStore(s.db)
+	categoryHandler := category.NewHandler(categoryStore)
+	categoryHandler.RegisterRoutes(subRouter)
+
+	couponStore := coupon.NewStore(s.db)
+	couponHandler := coupon.NewHandler(couponStore)
+	couponHandler.RegisterRoutes(subRouter)
+
+	promotionStore := promotion.NewStore(s.db)
+	promotionHandler := promotion.NewHandler(promotionStore)
+	promotionHandler.RegisterRoutes(subRouter)
+
+	shippingStore := shipping.NewStore(s.db)
+	shippingQuoter := shipping.NewRateCalculator(shippingStore, shipping.NewCarriers())
+	shippingHandler := shipping.NewHandler(shippingStore, productStore, shippingQuoter)
+	shippingHandler.RegisterRoutes(subRouter)
+
+	taxStore := tax.NewStore(s.db)
+	taxHandler := tax.NewHandler(taxStore)
+	taxHandler.RegisterRoutes(subRouter)
+
+	taxProvider, err := tax.NewProviderFromConfig(config.Envs, taxStore)
+	if err != nil {
+		return err
+	}
+
+	orderStore := order.NewStore(s.db)
+	orderHandler := order.NewHandler(orderStore)
+	orderHandler.RegisterRoutes(subRouter)
+
+	paymentProvider, err := payment.NewProviderFromConfig(config.Envs)
+	if err != nil {
+		return err
+	}
+
+	paymentStore := payment.NewStore(s.db)
+	paymentProcessor := payment.NewProcessor(paymentStore, paymentProvider, orderStore, inventoryStore)
+	paymentHandler := payment.NewHandler(paymentStore, paymentStore, orderStore, paymentProcessor)
+	paymentHandler.RegisterRoutes(subRouter)
+
+	cartStore := cart.NewStore(s.db)
+	cartHandler := cart.NewHandler(s.db, cartStore, productStore, orderStore, userStore, inventoryStore, warehouseStore, couponStore,
+		promotionStore, shippingQuoter, taxProvider, paymentProcessor, allocator)
+	cartHandler.RegisterRoutes(subRouter)
+
+	recoveryHandler := recovery.NewHandler(cartStore, productStore)
+	recoveryHandler.RegisterRoutes(subRouter)
+
+	returnStore := returns.NewStore(s.db)
+	returnHandler := returns.NewHandler(returnStore, orderStore, paymentStore, paymentProcessor)
+	returnHandler.RegisterRoutes(subRouter)
+
+	invoiceStore := invoice.NewStore(s.db)
+	invoiceHandler := invoice.NewHandler(invoiceStore, orderStore, productStore, userStore, returnStore)
+	invoiceHandler.RegisterRoutes(subRouter)
+
+	webhookStore := webhook.NewStore(s.db)
+	webhookHandler := webhook.NewHandler(webhookStore)
+	webhookHandler.RegisterRoutes(subRouter)
+
+	notificationTemplates, err := notification.LoadTemplates()
+	if err != nil {
+		return err
+	}
+
+	notificationStore := notification.NewStore(s.db)
+	notificationHandler := notification.NewHandler(notificationStore, notificationTemplates)
+	notificationHandler.RegisterRoutes(subRouter)
+
+	jobHandler := job.NewHandler(job.NewStore(s.db))
+	jobHandler.RegisterRoutes(subRouter)
+
+	// the worker runs in cmd/worker instead when it's scaled apart from the API.
+	if config.Envs.JobWorkerInProcess != "false" {
+		worker, err := NewJobWorker(s.db)
+		if err != nil {
+			return err
+		}
+		worker.Start(context.Background(), secondsSetting(config.Envs.JobPollIntervalInSeconds, 1))
+	}
+
 	log.Println("Listening on", s.addr)
-	return http.ListenAndServe(s.addr, subRouter)
-}
\ No newline at end of file
+	return http.ListenAndServe(s.addr, router)
+}
+
+// parses a setting holding a number of seconds, fallback is used when it's missing or invalid.
+func secondsSetting(value string, fallback int) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		seconds = fallback
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+func splitEmails(value string) []string {
+	emails := make([]string, 0)
+	for _, email := range strings.Split(value, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			emails = append(emails, email)
+		}
+	}
+
+	return emails
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/mailer"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/cart"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/coupon"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/idempotency"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/inventory"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/job"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/notification"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/order"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/outbox"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/product"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/recovery"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/user"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/webhook"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// how long the jobs that succeeded are kept for the admins to look at.
+const finishedJobsRetention = 7 * 24 * time.Hour
+
+// NewJobWorker returns the worker with the handlers and the recurring jobs registered, the API server runs it
+// in-process unless JOB_WORKER_IN_PROCESS is false, cmd/worker runs it on its own.
+func NewJobWorker(db *sql.DB) (*job.Worker, error) {
+	concurrency, err := strconv.Atoi(config.Envs.JobWorkerConcurrency)
+	if err != nil || concurrency <= 0 {
+		concurrency = 4
+	}
+
+	mail, err := mailer.NewFromConfig(config.Envs)
+	if err != nil {
+		return nil, err
+	}
+
+	eventBroker, err := outbox.NewBrokerFromConfig(config.Envs)
+	if err != nil {
+		return nil, err
+	}
+
+	notificationTemplates, err := notification.LoadTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	webhookMaxAttempts, err := strconv.Atoi(config.Envs.WebhookMaxAttempts)
+	if err != nil || webhookMaxAttempts <= 0 {
+		webhookMaxAttempts = 10
+	}
+
+	jobStore := job.NewStore(db)
+	idempotencyStore := idempotency.NewStore(db)
+	inventoryStore := inventory.NewStore(db)
+	orderStore := order.NewStore(db)
+	productStore := product.NewStore(db)
+	userStore := user.NewStore(db)
+	webhookStore := webhook.NewStore(db)
+	notificationStore := notification.NewStore(db)
+
+	cartStore := cart.NewStore(db)
+	cartReminder := recovery.NewReminder(db, cartStore, coupon.NewStore(db))
+	stockNotifier := inventory.NewStockNotifier(inventoryStore, productStore, mail,
+		splitEmails(config.Envs.StockAlertEmails), secondsSetting(config.Envs.StockAlertRateLimitInSeconds, 3600))
+	// the events go to the partner webhooks, the customers' emails and the carts recovery as well as to the broker.
+	outboxRelay := outbox.NewRelay(outbox.NewStore(db), outbox.NewFanOutBroker(eventBroker, webhook.NewDispatcher(webhookStore),
+		notification.NewDispatcher(notificationStore, notificationTemplates), recovery.NewDispatcher(cartStore)))
+	webhookSender := webhook.NewSender(webhookStore, webhookMaxAttempts)
+	notificationSender := notification.NewSender(notificationStore, userStore, orderStore, productStore, mail, notificationTemplates)
+
+	worker := job.NewWorker(jobStore, concurrency)
+
+	job.Handle(worker, types.JobTypePruneJobs, func(ctx context.Context, _ struct{}) error {
+		_, err := jobStore.DeleteFinishedJobs(time.Now().Add(-finishedJobsRetention))
+		return err
+	})
+	job.Handle(worker, types.JobTypeSweepIdempotencyKeys, func(ctx context.Context, _ struct{}) error {
+		_, err := idempotencyStore.DeleteExpiredIdempotencyKeys()
+		return err
+	})
+	job.Handle(worker, types.JobTypeRemindAbandonedCarts, func(ctx context.Context, _ struct{}) error {
+		_, err := cartReminder.Remind(ctx)
+		return err
+	})
+	job.Handle(worker, types.JobTypeSweepReservations, func(ctx context.Context, _ struct{}) error {
+		return inventory.SweepExpiredReservations(db, inventoryStore, orderStore)
+	})
+	job.Handle(worker, types.JobTypeDispatchStockAlerts, func(ctx context.Context, _ struct{}) error {
+		return stockNotifier.Dispatch()
+	})
+	job.Handle(worker, types.JobTypeRelayOutbox, func(ctx context.Context, _ struct{}) error {
+		_, err := outboxRelay.PublishAll(ctx)
+		return err
+	})
+	job.Handle(worker, types.JobTypeDeliverWebhooks, func(ctx context.Context, _ struct{}) error {
+		_, err := webhookSender.DeliverAll(ctx)
+		return err
+	})
+	job.Handle(worker, types.JobTypeSendNotifications, func(ctx context.Context, _ struct{}) error {
+		_, err := notificationSender.SendAll(ctx)
+		return err
+	})
+
+	schedules := []struct {
+		name, spec, jobType string
+	}{
+		{"prune-jobs", "@daily", types.JobTypePruneJobs},
+		{"sweep-idempotency-keys", "@hourly", types.JobTypeSweepIdempotencyKeys},
+		{"remind-abandoned-carts", "*/15 * * * *", types.JobTypeRemindAbandonedCarts},
+		{"sweep-reservations", everySeconds(config.Envs.ReservationSweepIntervalInSeconds, 60), types.JobTypeSweepReservations},
+		{"dispatch-stock-alerts", everySeconds(config.Envs.StockNotifierIntervalInSeconds, 60), types.JobTypeDispatchStockAlerts},
+		{"relay-outbox", everySeconds(config.Envs.OutboxRelayIntervalInSeconds, 5), types.JobTypeRelayOutbox},
+		{"deliver-webhooks", everySeconds(config.Envs.WebhookDeliveryIntervalInSeconds, 10), types.JobTypeDeliverWebhooks},
+		{"send-notifications", everySeconds(config.Envs.NotificationIntervalInSeconds, 10), types.JobTypeSendNotifications},
+	}
+	for _, schedule := range schedules {
+		if err := worker.Schedule(schedule.name, schedule.spec, schedule.jobType, struct{}{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return worker, nil
+}
+
+// the "@every" schedule of a setting holding a number of seconds.
+func everySeconds(value string, fallback int) string {
+	return "@every " + secondsSetting(value, fallback).String()
+}
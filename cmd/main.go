@@ -5,10 +5,17 @@ import (
 	"log"
 
 	"github.com/mohammadahmadkhader/golang-ecommerce/cmd/api"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
 	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
 )
 
 func main() {
+	// every amount in the database is in the shop currency.
+	if err := money.SetDefaultCurrency(config.Envs.Currency); err != nil {
+		log.Fatal(err)
+	}
+
 	db ,err := utils.StartMySqlDB()
 	if err != nil {
 		log.Fatal(err)
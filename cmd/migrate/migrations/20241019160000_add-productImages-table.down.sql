@@ -0,0 +1 @@
+DROP TABLE IF EXISTS productImages;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS productImages (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `url` varchar(1024) NOT NULL,
+    `thumbnailUrl` varchar(1024) NOT NULL,
+    `storageKey` varchar(512) NOT NULL,
+    `thumbnailKey` varchar(512) NOT NULL,
+    `altText` varchar(255) NOT NULL DEFAULT '',
+    `position` INT UNSIGNED NOT NULL DEFAULT 0,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    KEY(`productId`, `position`),
+    FOREIGN KEY(`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
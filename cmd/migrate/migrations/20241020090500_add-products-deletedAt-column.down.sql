@@ -0,0 +1 @@
+ALTER TABLE products DROP KEY `deletedAt`, DROP COLUMN `deletedAt`;
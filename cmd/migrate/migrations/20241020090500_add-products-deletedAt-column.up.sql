@@ -0,0 +1,3 @@
+ALTER TABLE products
+    ADD COLUMN `deletedAt` TIMESTAMP NULL DEFAULT NULL,
+    ADD KEY (`deletedAt`);
@@ -0,0 +1 @@
+ALTER TABLE products DROP KEY `sku`, DROP COLUMN `sku`;
@@ -0,0 +1,3 @@
+ALTER TABLE products
+    ADD COLUMN `sku` varchar(64) NULL DEFAULT NULL,
+    ADD UNIQUE KEY (`sku`);
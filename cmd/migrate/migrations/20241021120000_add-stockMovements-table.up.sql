@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS stockMovements (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `quantityChange` INT NOT NULL,
+    `quantityAfter` INT UNSIGNED NOT NULL,
+    `reason` ENUM('sale', 'cancellation', 'restock', 'adjustment', 'return', 'damage') NOT NULL,
+    `actorId` INT UNSIGNED NULL DEFAULT NULL,
+    `orderId` INT UNSIGNED NULL DEFAULT NULL,
+    `purchaseOrderRef` varchar(64) NULL DEFAULT NULL,
+    `note` varchar(255) NOT NULL DEFAULT '',
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    KEY(`productId`, `id`),
+    FOREIGN KEY(`productId`) REFERENCES products(`id`),
+    FOREIGN KEY(`actorId`) REFERENCES users(`id`),
+    FOREIGN KEY(`orderId`) REFERENCES orders(`id`)
+);
+
@@ -0,0 +1 @@
+DELETE FROM stockMovements WHERE note = 'opening balance';
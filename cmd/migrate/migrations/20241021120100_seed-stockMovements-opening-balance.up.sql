@@ -0,0 +1,3 @@
+-- the current quantities become the opening balance of the ledger.
+INSERT INTO stockMovements (productId, quantityChange, quantityAfter, reason, note)
+SELECT id, quantity, quantity, 'adjustment', 'opening balance' FROM products WHERE quantity > 0;
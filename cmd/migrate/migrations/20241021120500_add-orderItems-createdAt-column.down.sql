@@ -0,0 +1 @@
+ALTER TABLE orderItems DROP COLUMN `createdAt`;
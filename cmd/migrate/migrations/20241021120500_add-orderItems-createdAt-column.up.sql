@@ -0,0 +1,2 @@
+ALTER TABLE orderItems
+    ADD COLUMN `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS stockReservations (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `orderId` INT UNSIGNED NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+    `status` ENUM('active', 'committed', 'released', 'expired') NOT NULL DEFAULT 'active',
+    `expiresAt` TIMESTAMP NOT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    KEY(`productId`, `status`, `expiresAt`),
+    KEY(`orderId`),
+    KEY(`status`, `expiresAt`),
+    FOREIGN KEY(`productId`) REFERENCES products(`id`),
+    FOREIGN KEY(`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY(`userId`) REFERENCES users(`id`)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS warehouses (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `name` varchar(100) NOT NULL,
+    `code` varchar(32) NOT NULL,
+    `country` char(2) NOT NULL DEFAULT '',
+    `latitude` DECIMAL(9, 6) NULL DEFAULT NULL,
+    `longitude` DECIMAL(9, 6) NULL DEFAULT NULL,
+    `priority` INT UNSIGNED NOT NULL DEFAULT 0,
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    UNIQUE KEY(`code`)
+);
@@ -0,0 +1 @@
+DELETE FROM warehouses WHERE code = 'MAIN';
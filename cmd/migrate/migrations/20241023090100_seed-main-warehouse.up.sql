@@ -0,0 +1,2 @@
+-- the stock that existed before warehouses lives in the main warehouse.
+INSERT INTO warehouses (name, code, priority) VALUES ('Main warehouse', 'MAIN', 0);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS warehouseStock (
+    `warehouseId` INT UNSIGNED NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `quantity` INT UNSIGNED NOT NULL DEFAULT 0,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`warehouseId`, `productId`),
+    KEY(`productId`),
+    FOREIGN KEY(`warehouseId`) REFERENCES warehouses(`id`),
+    FOREIGN KEY(`productId`) REFERENCES products(`id`)
+);
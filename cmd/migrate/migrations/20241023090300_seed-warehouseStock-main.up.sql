@@ -0,0 +1,2 @@
+INSERT INTO warehouseStock (warehouseId, productId, quantity)
+SELECT warehouses.id, products.id, products.quantity FROM products JOIN warehouses ON warehouses.code = 'MAIN';
@@ -0,0 +1 @@
+ALTER TABLE stockMovements DROP FOREIGN KEY `fk_stockMovements_warehouseId`, DROP COLUMN `warehouseId`;
@@ -0,0 +1,3 @@
+ALTER TABLE stockMovements
+    ADD COLUMN `warehouseId` INT UNSIGNED NULL DEFAULT NULL,
+    ADD CONSTRAINT `fk_stockMovements_warehouseId` FOREIGN KEY(`warehouseId`) REFERENCES warehouses(`id`);
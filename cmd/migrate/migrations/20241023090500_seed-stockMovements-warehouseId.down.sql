@@ -0,0 +1 @@
+UPDATE stockMovements SET warehouseId = NULL;
@@ -0,0 +1 @@
+UPDATE stockMovements SET warehouseId = (SELECT id FROM warehouses WHERE code = 'MAIN') WHERE warehouseId IS NULL;
@@ -0,0 +1 @@
+ALTER TABLE stockReservations DROP FOREIGN KEY `fk_stockReservations_warehouseId`, DROP COLUMN `warehouseId`;
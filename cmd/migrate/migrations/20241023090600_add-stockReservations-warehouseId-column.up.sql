@@ -0,0 +1,3 @@
+ALTER TABLE stockReservations
+    ADD COLUMN `warehouseId` INT UNSIGNED NULL DEFAULT NULL,
+    ADD CONSTRAINT `fk_stockReservations_warehouseId` FOREIGN KEY(`warehouseId`) REFERENCES warehouses(`id`);
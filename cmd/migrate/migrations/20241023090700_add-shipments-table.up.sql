@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS shipments (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `orderId` INT UNSIGNED NOT NULL,
+    `warehouseId` INT UNSIGNED NOT NULL,
+    `status` ENUM('pending', 'shipped', 'delivered', 'cancelled') NOT NULL DEFAULT 'pending',
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    FOREIGN KEY(`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY(`warehouseId`) REFERENCES warehouses(`id`)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS shipmentItems (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `shipmentId` INT UNSIGNED NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+
+    PRIMARY KEY(`id`),
+    FOREIGN KEY(`shipmentId`) REFERENCES shipments(`id`),
+    FOREIGN KEY(`productId`) REFERENCES products(`id`)
+);
@@ -0,0 +1 @@
+ALTER TABLE products DROP COLUMN `lowStockThreshold`;
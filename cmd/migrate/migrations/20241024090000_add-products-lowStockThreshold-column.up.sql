@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN `lowStockThreshold` INT UNSIGNED NULL DEFAULT NULL;
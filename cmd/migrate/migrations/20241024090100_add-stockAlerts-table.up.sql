@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS stockAlerts (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `kind` ENUM('low_stock', 'out_of_stock', 'back_in_stock') NOT NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+    `status` ENUM('pending', 'sent', 'suppressed', 'skipped') NOT NULL DEFAULT 'pending',
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `sentAt` TIMESTAMP NULL DEFAULT NULL,
+
+    PRIMARY KEY(`id`),
+    KEY(`status`, `id`),
+    KEY(`productId`, `kind`, `sentAt`),
+    FOREIGN KEY(`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS stockSubscriptions (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `email` varchar(255) NOT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `notifiedAt` TIMESTAMP NULL DEFAULT NULL,
+
+    PRIMARY KEY(`id`),
+    KEY(`productId`, `notifiedAt`),
+    FOREIGN KEY(`productId`) REFERENCES products(`id`) ON DELETE CASCADE,
+    FOREIGN KEY(`userId`) REFERENCES users(`id`)
+);
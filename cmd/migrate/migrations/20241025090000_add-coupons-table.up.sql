@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS coupons (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `code` varchar(64) NOT NULL,
+    `type` ENUM('percentage', 'fixed', 'free_shipping') NOT NULL,
+    `value` DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    `minSubtotal` DECIMAL(10, 2) NULL DEFAULT NULL,
+    `usageLimit` INT UNSIGNED NULL DEFAULT NULL,
+    `perUserLimit` INT UNSIGNED NULL DEFAULT NULL,
+    `startsAt` TIMESTAMP NULL DEFAULT NULL,
+    `endsAt` TIMESTAMP NULL DEFAULT NULL,
+    `stackable` BOOLEAN NOT NULL DEFAULT FALSE,
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    UNIQUE KEY(`code`)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS couponProducts (
+    `couponId` INT UNSIGNED NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+
+    PRIMARY KEY(`couponId`, `productId`),
+    FOREIGN KEY(`couponId`) REFERENCES coupons(`id`) ON DELETE CASCADE,
+    FOREIGN KEY(`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
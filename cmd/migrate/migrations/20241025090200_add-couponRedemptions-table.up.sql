@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS couponRedemptions (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `couponId` INT UNSIGNED NOT NULL,
+    `orderId` INT UNSIGNED NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `discount` DECIMAL(10, 2) NOT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    KEY(`couponId`, `userId`),
+    KEY(`orderId`),
+    FOREIGN KEY(`couponId`) REFERENCES coupons(`id`),
+    FOREIGN KEY(`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY(`userId`) REFERENCES users(`id`)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS promotions (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `name` varchar(255) NOT NULL,
+    `type` ENUM('buy_x_get_y', 'order_percentage', 'bundle', 'quantity_tier') NOT NULL,
+    `priority` INT UNSIGNED NOT NULL DEFAULT 0,
+    `rules` JSON NOT NULL,
+    `startsAt` TIMESTAMP NULL DEFAULT NULL,
+    `endsAt` TIMESTAMP NULL DEFAULT NULL,
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    INDEX(`active`, `priority`)
+);
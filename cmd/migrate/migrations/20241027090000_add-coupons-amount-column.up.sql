@@ -0,0 +1 @@
+ALTER TABLE coupons ADD COLUMN `amount` DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `value`;
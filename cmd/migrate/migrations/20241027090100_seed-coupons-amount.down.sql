@@ -0,0 +1 @@
+UPDATE coupons SET `value` = `amount` WHERE `type` = 'fixed';
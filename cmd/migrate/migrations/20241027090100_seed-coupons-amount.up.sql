@@ -0,0 +1 @@
+UPDATE coupons SET `amount` = `value`, `value` = 0 WHERE `type` = 'fixed';
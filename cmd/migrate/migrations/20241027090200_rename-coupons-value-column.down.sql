@@ -0,0 +1 @@
+ALTER TABLE coupons CHANGE `percentage` `value` DECIMAL(10, 2) NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE coupons CHANGE `value` `percentage` DECIMAL(5, 2) NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE orders DROP COLUMN `pricing`, DROP COLUMN `taxTotal`, DROP COLUMN `shippingTotal`, DROP COLUMN `discountTotal`, DROP COLUMN `subtotal`;
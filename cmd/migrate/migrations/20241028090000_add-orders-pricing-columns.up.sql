@@ -0,0 +1,6 @@
+ALTER TABLE orders
+    ADD COLUMN `subtotal` DECIMAL(10,2) NOT NULL DEFAULT 0,
+    ADD COLUMN `discountTotal` DECIMAL(10,2) NOT NULL DEFAULT 0,
+    ADD COLUMN `shippingTotal` DECIMAL(10,2) NOT NULL DEFAULT 0,
+    ADD COLUMN `taxTotal` DECIMAL(10,2) NOT NULL DEFAULT 0,
+    ADD COLUMN `pricing` JSON NULL DEFAULT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE products
+    ADD COLUMN `taxClass` VARCHAR(32) NOT NULL DEFAULT 'standard';
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS taxRates (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `country` CHAR(2) NOT NULL,
+    `region` VARCHAR(100) NOT NULL DEFAULT '',
+    `taxClass` VARCHAR(32) NOT NULL,
+    `name` VARCHAR(64) NOT NULL,
+    `rate` DECIMAL(6,3) NOT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    UNIQUE KEY(`country`, `region`, `taxClass`, `name`)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS orderItemTaxes (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `orderItemId` INT NOT NULL,
+    `name` VARCHAR(64) NOT NULL,
+    `rate` DECIMAL(6,3) NOT NULL,
+    `taxable` DECIMAL(10,2) NOT NULL,
+    `amount` DECIMAL(10,2) NOT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    FOREIGN KEY(`orderItemId`) REFERENCES orderItems(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+ALTER TABLE products DROP COLUMN `heightMm`, DROP COLUMN `widthMm`, DROP COLUMN `lengthMm`, DROP COLUMN `weightGrams`;
@@ -0,0 +1,5 @@
+ALTER TABLE products
+    ADD COLUMN `weightGrams` INT UNSIGNED NOT NULL DEFAULT 0,
+    ADD COLUMN `lengthMm` INT UNSIGNED NOT NULL DEFAULT 0,
+    ADD COLUMN `widthMm` INT UNSIGNED NOT NULL DEFAULT 0,
+    ADD COLUMN `heightMm` INT UNSIGNED NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS shippingZones (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `name` VARCHAR(100) NOT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS shippingZoneLocations (
+    `zoneId` INT UNSIGNED NOT NULL,
+    `country` CHAR(2) NOT NULL,
+    `region` VARCHAR(100) NOT NULL DEFAULT '',
+
+    PRIMARY KEY(`country`, `region`),
+    KEY(`zoneId`),
+    FOREIGN KEY(`zoneId`) REFERENCES shippingZones(`id`) ON DELETE CASCADE
+);
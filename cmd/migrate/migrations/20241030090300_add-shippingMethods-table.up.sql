@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS shippingMethods (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `zoneId` INT UNSIGNED NOT NULL,
+    `name` VARCHAR(100) NOT NULL,
+    `type` ENUM('flat_rate', 'weight_based', 'free_over', 'carrier') NOT NULL,
+    `rate` DECIMAL(10,2) NOT NULL DEFAULT 0,
+    `ratePerKg` DECIMAL(10,2) NOT NULL DEFAULT 0,
+    `freeOver` DECIMAL(10,2) NULL DEFAULT NULL,
+    `carrier` VARCHAR(32) NOT NULL DEFAULT '',
+    `carrierService` VARCHAR(64) NOT NULL DEFAULT '',
+    `maxWeightGrams` INT UNSIGNED NULL DEFAULT NULL,
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    KEY(`zoneId`, `active`),
+    FOREIGN KEY(`zoneId`) REFERENCES shippingZones(`id`)
+);
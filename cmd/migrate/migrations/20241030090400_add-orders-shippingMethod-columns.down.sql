@@ -0,0 +1 @@
+ALTER TABLE orders DROP FOREIGN KEY `fk_orders_shippingMethodId`, DROP COLUMN `shippingMethod`, DROP COLUMN `shippingMethodId`;
@@ -0,0 +1,4 @@
+ALTER TABLE orders
+    ADD COLUMN `shippingMethodId` INT UNSIGNED NULL DEFAULT NULL,
+    ADD COLUMN `shippingMethod` VARCHAR(100) NOT NULL DEFAULT '',
+    ADD CONSTRAINT `fk_orders_shippingMethodId` FOREIGN KEY(`shippingMethodId`) REFERENCES shippingMethods(`id`);
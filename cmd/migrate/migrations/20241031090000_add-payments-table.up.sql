@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS payments (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `orderId` INT UNSIGNED NOT NULL,
+    `provider` VARCHAR(32) NOT NULL,
+    `providerRef` VARCHAR(255) NOT NULL,
+    `status` ENUM('pending', 'requires_action', 'authorized', 'captured', 'partially_refunded', 'refunded', 'voided', 'failed') NOT NULL DEFAULT 'pending',
+    `amount` DECIMAL(10,2) NOT NULL,
+    `capturedAmount` DECIMAL(10,2) NOT NULL DEFAULT 0,
+    `refundedAmount` DECIMAL(10,2) NOT NULL DEFAULT 0,
+    `nextAction` VARCHAR(255) NOT NULL DEFAULT '',
+    `failureReason` VARCHAR(255) NOT NULL DEFAULT '',
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    UNIQUE KEY(`provider`, `providerRef`),
+    KEY(`orderId`),
+    FOREIGN KEY(`orderId`) REFERENCES orders(`id`)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS webhookEvents (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `provider` VARCHAR(32) NOT NULL,
+    `eventId` VARCHAR(255) NOT NULL,
+    `type` VARCHAR(64) NOT NULL,
+    `payload` JSON NOT NULL,
+    `status` ENUM('pending', 'processed', 'ignored', 'failed') NOT NULL DEFAULT 'pending',
+    `attempts` INT UNSIGNED NOT NULL DEFAULT 0,
+    `lastError` VARCHAR(1024) NOT NULL DEFAULT '',
+    `receivedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `processedAt` TIMESTAMP NULL DEFAULT NULL,
+
+    PRIMARY KEY(`id`),
+    UNIQUE KEY(`provider`, `eventId`),
+    KEY(`status`, `id`)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS idempotencyKeys (
+    `userId` INT UNSIGNED NOT NULL,
+    `idempotencyKey` VARCHAR(255) NOT NULL,
+    `fingerprint` CHAR(64) NOT NULL,
+    `status` ENUM('in_flight', 'completed') NOT NULL DEFAULT 'in_flight',
+    `responseStatus` SMALLINT UNSIGNED NULL DEFAULT NULL,
+    `contentType` VARCHAR(255) NOT NULL DEFAULT '',
+    `responseBody` MEDIUMBLOB NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `expiresAt` TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(`userId`, `idempotencyKey`),
+    KEY(`expiresAt`),
+    FOREIGN KEY(`userId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS returns (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `orderId` INT UNSIGNED NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `status` ENUM('requested', 'approved', 'rejected', 'cancelled', 'received', 'partially_refunded', 'refunded') NOT NULL DEFAULT 'requested',
+    `reason` VARCHAR(255) NOT NULL,
+    `staffNote` VARCHAR(255) NOT NULL DEFAULT '',
+    `refundableTotal` DECIMAL(10,2) NOT NULL DEFAULT 0,
+    `refundedTotal` DECIMAL(10,2) NOT NULL DEFAULT 0,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    KEY(`orderId`),
+    KEY(`userId`),
+    KEY(`status`, `id`),
+    FOREIGN KEY(`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY(`userId`) REFERENCES users(`id`)
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS returnItems (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `returnId` INT UNSIGNED NOT NULL,
+    `orderItemId` INT NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+    `lineRefundable` DECIMAL(10,2) NOT NULL,
+    `refundAmount` DECIMAL(10,2) NOT NULL DEFAULT 0,
+    `receivedQuantity` INT UNSIGNED NOT NULL DEFAULT 0,
+    `disposition` ENUM('restock', 'write_off') NULL,
+    `warehouseId` INT UNSIGNED NULL,
+
+    PRIMARY KEY(`id`),
+    KEY(`orderItemId`),
+    FOREIGN KEY(`returnId`) REFERENCES returns(`id`) ON DELETE CASCADE,
+    FOREIGN KEY(`orderItemId`) REFERENCES orderItems(`id`),
+    FOREIGN KEY(`productId`) REFERENCES products(`id`),
+    FOREIGN KEY(`warehouseId`) REFERENCES warehouses(`id`)
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS invoiceSequences (
+    `kind` ENUM('invoice', 'credit_note') NOT NULL,
+    `year` SMALLINT UNSIGNED NOT NULL,
+    `lastNumber` INT UNSIGNED NOT NULL,
+
+    PRIMARY KEY(`kind`, `year`)
+);
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS invoices (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `kind` ENUM('invoice', 'credit_note') NOT NULL,
+    `number` VARCHAR(32) NOT NULL,
+    `year` SMALLINT UNSIGNED NOT NULL,
+    `sequence` INT UNSIGNED NOT NULL,
+    `orderId` INT UNSIGNED NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `creditedInvoiceId` INT UNSIGNED NULL,
+    `total` DECIMAL(10,2) NOT NULL,
+    `document` JSON NOT NULL,
+    `issuedAt` TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(`id`),
+    UNIQUE KEY(`number`),
+    UNIQUE KEY(`kind`, `year`, `sequence`),
+    KEY(`orderId`, `kind`),
+    KEY(`creditedInvoiceId`),
+    FOREIGN KEY(`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY(`userId`) REFERENCES users(`id`),
+    FOREIGN KEY(`creditedInvoiceId`) REFERENCES invoices(`id`)
+);
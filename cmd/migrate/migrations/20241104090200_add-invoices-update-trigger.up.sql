@@ -0,0 +1,2 @@
+CREATE TRIGGER invoicesImmutableOnUpdate BEFORE UPDATE ON invoices FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'issued invoices can not be changed, issue a credit note instead';
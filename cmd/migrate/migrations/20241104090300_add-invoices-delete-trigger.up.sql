@@ -0,0 +1,2 @@
+CREATE TRIGGER invoicesImmutableOnDelete BEFORE DELETE ON invoices FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'issued invoices can not be deleted, issue a credit note instead';
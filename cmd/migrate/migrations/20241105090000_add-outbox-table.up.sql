@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `aggregateType` VARCHAR(32) NOT NULL,
+    `aggregateId` VARCHAR(64) NOT NULL,
+    `type` VARCHAR(64) NOT NULL,
+    `payload` JSON NOT NULL,
+    `attempts` INT UNSIGNED NOT NULL DEFAULT 0,
+    `lastError` VARCHAR(1024) NOT NULL DEFAULT '',
+    `occurredAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `publishedAt` TIMESTAMP NULL DEFAULT NULL,
+
+    PRIMARY KEY(`id`),
+    KEY(`publishedAt`, `id`),
+    KEY(`aggregateType`, `aggregateId`, `id`)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS webhookEndpoints (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `url` VARCHAR(2048) NOT NULL,
+    `description` VARCHAR(255) NOT NULL DEFAULT '',
+    `eventTypes` JSON NOT NULL,
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `secret` VARCHAR(128) NOT NULL,
+    `previousSecret` VARCHAR(128) NOT NULL DEFAULT '',
+    `previousSecretExpiresAt` TIMESTAMP NULL DEFAULT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`)
+);
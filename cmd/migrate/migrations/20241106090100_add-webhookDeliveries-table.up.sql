@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS webhookDeliveries (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `endpointId` INT UNSIGNED NOT NULL,
+    `eventId` BIGINT UNSIGNED NOT NULL,
+    `eventType` VARCHAR(64) NOT NULL,
+    `payload` JSON NOT NULL,
+    `status` ENUM('pending', 'delivered', 'dead') NOT NULL DEFAULT 'pending',
+    `attempts` INT UNSIGNED NOT NULL DEFAULT 0,
+    `nextAttemptAt` TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    `lastError` VARCHAR(1024) NOT NULL DEFAULT '',
+    `deliveredAt` TIMESTAMP NULL DEFAULT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    FOREIGN KEY(`endpointId`) REFERENCES webhookEndpoints(`id`) ON DELETE CASCADE,
+    UNIQUE KEY(`endpointId`, `eventId`),
+    KEY(`status`, `nextAttemptAt`)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS webhookDeliveryAttempts (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `deliveryId` BIGINT UNSIGNED NOT NULL,
+    `responseStatus` INT NULL DEFAULT NULL,
+    `responseBody` VARCHAR(1024) NOT NULL DEFAULT '',
+    `error` VARCHAR(1024) NOT NULL DEFAULT '',
+    `durationMs` INT UNSIGNED NOT NULL DEFAULT 0,
+    `attemptedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    FOREIGN KEY(`deliveryId`) REFERENCES webhookDeliveries(`id`) ON DELETE CASCADE,
+    KEY(`deliveryId`, `id`)
+);
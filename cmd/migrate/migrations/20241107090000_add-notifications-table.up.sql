@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS notifications (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `template` VARCHAR(64) NOT NULL,
+    `templateVersion` INT UNSIGNED NOT NULL,
+    `eventId` BIGINT UNSIGNED NULL DEFAULT NULL,
+    `payload` JSON NOT NULL,
+    `status` ENUM('queued', 'sent', 'skipped', 'failed') NOT NULL DEFAULT 'queued',
+    `attempts` INT UNSIGNED NOT NULL DEFAULT 0,
+    `nextAttemptAt` TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    `lastError` VARCHAR(1024) NOT NULL DEFAULT '',
+    `sentAt` TIMESTAMP NULL DEFAULT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    FOREIGN KEY(`userId`) REFERENCES users(`id`) ON DELETE CASCADE,
+    UNIQUE KEY(`eventId`, `template`),
+    KEY(`status`, `nextAttemptAt`)
+);
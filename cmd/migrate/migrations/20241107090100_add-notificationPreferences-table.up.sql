@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS notificationPreferences (
+    `userId` INT UNSIGNED NOT NULL,
+    `locale` VARCHAR(8) NOT NULL DEFAULT 'en',
+    `account` BOOLEAN NOT NULL DEFAULT TRUE,
+    `orders` BOOLEAN NOT NULL DEFAULT TRUE,
+    `shipping` BOOLEAN NOT NULL DEFAULT TRUE,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`userId`),
+    FOREIGN KEY(`userId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
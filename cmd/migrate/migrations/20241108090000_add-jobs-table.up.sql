@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS jobs (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `type` VARCHAR(64) NOT NULL,
+    `payload` JSON NOT NULL,
+    `status` ENUM('queued', 'running', 'succeeded', 'failed') NOT NULL DEFAULT 'queued',
+    `attempts` INT UNSIGNED NOT NULL DEFAULT 0,
+    `maxAttempts` INT UNSIGNED NOT NULL,
+    `uniqueKey` VARCHAR(191) NULL DEFAULT NULL,
+    `runAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `lockedUntil` TIMESTAMP NULL DEFAULT NULL,
+    `lastError` VARCHAR(1024) NOT NULL DEFAULT '',
+    `finishedAt` TIMESTAMP NULL DEFAULT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    UNIQUE KEY(`uniqueKey`),
+    KEY(`status`, `runAt`),
+    KEY(`status`, `lockedUntil`)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS jobSchedules (
+    `name` VARCHAR(64) NOT NULL,
+    `nextRunAt` TIMESTAMP NOT NULL,
+    `lastRunAt` TIMESTAMP NULL DEFAULT NULL,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`name`)
+);
@@ -0,0 +1 @@
+ALTER TABLE notificationPreferences DROP COLUMN `marketing`;
@@ -0,0 +1,2 @@
+ALTER TABLE notificationPreferences
+    ADD COLUMN `marketing` BOOLEAN NOT NULL DEFAULT TRUE AFTER `shipping`;
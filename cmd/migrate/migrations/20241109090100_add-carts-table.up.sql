@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS carts (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `lastActivityAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    UNIQUE KEY(`userId`),
+    KEY(`lastActivityAt`),
+    FOREIGN KEY(`userId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS cartItems (
+    `cartId` INT UNSIGNED NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+
+    PRIMARY KEY(`cartId`, `productId`),
+    FOREIGN KEY(`cartId`) REFERENCES carts(`id`) ON DELETE CASCADE,
+    FOREIGN KEY(`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS cartReminders (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `cartId` INT UNSIGNED NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `activityAt` TIMESTAMP NOT NULL,
+    `step` INT UNSIGNED NOT NULL,
+    `items` JSON NOT NULL,
+    `couponCode` VARCHAR(64) NULL DEFAULT NULL,
+    `clickedAt` TIMESTAMP NULL DEFAULT NULL,
+    `orderId` INT UNSIGNED NULL DEFAULT NULL,
+    `convertedAt` TIMESTAMP NULL DEFAULT NULL,
+    `sentAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    UNIQUE KEY(`cartId`, `activityAt`, `step`),
+    KEY(`userId`, `sentAt`),
+    KEY(`sentAt`),
+    FOREIGN KEY(`cartId`) REFERENCES carts(`id`) ON DELETE CASCADE,
+    FOREIGN KEY(`orderId`) REFERENCES orders(`id`) ON DELETE SET NULL
+);
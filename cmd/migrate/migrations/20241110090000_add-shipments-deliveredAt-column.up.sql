@@ -0,0 +1,2 @@
+ALTER TABLE shipments
+    ADD COLUMN `deliveredAt` TIMESTAMP NULL DEFAULT NULL AFTER `status`;
@@ -0,0 +1 @@
+UPDATE shipments SET `deliveredAt` = NULL, `updatedAt` = `updatedAt`;
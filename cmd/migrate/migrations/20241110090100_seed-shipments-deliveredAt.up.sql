@@ -0,0 +1 @@
+UPDATE shipments SET `deliveredAt` = `updatedAt`, `updatedAt` = `updatedAt` WHERE `status` = 'delivered';
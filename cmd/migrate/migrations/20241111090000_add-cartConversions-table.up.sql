@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS cartConversions (
+    `orderId` INT UNSIGNED NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `convertedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`orderId`),
+    FOREIGN KEY(`orderId`) REFERENCES orders(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS categories (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `name` varchar(100) NOT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP On Update CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(`id`),
+    UNIQUE KEY(`name`)
+);
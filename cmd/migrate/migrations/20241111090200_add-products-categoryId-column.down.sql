@@ -0,0 +1 @@
+ALTER TABLE products DROP FOREIGN KEY `fk_products_categoryId`, DROP COLUMN `categoryId`;
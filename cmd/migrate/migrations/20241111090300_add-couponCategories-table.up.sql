@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS couponCategories (
+    `couponId` INT UNSIGNED NOT NULL,
+    `categoryId` INT UNSIGNED NOT NULL,
+
+    PRIMARY KEY(`couponId`, `categoryId`),
+    FOREIGN KEY(`couponId`) REFERENCES coupons(`id`) ON DELETE CASCADE,
+    FOREIGN KEY(`categoryId`) REFERENCES categories(`id`)
+);
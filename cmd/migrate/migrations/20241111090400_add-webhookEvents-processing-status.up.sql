@@ -0,0 +1,2 @@
+ALTER TABLE webhookEvents
+    MODIFY COLUMN `status` ENUM('pending', 'processing', 'processed', 'ignored', 'failed') NOT NULL DEFAULT 'pending';
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/cmd/api"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+// runs the background jobs apart from the API, set JOB_WORKER_IN_PROCESS=false on the API when it runs.
+func main() {
+	if err := money.SetDefaultCurrency(config.Envs.Currency); err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := utils.StartMySqlDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		log.Fatal(err)
+	}
+
+	worker, err := api.NewJobWorker(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pollInterval := time.Second
+	if seconds, err := strconv.Atoi(config.Envs.JobPollIntervalInSeconds); err == nil && seconds > 0 {
+		pollInterval = time.Duration(seconds) * time.Second
+	}
+
+	// the running jobs are finished before it exits.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Println("Job worker started")
+	worker.Run(ctx, pollInterval)
+	log.Println("Job worker stopped")
+}
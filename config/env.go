@@ -3,20 +3,73 @@ package config
 import (
 	"fmt"
 	"log"
+	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	PublicHost             string
-	Port                   string
-	DBUser                 string
-	DBPassword             string
-	DBName                 string
-	DBAddress              string
-	JWTExpirationInSeconds string
-	JWTSecret              string
-	Env 				   string
+	PublicHost                        string
+	Port                              string
+	DBUser                            string
+	DBPassword                        string
+	DBName                            string
+	DBAddress                         string
+	JWTExpirationInSeconds            string
+	JWTSecret                         string
+	Env                               string
+	StorageDriver                     string
+	LocalStoragePath                  string
+	LocalStorageURL                   string
+	S3Endpoint                        string
+	S3Region                          string
+	S3Bucket                          string
+	S3AccessKey                       string
+	S3SecretKey                       string
+	S3PublicURL                       string
+	MaxImageSizeInBytes               string
+	ReservationTTLInSeconds           string
+	ReservationSweepIntervalInSeconds string
+	AllocationStrategy                string
+	MailFrom                          string
+	StockAlertEmails                  string
+	StockAlertRateLimitInSeconds      string
+	StockNotifierIntervalInSeconds    string
+	Currency                          string
+	TaxProvider                       string
+	TaxProviderURL                    string
+	TaxProviderAPIKey                 string
+	PricesIncludeTax                  string
+	PaymentProvider                   string
+	PaymentWebhookSecret              string
+	PaymentWebhookToleranceInSeconds  string
+	IdempotencyKeyTTLInSeconds        string
+	ReturnWindowInDays                string
+	SellerName                        string
+	SellerAddress                     string
+	SellerTaxID                       string
+	EventBroker                       string
+	EventLogPath                      string
+	RedisAddress                      string
+	OutboxRelayIntervalInSeconds      string
+	WebhookDeliveryIntervalInSeconds  string
+	WebhookMaxAttempts                string
+	MailDriver                        string
+	MailDropPath                      string
+	SMTPHost                          string
+	SMTPPort                          string
+	SMTPUsername                      string
+	SMTPPassword                      string
+	PublicAPIURL                      string
+	NotificationIntervalInSeconds     string
+	JobWorkerInProcess                string
+	JobWorkerConcurrency              string
+	JobPollIntervalInSeconds          string
+	StorefrontURL                     string
+	AbandonedCartThresholds           string
+	AbandonedCartCouponPercent        string
+	AbandonedCartCouponValidHours     string
 }
 
 var Envs = initConfig()
@@ -25,15 +78,66 @@ func initConfig() Config {
 	loadEnvFile()
 
 	return Config{
-		PublicHost:             getEnv("PUBLIC_HOST", "http://localhost"),
-		Port:                   getEnv("PORT", ":8080"),
-		DBUser:                 getEnv("DB_USER", "root"),
-		DBPassword:             getEnv("DB_PASSWORD", "mypassword"),
-		DBAddress:              fmt.Sprintf("%s:%s", getEnv("DB_HOST", "127.0.0.1"), getEnv("DB_PORT", "3306")),
-		DBName:                 getEnv("DB_NAME", "dbname"),
-		JWTExpirationInSeconds: getEnv("JWTExpirationInSeconds", string(3600*24*7)),
-		JWTSecret: getEnv("JWTSecret","fallback value"),
-		Env: getEnv("env", "production"),
+		PublicHost:                        getEnv("PUBLIC_HOST", "http://localhost"),
+		Port:                              getEnv("PORT", ":8080"),
+		DBUser:                            getEnv("DB_USER", "root"),
+		DBPassword:                        getEnv("DB_PASSWORD", "mypassword"),
+		DBAddress:                         fmt.Sprintf("%s:%s", getEnv("DB_HOST", "127.0.0.1"), getEnv("DB_PORT", "3306")),
+		DBName:                            getEnv("DB_NAME", "dbname"),
+		JWTExpirationInSeconds:            getEnv("JWTExpirationInSeconds", strconv.Itoa(3600*24*7)),
+		JWTSecret:                         getEnv("JWTSecret", "fallback value"),
+		Env:                               getEnv("env", "production"),
+		StorageDriver:                     getEnv("STORAGE_DRIVER", "local"),
+		LocalStoragePath:                  getEnv("LOCAL_STORAGE_PATH", "./uploads"),
+		LocalStorageURL:                   getEnv("LOCAL_STORAGE_URL", "http://localhost:8080/uploads"),
+		S3Endpoint:                        getEnv("S3_ENDPOINT", "http://127.0.0.1:9000"),
+		S3Region:                          getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:                          getEnv("S3_BUCKET", "products"),
+		S3AccessKey:                       getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:                       getEnv("S3_SECRET_KEY", ""),
+		S3PublicURL:                       getEnv("S3_PUBLIC_URL", ""),
+		MaxImageSizeInBytes:               getEnv("MAX_IMAGE_SIZE_IN_BYTES", strconv.Itoa(5<<20)),
+		ReservationTTLInSeconds:           getEnv("RESERVATION_TTL_IN_SECONDS", strconv.Itoa(15*60)),
+		ReservationSweepIntervalInSeconds: getEnv("RESERVATION_SWEEP_INTERVAL_IN_SECONDS", "60"),
+		AllocationStrategy:                getEnv("ALLOCATION_STRATEGY", "priority"),
+		MailFrom:                          getEnv("MAIL_FROM", "no-reply@localhost"),
+		StockAlertEmails:                  getEnv("STOCK_ALERT_EMAILS", ""),
+		StockAlertRateLimitInSeconds:      getEnv("STOCK_ALERT_RATE_LIMIT_IN_SECONDS", "3600"),
+		StockNotifierIntervalInSeconds:    getEnv("STOCK_NOTIFIER_INTERVAL_IN_SECONDS", "60"),
+		Currency:                          getEnv("CURRENCY", "USD"),
+		TaxProvider:                       getEnv("TAX_PROVIDER", "table"),
+		TaxProviderURL:                    getEnv("TAX_PROVIDER_URL", ""),
+		TaxProviderAPIKey:                 getEnv("TAX_PROVIDER_API_KEY", ""),
+		PricesIncludeTax:                  getEnv("PRICES_INCLUDE_TAX", "false"),
+		PaymentProvider:                   getEnv("PAYMENT_PROVIDER", "fake"),
+		PaymentWebhookSecret:              getEnv("PAYMENT_WEBHOOK_SECRET", ""),
+		PaymentWebhookToleranceInSeconds:  getEnv("PAYMENT_WEBHOOK_TOLERANCE_IN_SECONDS", "300"),
+		IdempotencyKeyTTLInSeconds:        getEnv("IDEMPOTENCY_KEY_TTL_IN_SECONDS", strconv.Itoa(24*3600)),
+		ReturnWindowInDays:                getEnv("RETURN_WINDOW_IN_DAYS", "30"),
+		SellerName:                        getEnv("SELLER_NAME", "Golang Ecommerce"),
+		SellerAddress:                     getEnv("SELLER_ADDRESS", ""),
+		SellerTaxID:                       getEnv("SELLER_TAX_ID", ""),
+		EventBroker:                       getEnv("EVENT_BROKER", "memory"),
+		EventLogPath:                      getEnv("EVENT_LOG_PATH", "./events.ndjson"),
+		RedisAddress:                      getEnv("REDIS_ADDRESS", "127.0.0.1:6379"),
+		OutboxRelayIntervalInSeconds:      getEnv("OUTBOX_RELAY_INTERVAL_IN_SECONDS", "5"),
+		WebhookDeliveryIntervalInSeconds:  getEnv("WEBHOOK_DELIVERY_INTERVAL_IN_SECONDS", "10"),
+		WebhookMaxAttempts:                getEnv("WEBHOOK_MAX_ATTEMPTS", "10"),
+		MailDriver:                        getEnv("MAIL_DRIVER", "log"),
+		MailDropPath:                      getEnv("MAIL_DROP_PATH", "./mail"),
+		SMTPHost:                          getEnv("SMTP_HOST", "127.0.0.1"),
+		SMTPPort:                          getEnv("SMTP_PORT", "587"),
+		SMTPUsername:                      getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:                      getEnv("SMTP_PASSWORD", ""),
+		PublicAPIURL:                      getEnv("PUBLIC_API_URL", "http://localhost:8080/api/v1"),
+		NotificationIntervalInSeconds:     getEnv("NOTIFICATION_INTERVAL_IN_SECONDS", "10"),
+		JobWorkerInProcess:                getEnv("JOB_WORKER_IN_PROCESS", "true"),
+		JobWorkerConcurrency:              getEnv("JOB_WORKER_CONCURRENCY", "4"),
+		JobPollIntervalInSeconds:          getEnv("JOB_POLL_INTERVAL_IN_SECONDS", "1"),
+		StorefrontURL:                     getEnv("STOREFRONT_URL", "http://localhost:3000"),
+		AbandonedCartThresholds:           getEnv("ABANDONED_CART_THRESHOLDS", "1h,24h,72h"),
+		AbandonedCartCouponPercent:        getEnv("ABANDONED_CART_COUPON_PERCENT", "10"),
+		AbandonedCartCouponValidHours:     getEnv("ABANDONED_CART_COUPON_VALID_HOURS", "72"),
 	}
 }
 
@@ -44,14 +148,21 @@ func getEnv(key, fallback string) string {
 		return envs[key]
 	}
 
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+
 	return fallback
 }
 
+// the .env file is optional, without it the values are taken from the process environment or the fallbacks.
 func loadEnvFile() {
-	godotenv.Load()
 	envVariables, err := godotenv.Read()
 	if err != nil {
-		log.Fatal(err)
+		if !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+		envVariables = map[string]string{}
 	}
 	envs = envVariables
 }
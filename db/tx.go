@@ -0,0 +1,33 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx, stores hold one so they can run inside a transaction.
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// InTx runs fn inside a transaction, it's committed when fn returns nil and rolled back otherwise.
+// When q is already a transaction fn joins it and the caller stays in charge of committing.
+func InTx(q DBTX, fn func(tx DBTX) error) error {
+	database, ok := q.(*sql.DB)
+	if !ok || database == nil {
+		return fn(q)
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
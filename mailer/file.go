@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// FileMailer drops every email in a directory as an .eml file instead of sending it, they open in any
+// mail client. It's meant for development and the staging environments.
+type FileMailer struct {
+	from    string
+	dir     string
+	counter atomic.Int64
+}
+
+func NewFileMailer(from, dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileMailer{
+		from: from,
+		dir:  dir,
+	}, nil
+}
+
+func (m *FileMailer) Send(email types.Email) error {
+	now := time.Now()
+	message, err := buildMessage(m.from, email, now)
+	if err != nil {
+		return err
+	}
+
+	// the counter keeps apart the emails dropped within the same nanosecond.
+	name := fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102T150405.000000000"), m.counter.Add(1))
+	return os.WriteFile(filepath.Join(m.dir, name), message, 0644)
+}
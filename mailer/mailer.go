@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+const (
+	LogDriver  = "log"
+	SMTPDriver = "smtp"
+	FileDriver = "file"
+)
+
+// returns the mailer selected by the MAIL_DRIVER env variable.
+func NewFromConfig(cfg config.Config) (types.Mailer, error) {
+	switch cfg.MailDriver {
+	case LogDriver:
+		return NewLogMailer(cfg.MailFrom), nil
+	case SMTPDriver:
+		return NewSMTPMailer(cfg.MailFrom, cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword), nil
+	case FileDriver:
+		return NewFileMailer(cfg.MailFrom, cfg.MailDropPath)
+	default:
+		return nil, fmt.Errorf("unknown mail driver '%s'", cfg.MailDriver)
+	}
+}
+
+// LogMailer writes the emails to the log instead of sending them, it's meant for development.
+type LogMailer struct {
+	from string
+}
+
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{
+		from: from,
+	}
+}
+
+func (m *LogMailer) Send(email types.Email) error {
+	log.Printf("email from %s to %s: %s\n%s", m.from, strings.Join(email.To, ", "), email.Subject, email.Body)
+	return nil
+}
@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// buildMessage writes the email as a MIME message, the text and the HTML bodies are alternatives of each other.
+func buildMessage(from string, email types.Email, date time.Time) ([]byte, error) {
+	to := make([]string, 0, len(email.To))
+	for _, address := range email.To {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient '%s': %v", address, err)
+		}
+
+		to = append(to, parsed.String())
+	}
+
+	var message bytes.Buffer
+	writeHeader(&message, "From", from)
+	writeHeader(&message, "To", strings.Join(to, ", "))
+	writeHeader(&message, "Subject", mime.QEncoding.Encode("utf-8", email.Subject))
+	writeHeader(&message, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&message, "MIME-Version", "1.0")
+
+	// sorted so the same email is always written the same way.
+	keys := make([]string, 0, len(email.Headers))
+	for key := range email.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeHeader(&message, key, email.Headers[key])
+	}
+
+	if email.HTMLBody == "" {
+		writeHeader(&message, "Content-Type", "text/plain; charset=utf-8")
+		writeHeader(&message, "Content-Transfer-Encoding", "quoted-printable")
+		message.WriteString("\r\n")
+		if err := writeQuotedPrintable(&message, email.Body); err != nil {
+			return nil, err
+		}
+
+		return message.Bytes(), nil
+	}
+
+	boundary, err := newBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	writeHeader(&message, "Content-Type", fmt.Sprintf(`multipart/alternative; boundary="%s"`, boundary))
+	message.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", email.Body},
+		{"text/html; charset=utf-8", email.HTMLBody},
+	} {
+		fmt.Fprintf(&message, "--%s\r\n", boundary)
+		writeHeader(&message, "Content-Type", part.contentType)
+		writeHeader(&message, "Content-Transfer-Encoding", "quoted-printable")
+		message.WriteString("\r\n")
+		if err := writeQuotedPrintable(&message, part.body); err != nil {
+			return nil, err
+		}
+		message.WriteString("\r\n")
+	}
+	fmt.Fprintf(&message, "--%s--\r\n", boundary)
+
+	return message.Bytes(), nil
+}
+
+// header values can't hold line breaks, they would start headers of their own.
+func writeHeader(message *bytes.Buffer, key, value string) {
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	fmt.Fprintf(message, "%s: %s\r\n", key, value)
+}
+
+func writeQuotedPrintable(message *bytes.Buffer, body string) error {
+	writer := quotedprintable.NewWriter(message)
+	if _, err := writer.Write([]byte(body)); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func newBoundary() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(random), nil
+}
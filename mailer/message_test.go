@@ -0,0 +1,70 @@
+package mailer
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestBuildMessage(t *testing.T) {
+	date := time.Date(2024, 11, 7, 9, 0, 0, 0, time.UTC)
+
+	t.Run("Should write the text and the HTML as alternatives", func(t *testing.T) {
+		message, err := buildMessage("shop@example.com", types.Email{
+			To:       []string{"sam@example.com"},
+			Subject:  "تأكيد طلبك",
+			Body:     "Hi Sam\n",
+			HTMLBody: "<p>Hi Sam</p>",
+			Headers:  map[string]string{"List-Unsubscribe": "<https://example.com/u>\r\nBcc: eve@example.com"},
+		}, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := mail.ReadMessage(strings.NewReader(string(message)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+		if err != nil || subject != "تأكيد طلبك" {
+			t.Errorf("expected the subject to be encoded got %q", parsed.Header.Get("Subject"))
+		}
+		if parsed.Header.Get("Bcc") != "" {
+			t.Error("expected a header value not to start another header")
+		}
+
+		mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+		if err != nil || mediaType != "multipart/alternative" {
+			t.Fatalf("expected a multipart message got %s", parsed.Header.Get("Content-Type"))
+		}
+
+		reader := multipart.NewReader(parsed.Body, params["boundary"])
+		for _, expected := range []struct{ contentType, body string }{
+			// the line breaks of the text are sent as CRLF.
+			{"text/plain; charset=utf-8", "Hi Sam\r\n"},
+			{"text/html; charset=utf-8", "<p>Hi Sam</p>"},
+		} {
+			part, err := reader.NextPart()
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(part)
+			if part.Header.Get("Content-Type") != expected.contentType || string(body) != expected.body {
+				t.Errorf("expected a %s part with %q got %s with %q", expected.contentType, expected.body, part.Header.Get("Content-Type"), body)
+			}
+		}
+	})
+
+	t.Run("Should refuse an invalid recipient", func(t *testing.T) {
+		if _, err := buildMessage("shop@example.com", types.Email{To: []string{"not an address"}}, date); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// SMTPMailer sends the emails through an SMTP server, STARTTLS is used when the server offers it.
+// Without a username the server is used without authentication, e.g. a local relay.
+type SMTPMailer struct {
+	from     string
+	address  string
+	host     string
+	username string
+	password string
+}
+
+func NewSMTPMailer(from, host, port, username, password string) *SMTPMailer {
+	return &SMTPMailer{
+		from:     from,
+		address:  net.JoinHostPort(host, port),
+		host:     host,
+		username: username,
+		password: password,
+	}
+}
+
+func (m *SMTPMailer) Send(email types.Email) error {
+	message, err := buildMessage(m.from, email, time.Now())
+	if err != nil {
+		return err
+	}
+
+	recipients := make([]string, 0, len(email.To))
+	for _, address := range email.To {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, parsed.Address)
+	}
+
+	sender, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender '%s': %v", m.from, err)
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	return smtp.SendMail(m.address, auth, sender.Address, recipients, message)
+}
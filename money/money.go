@@ -0,0 +1,422 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount, Amount is in the minor units of the currency (cents for USD).
+// Amounts of different currencies are never mixed, doing so is a bug and panics.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// DefaultCurrency is the currency of the shop, the amounts read from the database and
+// the JSON bodies are in it. It's set once at startup with SetDefaultCurrency.
+var DefaultCurrency = "USD"
+
+// how many decimals the currencies have, the ones that aren't listed have 2.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// RoundingMode tells how an amount that falls between two minor units is rounded.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds half away from zero, 0.125 becomes 0.13.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds half to the even neighbour, 0.125 becomes 0.12.
+	RoundHalfEven
+	// RoundDown truncates towards zero, 0.129 becomes 0.12.
+	RoundDown
+)
+
+const (
+	// DiscountRounding is used for every computed discount, a discount is never
+	// more than what the promotion or coupon advertises.
+	DiscountRounding = RoundDown
+	// TaxRounding is used for the tax of every line.
+	TaxRounding = RoundHalfUp
+)
+
+func SetDefaultCurrency(currency string) error {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if !validCurrency(currency) {
+		return fmt.Errorf("'%s' is not an ISO 4217 currency code", currency)
+	}
+
+	DefaultCurrency = currency
+	return nil
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// FromMinor returns the amount in the default currency.
+func FromMinor(amount int64) Money {
+	return New(amount, DefaultCurrency)
+}
+
+// Zero is no amount in the default currency.
+func Zero() Money {
+	return FromMinor(0)
+}
+
+// Parse reads a decimal amount like "12.5" or "-3.25" exactly, it fails when the amount has more
+// significant decimals than the currency.
+func Parse(value, currency string) (Money, error) {
+	value = strings.TrimSpace(value)
+	exponent := Exponent(currency)
+
+	negative := strings.HasPrefix(value, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(value, "-"), "+")
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("'%s' is not a valid amount", value)
+	}
+
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > exponent {
+		return Money{}, fmt.Errorf("'%s' has more than %d decimals", value, exponent)
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	if whole == "" {
+		whole = "0"
+	}
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("'%s' is out of range", value)
+	}
+	if negative {
+		amount = -amount
+	}
+
+	return New(amount, currency), nil
+}
+
+// Exponent is the number of decimals of the currency.
+func Exponent(currency string) int {
+	if exponent, ok := currencyExponents[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// String formats the amount with the decimals of its currency, without the currency.
+func (m Money) String() string {
+	exponent := Exponent(m.currency())
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+func (m Money) Add(other Money) Money {
+	m.mustMatch(other)
+	return New(m.Amount+other.Amount, m.currency())
+}
+
+func (m Money) Sub(other Money) Money {
+	m.mustMatch(other)
+	return New(m.Amount-other.Amount, m.currency())
+}
+
+func (m Money) Mul(quantity int64) Money {
+	return New(m.Amount*quantity, m.currency())
+}
+
+// MulDiv returns m * numerator / denominator rounded with mode, it's how fractions of an amount
+// are taken without going through floats.
+func (m Money) MulDiv(numerator, denominator int64, mode RoundingMode) Money {
+	return New(divRound(m.Amount*numerator, denominator, mode), m.currency())
+}
+
+// Percent returns percent of the amount, the percentage is taken to two decimals (basis points).
+func (m Money) Percent(percent float64, mode RoundingMode) Money {
+	basisPoints := int64(roundHalfUp(percent * 100))
+	return m.MulDiv(basisPoints, 10000, mode)
+}
+
+// Allocate splits the amount in proportion to the weights, the parts always add up to the amount.
+// The minor units left by the rounding go to the parts with the largest remainders, then the first ones.
+// The weights must not be negative.
+func (m Money) Allocate(weights []int64) []Money {
+	if m.Amount < 0 {
+		parts := New(-m.Amount, m.currency()).Allocate(weights)
+		for i := range parts {
+			parts[i].Amount = -parts[i].Amount
+		}
+		return parts
+	}
+
+	currency := m.currency()
+	parts := make([]Money, len(weights))
+	var total int64
+	for _, weight := range weights {
+		total += weight
+	}
+	if total == 0 {
+		for i := range parts {
+			parts[i] = New(0, currency)
+		}
+		return parts
+	}
+
+	remainders := make([]int64, len(weights))
+	var allocated int64
+	for i, weight := range weights {
+		parts[i] = New(m.Amount*weight/total, currency)
+		remainders[i] = m.Amount * weight % total
+		allocated += parts[i].Amount
+	}
+
+	for left := m.Amount - allocated; left > 0; left-- {
+		largest := 0
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+		parts[largest].Amount++
+		remainders[largest] = -1
+	}
+
+	return parts
+}
+
+// Cmp returns -1, 0 or 1 when m is less than, equal to or more than other.
+func (m Money) Cmp(other Money) int {
+	m.mustMatch(other)
+	switch {
+	case m.Amount < other.Amount:
+		return -1
+	case m.Amount > other.Amount:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func Min(a, b Money) Money {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+func Max(a, b Money) Money {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// Sum adds the amounts, the sum of nothing is zero in the default currency.
+func Sum(amounts ...Money) Money {
+	if len(amounts) == 0 {
+		return Zero()
+	}
+
+	total := New(0, amounts[0].currency())
+	for _, amount := range amounts {
+		total = total.Add(amount)
+	}
+	return total
+}
+
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON writes the amount as a decimal string so no client reads it as a float,
+// e.g. {"amount":"12.50","currency":"USD"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: m.currency()})
+}
+
+// UnmarshalJSON accepts the object written by MarshalJSON or a plain number in the default currency,
+// the number is read from its text so 0.1 stays exactly 0.1.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '{' {
+		var value moneyJSON
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+
+		currency := strings.ToUpper(value.Currency)
+		if currency == "" {
+			currency = DefaultCurrency
+		}
+		if currency != DefaultCurrency {
+			return fmt.Errorf("amounts must be in %s, got %s", DefaultCurrency, currency)
+		}
+
+		parsed, err := Parse(value.Amount, currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		return fmt.Errorf("amount must be a number or an object with amount and currency")
+	}
+
+	var number json.Number
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&number); err != nil {
+		return fmt.Errorf("amount must be a number or an object with amount and currency")
+	}
+	if strings.ContainsAny(number.String(), "eE") {
+		return fmt.Errorf("amount '%s' must be written without an exponent", number)
+	}
+
+	parsed, err := Parse(number.String(), DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan reads a DECIMAL column, the amount is in the default currency.
+func (m *Money) Scan(src any) error {
+	var value string
+	switch src := src.(type) {
+	case []byte:
+		value = string(src)
+	case string:
+		value = src
+	case int64:
+		value = strconv.FormatInt(src, 10)
+	case float64:
+		value = strconv.FormatFloat(src, 'f', -1, 64)
+	default:
+		return fmt.Errorf("can't scan %T into Money", src)
+	}
+
+	parsed, err := Parse(value, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value writes the amount as a decimal string so DECIMAL columns store it exactly.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// an amount built as a zero value has no currency, it's the default one.
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+func (m Money) mustMatch(other Money) {
+	if m.currency() != other.currency() {
+		panic(fmt.Sprintf("money: mixing %s and %s", m.currency(), other.currency()))
+	}
+}
+
+func validCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func roundHalfUp(value float64) float64 {
+	if value < 0 {
+		return -roundHalfUp(-value)
+	}
+	return float64(int64(value + 0.5))
+}
+
+// divides n by d, d must be positive, and rounds the quotient with mode.
+func divRound(n, d int64, mode RoundingMode) int64 {
+	quotient, remainder := n/d, n%d
+	if remainder == 0 {
+		return quotient
+	}
+
+	sign := int64(1)
+	if n < 0 {
+		sign = -1
+		remainder = -remainder
+	}
+
+	switch mode {
+	case RoundDown:
+		return quotient
+	case RoundHalfEven:
+		if 2*remainder > d || 2*remainder == d && quotient%2 != 0 {
+			return quotient + sign
+		}
+	default:
+		if 2*remainder >= d {
+			return quotient + sign
+		}
+	}
+
+	return quotient
+}
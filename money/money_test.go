@@ -0,0 +1,163 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		value    string
+		currency string
+		expected int64
+		err      bool
+	}{
+		{value: "12.34", currency: "USD", expected: 1234},
+		{value: "12.5", currency: "USD", expected: 1250},
+		{value: "-0.07", currency: "USD", expected: -7},
+		{value: "19.990", currency: "USD", expected: 1999},
+		{value: ".5", currency: "USD", expected: 50},
+		{value: "1500", currency: "JPY", expected: 1500},
+		{value: "1.234", currency: "KWD", expected: 1234},
+		{value: "0.001", currency: "USD", err: true},
+		{value: "12.5", currency: "JPY", err: true},
+		{value: "1e3", currency: "USD", err: true},
+		{value: "", currency: "USD", err: true},
+	}
+
+	for _, c := range cases {
+		parsed, err := Parse(c.value, c.currency)
+		if c.err {
+			if err == nil {
+				t.Errorf("expected '%s' to be rejected got %d", c.value, parsed.Amount)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("'%s': %v", c.value, err)
+			continue
+		}
+
+		if parsed.Amount != c.expected {
+			t.Errorf("expected '%s' to be %d got %d", c.value, c.expected, parsed.Amount)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	cases := []struct {
+		money    Money
+		expected string
+	}{
+		{money: New(1234, "USD"), expected: "12.34"},
+		{money: New(5, "USD"), expected: "0.05"},
+		{money: New(-120, "USD"), expected: "-1.20"},
+		{money: New(1500, "JPY"), expected: "1500"},
+		{money: New(1, "KWD"), expected: "0.001"},
+	}
+
+	for _, c := range cases {
+		if got := c.money.String(); got != c.expected {
+			t.Errorf("expected %s got %s", c.expected, got)
+		}
+	}
+}
+
+func TestRounding(t *testing.T) {
+	cases := []struct {
+		name     string
+		amount   int64
+		percent  float64
+		mode     RoundingMode
+		expected int64
+	}{
+		{name: "half up rounds 0.125 up", amount: 125, percent: 10, mode: RoundHalfUp, expected: 13},
+		{name: "half even rounds 0.125 down", amount: 125, percent: 10, mode: RoundHalfEven, expected: 12},
+		{name: "half even rounds 0.135 up", amount: 135, percent: 10, mode: RoundHalfEven, expected: 14},
+		{name: "down truncates", amount: 1999, percent: 50, mode: RoundDown, expected: 999},
+		{name: "half up rounds away from zero", amount: -125, percent: 10, mode: RoundHalfUp, expected: -13},
+		{name: "fractional percentages are exact", amount: 10000, percent: 12.5, mode: RoundHalfUp, expected: 1250},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := New(c.amount, "USD").Percent(c.percent, c.mode)
+			if got.Amount != c.expected {
+				t.Errorf("expected %d got %d", c.expected, got.Amount)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	parts := New(1000, "USD").Allocate([]int64{1, 1, 1})
+	if parts[0].Amount != 334 || parts[1].Amount != 333 || parts[2].Amount != 333 {
+		t.Errorf("expected 3.34, 3.33 and 3.33 got %v", parts)
+	}
+
+	parts = New(500, "USD").Allocate([]int64{1500, 1000})
+	if parts[0].Amount != 300 || parts[1].Amount != 200 {
+		t.Errorf("expected 3.00 and 2.00 got %v", parts)
+	}
+}
+
+func TestLargeCartTotalIsExact(t *testing.T) {
+	// 0.1 has no exact float representation, a float sum of these drifts away from 10000.
+	total := Zero()
+	for i := 0; i < 100000; i++ {
+		total = total.Add(New(10, DefaultCurrency))
+	}
+
+	if total.String() != "10000.00" {
+		t.Errorf("expected 10000.00 got %s", total)
+	}
+
+	price, _ := Parse("19.99", DefaultCurrency)
+	if got := price.Mul(333333).String(); got != "6663326.67" {
+		t.Errorf("expected 6663326.67 got %s", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	t.Run("Should write the amount as a decimal string", func(t *testing.T) {
+		marshalled, err := json.Marshal(New(1999, "USD"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(marshalled) != `{"amount":"19.99","currency":"USD"}` {
+			t.Errorf("got %s", marshalled)
+		}
+	})
+
+	t.Run("Should read numbers and objects exactly", func(t *testing.T) {
+		for _, body := range []string{`0.3`, `{"amount":"0.30","currency":"USD"}`} {
+			var m Money
+			if err := json.Unmarshal([]byte(body), &m); err != nil {
+				t.Fatal(err)
+			}
+			if m.Amount != 30 {
+				t.Errorf("expected %s to be 30 got %d", body, m.Amount)
+			}
+		}
+	})
+
+	t.Run("Should reject another currency and plain strings", func(t *testing.T) {
+		for _, body := range []string{`{"amount":"1","currency":"EUR"}`, `"19.50"`, `1e2`} {
+			var m Money
+			if err := json.Unmarshal([]byte(body), &m); err == nil {
+				t.Errorf("expected %s to be rejected", body)
+			}
+		}
+	})
+}
+
+func TestScan(t *testing.T) {
+	var m Money
+	if err := m.Scan([]byte("1234.50")); err != nil {
+		t.Fatal(err)
+	}
+	if m.Amount != 123450 || m.Currency != DefaultCurrency {
+		t.Errorf("expected 123450 %s got %d %s", DefaultCurrency, m.Amount, m.Currency)
+	}
+}
@@ -19,6 +19,7 @@ const tokenPayloadKey = tokenKey("tokenPayload")
 type tokenPayload struct {
 	Email string `json:"email"`
 	UserId int `json:"userId"`
+	Role string `json:"role"`
 }
 
 func CreateJWT(secret []byte, user types.User) (string, error) {
@@ -32,6 +33,7 @@ func CreateJWT(secret []byte, user types.User) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"userId":    strconv.Itoa(user.ID),
 		"email":     user.Email,
+		"role":      user.Role,
 		"expiredAt": time.Now().Add(expiration).Unix(),
 	})
 
@@ -83,13 +85,76 @@ func AuthenticationMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		
-		ctx := context.WithValue(r.Context(), tokenPayloadKey, claims)
+		payload, err := claimsToTokenPayload(*claims)
+		if err != nil {
+			utils.WriteError(w, http.StatusForbidden, fmt.Errorf("forbidden"))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), tokenPayloadKey, payload)
 		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
 	}
 }
 
+// AdminMiddleware authenticates the request and only lets admins through.
+func AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return AuthenticationMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := GetTokenPayload(r.Context())
+		if err != nil || payload.Role != types.UserRoleAdmin {
+			utils.WriteError(w, http.StatusForbidden, fmt.Errorf("forbidden"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsAdminRequest reports whether the request carries a valid admin token,
+// it's for public routes that show extra details to admins.
+func IsAdminRequest(r *http.Request) bool {
+	claims, err := deCryptToken(r)
+	if err != nil {
+		return false
+	}
+
+	payload, err := claimsToTokenPayload(*claims)
+	return err == nil && payload.Role == types.UserRoleAdmin
+}
+
+// RequestUserId returns the id of the user of the token the request carries, it's for the
+// middlewares that run before the ones of the route.
+func RequestUserId(r *http.Request) (int, error) {
+	claims, err := deCryptToken(r)
+	if err != nil {
+		return 0, err
+	}
+
+	payload, err := claimsToTokenPayload(*claims)
+	if err != nil {
+		return 0, err
+	}
+
+	return payload.UserId, nil
+}
+
+func claimsToTokenPayload(claims jwt.MapClaims) (tokenPayload, error) {
+	userIdStr, _ := claims["userId"].(string)
+	userId, err := strconv.Atoi(userIdStr)
+	if err != nil {
+		return tokenPayload{}, fmt.Errorf("invalid token")
+	}
+
+	email, _ := claims["email"].(string)
+	role, _ := claims["role"].(string)
+
+	return tokenPayload{
+		Email:  email,
+		UserId: userId,
+		Role:   role,
+	}, nil
+}
+
 func GetTokenPayload(ctx context.Context) (tokenPayload, error) {
 	payload, ok := ctx.Value(tokenPayloadKey).(tokenPayload)
 	if !ok {
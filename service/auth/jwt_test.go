@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	handler := AdminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := GetTokenPayload(r.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payload.UserId != 7 {
+			t.Errorf("expected user id 7 got %d", payload.UserId)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	cases := []struct {
+		name     string
+		user     *types.User
+		expected int
+	}{
+		{name: "Should return 403 without token", user: nil, expected: http.StatusForbidden},
+		{name: "Should return 403 for customers", user: &types.User{ID: 7, Email: "c@gmail.com", Role: types.UserRoleCustomer}, expected: http.StatusForbidden},
+		{name: "Should let admins through", user: &types.User{ID: 7, Email: "a@gmail.com", Role: types.UserRoleAdmin}, expected: http.StatusOK},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/admin", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.user != nil {
+				token, err := CreateJWT([]byte(config.Envs.JWTSecret), *c.user)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", token)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != c.expected {
+				t.Errorf("expected status code %d got %d", c.expected, recorder.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package cart
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// lineAdjustment is what a promotion wants to take off the line at index.
+type lineAdjustment struct {
+	index  int
+	amount money.Money
+	reason string
+}
+
+// applyPromotions runs the promotions on the priced lines by ascending priority then id, every promotion
+// sees the lines as the previous ones left them. The adjustments are recorded on the lines they discount
+// and never take a line below zero.
+func applyPromotions(promotions []types.Promotion, lines []types.PriceLine, now time.Time) []types.AppliedPromotion {
+	sorted := slices.Clone(promotions)
+	slices.SortStableFunc(sorted, func(a, b types.Promotion) int {
+		if a.Priority != b.Priority {
+			return cmp.Compare(a.Priority, b.Priority)
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	applied := make([]types.AppliedPromotion, 0)
+	for _, promotion := range sorted {
+		if !promotionIsLive(promotion, now) {
+			continue
+		}
+
+		var adjustments []lineAdjustment
+		switch promotion.Type {
+		case types.PromotionTypeBuyXGetY:
+			adjustments = buyXGetYAdjustments(promotion.Rules, lines)
+		case types.PromotionTypeOrderPercentage:
+			adjustments = orderPercentageAdjustments(promotion.Rules, lines)
+		case types.PromotionTypeBundle:
+			adjustments = bundleAdjustments(promotion.Rules, lines)
+		case types.PromotionTypeQuantityTier:
+			adjustments = quantityTierAdjustments(promotion.Rules, lines)
+		}
+
+		discount := money.Zero()
+		for _, adjustment := range adjustments {
+			line := &lines[adjustment.index]
+			amount := money.Min(adjustment.amount, line.Total)
+			if !amount.IsPositive() {
+				continue
+			}
+
+			line.Adjustments = append(line.Adjustments, types.LineAdjustment{
+				PromotionID: promotion.ID,
+				Name:        promotion.Name,
+				Amount:      amount,
+				Reason:      adjustment.reason,
+			})
+			line.Discount = line.Discount.Add(amount)
+			line.Total = line.Subtotal.Sub(line.Discount)
+			discount = discount.Add(amount)
+		}
+
+		if discount.IsPositive() {
+			applied = append(applied, types.AppliedPromotion{
+				PromotionID: promotion.ID,
+				Name:        promotion.Name,
+				Type:        promotion.Type,
+				Discount:    discount,
+			})
+		}
+	}
+
+	return applied
+}
+
+func promotionIsLive(promotion types.Promotion, now time.Time) bool {
+	if !promotion.Active {
+		return false
+	}
+	if promotion.StartsAt != nil && now.Before(*promotion.StartsAt) {
+		return false
+	}
+	if promotion.EndsAt != nil && !now.Before(*promotion.EndsAt) {
+		return false
+	}
+
+	return true
+}
+
+// for every BuyQuantity + GetQuantity units of the products, GetQuantity of them are free,
+// the cheapest units are the ones given away.
+func buyXGetYAdjustments(rules types.PromotionRules, lines []types.PriceLine) []lineAdjustment {
+	groupSize := rules.BuyQuantity + rules.GetQuantity
+	if rules.BuyQuantity <= 0 || rules.GetQuantity <= 0 {
+		return nil
+	}
+
+	eligible := make([]int, 0)
+	units := 0
+	for i, line := range lines {
+		if slices.Contains(rules.ProductIDs, line.ProductID) && line.Quantity > 0 {
+			eligible = append(eligible, i)
+			units += line.Quantity
+		}
+	}
+
+	free := units / groupSize * rules.GetQuantity
+	if free == 0 {
+		return nil
+	}
+
+	// compares the unit prices a / qa and b / qb as a * qb and b * qa so no division is needed.
+	slices.SortStableFunc(eligible, func(a, b int) int {
+		return cmp.Compare(lines[a].Total.Amount*int64(lines[b].Quantity), lines[b].Total.Amount*int64(lines[a].Quantity))
+	})
+
+	adjustments := make([]lineAdjustment, 0)
+	for _, i := range eligible {
+		if free == 0 {
+			break
+		}
+
+		taken := min(free, lines[i].Quantity)
+		free -= taken
+		adjustments = append(adjustments, lineAdjustment{
+			index:  i,
+			amount: lines[i].Total.MulDiv(int64(taken), int64(lines[i].Quantity), money.DiscountRounding),
+			reason: fmt.Sprintf("buy %d get %d free, %d free unit(s)", rules.BuyQuantity, rules.GetQuantity, taken),
+		})
+	}
+
+	return adjustments
+}
+
+// takes Percentage off every line once what's left of the cart reaches MinSubtotal.
+func orderPercentageAdjustments(rules types.PromotionRules, lines []types.PriceLine) []lineAdjustment {
+	total := money.Zero()
+	for _, line := range lines {
+		total = total.Add(line.Total)
+	}
+	if rules.Percentage <= 0 || !total.IsPositive() || total.Cmp(rules.MinSubtotal) < 0 {
+		return nil
+	}
+
+	adjustments := make([]lineAdjustment, 0, len(lines))
+	for i, line := range lines {
+		adjustments = append(adjustments, lineAdjustment{
+			index:  i,
+			amount: line.Total.Percent(rules.Percentage, money.DiscountRounding),
+			reason: fmt.Sprintf("%g%% off orders of %s or more", rules.Percentage, rules.MinSubtotal),
+		})
+	}
+
+	return adjustments
+}
+
+// every complete set of the products is sold for BundlePrice, the saving is split between
+// the products in proportion to their price and the shares add up to the saving exactly.
+// The share of a product is spread over its lines in proportion to what is left on them.
+func bundleAdjustments(rules types.PromotionRules, lines []types.PriceLine) []lineAdjustment {
+	if len(rules.ProductIDs) < 2 {
+		return nil
+	}
+
+	productLines := make(map[int][]int)
+	quantities := make(map[int]int)
+	totals := make(map[int]money.Money)
+	for i, line := range lines {
+		productLines[line.ProductID] = append(productLines[line.ProductID], i)
+		quantities[line.ProductID] += line.Quantity
+		if total, ok := totals[line.ProductID]; ok {
+			totals[line.ProductID] = total.Add(line.Total)
+		} else {
+			totals[line.ProductID] = line.Total
+		}
+	}
+
+	bundles := -1
+	regularPrice := money.Zero()
+	unitPrices := make([]int64, 0, len(rules.ProductIDs))
+	for _, productID := range rules.ProductIDs {
+		if len(productLines[productID]) == 0 || quantities[productID] <= 0 {
+			return nil
+		}
+
+		if bundles == -1 || quantities[productID] < bundles {
+			bundles = quantities[productID]
+		}
+		unitPrice := totals[productID].MulDiv(1, int64(quantities[productID]), money.DiscountRounding)
+		unitPrices = append(unitPrices, unitPrice.Amount)
+		regularPrice = regularPrice.Add(unitPrice)
+	}
+
+	saving := regularPrice.Sub(rules.BundlePrice).Mul(int64(bundles))
+	if bundles <= 0 || !saving.IsPositive() {
+		return nil
+	}
+
+	reason := fmt.Sprintf("bundle of %d products for %s, %d bundle(s)", len(rules.ProductIDs), rules.BundlePrice, bundles)
+	adjustments := make([]lineAdjustment, 0, len(rules.ProductIDs))
+	for i, share := range saving.Allocate(unitPrices) {
+		indexes := productLines[rules.ProductIDs[i]]
+		weights := make([]int64, 0, len(indexes))
+		for _, index := range indexes {
+			weights = append(weights, lines[index].Total.Amount)
+		}
+
+		for j, part := range share.Allocate(weights) {
+			adjustments = append(adjustments, lineAdjustment{index: indexes[j], amount: part, reason: reason})
+		}
+	}
+
+	return adjustments
+}
+
+// the products are sold at the price of the highest tier their quantity reaches,
+// the quantity of a product is counted across all its lines.
+func quantityTierAdjustments(rules types.PromotionRules, lines []types.PriceLine) []lineAdjustment {
+	quantities := make(map[int]int)
+	for _, line := range lines {
+		quantities[line.ProductID] += line.Quantity
+	}
+
+	adjustments := make([]lineAdjustment, 0)
+	for i, line := range lines {
+		if !slices.Contains(rules.ProductIDs, line.ProductID) {
+			continue
+		}
+
+		var tier *types.PriceTier
+		for j := range rules.Tiers {
+			if quantities[line.ProductID] >= rules.Tiers[j].MinQuantity && (tier == nil || rules.Tiers[j].MinQuantity > tier.MinQuantity) {
+				tier = &rules.Tiers[j]
+			}
+		}
+		if tier == nil || tier.UnitPrice.Cmp(line.UnitPrice) >= 0 {
+			continue
+		}
+
+		adjustments = append(adjustments, lineAdjustment{
+			index:  i,
+			amount: line.UnitPrice.Sub(tier.UnitPrice).Mul(int64(line.Quantity)),
+			reason: fmt.Sprintf("%d+ units at %s each", tier.MinQuantity, tier.UnitPrice),
+		})
+	}
+
+	return adjustments
+}
@@ -0,0 +1,138 @@
+package cart
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestApplyPromotions(t *testing.T) {
+	now := time.Date(2024, 10, 26, 12, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+
+	// every item is a product id, a quantity and a unit price in cents.
+	newLines := func(items ...[3]int64) []types.PriceLine {
+		lines := make([]types.PriceLine, 0, len(items))
+		for _, item := range items {
+			subtotal := money.FromMinor(item[2] * item[1])
+			lines = append(lines, types.PriceLine{
+				ProductID: int(item[0]),
+				Quantity:  int(item[1]),
+				UnitPrice: money.FromMinor(item[2]),
+				Subtotal:  subtotal,
+				Discount:  money.Zero(),
+				Total:     subtotal,
+			})
+		}
+		return lines
+	}
+	cents := money.FromMinor
+
+	cases := []struct {
+		name       string
+		promotions []types.Promotion
+		lines      []types.PriceLine
+		discounts  []int64
+		applied    int
+	}{
+		{
+			name: "Should give the cheapest unit away on buy 2 get 1",
+			promotions: []types.Promotion{{ID: 1, Type: types.PromotionTypeBuyXGetY, Active: true,
+				Rules: types.PromotionRules{ProductIDs: []int{1, 2}, BuyQuantity: 2, GetQuantity: 1}}},
+			lines:     newLines([3]int64{1, 2, 1000}, [3]int64{2, 1, 400}),
+			discounts: []int64{0, 400},
+			applied:   1,
+		},
+		{
+			name: "Should not give anything away before the group is complete",
+			promotions: []types.Promotion{{ID: 1, Type: types.PromotionTypeBuyXGetY, Active: true,
+				Rules: types.PromotionRules{ProductIDs: []int{1}, BuyQuantity: 2, GetQuantity: 1}}},
+			lines:     newLines([3]int64{1, 2, 1000}),
+			discounts: []int64{0},
+		},
+		{
+			name: "Should take a percentage off every line of a big enough order",
+			promotions: []types.Promotion{{ID: 1, Type: types.PromotionTypeOrderPercentage, Active: true,
+				Rules: types.PromotionRules{MinSubtotal: cents(10000), Percentage: 10}}},
+			lines:     newLines([3]int64{1, 3, 3000}, [3]int64{2, 1, 1500}),
+			discounts: []int64{900, 150},
+			applied:   1,
+		},
+		{
+			name: "Should skip the percentage under the minimum",
+			promotions: []types.Promotion{{ID: 1, Type: types.PromotionTypeOrderPercentage, Active: true,
+				Rules: types.PromotionRules{MinSubtotal: cents(10000), Percentage: 10}}},
+			lines:     newLines([3]int64{1, 1, 9999}),
+			discounts: []int64{0},
+		},
+		{
+			name: "Should split the bundle saving by price",
+			promotions: []types.Promotion{{ID: 1, Type: types.PromotionTypeBundle, Active: true,
+				Rules: types.PromotionRules{ProductIDs: []int{1, 2}, BundlePrice: cents(2000)}}},
+			lines:     newLines([3]int64{1, 2, 1500}, [3]int64{2, 1, 1000}),
+			discounts: []int64{300, 200},
+			applied:   1,
+		},
+		{
+			name: "Should spread the bundle saving over every line of a product",
+			promotions: []types.Promotion{{ID: 1, Type: types.PromotionTypeBundle, Active: true,
+				Rules: types.PromotionRules{ProductIDs: []int{1, 2}, BundlePrice: cents(200)}}},
+			lines:     newLines([3]int64{1, 1, 1000}, [3]int64{1, 1, 1000}, [3]int64{2, 2, 1000}),
+			discounts: []int64{900, 900, 1800},
+			applied:   1,
+		},
+		{
+			name: "Should price by the highest tier reached across duplicate lines",
+			promotions: []types.Promotion{{ID: 1, Type: types.PromotionTypeQuantityTier, Active: true,
+				Rules: types.PromotionRules{ProductIDs: []int{1}, Tiers: []types.PriceTier{{MinQuantity: 3, UnitPrice: cents(900)}, {MinQuantity: 5, UnitPrice: cents(800)}}}}},
+			lines:     newLines([3]int64{1, 3, 1000}, [3]int64{1, 2, 1000}),
+			discounts: []int64{600, 400},
+			applied:   1,
+		},
+		{
+			name: "Should run the promotions by priority on what the previous ones left",
+			promotions: []types.Promotion{
+				{ID: 1, Priority: 2, Type: types.PromotionTypeOrderPercentage, Active: true,
+					Rules: types.PromotionRules{Percentage: 50}},
+				{ID: 2, Priority: 1, Type: types.PromotionTypeQuantityTier, Active: true,
+					Rules: types.PromotionRules{ProductIDs: []int{1}, Tiers: []types.PriceTier{{MinQuantity: 2, UnitPrice: cents(600)}}}},
+			},
+			lines:     newLines([3]int64{1, 2, 1000}),
+			discounts: []int64{1400},
+			applied:   2,
+		},
+		{
+			name: "Should ignore inactive and ended promotions",
+			promotions: []types.Promotion{
+				{ID: 1, Type: types.PromotionTypeOrderPercentage, Rules: types.PromotionRules{Percentage: 50}},
+				{ID: 2, Type: types.PromotionTypeOrderPercentage, Active: true, EndsAt: &yesterday, Rules: types.PromotionRules{Percentage: 50}},
+			},
+			lines:     newLines([3]int64{1, 1, 1000}),
+			discounts: []int64{0},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			applied := applyPromotions(c.promotions, c.lines, now)
+			if len(applied) != c.applied {
+				t.Fatalf("expected %d promotions to apply got %d", c.applied, len(applied))
+			}
+
+			for i, discount := range c.discounts {
+				line := c.lines[i]
+				if line.Discount.Amount != discount {
+					t.Errorf("expected line %d to be discounted by %d got %s", i, discount, line.Discount)
+				}
+				if line.Total != line.Subtotal.Sub(line.Discount) {
+					t.Errorf("expected line %d total to be %s got %s", i, line.Subtotal.Sub(line.Discount), line.Total)
+				}
+				if discount > 0 && len(line.Adjustments) == 0 {
+					t.Errorf("expected line %d to explain its discount", i)
+				}
+			}
+		})
+	}
+}
@@ -1,32 +1,131 @@
 package cart
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
 	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
 	"github.com/mohammadahmadkhader/golang-ecommerce/types"
 	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
 )
 
 type Handler struct {
-	productStore types.ProductStore
-	orderStore   types.OrderStore
-	userStore    types.UserStore
+	db               myDB.DBTX
+	cartStore        types.CartStore
+	productStore     types.ProductStore
+	orderStore       types.OrderStore
+	userStore        types.UserStore
+	inventoryStore   types.InventoryStore
+	warehouseStore   types.WarehouseStore
+	couponStore      types.CouponStore
+	promotionStore   types.PromotionStore
+	allocator        types.AllocationStrategy
+	reservationTTL   time.Duration
+	shippingQuoter   types.ShippingQuoter
+	taxProvider      types.TaxProvider
+	pricesIncludeTax bool
+	paymentProcessor types.PaymentProcessor
 }
 
-func NewHandler(productStore types.ProductStore, orderStore types.OrderStore, userStore types.UserStore) *Handler {
+func NewHandler(db myDB.DBTX, cartStore types.CartStore, productStore types.ProductStore, orderStore types.OrderStore, userStore types.UserStore,
+	inventoryStore types.InventoryStore, warehouseStore types.WarehouseStore, couponStore types.CouponStore,
+	promotionStore types.PromotionStore, shippingQuoter types.ShippingQuoter, taxProvider types.TaxProvider,
+	paymentProcessor types.PaymentProcessor, allocator types.AllocationStrategy) *Handler {
+	ttlInSeconds, err := strconv.Atoi(config.Envs.ReservationTTLInSeconds)
+	if err != nil || ttlInSeconds <= 0 {
+		ttlInSeconds = 15 * 60
+	}
+
+	pricesIncludeTax, err := strconv.ParseBool(config.Envs.PricesIncludeTax)
+	if err != nil {
+		pricesIncludeTax = false
+	}
+
 	return &Handler{
-		productStore: productStore,
-		orderStore:   orderStore,
-		userStore:    userStore,
+		db:               db,
+		cartStore:        cartStore,
+		productStore:     productStore,
+		orderStore:       orderStore,
+		userStore:        userStore,
+		inventoryStore:   inventoryStore,
+		warehouseStore:   warehouseStore,
+		couponStore:      couponStore,
+		promotionStore:   promotionStore,
+		allocator:        allocator,
+		reservationTTL:   time.Duration(ttlInSeconds) * time.Second,
+		shippingQuoter:   shippingQuoter,
+		taxProvider:      taxProvider,
+		pricesIncludeTax: pricesIncludeTax,
+		paymentProcessor: paymentProcessor,
 	}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/cart", auth.AuthenticationMiddleware(h.handleGetCart)).Methods("GET")
+	router.HandleFunc("/cart", auth.AuthenticationMiddleware(h.handleSaveCart)).Methods("PUT")
 	router.HandleFunc("/cart/checkout", auth.AuthenticationMiddleware(h.handleCheckout)).Methods("POST")
 }
 
+func (h *Handler) handleGetCart(w http.ResponseWriter, r *http.Request) {
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	cart, err := h.cartStore.GetCart(tokenPayload.UserId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    cart,
+	})
+}
+
+// handleSaveCart replaces the items of the user's cart, the cart is kept until the user checks out.
+func (h *Handler) handleSaveCart(w http.ResponseWriter, r *http.Request) {
+	var payload types.CartPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	items, err := h.checkCartItems(payload.Items)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	cart, err := h.cartStore.SaveCart(tokenPayload.UserId, items)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    cart,
+	})
+}
+
 func (h *Handler) handleCheckout(w http.ResponseWriter, r *http.Request) {
 	var cart types.CartCheckoutItems
 	err := utils.ParseJSON(r, &cart)
@@ -51,6 +150,7 @@ func (h *Handler) handleCheckout(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
+	cart.CartItems = mergeCartItems(cart.CartItems)
 
 	products, err := h.productStore.GetProductsByID(productsIds)
 	if err != nil {
@@ -65,14 +165,84 @@ func (h *Handler) handleCheckout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	coupons, err := h.getCartCoupons(cart.CouponCodes)
+	if err != nil && !errors.Is(err, types.ErrCouponNotApplicable) {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	now := time.Now()
+	promotions, err := h.promotionStore.GetActivePromotions(now)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	pricing, err := h.priceCart(cart, productsMap, promotions, coupons, now)
+	if errors.Is(err, types.ErrTaxUnavailable) || errors.Is(err, types.ErrCarrierUnavailable) {
+		utils.WriteError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil && !errors.Is(err, types.ErrCouponNotApplicable) && !errors.Is(err, types.ErrShippingMethodUnavailable) {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	allocations, err := h.allocateStock(cart, productsIds)
+	if errors.Is(err, types.ErrInsufficientStock) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	userId := tokenPayload.UserId
-	order, err := h.createOrder(cart.CartItems,productsMap,userId)
+	order, shipments, err := h.createOrder(cart, productsMap, pricing, allocations, userId)
+	var payment *types.Payment
+	if err == nil {
+		payment, err = h.paymentProcessor.Pay(order, cart.PaymentMethod)
+	}
+	// the order stays pending while the customer confirms the payment.
+	if errors.Is(err, types.ErrPaymentRequiresAction) {
+		utils.WriteJSON(w, http.StatusAccepted, map[string]any{
+			"order":     order,
+			"shipments": shipments,
+			"pricing":   pricing,
+			"payment":   payment,
+		})
+		return
+	}
+	if errors.Is(err, types.ErrPaymentDeclined) {
+		utils.WriteError(w, http.StatusPaymentRequired, err)
+		return
+	}
+	if errors.Is(err, types.ErrPaymentUnavailable) {
+		utils.WriteError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if errors.Is(err, types.ErrInsufficientStock) || errors.Is(err, types.ErrReservationExpired) || errors.Is(err, types.ErrCouponNotApplicable) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
 	if err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusCreated, map[string]any{
-		"order":order,
+		"order":     order,
+		"shipments": shipments,
+		"pricing":   pricing,
+		"payment":   payment,
 	})
 }
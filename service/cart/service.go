@@ -2,7 +2,15 @@ package cart
 
 import (
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/coupon"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/outbox"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/shipping"
 	"github.com/mohammadahmadkhader/golang-ecommerce/types"
 )
 
@@ -21,15 +29,214 @@ func (h *Handler) getCartItemsIds(cart types.CartCheckoutItems) ([]int, error) {
 	return productsIds, nil
 }
 
-func (h *Handler) calculateTotalPrice(cartItems []types.CartCheckoutItem, productsMap map[int]types.Product) float64 {
-	var totalPrice float64 = 0.0
+// a product given on several lines is checked, reserved and priced as a single line,
+// otherwise every line would pass the availability check on its own while together they exceed it.
+func mergeCartItems(cartItems []types.CartCheckoutItem) []types.CartCheckoutItem {
+	merged := make([]types.CartCheckoutItem, 0, len(cartItems))
+	mergedIndex := make(map[int]int)
+	for _, cartItem := range cartItems {
+		index, ok := mergedIndex[cartItem.ProductID]
+		if !ok {
+			mergedIndex[cartItem.ProductID] = len(merged)
+			merged = append(merged, cartItem)
+			continue
+		}
+
+		merged[index].Quantity += cartItem.Quantity
+	}
+
+	return merged
+}
 
+// checkCartItems merges the lines of the same product and checks that the products exist, the stock is
+// only checked at checkout.
+func (h *Handler) checkCartItems(cartItems []types.CartItem) ([]types.CartItem, error) {
+	if len(cartItems) == 0 {
+		return cartItems, nil
+	}
+
+	checkoutItems := make([]types.CartCheckoutItem, 0, len(cartItems))
+	productsIds := make([]int, 0, len(cartItems))
 	for _, cartItem := range cartItems {
+		checkoutItems = append(checkoutItems, types.CartCheckoutItem(cartItem))
+		productsIds = append(productsIds, cartItem.ProductID)
+	}
+
+	products, err := h.productStore.GetProductsByID(productsIds)
+	if err != nil {
+		return nil, err
+	}
+	productsMap := h.createProductsMap(products)
+
+	merged := make([]types.CartItem, 0, len(checkoutItems))
+	for _, checkoutItem := range mergeCartItems(checkoutItems) {
+		if _, ok := productsMap[checkoutItem.ProductID]; !ok {
+			return nil, fmt.Errorf("product with %v id does not exist", checkoutItem.ProductID)
+		}
+
+		merged = append(merged, types.CartItem(checkoutItem))
+	}
+
+	return merged, nil
+}
+
+// priceCart runs the pricing pipeline: every line is priced at unit price × quantity, the promotions
+// then the coupons are taken off what's left, then the shipping and the tax are added.
+// The goods never go below zero.
+func (h *Handler) priceCart(cart types.CartCheckoutItems, productsMap map[int]types.Product, promotions []types.Promotion,
+	coupons []types.Coupon, now time.Time) (types.PriceBreakdown, error) {
+	breakdown := types.PriceBreakdown{
+		Lines:         make([]types.PriceLine, 0, len(cart.CartItems)),
+		Subtotal:      money.Zero(),
+		DiscountTotal: money.Zero(),
+	}
+
+	for _, cartItem := range cart.CartItems {
 		product := productsMap[cartItem.ProductID]
-		totalPrice += product.Price
+		subtotal := product.Price.Mul(int64(cartItem.Quantity))
+		line := types.PriceLine{
+			ProductID:   cartItem.ProductID,
+			CategoryID:  product.CategoryID,
+			Quantity:    cartItem.Quantity,
+			UnitPrice:   product.Price,
+			Subtotal:    subtotal,
+			Discount:    money.Zero(),
+			Total:       subtotal,
+			Adjustments: make([]types.LineAdjustment, 0),
+			Taxes:       make([]types.TaxLine, 0),
+		}
+
+		breakdown.Lines = append(breakdown.Lines, line)
+		breakdown.Subtotal = breakdown.Subtotal.Add(line.Subtotal)
+	}
+
+	breakdown.Promotions = applyPromotions(promotions, breakdown.Lines, now)
+	for _, promotion := range breakdown.Promotions {
+		breakdown.DiscountTotal = breakdown.DiscountTotal.Add(promotion.Discount)
+	}
+
+	applied, err := coupon.Apply(coupons, breakdown.Lines, now)
+	if err != nil {
+		return types.PriceBreakdown{}, err
+	}
+
+	for _, appliedCoupon := range applied {
+		breakdown.DiscountTotal = breakdown.DiscountTotal.Add(appliedCoupon.Discount)
+		breakdown.FreeShipping = breakdown.FreeShipping || appliedCoupon.FreeShipping
+	}
+	breakdown.Coupons = applied
+	coupon.Spread(applied, coupons, breakdown.Lines)
+
+	goods := money.Max(money.Zero(), breakdown.Subtotal.Sub(breakdown.DiscountTotal))
+	if err := h.shipCart(&breakdown, cart, productsMap, goods); err != nil {
+		return types.PriceBreakdown{}, err
+	}
+
+	if err := h.taxCart(&breakdown, productsMap, cart.ShippingAddress); err != nil {
+		return types.PriceBreakdown{}, err
 	}
 
-	return totalPrice
+	breakdown.Total = goods.Add(breakdown.Shipping)
+	if !breakdown.TaxInclusive {
+		breakdown.Total = breakdown.Total.Add(breakdown.Tax)
+	}
+
+	return breakdown, nil
+}
+
+// taxCart asks the tax provider for the taxes of every line on what's paid for it, once its share of the coupon
+// discounts is taken off. The taxes are kept on their lines and summed up per rate.
+func (h *Handler) taxCart(breakdown *types.PriceBreakdown, productsMap map[int]types.Product,
+	address *types.ShippingAddress) error {
+	breakdown.TaxInclusive = h.pricesIncludeTax
+	breakdown.Taxes = make([]types.TaxSummary, 0)
+	breakdown.Tax = money.Zero()
+	if h.taxProvider == nil {
+		return nil
+	}
+
+	request := types.TaxRequest{
+		Address:   address,
+		Inclusive: h.pricesIncludeTax,
+		Lines:     make([]types.TaxableLine, 0, len(breakdown.Lines)),
+	}
+	for _, line := range breakdown.Lines {
+		request.Lines = append(request.Lines, types.TaxableLine{
+			ProductID: line.ProductID,
+			TaxClass:  productsMap[line.ProductID].TaxClass,
+			Amount:    money.Max(money.Zero(), line.Total.Sub(line.CouponDiscount)),
+		})
+	}
+
+	taxes, err := h.taxProvider.Calculate(request)
+	if err != nil {
+		return err
+	}
+
+	// the cart lines are merged by product at checkout, so a product has a single line.
+	linesIndex := make(map[int]int, len(breakdown.Lines))
+	for i := len(breakdown.Lines) - 1; i >= 0; i-- {
+		linesIndex[breakdown.Lines[i].ProductID] = i
+	}
+
+	summaryIndex := make(map[string]int)
+	for _, tax := range taxes {
+		index, ok := linesIndex[tax.ProductID]
+		if !ok {
+			return fmt.Errorf("tax was charged on product %d which isn't in the cart", tax.ProductID)
+		}
+		breakdown.Lines[index].Taxes = append(breakdown.Lines[index].Taxes, tax)
+		breakdown.Tax = breakdown.Tax.Add(tax.Amount)
+
+		key := fmt.Sprintf("%s@%g", tax.Name, tax.Rate)
+		summary, ok := summaryIndex[key]
+		if !ok {
+			summary = len(breakdown.Taxes)
+			summaryIndex[key] = summary
+			breakdown.Taxes = append(breakdown.Taxes, types.TaxSummary{Name: tax.Name, Rate: tax.Rate, Taxable: money.Zero(), Amount: money.Zero()})
+		}
+		breakdown.Taxes[summary].Taxable = breakdown.Taxes[summary].Taxable.Add(tax.Taxable)
+		breakdown.Taxes[summary].Amount = breakdown.Taxes[summary].Amount.Add(tax.Amount)
+	}
+
+	return nil
+}
+
+// quotes the shipping method chosen for the cart, a free shipping coupon waives its cost.
+// The free shipping thresholds of the methods are checked against the discounted goods.
+func (h *Handler) shipCart(breakdown *types.PriceBreakdown, cart types.CartCheckoutItems, productsMap map[int]types.Product,
+	goods money.Money) error {
+	breakdown.Shipping = money.Zero()
+	if h.shippingQuoter == nil || cart.ShippingAddress == nil {
+		return nil
+	}
+
+	parcel := shipping.NewParcel(cart.CartItems, productsMap, goods)
+	rate, err := h.shippingQuoter.QuoteMethod(cart.ShippingMethodID, *cart.ShippingAddress, parcel)
+	if err != nil {
+		return err
+	}
+
+	if breakdown.FreeShipping {
+		rate.Cost = money.Zero()
+	}
+	breakdown.ShippingRate = &rate
+	breakdown.Shipping = rate.Cost
+
+	return nil
+}
+
+// looks up the coupons of the cart, a code given twice is only applied once.
+func (h *Handler) getCartCoupons(codes []string) ([]types.Coupon, error) {
+	uniqueCodes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if !slices.Contains(uniqueCodes, code) {
+			uniqueCodes = append(uniqueCodes, code)
+		}
+	}
+
+	return h.couponStore.GetCouponsByCodes(uniqueCodes)
 }
 
 func (h *Handler) checkProductsAvailability(cartItems []types.CartCheckoutItem, productsMap map[int]types.Product) error {
@@ -39,8 +246,8 @@ func (h *Handler) checkProductsAvailability(cartItems []types.CartCheckoutItem,
 			return fmt.Errorf("product with %v id does not exist", cartItem.ProductID)
 		}
 
-		if product.Quantity < cartItem.Quantity {
-			return fmt.Errorf("you are requesting %v which is more than the available (%v)", cartItem.Quantity, product.Quantity)
+		if product.Available < cartItem.Quantity {
+			return fmt.Errorf("you are requesting %v which is more than the available (%v)", cartItem.Quantity, product.Available)
 		}
 	}
 
@@ -56,36 +263,140 @@ func (h *Handler) createProductsMap(products []types.Product) map[int]types.Prod
 	return productsMap
 }
 
-func (h *Handler) createOrder(cartItems []types.CartCheckoutItem, productsMap map[int]types.Product, userId int) (*types.Order, error) {
-	totalPrice := h.calculateTotalPrice(cartItems, productsMap)
+// splits the cart lines between the warehouses using the configured allocation strategy.
+func (h *Handler) allocateStock(cart types.CartCheckoutItems, productsIds []int) ([]types.StockAllocation, error) {
+	warehouses, err := h.warehouseStore.GetWarehouses()
+	if err != nil {
+		return nil, err
+	}
 
-	for _, cartItem := range cartItems {
-		product := productsMap[cartItem.ProductID]
-		updatePayload := types.ProductUpdatePayload{
-			Quantity: product.Quantity - cartItem.Quantity,
+	stock, err := h.warehouseStore.GetProductsStockLevels(productsIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.allocator.Allocate(cart.CartItems, warehouses, stock, cart.ShippingAddress)
+}
+
+// the order, its items, the coupon redemptions, the stock reservations, a shipment per warehouse and the order placed
+// event are written in one transaction, so a failure on any line leaves neither a partial order nor units held for
+// nothing. The cart is only converted once the order is completed.
+func (h *Handler) createOrder(cart types.CartCheckoutItems, productsMap map[int]types.Product, pricing types.PriceBreakdown,
+	allocations []types.StockAllocation, userId int) (*types.Order, []types.Shipment, error) {
+	var order types.Order
+	shipments := make([]types.Shipment, 0)
+	err := myDB.InTx(h.db, func(tx myDB.DBTX) error {
+		orderStore := h.orderStore.WithTx(tx)
+
+		var err error
+		newOrder := types.Order{
+			UserID:        userId,
+			Total:         pricing.Total,
+			Status:        types.OrderStatusPending,
+			Address:       formatShippingAddress(cart.ShippingAddress),
+			Subtotal:      pricing.Subtotal,
+			DiscountTotal: pricing.DiscountTotal,
+			ShippingTotal: pricing.Shipping,
+			TaxTotal:      pricing.Tax,
+			Pricing:       &pricing,
+		}
+		if pricing.ShippingRate != nil {
+			newOrder.ShippingMethodID = &pricing.ShippingRate.MethodID
+			newOrder.ShippingMethod = pricing.ShippingRate.Name
 		}
 
-		h.productStore.UpdateProduct(product.ID, updatePayload)
-	}
-	
-	order, err := h.orderStore.CreateOrder(types.Order{
-		UserID: userId,
-		Total:  totalPrice,
-		Status: "pending",
-		Address: "address",
+		order, err = orderStore.CreateOrder(newOrder)
+		if err != nil {
+			return err
+		}
+
+		orderItems := make([]types.OrderItem, 0, len(cart.CartItems))
+		for i, cartItem := range cart.CartItems {
+			orderItem, err := orderStore.CreateOrderItem(types.OrderItem{
+				OrderID: order.ID,
+				ProductID: cartItem.ProductID,
+				Quantity: cartItem.Quantity,
+				Price: productsMap[cartItem.ProductID].Price,
+			})
+			if err != nil {
+				return err
+			}
+
+			// the pricing lines follow the cart items.
+			if err := orderStore.CreateOrderItemTaxes(orderItem.ID, pricing.Lines[i].Taxes); err != nil {
+				return err
+			}
+
+			orderItems = append(orderItems, orderItem)
+		}
+
+		if len(pricing.Coupons) > 0 {
+			if err := h.couponStore.WithTx(tx).RedeemCoupons(order.ID, userId, pricing.Coupons); err != nil {
+				return err
+			}
+		}
+
+		_, err = h.inventoryStore.WithTx(tx).ReserveStock(order.ID, userId, allocations, h.reservationTTL)
+		if err != nil {
+			return err
+		}
+
+		for _, shipment := range groupShipments(order.ID, allocations) {
+			created, err := orderStore.CreateShipment(shipment)
+			if err != nil {
+				return err
+			}
+
+			shipments = append(shipments, *created)
+		}
+
+		return outbox.Record(tx, types.AggregateOrder, order.ID, types.EventOrderPlaced, types.OrderPlacedEvent{Order: order, Items: orderItems})
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	for _, cartItem := range cartItems {
-		h.orderStore.CreateOrderItem(types.OrderItem{
-			OrderID: order.ID,
-			ProductID: cartItem.ProductID,
-			Quantity: cartItem.Quantity,
-			Price: productsMap[cartItem.ProductID].Price,
+	return &order, shipments, nil
+}
+
+// an order split across warehouses gets one shipment per warehouse, in the order they were allocated.
+func groupShipments(orderID int, allocations []types.StockAllocation) []types.Shipment {
+	shipments := make([]types.Shipment, 0)
+	shipmentsIndex := make(map[int]int)
+	for _, allocation := range allocations {
+		index, ok := shipmentsIndex[allocation.WarehouseID]
+		if !ok {
+			index = len(shipments)
+			shipmentsIndex[allocation.WarehouseID] = index
+			shipments = append(shipments, types.Shipment{
+				OrderID:     orderID,
+				WarehouseID: allocation.WarehouseID,
+				Status:      types.ShipmentStatusPending,
+			})
+		}
+
+		shipments[index].Items = append(shipments[index].Items, types.ShipmentItem{
+			ProductID: allocation.ProductID,
+			Quantity:  allocation.Quantity,
 		})
 	}
 
-	return &order, nil
+	return shipments
+}
+
+func formatShippingAddress(address *types.ShippingAddress) string {
+	if address == nil {
+		return "address"
+	}
+
+	parts := []string{address.Line1, address.City}
+	if address.Region != "" {
+		parts = append(parts, address.Region)
+	}
+	if address.PostalCode != "" {
+		parts = append(parts, address.PostalCode)
+	}
+	parts = append(parts, strings.ToUpper(address.Country))
+
+	return strings.Join(parts, ", ")
 }
@@ -0,0 +1,373 @@
+package cart
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/tax"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestPriceCart(t *testing.T) {
+	now := time.Date(2024, 10, 28, 12, 0, 0, 0, time.UTC)
+	cents := money.FromMinor
+	productsMap := map[int]types.Product{
+		1: {ID: 1, Price: cents(1999), TaxClass: types.TaxClassStandard},
+		2: {ID: 2, Price: cents(500), TaxClass: "reduced"},
+	}
+
+	address := &types.ShippingAddress{Line1: "1 High Street", City: "London", Country: "GB"}
+	taxProvider := tax.NewTableProvider(&mockTaxRateStore{rates: []types.TaxRate{
+		{Country: "GB", TaxClass: types.TaxClassStandard, Name: "VAT", Rate: 10},
+		{Country: "GB", TaxClass: "reduced", Name: "VAT", Rate: 5},
+	}})
+
+	flatShipping := &mockShippingQuoter{cost: cents(700)}
+
+	cases := []struct {
+		name       string
+		handler    *Handler
+		cartItems  []types.CartCheckoutItem
+		promotions []types.Promotion
+		coupons    []types.Coupon
+		lines      []int64
+		subtotal   int64
+		discount   int64
+		shipping   int64
+		tax        int64
+		total      int64
+	}{
+		{
+			name:      "Should charge every unit of a line",
+			handler:   &Handler{},
+			cartItems: []types.CartCheckoutItem{{ProductID: 1, Quantity: 5}, {ProductID: 2, Quantity: 1}},
+			lines:     []int64{9995, 500},
+			subtotal:  10495,
+			total:     10495,
+		},
+		{
+			name:      "Should price every line of a duplicated product",
+			handler:   &Handler{},
+			cartItems: []types.CartCheckoutItem{{ProductID: 2, Quantity: 2}, {ProductID: 2, Quantity: 3}},
+			lines:     []int64{1000, 1500},
+			subtotal:  2500,
+			total:     2500,
+		},
+		{
+			name:      "Should price a merged duplicated product like the lines it came from",
+			handler:   &Handler{},
+			cartItems: mergeCartItems([]types.CartCheckoutItem{{ProductID: 2, Quantity: 2}, {ProductID: 2, Quantity: 3}}),
+			lines:     []int64{2500},
+			subtotal:  2500,
+			total:     2500,
+		},
+		{
+			name:      "Should add the shipping and the tax on the discounted goods",
+			handler:   &Handler{shippingQuoter: flatShipping, taxProvider: taxProvider},
+			cartItems: []types.CartCheckoutItem{{ProductID: 1, Quantity: 2}},
+			promotions: []types.Promotion{{ID: 1, Type: types.PromotionTypeOrderPercentage, Active: true,
+				Rules: types.PromotionRules{Percentage: 25}}},
+			lines:    []int64{3998},
+			subtotal: 3998,
+			discount: 999,
+			shipping: 700,
+			tax:      300,
+			total:    3999,
+		},
+		{
+			name:      "Should waive the shipping with a free shipping coupon",
+			handler:   &Handler{shippingQuoter: flatShipping},
+			cartItems: []types.CartCheckoutItem{{ProductID: 2, Quantity: 4}},
+			coupons:   []types.Coupon{{ID: 1, Code: "FREESHIP", Type: types.CouponTypeFreeShipping, Active: true}},
+			lines:     []int64{2000},
+			subtotal:  2000,
+			total:     2000,
+		},
+		{
+			name:      "Should charge the shipping even when the coupons cover the goods",
+			handler:   &Handler{shippingQuoter: flatShipping, taxProvider: taxProvider},
+			cartItems: []types.CartCheckoutItem{{ProductID: 2, Quantity: 1}},
+			coupons:   []types.Coupon{{ID: 1, Code: "TENOFF", Type: types.CouponTypeFixed, Amount: cents(1000), Active: true}},
+			lines:     []int64{500},
+			subtotal:  500,
+			discount:  500,
+			shipping:  700,
+			total:     700,
+		},
+		{
+			name:      "Should tax every product at the rate of its class",
+			handler:   &Handler{taxProvider: taxProvider},
+			cartItems: []types.CartCheckoutItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 2}},
+			lines:     []int64{1999, 1000},
+			subtotal:  2999,
+			tax:       250,
+			total:     3249,
+		},
+		{
+			name:      "Should tax the lines on what's left after a product coupon",
+			handler:   &Handler{taxProvider: taxProvider},
+			cartItems: []types.CartCheckoutItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 2}},
+			coupons: []types.Coupon{{ID: 1, Code: "FIVEOFF", Type: types.CouponTypeFixed, Amount: cents(500), Active: true,
+				ProductIDs: []int{2}}},
+			lines:    []int64{1999, 1000},
+			subtotal: 2999,
+			discount: 500,
+			tax:      225,
+			total:    2724,
+		},
+		{
+			name:      "Should spread a cart coupon over the lines",
+			handler:   &Handler{taxProvider: taxProvider},
+			cartItems: []types.CartCheckoutItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 2}},
+			coupons:   []types.Coupon{{ID: 1, Code: "TENPERCENT", Type: types.CouponTypePercentage, Percentage: 10, Active: true}},
+			lines:     []int64{1999, 1000},
+			subtotal:  2999,
+			discount:  299,
+			tax:       225,
+			total:     2925,
+		},
+		{
+			name:      "Should not add the tax again when the prices include it",
+			handler:   &Handler{taxProvider: taxProvider, pricesIncludeTax: true},
+			cartItems: []types.CartCheckoutItem{{ProductID: 1, Quantity: 1}},
+			lines:     []int64{1999},
+			subtotal:  1999,
+			tax:       182,
+			total:     1999,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			breakdown, err := c.handler.priceCart(types.CartCheckoutItems{CartItems: c.cartItems, ShippingAddress: address, ShippingMethodID: 1},
+				productsMap, c.promotions, c.coupons, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(breakdown.Lines) != len(c.lines) {
+				t.Fatalf("expected %d lines got %d", len(c.lines), len(breakdown.Lines))
+			}
+			for i, subtotal := range c.lines {
+				if breakdown.Lines[i].Subtotal.Amount != subtotal {
+					t.Errorf("expected line %d subtotal to be %d got %s", i, subtotal, breakdown.Lines[i].Subtotal)
+				}
+			}
+
+			amounts := []struct {
+				name     string
+				expected int64
+				got      money.Money
+			}{
+				{"subtotal", c.subtotal, breakdown.Subtotal},
+				{"discount", c.discount, breakdown.DiscountTotal},
+				{"shipping", c.shipping, breakdown.Shipping},
+				{"tax", c.tax, breakdown.Tax},
+				{"total", c.total, breakdown.Total},
+			}
+			for _, amount := range amounts {
+				if amount.got.Amount != amount.expected {
+					t.Errorf("expected a %s of %d got %s", amount.name, amount.expected, amount.got)
+				}
+			}
+
+			// what's paid for the lines is what the returns refund, with the shipping it's the total.
+			paid := breakdown.Shipping
+			for _, line := range breakdown.Lines {
+				paid = paid.Add(line.Total.Sub(line.CouponDiscount))
+				for _, tax := range line.Taxes {
+					if !breakdown.TaxInclusive {
+						paid = paid.Add(tax.Amount)
+					}
+				}
+			}
+			if paid != breakdown.Total {
+				t.Errorf("expected the lines and the shipping to add up to the total %s got %s", breakdown.Total, paid)
+			}
+		})
+	}
+}
+
+func TestMergeCartItems(t *testing.T) {
+	merged := mergeCartItems([]types.CartCheckoutItem{
+		{ProductID: 3, Quantity: 1},
+		{ProductID: 1, Quantity: 2},
+		{ProductID: 3, Quantity: 4},
+	})
+
+	expected := []types.CartCheckoutItem{{ProductID: 3, Quantity: 5}, {ProductID: 1, Quantity: 2}}
+	if len(merged) != len(expected) {
+		t.Fatalf("expected %d lines got %d", len(expected), len(merged))
+	}
+	for i := range expected {
+		if merged[i] != expected[i] {
+			t.Errorf("expected line %d to be %+v got %+v", i, expected[i], merged[i])
+		}
+	}
+}
+
+func TestPriceCartIsExactForLargeCarts(t *testing.T) {
+	h := &Handler{}
+
+	// 0.1 and 0.2 have no exact float representation, 10000 lines of them drift when summed as floats.
+	productsMap := map[int]types.Product{}
+	cartItems := make([]types.CartCheckoutItem, 0, 10000)
+	for i := 1; i <= 10000; i++ {
+		productsMap[i] = types.Product{ID: i, Price: money.FromMinor(10 * int64(i%2+1))}
+		cartItems = append(cartItems, types.CartCheckoutItem{ProductID: i, Quantity: 3})
+	}
+
+	promotions := []types.Promotion{{ID: 1, Type: types.PromotionTypeOrderPercentage, Active: true,
+		Rules: types.PromotionRules{Percentage: 33.33}}}
+
+	breakdown, err := h.priceCart(types.CartCheckoutItems{CartItems: cartItems}, productsMap, promotions, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 5000 lines of 0.60 and 5000 lines of 0.30.
+	if breakdown.Subtotal.String() != "4500.00" {
+		t.Errorf("expected a subtotal of 4500.00 got %s", breakdown.Subtotal)
+	}
+
+	// 33.33% of 0.60 is 0.19998 and of 0.30 is 0.09999, both are rounded down.
+	if breakdown.DiscountTotal.String() != "1400.00" {
+		t.Errorf("expected a discount of 1400.00 got %s", breakdown.DiscountTotal)
+	}
+	if breakdown.Total.String() != "3100.00" {
+		t.Errorf("expected a total of 3100.00 got %s", breakdown.Total)
+	}
+
+	lines := money.Zero()
+	for _, line := range breakdown.Lines {
+		lines = lines.Add(line.Total)
+	}
+	if lines != breakdown.Total {
+		t.Errorf("expected the lines to add up to the total %s got %s", breakdown.Total, lines)
+	}
+}
+
+func TestCheckCartItems(t *testing.T) {
+	deletedAt := time.Now()
+	h := &Handler{productStore: &mockProductStore{products: []types.Product{{ID: 1}, {ID: 2}, {ID: 3, DeletedAt: &deletedAt}}}}
+
+	t.Run("Should merge the lines of the same product", func(t *testing.T) {
+		items, err := h.checkCartItems([]types.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 2}, {ProductID: 1, Quantity: 3}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []types.CartItem{{ProductID: 1, Quantity: 4}, {ProductID: 2, Quantity: 2}}
+		if len(items) != len(expected) || items[0] != expected[0] || items[1] != expected[1] {
+			t.Errorf("expected %+v got %+v", expected, items)
+		}
+	})
+
+	t.Run("Should refuse a product that doesn't exist", func(t *testing.T) {
+		if _, err := h.checkCartItems([]types.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 9, Quantity: 1}}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("Should refuse a deleted product", func(t *testing.T) {
+		if _, err := h.checkCartItems([]types.CartItem{{ProductID: 3, Quantity: 1}}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestCheckoutRefusesDeletedProducts(t *testing.T) {
+	deletedAt := time.Now()
+	router := mux.NewRouter()
+	handler := &Handler{productStore: &mockProductStore{products: []types.Product{
+		{ID: 1, Price: money.FromMinor(1000), Quantity: 5, Available: 5},
+		{ID: 2, Price: money.FromMinor(1000), Quantity: 5, Available: 5, DeletedAt: &deletedAt},
+	}}}
+	handler.RegisterRoutes(router)
+
+	recorder := checkout(t, router, `{"cartItems":[{"productId":1,"quantity":1},{"productId":2,"quantity":1}],"shippingMethodId":1,"paymentMethod":"card",
+	"shippingAddress":{"line1":"1 Main St","city":"Amman","country":"JO"}}`)
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "product with 2 id does not exist") {
+		t.Errorf("expected the deleted product to be refused got %d: %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestCheckoutRefusesAnEmptyCart(t *testing.T) {
+	router := mux.NewRouter()
+	handler := &Handler{productStore: &mockProductStore{}}
+	handler.RegisterRoutes(router)
+
+	recorder := checkout(t, router, `{"cartItems":[],"shippingMethodId":1,"paymentMethod":"card",
+	"shippingAddress":{"line1":"1 Main St","city":"Amman","country":"JO"}}`)
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "CartItems") {
+		t.Errorf("expected the empty cart to be refused got %d: %s", recorder.Code, recorder.Body)
+	}
+}
+
+func checkout(t *testing.T, router *mux.Router, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodPost, "/cart/checkout", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), types.User{ID: 10, Role: types.UserRoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", token)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+type mockProductStore struct {
+	types.ProductStore
+	products []types.Product
+}
+
+func (m *mockProductStore) GetProductsByID(productIDs []int) ([]types.Product, error) {
+	// like the store, the deleted products are left out.
+	products := make([]types.Product, 0)
+	for _, product := range m.products {
+		if slices.Contains(productIDs, product.ID) && product.DeletedAt == nil {
+			products = append(products, product)
+		}
+	}
+
+	return products, nil
+}
+
+type mockTaxRateStore struct {
+	types.TaxRateStore
+	rates []types.TaxRate
+}
+
+func (m *mockTaxRateStore) GetTaxRatesFor(country, region string) ([]types.TaxRate, error) {
+	rates := make([]types.TaxRate, 0)
+	for _, rate := range m.rates {
+		if rate.Country == country && (rate.Region == "" || rate.Region == region) {
+			rates = append(rates, rate)
+		}
+	}
+
+	return rates, nil
+}
+
+type mockShippingQuoter struct {
+	types.ShippingQuoter
+	cost money.Money
+}
+
+func (m *mockShippingQuoter) QuoteMethod(methodID int, address types.ShippingAddress, parcel types.Parcel) (types.ShippingRate, error) {
+	return types.ShippingRate{MethodID: methodID, Name: "Standard", Type: types.ShippingMethodFlatRate, Cost: m.cost}, nil
+}
@@ -1 +1,314 @@
-package cart
\ No newline at end of file
+package cart
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// returns a store that runs its queries inside the given transaction.
+func (s *Store) WithTx(tx myDB.DBTX) types.CartStore {
+	return &Store{
+		db: tx,
+	}
+}
+
+func (s *Store) GetCart(userID int) (*types.Cart, error) {
+	cart := &types.Cart{UserID: userID, Items: make([]types.CartItem, 0)}
+	err := s.db.QueryRow("SELECT id, lastActivityAt FROM carts WHERE userId = ?", userID).Scan(&cart.ID, &cart.LastActivityAt)
+	if err == sql.ErrNoRows {
+		return cart, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cart.Items, err = s.getCartItems(cart.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+func (s *Store) SaveCart(userID int, items []types.CartItem) (*types.Cart, error) {
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		_, err := tx.Exec(`
+		INSERT INTO carts (userId) VALUES (?)
+		ON DUPLICATE KEY UPDATE lastActivityAt = CURRENT_TIMESTAMP`, userID)
+		if err != nil {
+			return err
+		}
+
+		var cartId int
+		if err := tx.QueryRow("SELECT id FROM carts WHERE userId = ? FOR UPDATE", userID).Scan(&cartId); err != nil {
+			return err
+		}
+
+		return setCartItems(tx, cartId, items)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCart(userID)
+}
+
+func (s *Store) ConvertCart(userID, orderID int, remindedSince time.Time) error {
+	return myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		// an order is converted once however many times its completion is published.
+		result, err := tx.Exec("INSERT IGNORE INTO cartConversions (orderId, userId) VALUES (?,?)", orderID, userID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		// only what was ordered leaves the cart, the items the user saved besides stay.
+		_, err = tx.Exec(`
+		UPDATE cartItems ci
+		JOIN carts c ON c.id = ci.cartId
+		JOIN (SELECT productId, SUM(quantity) AS quantity FROM orderItems WHERE orderId = ? GROUP BY productId) oi
+		ON oi.productId = ci.productId
+		SET ci.quantity = GREATEST(CAST(ci.quantity AS SIGNED) - oi.quantity, 0)
+		WHERE c.userId = ?`, orderID, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("DELETE ci FROM cartItems ci JOIN carts c ON c.id = ci.cartId WHERE c.userId = ? AND ci.quantity = 0", userID)
+		if err != nil {
+			return err
+		}
+
+		// the order goes to the last reminder the user was sent.
+		_, err = tx.Exec(`
+		UPDATE cartReminders SET orderId = ?, convertedAt = CURRENT_TIMESTAMP
+		WHERE userId = ? AND orderId IS NULL AND sentAt >= ?
+		ORDER BY sentAt DESC, id DESC
+		LIMIT 1`, orderID, userID, remindedSince)
+		return err
+	})
+}
+
+func (s *Store) GetAbandonedCarts(step int, idleSince, remindedBefore time.Time, limit int) ([]types.Cart, error) {
+	rows, err := s.db.Query(`
+	SELECT c.id, c.userId, c.lastActivityAt FROM carts c
+	LEFT JOIN notificationPreferences np ON np.userId = c.userId
+	WHERE c.lastActivityAt <= ? AND COALESCE(np.marketing, TRUE)
+	AND EXISTS (SELECT 1 FROM cartItems ci WHERE ci.cartId = c.id)
+	AND NOT EXISTS (SELECT 1 FROM orders o WHERE o.userId = c.userId AND o.createdAt >= c.lastActivityAt)
+	AND (SELECT COUNT(*) FROM cartReminders cr WHERE cr.cartId = c.id AND cr.activityAt = c.lastActivityAt) = ?
+	AND NOT EXISTS (
+		SELECT 1 FROM cartReminders cr WHERE cr.cartId = c.id AND cr.activityAt = c.lastActivityAt AND cr.sentAt > ?
+	)
+	ORDER BY c.lastActivityAt, c.id
+	LIMIT ?`, idleSince, step-1, remindedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	carts := make([]types.Cart, 0)
+	for rows.Next() {
+		var cart types.Cart
+		if err := rows.Scan(&cart.ID, &cart.UserID, &cart.LastActivityAt); err != nil {
+			return nil, err
+		}
+
+		carts = append(carts, cart)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range carts {
+		if carts[i].Items, err = s.getCartItems(carts[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return carts, nil
+}
+
+func (s *Store) CreateCartReminder(reminder types.CartReminder) (*types.CartReminder, bool, error) {
+	items, err := json.Marshal(reminder.Items)
+	if err != nil {
+		return nil, false, err
+	}
+
+	result, err := s.db.Exec(`
+	INSERT IGNORE INTO cartReminders (cartId, userId, activityAt, step, items, couponCode) VALUES (?,?,?,?,?,?)`,
+		reminder.CartID, reminder.UserID, reminder.ActivityAt, reminder.Step, items, reminder.CouponCode)
+	if err != nil {
+		return nil, false, err
+	}
+
+	created, err := result.RowsAffected()
+	if err != nil || created == 0 {
+		return nil, false, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, false, err
+	}
+
+	createdReminder, err := s.GetCartReminderById(int(id))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return createdReminder, true, nil
+}
+
+func (s *Store) GetCartReminderById(id int) (*types.CartReminder, error) {
+	reminder := new(types.CartReminder)
+	var items []byte
+	err := s.db.QueryRow(`
+	SELECT id, cartId, userId, activityAt, step, items, couponCode, clickedAt, orderId, convertedAt, sentAt
+	FROM cartReminders WHERE id = ?`, id).
+		Scan(&reminder.ID, &reminder.CartID, &reminder.UserID, &reminder.ActivityAt, &reminder.Step, &items,
+			&reminder.CouponCode, &reminder.ClickedAt, &reminder.OrderID, &reminder.ConvertedAt, &reminder.SentAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("cart reminder with id %v was not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(items, &reminder.Items); err != nil {
+		return nil, err
+	}
+
+	return reminder, nil
+}
+
+// MarkCartReminderClicked records the first click on the restore link of the reminder, the returned bool
+// reports whether this click was the first.
+func (s *Store) MarkCartReminderClicked(id int) (bool, error) {
+	result, err := s.db.Exec("UPDATE cartReminders SET clickedAt = CURRENT_TIMESTAMP WHERE id = ? AND clickedAt IS NULL", id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (s *Store) GetCartRecoveryReport(from, to time.Time) (*types.CartRecoveryReport, error) {
+	report := &types.CartRecoveryReport{From: from, To: to, Steps: make([]types.CartRecoveryStep, 0)}
+
+	// a reminder only counts as converted while its order isn't cancelled, in every figure of the report.
+	rows, err := s.db.Query(`
+	SELECT cr.step, COUNT(*), COUNT(cr.clickedAt), COUNT(o.id) FROM cartReminders cr
+	LEFT JOIN orders o ON o.id = cr.orderId AND o.status <> ?
+	WHERE cr.sentAt >= ? AND cr.sentAt < ?
+	GROUP BY cr.step ORDER BY cr.step`, types.OrderStatusCancelled, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var step types.CartRecoveryStep
+		if err := rows.Scan(&step.Step, &step.Sent, &step.Clicked, &step.Converted); err != nil {
+			return nil, err
+		}
+
+		report.Steps = append(report.Steps, step)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// a cart is abandoned again every time it changes, each abandonment counts once however many reminders it got.
+	err = s.db.QueryRow(`
+	SELECT COUNT(DISTINCT cr.cartId, cr.activityAt), COUNT(DISTINCT CASE WHEN o.id IS NOT NULL THEN CONCAT(cr.cartId, '-', cr.activityAt) END)
+	FROM cartReminders cr
+	LEFT JOIN orders o ON o.id = cr.orderId AND o.status <> ?
+	WHERE cr.sentAt >= ? AND cr.sentAt < ?`, types.OrderStatusCancelled, from, to).Scan(&report.CartsReminded, &report.CartsRecovered)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRow(`
+	SELECT COALESCE(SUM(o.total), 0), COUNT(DISTINCT CASE WHEN cr.couponCode IS NOT NULL AND EXISTS (
+		SELECT 1 FROM couponRedemptions r JOIN coupons cp ON cp.id = r.couponId WHERE r.orderId = o.id AND cp.code = cr.couponCode
+	) THEN o.id END)
+	FROM cartReminders cr
+	JOIN orders o ON o.id = cr.orderId
+	WHERE cr.sentAt >= ? AND cr.sentAt < ? AND o.status <> ?`, from, to, types.OrderStatusCancelled).
+		Scan(&report.RecoveredRevenue, &report.CouponsRedeemed)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.CartsReminded > 0 {
+		report.ConversionRate = float64(report.CartsRecovered) * 100 / float64(report.CartsReminded)
+	}
+
+	return report, nil
+}
+
+func (s *Store) getCartItems(cartID int) ([]types.CartItem, error) {
+	rows, err := s.db.Query("SELECT productId, quantity FROM cartItems WHERE cartId = ? ORDER BY productId", cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	items := make([]types.CartItem, 0)
+	for rows.Next() {
+		var item types.CartItem
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func setCartItems(q myDB.DBTX, cartID int, items []types.CartItem) error {
+	if _, err := q.Exec("DELETE FROM cartItems WHERE cartId = ?", cartID); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, 3*len(items))
+	for _, item := range items {
+		args = append(args, cartID, item.ProductID, item.Quantity)
+	}
+
+	_, err := q.Exec("INSERT INTO cartItems (cartId, productId, quantity) VALUES (?,?,?)"+strings.Repeat(",(?,?,?)", len(items)-1), args...)
+	return err
+}
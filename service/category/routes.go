@@ -0,0 +1,126 @@
+package category
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+type Handler struct {
+	store types.CategoryStore
+}
+
+func NewHandler(store types.CategoryStore) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/categories", h.GetCategories).Methods("GET")
+	router.HandleFunc("/admin/categories", auth.AdminMiddleware(h.CreateCategory)).Methods("POST")
+	router.HandleFunc("/admin/categories/{id}", auth.AdminMiddleware(h.UpdateCategory)).Methods("PUT")
+	router.HandleFunc("/admin/categories/{id}", auth.AdminMiddleware(h.DeleteCategory)).Methods("DELETE")
+}
+
+func (h *Handler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.store.GetCategories()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    categories,
+	})
+}
+
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	payload, err := parseCategoryPayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	created, err := h.store.CreateCategory(payload.Name)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    created,
+	})
+}
+
+func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload, err := parseCategoryPayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := h.store.UpdateCategory(id, payload.Name)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    updated,
+	})
+}
+
+func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DeleteCategory(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusNoContent, map[string]any{})
+}
+
+func parseCategoryPayload(r *http.Request) (types.CategoryPayload, error) {
+	var payload types.CategoryPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return payload, err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return payload, err
+	}
+
+	return payload, nil
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("category id must be unsigned integer")
+	}
+
+	return id, nil
+}
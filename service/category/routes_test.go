@@ -0,0 +1,100 @@
+package category
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestCreateCategory(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"Should create a category", `{"name":"Desks"}`, http.StatusCreated},
+		{"Should refuse a category without a name", `{"name":""}`, http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := &mockCategoryStore{}
+			recorder := send(t, NewHandler(store), http.MethodPost, "/admin/categories", c.body, types.UserRoleAdmin)
+			if recorder.Code != c.status {
+				t.Fatalf("expected status code %d got %d: %s", c.status, recorder.Code, recorder.Body)
+			}
+			if c.status == http.StatusCreated && store.saved != "Desks" {
+				t.Errorf("expected the category to be saved got %q", store.saved)
+			}
+		})
+	}
+}
+
+func TestUpdateCategory(t *testing.T) {
+	t.Run("Should rename the category", func(t *testing.T) {
+		store := &mockCategoryStore{}
+
+		recorder := send(t, NewHandler(store), http.MethodPut, "/admin/categories/3", `{"name":"Chairs"}`, types.UserRoleAdmin)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+		if store.saved != "Chairs" {
+			t.Errorf("expected category 3 to be renamed got %q", store.saved)
+		}
+	})
+
+	t.Run("Should refuse an invalid id", func(t *testing.T) {
+		recorder := send(t, NewHandler(&mockCategoryStore{}), http.MethodPut, "/admin/categories/0", `{"name":"Chairs"}`, types.UserRoleAdmin)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+}
+
+func TestCategoriesAreAdminOnly(t *testing.T) {
+	recorder := send(t, NewHandler(&mockCategoryStore{}), http.MethodPost, "/admin/categories", `{"name":"Desks"}`, types.UserRoleCustomer)
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("expected status code %d got %d", http.StatusForbidden, recorder.Code)
+	}
+}
+
+func send(t *testing.T, handler *Handler, method, path, body, role string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), types.User{ID: 1, Role: role})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", token)
+
+	recorder := httptest.NewRecorder()
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+	router.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+type mockCategoryStore struct {
+	types.CategoryStore
+	saved string
+}
+
+func (m *mockCategoryStore) CreateCategory(name string) (*types.Category, error) {
+	m.saved = name
+	return &types.Category{ID: 1, Name: name}, nil
+}
+
+func (m *mockCategoryStore) UpdateCategory(id int, name string) (*types.Category, error) {
+	m.saved = name
+	return &types.Category{ID: id, Name: name}, nil
+}
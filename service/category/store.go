@@ -0,0 +1,138 @@
+package category
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) GetCategories() ([]types.Category, error) {
+	rows, err := s.db.Query("SELECT * FROM categories ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanCategories(rows)
+}
+
+func (s *Store) GetCategoryById(id int) (*types.Category, error) {
+	rows, err := s.db.Query("SELECT * FROM categories WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := scanCategories(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return nil, fmt.Errorf("no category was found for id %v", id)
+	}
+
+	return &categories[0], nil
+}
+
+func (s *Store) CreateCategory(name string) (*types.Category, error) {
+	if err := s.checkNameIsFree(name, 0); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec("INSERT INTO categories (name) VALUES (?)", strings.TrimSpace(name))
+	if err != nil {
+		return nil, err
+	}
+
+	categoryId, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCategoryById(int(categoryId))
+}
+
+func (s *Store) UpdateCategory(id int, name string) (*types.Category, error) {
+	if err := s.checkNameIsFree(name, id); err != nil {
+		return nil, err
+	}
+
+	// MySQL reports no affected rows when nothing changed, a missing category is caught when it's read back.
+	_, err := s.db.Exec("UPDATE categories SET name = ? WHERE id = ?", strings.TrimSpace(name), id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCategoryById(id)
+}
+
+// the products of the category are left without one, the coupons restricted to it would silently apply
+// to nothing so they must be changed first.
+func (s *Store) DeleteCategory(id int) error {
+	var restricted bool
+	err := s.db.QueryRow("SELECT COUNT(*) > 0 FROM couponCategories WHERE categoryId = ?", id).Scan(&restricted)
+	if err != nil {
+		return err
+	}
+	if restricted {
+		return fmt.Errorf("category with id %v has coupons restricted to it, change them first", id)
+	}
+
+	result, err := s.db.Exec("DELETE FROM categories WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no category was found for id %v", id)
+	}
+
+	return nil
+}
+
+// excludeId is the category being updated, it may keep its own name.
+func (s *Store) checkNameIsFree(name string, excludeId int) error {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM categories WHERE name = ? AND id <> ?", strings.TrimSpace(name), excludeId).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("category with name %s already exists", strings.TrimSpace(name))
+	}
+
+	return nil
+}
+
+func scanCategories(rows *sql.Rows) ([]types.Category, error) {
+	defer rows.Close()
+
+	categories := make([]types.Category, 0)
+	for rows.Next() {
+		category := new(types.Category)
+		if err := rows.Scan(&category.ID, &category.Name, &category.CreatedAt, &category.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		categories = append(categories, *category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
@@ -0,0 +1,129 @@
+package coupon
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// Apply checks the coupons against the cart lines and returns the discount each one gives.
+// Every coupon is computed on the Total of the lines it's eligible for, what's left once the promotions were taken off,
+// then the discounts are capped in order so together they never exceed the cart total.
+// The usage limits are checked when the coupons are redeemed.
+func Apply(coupons []types.Coupon, lines []types.PriceLine, now time.Time) ([]types.AppliedCoupon, error) {
+	subtotal := money.Zero()
+	for _, line := range lines {
+		subtotal = subtotal.Add(line.Total)
+	}
+
+	if len(coupons) > 1 {
+		for _, coupon := range coupons {
+			if !coupon.Stackable {
+				return nil, fmt.Errorf("%w: %s can't be combined with other coupons", types.ErrCouponNotApplicable, coupon.Code)
+			}
+		}
+	}
+
+	applied := make([]types.AppliedCoupon, 0, len(coupons))
+	remaining := subtotal
+	for _, coupon := range coupons {
+		if err := checkCoupon(coupon, subtotal, now); err != nil {
+			return nil, err
+		}
+
+		eligible := eligibleSubtotal(coupon, lines)
+		if eligible.IsZero() {
+			return nil, fmt.Errorf("%w: %s doesn't apply to any product in the cart", types.ErrCouponNotApplicable, coupon.Code)
+		}
+
+		result := types.AppliedCoupon{CouponID: coupon.ID, Code: coupon.Code, Type: coupon.Type, Discount: money.Zero()}
+		switch coupon.Type {
+		case types.CouponTypePercentage:
+			result.Discount = eligible.Percent(coupon.Percentage, money.DiscountRounding)
+		case types.CouponTypeFixed:
+			result.Discount = money.Min(coupon.Amount, eligible)
+		case types.CouponTypeFreeShipping:
+			result.FreeShipping = true
+		default:
+			return nil, fmt.Errorf("unknown coupon type '%s'", coupon.Type)
+		}
+
+		result.Discount = money.Min(result.Discount, remaining)
+		remaining = remaining.Sub(result.Discount)
+		applied = append(applied, result)
+	}
+
+	return applied, nil
+}
+
+func checkCoupon(coupon types.Coupon, subtotal money.Money, now time.Time) error {
+	if !coupon.Active {
+		return fmt.Errorf("%w: %s is not active", types.ErrCouponNotApplicable, coupon.Code)
+	}
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return fmt.Errorf("%w: %s is not valid yet", types.ErrCouponNotApplicable, coupon.Code)
+	}
+	if coupon.EndsAt != nil && !now.Before(*coupon.EndsAt) {
+		return fmt.Errorf("%w: %s has expired", types.ErrCouponNotApplicable, coupon.Code)
+	}
+	if coupon.MinSubtotal != nil && subtotal.Cmp(*coupon.MinSubtotal) < 0 {
+		return fmt.Errorf("%w: %s requires a subtotal of at least %s", types.ErrCouponNotApplicable, coupon.Code, coupon.MinSubtotal)
+	}
+
+	return nil
+}
+
+func eligibleSubtotal(coupon types.Coupon, lines []types.PriceLine) money.Money {
+	eligible := money.Zero()
+	for _, line := range lines {
+		if appliesTo(coupon, line) {
+			eligible = eligible.Add(line.Total)
+		}
+	}
+
+	return eligible
+}
+
+// a coupon restricted to products or categories applies to a line whose product is one of them or is in one
+// of them, a coupon without restrictions applies to every line.
+func appliesTo(coupon types.Coupon, line types.PriceLine) bool {
+	if len(coupon.ProductIDs) == 0 && len(coupon.CategoryIDs) == 0 {
+		return true
+	}
+	if slices.Contains(coupon.ProductIDs, line.ProductID) {
+		return true
+	}
+
+	return line.CategoryID != nil && slices.Contains(coupon.CategoryIDs, *line.CategoryID)
+}
+
+// Spread puts the discount of every applied coupon on the lines it applies to, in proportion to what's left
+// to pay for each line, and keeps each line's share in its CouponDiscount. The tax of a line and what's
+// refunded for it are worked out on what was paid for it once the coupons are taken off.
+func Spread(applied []types.AppliedCoupon, coupons []types.Coupon, lines []types.PriceLine) {
+	couponsByID := make(map[int]types.Coupon, len(coupons))
+	for _, coupon := range coupons {
+		couponsByID[coupon.ID] = coupon
+	}
+
+	for i := range lines {
+		lines[i].CouponDiscount = money.Zero()
+	}
+
+	for _, appliedCoupon := range applied {
+		coupon := couponsByID[appliedCoupon.CouponID]
+		weights := make([]int64, len(lines))
+		for i, line := range lines {
+			if appliesTo(coupon, line) {
+				weights[i] = money.Max(money.Zero(), line.Total.Sub(line.CouponDiscount)).Amount
+			}
+		}
+
+		for i, share := range appliedCoupon.Discount.Allocate(weights) {
+			lines[i].CouponDiscount = money.Min(lines[i].Total, lines[i].CouponDiscount.Add(share))
+		}
+	}
+}
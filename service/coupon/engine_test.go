@@ -0,0 +1,132 @@
+package coupon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestApply(t *testing.T) {
+	now := time.Date(2024, 10, 25, 12, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+	minSubtotal := money.FromMinor(10000)
+	desks := 4
+
+	lines := []types.PriceLine{
+		{ProductID: 1, Quantity: 2, UnitPrice: money.FromMinor(2500), Subtotal: money.FromMinor(5000), Total: money.FromMinor(5000)},
+		{ProductID: 2, CategoryID: &desks, Quantity: 1, UnitPrice: money.FromMinor(1999), Subtotal: money.FromMinor(1999), Total: money.FromMinor(1999)},
+	}
+
+	cases := []struct {
+		name      string
+		coupons   []types.Coupon
+		discounts []int64
+		err       bool
+	}{
+		{
+			name:      "Should take a percentage of the subtotal",
+			coupons:   []types.Coupon{{Code: "TEN", Type: types.CouponTypePercentage, Percentage: 10, Active: true}},
+			discounts: []int64{699},
+		},
+		{
+			name:      "Should only discount the eligible products",
+			coupons:   []types.Coupon{{Code: "MUGS", Type: types.CouponTypePercentage, Percentage: 50, Active: true, ProductIDs: []int{1}}},
+			discounts: []int64{2500},
+		},
+		{
+			name:      "Should not take more than the eligible subtotal",
+			coupons:   []types.Coupon{{Code: "BIG", Type: types.CouponTypeFixed, Amount: money.FromMinor(3000), Active: true, ProductIDs: []int{2}}},
+			discounts: []int64{1999},
+		},
+		{
+			name:      "Should only discount the products of the eligible categories",
+			coupons:   []types.Coupon{{Code: "DESKS", Type: types.CouponTypeFixed, Amount: money.FromMinor(3000), Active: true, CategoryIDs: []int{4}}},
+			discounts: []int64{1999},
+		},
+		{
+			name: "Should discount the eligible products along with the eligible categories",
+			coupons: []types.Coupon{{Code: "OFFICE", Type: types.CouponTypeFixed, Amount: money.FromMinor(8000), Active: true,
+				ProductIDs: []int{1}, CategoryIDs: []int{4}}},
+			discounts: []int64{6999},
+		},
+		{
+			name:      "Should give free shipping without a discount",
+			coupons:   []types.Coupon{{Code: "SHIP", Type: types.CouponTypeFreeShipping, Active: true}},
+			discounts: []int64{0},
+		},
+		{
+			name: "Should cap stacked coupons at the subtotal",
+			coupons: []types.Coupon{
+				{Code: "FIFTY", Type: types.CouponTypeFixed, Amount: money.FromMinor(5000), Active: true, Stackable: true},
+				{Code: "THIRTY", Type: types.CouponTypeFixed, Amount: money.FromMinor(3000), Active: true, Stackable: true},
+			},
+			discounts: []int64{5000, 1999},
+		},
+		{
+			name: "Should reject a coupon that can't be stacked",
+			coupons: []types.Coupon{
+				{Code: "TEN", Type: types.CouponTypePercentage, Percentage: 10, Active: true, Stackable: true},
+				{Code: "SOLO", Type: types.CouponTypeFixed, Amount: money.FromMinor(500), Active: true},
+			},
+			err: true,
+		},
+		{
+			name:    "Should reject an inactive coupon",
+			coupons: []types.Coupon{{Code: "OFF", Type: types.CouponTypeFixed, Amount: money.FromMinor(500)}},
+			err:     true,
+		},
+		{
+			name:    "Should reject a coupon that didn't start",
+			coupons: []types.Coupon{{Code: "SOON", Type: types.CouponTypeFixed, Amount: money.FromMinor(500), Active: true, StartsAt: &tomorrow}},
+			err:     true,
+		},
+		{
+			name:    "Should reject an expired coupon",
+			coupons: []types.Coupon{{Code: "OLD", Type: types.CouponTypeFixed, Amount: money.FromMinor(500), Active: true, EndsAt: &yesterday}},
+			err:     true,
+		},
+		{
+			name:    "Should reject a cart under the minimum subtotal",
+			coupons: []types.Coupon{{Code: "BIGCART", Type: types.CouponTypeFixed, Amount: money.FromMinor(500), Active: true, MinSubtotal: &minSubtotal}},
+			err:     true,
+		},
+		{
+			name:    "Should reject a coupon for products that aren't in the cart",
+			coupons: []types.Coupon{{Code: "OTHER", Type: types.CouponTypeFixed, Amount: money.FromMinor(500), Active: true, ProductIDs: []int{9}}},
+			err:     true,
+		},
+		{
+			name:    "Should reject a coupon for categories that aren't in the cart",
+			coupons: []types.Coupon{{Code: "CHAIRS", Type: types.CouponTypeFixed, Amount: money.FromMinor(500), Active: true, CategoryIDs: []int{9}}},
+			err:     true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			applied, err := Apply(c.coupons, lines, now)
+			if c.err {
+				if !errors.Is(err, types.ErrCouponNotApplicable) {
+					t.Fatalf("expected ErrCouponNotApplicable got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(applied) != len(c.discounts) {
+				t.Fatalf("expected %d coupons to be applied got %d", len(c.discounts), len(applied))
+			}
+			for i, discount := range c.discounts {
+				if applied[i].Discount.Amount != discount {
+					t.Errorf("expected %s to take %d got %s", applied[i].Code, discount, applied[i].Discount)
+				}
+			}
+		})
+	}
+}
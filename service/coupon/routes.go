@@ -0,0 +1,188 @@
+package coupon
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/middlewares"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+type Handler struct {
+	store types.CouponStore
+}
+
+func NewHandler(store types.CouponStore) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/coupons", auth.AdminMiddleware(middlewares.PaginationMiddleware(h.GetCoupons))).Methods("GET")
+	router.HandleFunc("/admin/coupons", auth.AdminMiddleware(h.CreateCoupon)).Methods("POST")
+	router.HandleFunc("/admin/coupons/{id}", auth.AdminMiddleware(h.GetCoupon)).Methods("GET")
+	router.HandleFunc("/admin/coupons/{id}", auth.AdminMiddleware(h.UpdateCoupon)).Methods("PUT")
+	router.HandleFunc("/admin/coupons/{id}", auth.AdminMiddleware(h.DeleteCoupon)).Methods("DELETE")
+}
+
+func (h *Handler) GetCoupons(w http.ResponseWriter, r *http.Request) {
+	pagination := middlewares.GetPagination(r)
+	offset := middlewares.CalculateOffset(pagination)
+
+	coupons, count, err := h.store.GetCoupons(pagination.Limit, offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK,
+		map[string]any{
+			"coupons": coupons,
+			"page":    pagination.Page,
+			"limit":   pagination.Limit,
+			"count":   count,
+		})
+}
+
+func (h *Handler) GetCoupon(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	coupon, err := h.store.GetCouponById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    coupon,
+	})
+}
+
+func (h *Handler) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	coupon, err := parseCouponPayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	created, err := h.store.CreateCoupon(coupon)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    created,
+	})
+}
+
+// PUT replaces the coupon including the products it's restricted to.
+func (h *Handler) UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	coupon, err := parseCouponPayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := h.store.UpdateCoupon(id, coupon)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    updated,
+	})
+}
+
+func (h *Handler) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DeleteCoupon(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusNoContent, map[string]any{})
+}
+
+func parseCouponPayload(r *http.Request) (types.Coupon, error) {
+	var payload types.CouponPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return types.Coupon{}, err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return types.Coupon{}, err
+	}
+
+	if payload.StartsAt != nil && payload.EndsAt != nil && !payload.EndsAt.After(*payload.StartsAt) {
+		return types.Coupon{}, fmt.Errorf("endsAt must be after startsAt")
+	}
+
+	active := true
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+
+	productIDs := payload.ProductIDs
+	if productIDs == nil {
+		productIDs = []int{}
+	}
+
+	categoryIDs := payload.CategoryIDs
+	if categoryIDs == nil {
+		categoryIDs = []int{}
+	}
+
+	return types.Coupon{
+		Code:         strings.ToUpper(payload.Code),
+		Type:         payload.Type,
+		Percentage:   payload.Percentage,
+		Amount:       payload.Amount,
+		MinSubtotal:  payload.MinSubtotal,
+		UsageLimit:   payload.UsageLimit,
+		PerUserLimit: payload.PerUserLimit,
+		StartsAt:     payload.StartsAt,
+		EndsAt:       payload.EndsAt,
+		Stackable:    payload.Stackable,
+		Active:       active,
+		ProductIDs:   productIDs,
+		CategoryIDs:  categoryIDs,
+	}, nil
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("coupon id must be unsigned integer")
+	}
+
+	return id, nil
+}
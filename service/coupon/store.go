@@ -0,0 +1,393 @@
+package coupon
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// returns a store that runs its queries inside the given transaction.
+func (s *Store) WithTx(tx myDB.DBTX) types.CouponStore {
+	return &Store{
+		db: tx,
+	}
+}
+
+func (s *Store) GetCoupons(limit, offset int) ([]types.Coupon, int, error) {
+	rows, err := s.db.Query("SELECT * FROM coupons ORDER BY id DESC LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	coupons, err := s.scanCoupons(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM coupons").Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return coupons, count, nil
+}
+
+func (s *Store) GetCouponById(id int) (*types.Coupon, error) {
+	rows, err := s.db.Query("SELECT * FROM coupons WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	coupons, err := s.scanCoupons(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(coupons) == 0 {
+		return nil, fmt.Errorf("no coupon was found for id %v", id)
+	}
+
+	return &coupons[0], nil
+}
+
+// codes are case insensitive, the coupons are returned in the order of the codes.
+// A code that doesn't exist is an error so the customer knows which one was wrong.
+func (s *Store) GetCouponsByCodes(codes []string) ([]types.Coupon, error) {
+	if len(codes) == 0 {
+		return []types.Coupon{}, nil
+	}
+
+	placeholders := strings.Repeat(",?", len(codes)-1)
+	query := fmt.Sprintf("SELECT * FROM coupons WHERE code IN (?%v)", placeholders)
+
+	args := make([]interface{}, len(codes))
+	for i, code := range codes {
+		args[i] = strings.ToUpper(code)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := s.scanCoupons(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byCode := make(map[string]types.Coupon, len(found))
+	for _, coupon := range found {
+		byCode[coupon.Code] = coupon
+	}
+
+	coupons := make([]types.Coupon, 0, len(codes))
+	for _, code := range codes {
+		coupon, ok := byCode[strings.ToUpper(code)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not a valid coupon code", types.ErrCouponNotApplicable, code)
+		}
+
+		coupons = append(coupons, coupon)
+	}
+
+	return coupons, nil
+}
+
+func (s *Store) CreateCoupon(coupon types.Coupon) (*types.Coupon, error) {
+	var couponId int64
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		if err := checkCodeIsFree(tx, coupon.Code, 0); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`
+		INSERT INTO coupons (code, type, percentage, amount, minSubtotal, usageLimit, perUserLimit, startsAt, endsAt, stackable, active)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+			strings.ToUpper(coupon.Code), coupon.Type, coupon.Percentage, coupon.Amount, coupon.MinSubtotal, coupon.UsageLimit, coupon.PerUserLimit,
+			coupon.StartsAt, coupon.EndsAt, coupon.Stackable, coupon.Active)
+		if err != nil {
+			return err
+		}
+
+		couponId, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		if err := setCouponProducts(tx, int(couponId), coupon.ProductIDs); err != nil {
+			return err
+		}
+
+		return setCouponCategories(tx, int(couponId), coupon.CategoryIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCouponById(int(couponId))
+}
+
+// UpdateCoupon replaces the coupon, the redemptions already made keep counting towards its limits.
+func (s *Store) UpdateCoupon(id int, coupon types.Coupon) (*types.Coupon, error) {
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		if err := checkCodeIsFree(tx, coupon.Code, id); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`
+		UPDATE coupons SET code = ?, type = ?, percentage = ?, amount = ?, minSubtotal = ?, usageLimit = ?, perUserLimit = ?,
+		startsAt = ?, endsAt = ?, stackable = ?, active = ? WHERE id = ?`,
+			strings.ToUpper(coupon.Code), coupon.Type, coupon.Percentage, coupon.Amount, coupon.MinSubtotal, coupon.UsageLimit, coupon.PerUserLimit,
+			coupon.StartsAt, coupon.EndsAt, coupon.Stackable, coupon.Active, id)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			if _, err := s.WithTx(tx).GetCouponById(id); err != nil {
+				return err
+			}
+		}
+
+		if err := setCouponProducts(tx, id, coupon.ProductIDs); err != nil {
+			return err
+		}
+
+		return setCouponCategories(tx, id, coupon.CategoryIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCouponById(id)
+}
+
+// a coupon that was redeemed stays for the order history, it can only be deactivated.
+func (s *Store) DeleteCoupon(id int) error {
+	var redeemed bool
+	err := s.db.QueryRow("SELECT COUNT(*) > 0 FROM couponRedemptions WHERE couponId = ?", id).Scan(&redeemed)
+	if err != nil {
+		return err
+	}
+	if redeemed {
+		return fmt.Errorf("coupon with id %v has been redeemed, deactivate it instead", id)
+	}
+
+	result, err := s.db.Exec("DELETE FROM coupons WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no coupon was found for id %v", id)
+	}
+
+	return nil
+}
+
+// RedeemCoupons records the coupons on the order, it must run in the transaction that creates the order.
+// The coupon rows are locked while the limits are checked so concurrent checkouts can't both take the last use,
+// redemptions of cancelled orders don't count so the uses are given back when an order is cancelled.
+func (s *Store) RedeemCoupons(orderID, userID int, applied []types.AppliedCoupon) error {
+	sorted := make([]types.AppliedCoupon, len(applied))
+	copy(sorted, applied)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CouponID < sorted[j].CouponID })
+
+	return myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		for _, coupon := range sorted {
+			var usageLimit, perUserLimit *int
+			err := tx.QueryRow("SELECT usageLimit, perUserLimit FROM coupons WHERE id = ? FOR UPDATE", coupon.CouponID).
+				Scan(&usageLimit, &perUserLimit)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: %s is not a valid coupon code", types.ErrCouponNotApplicable, coupon.Code)
+			}
+			if err != nil {
+				return err
+			}
+
+			var used, usedByUser int
+			err = tx.QueryRow(`
+			SELECT COUNT(*), COALESCE(SUM(cr.userId = ?), 0) FROM couponRedemptions cr
+			JOIN orders o ON o.id = cr.orderId
+			WHERE cr.couponId = ? AND o.status <> ?`, userID, coupon.CouponID, types.OrderStatusCancelled).Scan(&used, &usedByUser)
+			if err != nil {
+				return err
+			}
+
+			if usageLimit != nil && used >= *usageLimit {
+				return fmt.Errorf("%w: %s has reached its usage limit", types.ErrCouponNotApplicable, coupon.Code)
+			}
+			if perUserLimit != nil && usedByUser >= *perUserLimit {
+				return fmt.Errorf("%w: you already used %s the maximum number of times", types.ErrCouponNotApplicable, coupon.Code)
+			}
+
+			_, err = tx.Exec("INSERT INTO couponRedemptions (couponId, orderId, userId, discount) VALUES (?,?,?,?)",
+				coupon.CouponID, orderID, userID, coupon.Discount)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// excludeId is the coupon being updated, it may keep its own code.
+func checkCodeIsFree(q myDB.DBTX, code string, excludeId int) error {
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM coupons WHERE code = ? AND id <> ?", strings.ToUpper(code), excludeId).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("coupon with code %s already exists", strings.ToUpper(code))
+	}
+
+	return nil
+}
+
+func setCouponProducts(q myDB.DBTX, couponID int, productIDs []int) error {
+	if _, err := q.Exec("DELETE FROM couponProducts WHERE couponId = ?", couponID); err != nil {
+		return err
+	}
+
+	for _, productID := range productIDs {
+		_, err := q.Exec("INSERT IGNORE INTO couponProducts (couponId, productId) VALUES (?,?)", couponID, productID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setCouponCategories(q myDB.DBTX, couponID int, categoryIDs []int) error {
+	if _, err := q.Exec("DELETE FROM couponCategories WHERE couponId = ?", couponID); err != nil {
+		return err
+	}
+
+	for _, categoryID := range categoryIDs {
+		_, err := q.Exec("INSERT IGNORE INTO couponCategories (couponId, categoryId) VALUES (?,?)", couponID, categoryID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scans the coupons then loads the products and categories they're restricted to.
+func (s *Store) scanCoupons(rows *sql.Rows) ([]types.Coupon, error) {
+	defer rows.Close()
+
+	coupons := make([]types.Coupon, 0)
+	couponsIndex := make(map[int]int)
+	for rows.Next() {
+		coupon := new(types.Coupon)
+		if err := rows.Scan(couponAllFieldsScanner(coupon)); err != nil {
+			return nil, err
+		}
+
+		coupon.ProductIDs = make([]int, 0)
+		coupon.CategoryIDs = make([]int, 0)
+		couponsIndex[coupon.ID] = len(coupons)
+		coupons = append(coupons, *coupon)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(coupons) == 0 {
+		return coupons, nil
+	}
+
+	placeholders := strings.Repeat(",?", len(coupons)-1)
+	query := fmt.Sprintf("SELECT couponId, productId FROM couponProducts WHERE couponId IN (?%v) ORDER BY productId", placeholders)
+
+	args := make([]interface{}, len(coupons))
+	for i, coupon := range coupons {
+		args[i] = coupon.ID
+	}
+
+	productRows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer productRows.Close()
+
+	for productRows.Next() {
+		var couponId, productId int
+		if err := productRows.Scan(&couponId, &productId); err != nil {
+			return nil, err
+		}
+
+		index := couponsIndex[couponId]
+		coupons[index].ProductIDs = append(coupons[index].ProductIDs, productId)
+	}
+	if err := productRows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = fmt.Sprintf("SELECT couponId, categoryId FROM couponCategories WHERE couponId IN (?%v) ORDER BY categoryId", placeholders)
+	categoryRows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer categoryRows.Close()
+
+	for categoryRows.Next() {
+		var couponId, categoryId int
+		if err := categoryRows.Scan(&couponId, &categoryId); err != nil {
+			return nil, err
+		}
+
+		index := couponsIndex[couponId]
+		coupons[index].CategoryIDs = append(coupons[index].CategoryIDs, categoryId)
+	}
+
+	return coupons, categoryRows.Err()
+}
+
+func couponAllFieldsScanner(coupon *types.Coupon) (*int, *string, *string, *float64, *money.Money, **money.Money, **int, **int, **time.Time, **time.Time, *bool, *bool, *time.Time, *time.Time) {
+	return &coupon.ID,
+		&coupon.Code,
+		&coupon.Type,
+		&coupon.Percentage,
+		&coupon.Amount,
+		&coupon.MinSubtotal,
+		&coupon.UsageLimit,
+		&coupon.PerUserLimit,
+		&coupon.StartsAt,
+		&coupon.EndsAt,
+		&coupon.Stackable,
+		&coupon.Active,
+		&coupon.CreatedAt,
+		&coupon.UpdatedAt
+}
@@ -0,0 +1,147 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+const (
+	KeyHeader = "Idempotency-Key"
+	// ReplayedHeader is set on the responses that were stored by an earlier request.
+	ReplayedHeader = "Idempotent-Replayed"
+	maxKeyLength   = 255
+)
+
+// Middleware makes the POST requests of authenticated users sent with an Idempotency-Key safe
+// to retry. The first request runs and its response is kept for ttl, a retry with the same
+// method, path and body gets that response back without running again. Reusing the key for
+// another request is refused with 422, and a retry arriving while the first request still runs
+// with 409. The server errors aren't kept, so a request that failed that way can be retried.
+func Middleware(store types.IdempotencyStore, ttl time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(KeyHeader)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxKeyLength {
+				utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("the %s header can't be longer than %d characters", KeyHeader, maxKeyLength))
+				return
+			}
+
+			// the keys belong to users, the anonymous requests run as usual.
+			userId, err := auth.RequestUserId(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				utils.WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			record, started, err := store.StartIdempotentRequest(userId, key, fingerprint(r, body), ttl)
+			if err != nil {
+				utils.WriteError(w, http.StatusInternalServerError, err)
+				return
+			}
+			if !started {
+				replay(w, r, record, body)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			release := func() {
+				if err := store.DeleteIdempotencyKey(userId, key); err != nil {
+					log.Printf("idempotency key %s of user %d couldn't be released: %v", key, userId, err)
+				}
+			}
+			defer func() {
+				if p := recover(); p != nil {
+					release()
+					panic(p)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= http.StatusInternalServerError {
+				release()
+				return
+			}
+
+			// when the response can't be kept the key stays in flight until it expires, the retries
+			// are refused rather than running the request twice.
+			err = store.CompleteIdempotentRequest(userId, key, recorder.status, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+			if err != nil {
+				log.Printf("idempotency key %s of user %d couldn't be completed: %v", key, userId, err)
+			}
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, record *types.IdempotencyRecord, body []byte) {
+	if record.Fingerprint != fingerprint(r, body) {
+		utils.WriteError(w, http.StatusUnprocessableEntity, fmt.Errorf("the %s was already used for another request", KeyHeader))
+		return
+	}
+	if record.Status != types.IdempotencyStatusCompleted || record.ResponseStatus == nil {
+		utils.WriteError(w, http.StatusConflict, fmt.Errorf("a request with this %s is still in progress", KeyHeader))
+		return
+	}
+
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(*record.ResponseStatus)
+	w.Write(record.ResponseBody)
+}
+
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.RequestURI())
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder writes the response through and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(data)
+
+	return r.ResponseWriter.Write(data)
+}
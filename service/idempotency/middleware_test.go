@@ -0,0 +1,172 @@
+package idempotency
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestMiddleware(t *testing.T) {
+	store := &mockIdempotencyStore{records: map[string]*types.IdempotencyRecord{}}
+	runs := 0
+	status := http.StatusCreated
+
+	router := mux.NewRouter()
+	router.Use(Middleware(store, time.Hour))
+	router.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"run":%d}`, runs)
+	}).Methods(http.MethodPost)
+
+	send := func(userId int, key, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "" {
+			req.Header.Set(KeyHeader, key)
+		}
+		if userId != 0 {
+			token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), types.User{ID: userId, Role: types.UserRoleCustomer})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", token)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	t.Run("Should run every request sent without a key", func(t *testing.T) {
+		send(1, "", `{}`)
+		send(1, "", `{}`)
+		if runs != 2 || len(store.records) != 0 {
+			t.Errorf("expected 2 runs and no key got %d runs and %d keys", runs, len(store.records))
+		}
+	})
+
+	t.Run("Should run the first request and keep its response", func(t *testing.T) {
+		recorder := send(1, "key-1", `{"cartId":1}`)
+		if recorder.Code != http.StatusCreated || recorder.Body.String() != `{"run":3}` {
+			t.Fatalf("expected the request to run got %d: %s", recorder.Code, recorder.Body)
+		}
+
+		record := store.records["1/key-1"]
+		if record.Status != types.IdempotencyStatusCompleted || string(record.ResponseBody) != `{"run":3}` {
+			t.Errorf("expected the response to be kept got %+v", record)
+		}
+	})
+
+	t.Run("Should replay the response of a retry without running it", func(t *testing.T) {
+		recorder := send(1, "key-1", `{"cartId":1}`)
+		if runs != 3 {
+			t.Errorf("expected the request not to run again got %d runs", runs)
+		}
+		if recorder.Code != http.StatusCreated || recorder.Body.String() != `{"run":3}` {
+			t.Errorf("expected the kept response got %d: %s", recorder.Code, recorder.Body)
+		}
+		if recorder.Header().Get(ReplayedHeader) != "true" || recorder.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("expected the replayed headers got %v", recorder.Header())
+		}
+	})
+
+	t.Run("Should refuse the key reused for another request", func(t *testing.T) {
+		recorder := send(1, "key-1", `{"cartId":2}`)
+		if recorder.Code != http.StatusUnprocessableEntity || runs != 3 {
+			t.Errorf("expected status code %d got %d", http.StatusUnprocessableEntity, recorder.Code)
+		}
+	})
+
+	t.Run("Should keep the keys of the users apart", func(t *testing.T) {
+		recorder := send(2, "key-1", `{"cartId":2}`)
+		if recorder.Code != http.StatusCreated || runs != 4 {
+			t.Errorf("expected the request to run got %d", recorder.Code)
+		}
+	})
+
+	t.Run("Should refuse a retry while the request is in flight", func(t *testing.T) {
+		store.records["1/key-2"] = &types.IdempotencyRecord{UserID: 1, Key: "key-2", Fingerprint: fingerprintOf(`{}`), Status: types.IdempotencyStatusInFlight}
+
+		recorder := send(1, "key-2", `{}`)
+		if recorder.Code != http.StatusConflict || runs != 4 {
+			t.Errorf("expected status code %d got %d", http.StatusConflict, recorder.Code)
+		}
+	})
+
+	t.Run("Should release the key of a request that failed on the server", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		send(1, "key-3", `{}`)
+		if _, ok := store.records["1/key-3"]; ok {
+			t.Error("expected the key to be released")
+		}
+
+		status = http.StatusCreated
+		recorder := send(1, "key-3", `{}`)
+		if recorder.Code != http.StatusCreated || runs != 6 {
+			t.Errorf("expected the retry to run got %d after %d runs", recorder.Code, runs)
+		}
+	})
+
+	t.Run("Should run the requests of anonymous users", func(t *testing.T) {
+		send(0, "key-1", `{}`)
+		send(0, "key-1", `{}`)
+		if runs != 8 {
+			t.Errorf("expected both requests to run got %d runs", runs)
+		}
+	})
+
+	t.Run("Should refuse a key that's too long", func(t *testing.T) {
+		recorder := send(1, string(bytes.Repeat([]byte("k"), maxKeyLength+1)), `{}`)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+}
+
+func fingerprintOf(body string) string {
+	req, _ := http.NewRequest(http.MethodPost, "/orders", nil)
+	return fingerprint(req, []byte(body))
+}
+
+type mockIdempotencyStore struct {
+	types.IdempotencyStore
+	records map[string]*types.IdempotencyRecord
+}
+
+func (m *mockIdempotencyStore) StartIdempotentRequest(userID int, key, fingerprint string, ttl time.Duration) (*types.IdempotencyRecord, bool, error) {
+	id := fmt.Sprintf("%d/%s", userID, key)
+	if record, ok := m.records[id]; ok {
+		return record, false, nil
+	}
+
+	record := &types.IdempotencyRecord{UserID: userID, Key: key, Fingerprint: fingerprint, Status: types.IdempotencyStatusInFlight}
+	m.records[id] = record
+	return record, true, nil
+}
+
+func (m *mockIdempotencyStore) CompleteIdempotentRequest(userID int, key string, responseStatus int, contentType string, responseBody []byte) error {
+	record := m.records[fmt.Sprintf("%d/%s", userID, key)]
+	record.Status = types.IdempotencyStatusCompleted
+	record.ResponseStatus = &responseStatus
+	record.ContentType = contentType
+	record.ResponseBody = responseBody
+	return nil
+}
+
+func (m *mockIdempotencyStore) DeleteIdempotencyKey(userID int, key string) error {
+	delete(m.records, fmt.Sprintf("%d/%s", userID, key))
+	return nil
+}
@@ -0,0 +1,93 @@
+package idempotency
+
+import (
+	"database/sql"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// StartIdempotentRequest records the key as in flight for ttl, an expired key is taken over.
+// When the key is already in use the existing record is returned and started is false.
+func (s *Store) StartIdempotentRequest(userID int, key, fingerprint string, ttl time.Duration) (*types.IdempotencyRecord, bool, error) {
+	var record *types.IdempotencyRecord
+	var started bool
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		_, err := tx.Exec("DELETE FROM idempotencyKeys WHERE userId = ? AND idempotencyKey = ? AND expiresAt <= CURRENT_TIMESTAMP",
+			userID, key)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`
+		INSERT IGNORE INTO idempotencyKeys (userId, idempotencyKey, fingerprint, status, expiresAt)
+		VALUES (?,?,?,?, DATE_ADD(CURRENT_TIMESTAMP, INTERVAL ? SECOND))`,
+			userID, key, fingerprint, types.IdempotencyStatusInFlight, int(ttl.Seconds()))
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		started = rowsAffected > 0
+
+		record = new(types.IdempotencyRecord)
+		return tx.QueryRow("SELECT * FROM idempotencyKeys WHERE userId = ? AND idempotencyKey = ?", userID, key).
+			Scan(idempotencyRecordAllFieldsScanner(record))
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return record, started, nil
+}
+
+func (s *Store) CompleteIdempotentRequest(userID int, key string, responseStatus int, contentType string, responseBody []byte) error {
+	_, err := s.db.Exec(`
+	UPDATE idempotencyKeys SET status = ?, responseStatus = ?, contentType = ?, responseBody = ? WHERE userId = ? AND idempotencyKey = ?`,
+		types.IdempotencyStatusCompleted, responseStatus, contentType, responseBody, userID, key)
+
+	return err
+}
+
+func (s *Store) DeleteIdempotencyKey(userID int, key string) error {
+	_, err := s.db.Exec("DELETE FROM idempotencyKeys WHERE userId = ? AND idempotencyKey = ?", userID, key)
+
+	return err
+}
+
+func (s *Store) DeleteExpiredIdempotencyKeys() (int, error) {
+	result, err := s.db.Exec("DELETE FROM idempotencyKeys WHERE expiresAt <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+func idempotencyRecordAllFieldsScanner(record *types.IdempotencyRecord) (*int, *string, *string, *string, **int, *string, *[]byte,
+	*time.Time, *time.Time) {
+	return &record.UserID,
+		&record.Key,
+		&record.Fingerprint,
+		&record.Status,
+		&record.ResponseStatus,
+		&record.ContentType,
+		&record.ResponseBody,
+		&record.CreatedAt,
+		&record.ExpiresAt
+}
@@ -0,0 +1,260 @@
+package inventory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// the names the allocation strategy can be configured with.
+const (
+	AllocationPriority        = "priority"
+	AllocationNearest         = "nearest"
+	AllocationFewestShipments = "fewest-shipments"
+)
+
+// above this many warehouses the fewest shipments strategy stops trying every combination.
+const maxExhaustiveWarehouses = 12
+
+func NewAllocationStrategy(name string) (types.AllocationStrategy, error) {
+	switch name {
+	case AllocationPriority, "":
+		return PriorityStrategy{}, nil
+	case AllocationNearest:
+		return NearestStrategy{}, nil
+	case AllocationFewestShipments:
+		return FewestShipmentsStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown allocation strategy '%s'", name)
+	}
+}
+
+// PriorityStrategy takes every line from the warehouses in priority order.
+type PriorityStrategy struct{}
+
+func (PriorityStrategy) Allocate(items []types.CartCheckoutItem, warehouses []types.Warehouse, stock map[int][]types.ProductStockLevel, destination *types.ShippingAddress) ([]types.StockAllocation, error) {
+	return allocateInOrder(items, byPriority(warehouses), stock)
+}
+
+// NearestStrategy takes every line from the warehouses closest to the shipping address, by distance when
+// both have coordinates and by country otherwise, without an address it behaves like PriorityStrategy.
+type NearestStrategy struct{}
+
+func (NearestStrategy) Allocate(items []types.CartCheckoutItem, warehouses []types.Warehouse, stock map[int][]types.ProductStockLevel, destination *types.ShippingAddress) ([]types.StockAllocation, error) {
+	ordered := byPriority(warehouses)
+	if destination == nil {
+		return allocateInOrder(items, ordered, stock)
+	}
+
+	distances := make(map[int]float64, len(ordered))
+	for _, warehouse := range ordered {
+		distances[warehouse.ID] = distanceTo(warehouse, *destination)
+	}
+
+	// the sort is stable so equally distant warehouses keep their priority order.
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return distances[ordered[i].ID] < distances[ordered[j].ID]
+	})
+
+	return allocateInOrder(items, ordered, stock)
+}
+
+// FewestShipmentsStrategy picks the smallest set of warehouses that can fulfil the whole order,
+// preferring the sets with the best priorities, then allocates the lines within it in priority order.
+type FewestShipmentsStrategy struct{}
+
+func (FewestShipmentsStrategy) Allocate(items []types.CartCheckoutItem, warehouses []types.Warehouse, stock map[int][]types.ProductStockLevel, destination *types.ShippingAddress) ([]types.StockAllocation, error) {
+	ordered := byPriority(warehouses)
+	if len(ordered) > maxExhaustiveWarehouses {
+		return allocateInOrder(items, greedyCover(items, ordered, stock), stock)
+	}
+
+	for size := 1; size <= len(ordered); size++ {
+		var found []types.Warehouse
+		// combinations are generated in priority order, so the first one that fits is the preferred one.
+		forEachCombination(len(ordered), size, func(indexes []int) bool {
+			subset := make([]types.Warehouse, len(indexes))
+			for i, index := range indexes {
+				subset[i] = ordered[index]
+			}
+
+			if _, err := allocateInOrder(items, subset, stock); err == nil {
+				found = subset
+				return false
+			}
+			return true
+		})
+
+		if found != nil {
+			return allocateInOrder(items, found, stock)
+		}
+	}
+
+	// none of the combinations fits, allocating from all of them reports the missing line.
+	return allocateInOrder(items, ordered, stock)
+}
+
+// allocateInOrder takes each line from the warehouses in the given order until it's covered.
+func allocateInOrder(items []types.CartCheckoutItem, warehouses []types.Warehouse, stock map[int][]types.ProductStockLevel) ([]types.StockAllocation, error) {
+	available := availableByLocation(stock)
+
+	allocations := make([]types.StockAllocation, 0, len(items))
+	for _, item := range items {
+		remaining := item.Quantity
+		for _, warehouse := range warehouses {
+			if remaining == 0 {
+				break
+			}
+
+			key := locationKey{productID: item.ProductID, warehouseID: warehouse.ID}
+			taken := min(remaining, available[key])
+			if taken <= 0 {
+				continue
+			}
+
+			available[key] -= taken
+			remaining -= taken
+			allocations = append(allocations, types.StockAllocation{
+				ProductID:   item.ProductID,
+				WarehouseID: warehouse.ID,
+				Quantity:    taken,
+			})
+		}
+
+		if remaining > 0 {
+			return nil, fmt.Errorf("%w for product with id %v, %v more are needed", types.ErrInsufficientStock, item.ProductID, remaining)
+		}
+	}
+
+	return allocations, nil
+}
+
+// greedyCover orders the warehouses so the ones covering most of what is still missing come first.
+func greedyCover(items []types.CartCheckoutItem, warehouses []types.Warehouse, stock map[int][]types.ProductStockLevel) []types.Warehouse {
+	available := availableByLocation(stock)
+	missing := make(map[int]int, len(items))
+	for _, item := range items {
+		missing[item.ProductID] += item.Quantity
+	}
+
+	remaining := make([]types.Warehouse, len(warehouses))
+	copy(remaining, warehouses)
+
+	ordered := make([]types.Warehouse, 0, len(warehouses))
+	for len(remaining) > 0 {
+		best, bestCovered := 0, -1
+		for i, warehouse := range remaining {
+			covered := 0
+			for productID, quantity := range missing {
+				covered += min(quantity, available[locationKey{productID: productID, warehouseID: warehouse.ID}])
+			}
+			if covered > bestCovered {
+				best, bestCovered = i, covered
+			}
+		}
+
+		warehouse := remaining[best]
+		for productID, quantity := range missing {
+			missing[productID] = quantity - min(quantity, available[locationKey{productID: productID, warehouseID: warehouse.ID}])
+		}
+
+		ordered = append(ordered, warehouse)
+		remaining = append(remaining[:best], remaining[best+1:]...)
+	}
+
+	return ordered
+}
+
+type locationKey struct {
+	productID   int
+	warehouseID int
+}
+
+func availableByLocation(stock map[int][]types.ProductStockLevel) map[locationKey]int {
+	available := make(map[locationKey]int)
+	for productID, levels := range stock {
+		for _, level := range levels {
+			available[locationKey{productID: productID, warehouseID: level.WarehouseID}] += level.Available
+		}
+	}
+
+	return available
+}
+
+// returns the active warehouses sorted by priority, then by id.
+func byPriority(warehouses []types.Warehouse) []types.Warehouse {
+	ordered := make([]types.Warehouse, 0, len(warehouses))
+	for _, warehouse := range warehouses {
+		if warehouse.Active {
+			ordered = append(ordered, warehouse)
+		}
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Priority != ordered[j].Priority {
+			return ordered[i].Priority < ordered[j].Priority
+		}
+		return ordered[i].ID < ordered[j].ID
+	})
+
+	return ordered
+}
+
+// calls fn with every combination of size indexes out of n in lexicographic order, until fn returns false.
+func forEachCombination(n, size int, fn func(indexes []int) bool) {
+	indexes := make([]int, size)
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	for {
+		if !fn(indexes) {
+			return
+		}
+
+		i := size - 1
+		for i >= 0 && indexes[i] == n-size+i {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+
+		indexes[i]++
+		for j := i + 1; j < size; j++ {
+			indexes[j] = indexes[j-1] + 1
+		}
+	}
+}
+
+// returns the distance in kilometers, or a ranking value when the coordinates are missing:
+// warehouses in the destination country come before the others, and both after the ones that have a distance.
+func distanceTo(warehouse types.Warehouse, destination types.ShippingAddress) float64 {
+	const (
+		earthRadiusKm   = 6371.0
+		sameCountryRank = 1e6
+		otherRank       = 2e6
+	)
+
+	if warehouse.Latitude != nil && warehouse.Longitude != nil && destination.Latitude != nil && destination.Longitude != nil {
+		lat1, lat2 := toRadians(*warehouse.Latitude), toRadians(*destination.Latitude)
+		deltaLat := lat2 - lat1
+		deltaLon := toRadians(*destination.Longitude - *warehouse.Longitude)
+
+		a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+		return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+	}
+
+	if warehouse.Country != "" && strings.EqualFold(warehouse.Country, destination.Country) {
+		return sameCountryRank
+	}
+
+	return otherRank
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
@@ -0,0 +1,143 @@
+package inventory
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestAllocationStrategies(t *testing.T) {
+	ammanLat, ammanLon := 31.95, 35.91
+	berlinLat, berlinLon := 52.52, 13.40
+	chicagoLat, chicagoLon := 41.88, -87.63
+
+	// the east warehouse has the best priority, west is the closest to Chicago.
+	warehouses := []types.Warehouse{
+		{ID: 1, Code: "EAST", Country: "JO", Latitude: &ammanLat, Longitude: &ammanLon, Priority: 0, Active: true},
+		{ID: 2, Code: "CENTRAL", Country: "DE", Latitude: &berlinLat, Longitude: &berlinLon, Priority: 1, Active: true},
+		{ID: 3, Code: "WEST", Country: "US", Latitude: &chicagoLat, Longitude: &chicagoLon, Priority: 2, Active: true},
+		{ID: 4, Code: "CLOSED", Country: "US", Priority: 0, Active: false},
+	}
+
+	stock := map[int][]types.ProductStockLevel{
+		10: {{WarehouseID: 1, Available: 2}, {WarehouseID: 2, Available: 5}, {WarehouseID: 3, Available: 5}, {WarehouseID: 4, Available: 100}},
+		20: {{WarehouseID: 1, Available: 3}, {WarehouseID: 3, Available: 1}},
+	}
+
+	newYorkLat, newYorkLon := 40.71, -74.00
+	newYork := &types.ShippingAddress{Country: "US", Latitude: &newYorkLat, Longitude: &newYorkLon}
+	paris := &types.ShippingAddress{Country: "FR"}
+	hamburg := &types.ShippingAddress{Country: "de"}
+
+	cases := []struct {
+		name        string
+		strategy    types.AllocationStrategy
+		items       []types.CartCheckoutItem
+		destination *types.ShippingAddress
+		expected    []types.StockAllocation
+		expectErr   error
+	}{
+		{
+			name:     "priority fills from the best priority first",
+			strategy: PriorityStrategy{},
+			items:    []types.CartCheckoutItem{{ProductID: 10, Quantity: 4}, {ProductID: 20, Quantity: 1}},
+			expected: []types.StockAllocation{
+				{ProductID: 10, WarehouseID: 1, Quantity: 2},
+				{ProductID: 10, WarehouseID: 2, Quantity: 2},
+				{ProductID: 20, WarehouseID: 1, Quantity: 1},
+			},
+		},
+		{
+			name:        "nearest uses the distance to the coordinates",
+			strategy:    NearestStrategy{},
+			items:       []types.CartCheckoutItem{{ProductID: 10, Quantity: 4}, {ProductID: 20, Quantity: 2}},
+			destination: newYork,
+			expected: []types.StockAllocation{
+				{ProductID: 10, WarehouseID: 3, Quantity: 4},
+				{ProductID: 20, WarehouseID: 3, Quantity: 1},
+				{ProductID: 20, WarehouseID: 1, Quantity: 1},
+			},
+		},
+		{
+			name:        "nearest falls back to the country then the priority",
+			strategy:    NearestStrategy{},
+			items:       []types.CartCheckoutItem{{ProductID: 10, Quantity: 3}},
+			destination: hamburg,
+			expected:    []types.StockAllocation{{ProductID: 10, WarehouseID: 2, Quantity: 3}},
+		},
+		{
+			name:        "nearest without a close warehouse keeps the priority order",
+			strategy:    NearestStrategy{},
+			items:       []types.CartCheckoutItem{{ProductID: 10, Quantity: 3}},
+			destination: paris,
+			expected: []types.StockAllocation{
+				{ProductID: 10, WarehouseID: 1, Quantity: 2},
+				{ProductID: 10, WarehouseID: 2, Quantity: 1},
+			},
+		},
+		{
+			name:     "fewest shipments prefers a single warehouse over the priority",
+			strategy: FewestShipmentsStrategy{},
+			items:    []types.CartCheckoutItem{{ProductID: 10, Quantity: 4}, {ProductID: 20, Quantity: 1}},
+			expected: []types.StockAllocation{
+				{ProductID: 10, WarehouseID: 3, Quantity: 4},
+				{ProductID: 20, WarehouseID: 3, Quantity: 1},
+			},
+		},
+		{
+			name:     "fewest shipments splits when no warehouse has everything",
+			strategy: FewestShipmentsStrategy{},
+			items:    []types.CartCheckoutItem{{ProductID: 10, Quantity: 5}, {ProductID: 20, Quantity: 3}},
+			expected: []types.StockAllocation{
+				{ProductID: 10, WarehouseID: 1, Quantity: 2},
+				{ProductID: 10, WarehouseID: 2, Quantity: 3},
+				{ProductID: 20, WarehouseID: 1, Quantity: 3},
+			},
+		},
+		{
+			name:      "inactive warehouses are never used",
+			strategy:  PriorityStrategy{},
+			items:     []types.CartCheckoutItem{{ProductID: 10, Quantity: 13}},
+			expectErr: types.ErrInsufficientStock,
+		},
+		{
+			name:      "fewest shipments reports the missing stock",
+			strategy:  FewestShipmentsStrategy{},
+			items:     []types.CartCheckoutItem{{ProductID: 20, Quantity: 5}},
+			expectErr: types.ErrInsufficientStock,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run("Should "+c.name, func(t *testing.T) {
+			allocations, err := c.strategy.Allocate(c.items, warehouses, stock, c.destination)
+			if c.expectErr != nil {
+				if !errors.Is(err, c.expectErr) {
+					t.Fatalf("expected %v got %v", c.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(allocations, c.expected) {
+				t.Errorf("expected %+v got %+v", c.expected, allocations)
+			}
+		})
+	}
+}
+
+func TestNewAllocationStrategy(t *testing.T) {
+	for _, name := range []string{AllocationPriority, AllocationNearest, AllocationFewestShipments} {
+		if _, err := NewAllocationStrategy(name); err != nil {
+			t.Errorf("expected %s to be a known strategy got %v", name, err)
+		}
+	}
+
+	if _, err := NewAllocationStrategy("random"); err == nil {
+		t.Error("expected an unknown strategy to be rejected")
+	}
+}
@@ -0,0 +1,205 @@
+package inventory
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// returns the alerts a quantity change from before to after triggers.
+func stockAlertKinds(lowStockThreshold *int, before, after int) []string {
+	kinds := make([]string, 0, 1)
+
+	switch {
+	case before > 0 && after <= 0:
+		kinds = append(kinds, types.StockAlertOutOfStock)
+	case lowStockThreshold != nil && before > *lowStockThreshold && after <= *lowStockThreshold:
+		kinds = append(kinds, types.StockAlertLowStock)
+	case before <= 0 && after > 0:
+		kinds = append(kinds, types.StockAlertBackInStock)
+	}
+
+	return kinds
+}
+
+// records the alerts in the same transaction as the movement, they're sent later by the StockNotifier.
+// an alert that is still pending for the product isn't recorded twice.
+func recordStockAlerts(q myDB.DBTX, productID int, lowStockThreshold *int, before, after int) error {
+	for _, kind := range stockAlertKinds(lowStockThreshold, before, after) {
+		var pending bool
+		err := q.QueryRow("SELECT COUNT(*) > 0 FROM stockAlerts WHERE productId = ? AND kind = ? AND status = ?",
+			productID, kind, types.StockAlertStatusPending).Scan(&pending)
+		if err != nil {
+			return err
+		}
+		if pending {
+			continue
+		}
+
+		_, err = q.Exec("INSERT INTO stockAlerts (productId, kind, quantity) VALUES (?,?,?)", productID, kind, max(0, after))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// oldest alerts first.
+func (s *Store) GetPendingStockAlerts(limit int) ([]types.StockAlert, error) {
+	rows, err := s.db.Query("SELECT * FROM stockAlerts WHERE status = ? ORDER BY id LIMIT ?", types.StockAlertStatusPending, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	alerts := make([]types.StockAlert, 0)
+	for rows.Next() {
+		alert := new(types.StockAlert)
+		if err := rows.Scan(stockAlertAllFieldsScanner(alert)); err != nil {
+			return nil, err
+		}
+
+		alerts = append(alerts, *alert)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+func (s *Store) SetStockAlertStatus(alertID int, status string) error {
+	_, err := s.db.Exec(`
+	UPDATE stockAlerts SET status = ?, sentAt = IF(? = 'sent', CURRENT_TIMESTAMP, sentAt) WHERE id = ?`,
+		status, status, alertID)
+	return err
+}
+
+// returns nil when no alert of this kind was ever sent for the product.
+func (s *Store) GetLastSentStockAlert(productID int, kind string) (*types.StockAlert, error) {
+	alert := new(types.StockAlert)
+	err := s.db.QueryRow(`
+	SELECT * FROM stockAlerts WHERE productId = ? AND kind = ? AND status = ? ORDER BY sentAt DESC LIMIT 1`,
+		productID, kind, types.StockAlertStatusSent).Scan(stockAlertAllFieldsScanner(alert))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return alert, nil
+}
+
+// Subscribe registers the customer for a back in stock email, it only works while the product can't be bought.
+// Subscribing again before being notified returns the existing subscription and false.
+func (s *Store) Subscribe(productID, userID int, email string) (*types.StockSubscription, bool, error) {
+	subscription := new(types.StockSubscription)
+	created := false
+
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		var available int
+		err := tx.QueryRow(`
+		SELECT GREATEST(0, quantity - (
+			SELECT COALESCE(SUM(r.quantity), 0) FROM stockReservations r
+			WHERE r.productId = products.id AND r.status = 'active' AND r.expiresAt > CURRENT_TIMESTAMP
+		)) FROM products WHERE id = ? AND deletedAt IS NULL FOR UPDATE`, productID).Scan(&available)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no product was found for id %v", productID)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow("SELECT * FROM stockSubscriptions WHERE productId = ? AND userId = ? AND notifiedAt IS NULL", productID, userID).
+			Scan(stockSubscriptionAllFieldsScanner(subscription))
+		if err == nil {
+			return nil
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		if available > 0 {
+			return fmt.Errorf("%w, %v are available", types.ErrProductInStock, available)
+		}
+
+		result, err := tx.Exec("INSERT INTO stockSubscriptions (productId, userId, email) VALUES (?,?,?)", productID, userID, email)
+		if err != nil {
+			return err
+		}
+
+		subscriptionId, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		created = true
+
+		return tx.QueryRow("SELECT * FROM stockSubscriptions WHERE id = ?", subscriptionId).Scan(stockSubscriptionAllFieldsScanner(subscription))
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return subscription, created, nil
+}
+
+func (s *Store) GetWaitingSubscriptions(productID int) ([]types.StockSubscription, error) {
+	rows, err := s.db.Query("SELECT * FROM stockSubscriptions WHERE productId = ? AND notifiedAt IS NULL ORDER BY id", productID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	subscriptions := make([]types.StockSubscription, 0)
+	for rows.Next() {
+		subscription := new(types.StockSubscription)
+		if err := rows.Scan(stockSubscriptionAllFieldsScanner(subscription)); err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, *subscription)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+func (s *Store) MarkSubscriptionsNotified(subscriptionIDs []int) error {
+	if len(subscriptionIDs) == 0 {
+		return nil
+	}
+
+	placeholders := strings.Repeat(",?", len(subscriptionIDs)-1)
+	query := fmt.Sprintf("UPDATE stockSubscriptions SET notifiedAt = CURRENT_TIMESTAMP WHERE id IN (?%v)", placeholders)
+
+	args := make([]interface{}, len(subscriptionIDs))
+	for i, val := range subscriptionIDs {
+		args[i] = val
+	}
+
+	_, err := s.db.Exec(query, args...)
+	return err
+}
+
+func stockAlertAllFieldsScanner(alert *types.StockAlert) (*int, *int, *string, *int, *string, *time.Time, **time.Time) {
+	return &alert.ID, &alert.ProductID, &alert.Kind, &alert.Quantity, &alert.Status, &alert.CreatedAt, &alert.SentAt
+}
+
+func stockSubscriptionAllFieldsScanner(subscription *types.StockSubscription) (*int, *int, *int, *string, *time.Time, **time.Time) {
+	return &subscription.ID,
+		&subscription.ProductID,
+		&subscription.UserID,
+		&subscription.Email,
+		&subscription.CreatedAt,
+		&subscription.NotifiedAt
+}
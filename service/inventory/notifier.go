@@ -0,0 +1,157 @@
+package inventory
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// how many pending alerts are handled on every tick.
+const stockAlertsBatchSize = 100
+
+// the notifier only needs to read the products, not the whole ProductStore.
+type productGetter interface {
+	GetProductById(id int) (types.Product, error)
+}
+
+// StockNotifier sends the stock alerts recorded by the movements, at most one alert of each kind
+// is sent per product within rateLimit.
+type StockNotifier struct {
+	store        types.StockNotificationStore
+	productStore productGetter
+	mailer       types.Mailer
+	staffEmails  []string
+	rateLimit    time.Duration
+	now          func() time.Time
+}
+
+func NewStockNotifier(store types.StockNotificationStore, productStore productGetter, mailer types.Mailer, staffEmails []string, rateLimit time.Duration) *StockNotifier {
+	return &StockNotifier{
+		store:        store,
+		productStore: productStore,
+		mailer:       mailer,
+		staffEmails:  staffEmails,
+		rateLimit:    rateLimit,
+		now:          time.Now,
+	}
+}
+
+// Dispatch handles the pending alerts, the ones that fail to send stay pending and are retried on the next call.
+func (n *StockNotifier) Dispatch() error {
+	alerts, err := n.store.GetPendingStockAlerts(stockAlertsBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range alerts {
+		status, err := n.handleAlert(alert)
+		if err != nil {
+			log.Printf("stock notifier: alert %d: %v", alert.ID, err)
+			continue
+		}
+		if status == types.StockAlertStatusPending {
+			continue
+		}
+
+		if err := n.store.SetStockAlertStatus(alert.ID, status); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returns the status the alert ends up in.
+func (n *StockNotifier) handleAlert(alert types.StockAlert) (string, error) {
+	lastSent, err := n.store.GetLastSentStockAlert(alert.ProductID, alert.Kind)
+	if err != nil {
+		return "", err
+	}
+
+	rateLimited := lastSent != nil && lastSent.SentAt != nil && n.now().Sub(*lastSent.SentAt) < n.rateLimit
+	if rateLimited {
+		// staff already knows, while the customers are still waiting so their email is only delayed.
+		if alert.Kind == types.StockAlertBackInStock {
+			return types.StockAlertStatusPending, nil
+		}
+		return types.StockAlertStatusSuppressed, nil
+	}
+
+	product, err := n.productStore.GetProductById(alert.ProductID)
+	if err != nil {
+		return "", err
+	}
+	if product.DeletedAt != nil {
+		return types.StockAlertStatusSkipped, nil
+	}
+
+	if alert.Kind == types.StockAlertBackInStock {
+		return n.notifySubscribers(product)
+	}
+
+	return n.notifyStaff(alert, product)
+}
+
+func (n *StockNotifier) notifyStaff(alert types.StockAlert, product types.Product) (string, error) {
+	if len(n.staffEmails) == 0 {
+		return types.StockAlertStatusSkipped, nil
+	}
+
+	subject := fmt.Sprintf("%s is out of stock", product.Name)
+	body := fmt.Sprintf("Product #%d %s sold out.", product.ID, product.Name)
+	if alert.Kind == types.StockAlertLowStock {
+		subject = fmt.Sprintf("%s is running low", product.Name)
+		body = fmt.Sprintf("Product #%d %s is down to %d units.", product.ID, product.Name, alert.Quantity)
+	}
+
+	err := n.mailer.Send(types.Email{To: n.staffEmails, Subject: subject, Body: body})
+	if err != nil {
+		return "", err
+	}
+
+	return types.StockAlertStatusSent, nil
+}
+
+// every waiting customer gets their own email and is only notified once.
+func (n *StockNotifier) notifySubscribers(product types.Product) (string, error) {
+	// the stock may have sold out again before the alert was handled, the customers keep waiting.
+	if product.Available <= 0 {
+		return types.StockAlertStatusSkipped, nil
+	}
+
+	subscriptions, err := n.store.GetWaitingSubscriptions(product.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(subscriptions) == 0 {
+		return types.StockAlertStatusSkipped, nil
+	}
+
+	notified := make([]int, 0, len(subscriptions))
+	var sendErr error
+	for _, subscription := range subscriptions {
+		err := n.mailer.Send(types.Email{
+			To:      []string{subscription.Email},
+			Subject: fmt.Sprintf("%s is back in stock", product.Name),
+			Body:    fmt.Sprintf("Good news, %s is available again.", product.Name),
+		})
+		if err != nil {
+			sendErr = err
+			continue
+		}
+
+		notified = append(notified, subscription.ID)
+	}
+
+	if err := n.store.MarkSubscriptionsNotified(notified); err != nil {
+		return "", err
+	}
+	if sendErr != nil {
+		// the alert stays pending so the customers that were missed are retried.
+		return "", sendErr
+	}
+
+	return types.StockAlertStatusSent, nil
+}
@@ -0,0 +1,196 @@
+package inventory
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestStockAlertKinds(t *testing.T) {
+	threshold := 5
+	cases := []struct {
+		before, after int
+		threshold     *int
+		expected      string
+	}{
+		{before: 6, after: 5, threshold: &threshold, expected: types.StockAlertLowStock},
+		{before: 5, after: 4, threshold: &threshold, expected: ""},
+		{before: 3, after: 0, threshold: &threshold, expected: types.StockAlertOutOfStock},
+		{before: 3, after: 0, threshold: nil, expected: types.StockAlertOutOfStock},
+		{before: 10, after: 2, threshold: nil, expected: ""},
+		{before: 0, after: 4, threshold: &threshold, expected: types.StockAlertBackInStock},
+	}
+
+	for _, c := range cases {
+		kinds := stockAlertKinds(c.threshold, c.before, c.after)
+		got := ""
+		if len(kinds) > 0 {
+			got = kinds[0]
+		}
+
+		if got != c.expected {
+			t.Errorf("moving from %d to %d expected '%s' got '%s'", c.before, c.after, c.expected, got)
+		}
+	}
+}
+
+func TestStockNotifier(t *testing.T) {
+	now := time.Date(2024, 10, 24, 12, 0, 0, 0, time.UTC)
+
+	newNotifier := func(store *mockNotificationStore, mailer *mockMailer, product types.Product) *StockNotifier {
+		notifier := NewStockNotifier(store, mockProductGetter{product: product}, mailer, []string{"ops@example.com"}, time.Hour)
+		notifier.now = func() time.Time { return now }
+		return notifier
+	}
+
+	t.Run("Should email staff when a product runs low", func(t *testing.T) {
+		store := &mockNotificationStore{alerts: []types.StockAlert{{ID: 1, ProductID: 7, Kind: types.StockAlertLowStock, Quantity: 2}}}
+		mailer := &mockMailer{}
+
+		if err := newNotifier(store, mailer, types.Product{ID: 7, Name: "Mug"}).Dispatch(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(mailer.sent) != 1 || mailer.sent[0].To[0] != "ops@example.com" {
+			t.Fatalf("expected one email to staff got %+v", mailer.sent)
+		}
+		if store.statuses[1] != types.StockAlertStatusSent {
+			t.Errorf("expected alert to be sent got '%s'", store.statuses[1])
+		}
+	})
+
+	t.Run("Should suppress a staff alert sent within the rate limit", func(t *testing.T) {
+		sentAt := now.Add(-10 * time.Minute)
+		store := &mockNotificationStore{
+			alerts:   []types.StockAlert{{ID: 2, ProductID: 7, Kind: types.StockAlertOutOfStock}},
+			lastSent: &types.StockAlert{ID: 1, SentAt: &sentAt},
+		}
+		mailer := &mockMailer{}
+
+		if err := newNotifier(store, mailer, types.Product{ID: 7, Name: "Mug"}).Dispatch(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(mailer.sent) != 0 {
+			t.Errorf("expected no email got %d", len(mailer.sent))
+		}
+		if store.statuses[2] != types.StockAlertStatusSuppressed {
+			t.Errorf("expected alert to be suppressed got '%s'", store.statuses[2])
+		}
+	})
+
+	t.Run("Should email every waiting customer once when back in stock", func(t *testing.T) {
+		store := &mockNotificationStore{
+			alerts: []types.StockAlert{{ID: 3, ProductID: 7, Kind: types.StockAlertBackInStock}},
+			subscriptions: []types.StockSubscription{
+				{ID: 10, ProductID: 7, Email: "a@example.com"},
+				{ID: 11, ProductID: 7, Email: "b@example.com"},
+			},
+		}
+		mailer := &mockMailer{}
+
+		if err := newNotifier(store, mailer, types.Product{ID: 7, Name: "Mug", Available: 4}).Dispatch(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(mailer.sent) != 2 || len(mailer.sent[0].To) != 1 {
+			t.Fatalf("expected one email per customer got %+v", mailer.sent)
+		}
+		if len(store.notified) != 2 {
+			t.Errorf("expected both subscriptions to be marked as notified got %v", store.notified)
+		}
+	})
+
+	t.Run("Should keep the customers waiting when the product sold out again", func(t *testing.T) {
+		store := &mockNotificationStore{
+			alerts:        []types.StockAlert{{ID: 4, ProductID: 7, Kind: types.StockAlertBackInStock}},
+			subscriptions: []types.StockSubscription{{ID: 10, ProductID: 7, Email: "a@example.com"}},
+		}
+		mailer := &mockMailer{}
+
+		if err := newNotifier(store, mailer, types.Product{ID: 7, Name: "Mug", Available: 0}).Dispatch(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(mailer.sent) != 0 || len(store.notified) != 0 {
+			t.Errorf("expected nobody to be notified")
+		}
+		if store.statuses[4] != types.StockAlertStatusSkipped {
+			t.Errorf("expected alert to be skipped got '%s'", store.statuses[4])
+		}
+	})
+
+	t.Run("Should leave the alert pending when the email fails", func(t *testing.T) {
+		store := &mockNotificationStore{alerts: []types.StockAlert{{ID: 5, ProductID: 7, Kind: types.StockAlertOutOfStock}}}
+		mailer := &mockMailer{fail: true}
+
+		if err := newNotifier(store, mailer, types.Product{ID: 7, Name: "Mug"}).Dispatch(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := store.statuses[5]; ok {
+			t.Errorf("expected alert to stay pending got '%s'", store.statuses[5])
+		}
+	})
+}
+
+type mockNotificationStore struct {
+	alerts        []types.StockAlert
+	lastSent      *types.StockAlert
+	subscriptions []types.StockSubscription
+	statuses      map[int]string
+	notified      []int
+}
+
+func (m *mockNotificationStore) GetPendingStockAlerts(limit int) ([]types.StockAlert, error) {
+	return m.alerts, nil
+}
+
+func (m *mockNotificationStore) SetStockAlertStatus(alertID int, status string) error {
+	if m.statuses == nil {
+		m.statuses = map[int]string{}
+	}
+	m.statuses[alertID] = status
+	return nil
+}
+
+func (m *mockNotificationStore) GetLastSentStockAlert(productID int, kind string) (*types.StockAlert, error) {
+	return m.lastSent, nil
+}
+
+func (m *mockNotificationStore) Subscribe(productID, userID int, email string) (*types.StockSubscription, bool, error) {
+	return &types.StockSubscription{ProductID: productID, UserID: userID, Email: email}, true, nil
+}
+
+func (m *mockNotificationStore) GetWaitingSubscriptions(productID int) ([]types.StockSubscription, error) {
+	return m.subscriptions, nil
+}
+
+func (m *mockNotificationStore) MarkSubscriptionsNotified(subscriptionIDs []int) error {
+	m.notified = append(m.notified, subscriptionIDs...)
+	return nil
+}
+
+type mockMailer struct {
+	sent []types.Email
+	fail bool
+}
+
+func (m *mockMailer) Send(email types.Email) error {
+	if m.fail {
+		return fmt.Errorf("smtp unavailable")
+	}
+
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+type mockProductGetter struct {
+	product types.Product
+}
+
+func (m mockProductGetter) GetProductById(id int) (types.Product, error) {
+	return m.product, nil
+}
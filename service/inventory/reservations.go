@@ -0,0 +1,241 @@
+package inventory
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// ReserveStock holds the allocated units in their warehouses until ttl passes, the available stock
+// of a location is its quantity minus its active reservations so two checkouts can't hold the same units.
+func (s *Store) ReserveStock(orderID, userID int, allocations []types.StockAllocation, ttl time.Duration) ([]types.StockReservation, error) {
+	// rows are locked in the same order as ApplyMovement does, so concurrent checkouts can't deadlock.
+	sortedAllocations := make([]types.StockAllocation, len(allocations))
+	copy(sortedAllocations, allocations)
+	sort.Slice(sortedAllocations, func(i, j int) bool {
+		if sortedAllocations[i].ProductID != sortedAllocations[j].ProductID {
+			return sortedAllocations[i].ProductID < sortedAllocations[j].ProductID
+		}
+		return sortedAllocations[i].WarehouseID < sortedAllocations[j].WarehouseID
+	})
+
+	reservations := make([]types.StockReservation, 0, len(allocations))
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		for _, allocation := range sortedAllocations {
+			if allocation.Quantity <= 0 {
+				return fmt.Errorf("product with id %v has invalid quantity", allocation.ProductID)
+			}
+
+			var productId int
+			err := tx.QueryRow("SELECT id FROM products WHERE id = ? AND deletedAt IS NULL FOR UPDATE", allocation.ProductID).Scan(&productId)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("no product was found for id %v", allocation.ProductID)
+			}
+			if err != nil {
+				return err
+			}
+
+			quantity, err := lockWarehouseStock(tx, allocation.WarehouseID, allocation.ProductID)
+			if err != nil {
+				return err
+			}
+
+			var reserved int
+			err = tx.QueryRow(`SELECT COALESCE(SUM(quantity), 0) FROM stockReservations
+			WHERE productId = ? AND warehouseId = ? AND status = ? AND expiresAt > CURRENT_TIMESTAMP`,
+				allocation.ProductID, allocation.WarehouseID, types.ReservationStatusActive).Scan(&reserved)
+			if err != nil {
+				return err
+			}
+
+			if available := quantity - reserved; available < allocation.Quantity {
+				return fmt.Errorf("%w for product with id %v in warehouse %v, requested %v but only %v are available",
+					types.ErrInsufficientStock, allocation.ProductID, allocation.WarehouseID, allocation.Quantity, max(0, available))
+			}
+
+			result, err := tx.Exec(`
+			INSERT INTO stockReservations (productId, orderId, userId, quantity, expiresAt, warehouseId)
+			VALUES (?,?,?,?, DATE_ADD(CURRENT_TIMESTAMP, INTERVAL ? SECOND), ?)`,
+				allocation.ProductID, orderID, userID, allocation.Quantity, int(ttl.Seconds()), allocation.WarehouseID)
+			if err != nil {
+				return err
+			}
+
+			reservationId, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+
+			reservation, err := scanRowIntoReservation(tx.QueryRow("SELECT * FROM stockReservations WHERE id = ?", reservationId))
+			if err != nil {
+				return err
+			}
+
+			reservations = append(reservations, *reservation)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+// CommitReservations turns the active reservations of a paid order into sale movements,
+// it fails with ErrReservationExpired when the order was paid too late.
+func (s *Store) CommitReservations(orderID int) error {
+	return myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		reservations, err := getOrderReservationsForUpdate(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		active := make([]types.StockReservation, 0, len(reservations))
+		for _, reservation := range reservations {
+			switch reservation.Status {
+			case types.ReservationStatusActive:
+				active = append(active, reservation)
+			case types.ReservationStatusExpired, types.ReservationStatusReleased:
+				return types.ErrReservationExpired
+			}
+		}
+		if len(active) == 0 {
+			return fmt.Errorf("order with id %v has no active stock reservations", orderID)
+		}
+
+		var expired bool
+		err = tx.QueryRow(`SELECT COUNT(*) > 0 FROM stockReservations
+		WHERE orderId = ? AND status = ? AND expiresAt <= CURRENT_TIMESTAMP`, orderID, types.ReservationStatusActive).Scan(&expired)
+		if err != nil {
+			return err
+		}
+		if expired {
+			return types.ErrReservationExpired
+		}
+
+		for _, reservation := range active {
+			_, err := ApplyMovement(tx, types.StockMovement{
+				ProductID:      reservation.ProductID,
+				QuantityChange: -reservation.Quantity,
+				Reason:         types.StockReasonSale,
+				ActorID:        &reservation.UserID,
+				OrderID:        &reservation.OrderID,
+				WarehouseID:    reservation.WarehouseID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return setOrderReservationsStatus(tx, orderID, types.ReservationStatusCommitted)
+	})
+}
+
+// ReleaseReservations gives the held stock back, e.g. when the payment of the order failed.
+func (s *Store) ReleaseReservations(orderID int) error {
+	return setOrderReservationsStatus(s.db, orderID, types.ReservationStatusReleased)
+}
+
+// ReleaseExpiredReservations marks every reservation that passed its expiry as expired
+// and returns the ids of the orders they belonged to.
+func (s *Store) ReleaseExpiredReservations() ([]int, error) {
+	orderIDs := make([]int, 0)
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		rows, err := tx.Query(`SELECT DISTINCT orderId FROM stockReservations
+		WHERE status = ? AND expiresAt <= CURRENT_TIMESTAMP FOR UPDATE`, types.ReservationStatusActive)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var orderID int
+			if err := rows.Scan(&orderID); err != nil {
+				return err
+			}
+
+			orderIDs = append(orderIDs, orderID)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, orderID := range orderIDs {
+			if err := setOrderReservationsStatus(tx, orderID, types.ReservationStatusExpired); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orderIDs, nil
+}
+
+func getOrderReservationsForUpdate(q myDB.DBTX, orderID int) ([]types.StockReservation, error) {
+	rows, err := q.Query("SELECT * FROM stockReservations WHERE orderId = ? ORDER BY productId FOR UPDATE", orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	reservations := make([]types.StockReservation, 0)
+	for rows.Next() {
+		reservation := new(types.StockReservation)
+		if err := rows.Scan(reservationAllFieldsScanner(reservation)); err != nil {
+			return nil, err
+		}
+
+		reservations = append(reservations, *reservation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(reservations) == 0 {
+		return nil, fmt.Errorf("no stock reservations were found for order with id %v", orderID)
+	}
+
+	return reservations, nil
+}
+
+// only active reservations change status, committed ones are final.
+func setOrderReservationsStatus(q myDB.DBTX, orderID int, status string) error {
+	_, err := q.Exec("UPDATE stockReservations SET status = ? WHERE orderId = ? AND status = ?",
+		status, orderID, types.ReservationStatusActive)
+	return err
+}
+
+func scanRowIntoReservation(row *sql.Row) (*types.StockReservation, error) {
+	reservation := new(types.StockReservation)
+	err := row.Scan(reservationAllFieldsScanner(reservation))
+	if err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+func reservationAllFieldsScanner(reservation *types.StockReservation) (*int, *int, *int, *int, *int, *string, *time.Time, *time.Time, *time.Time, **int) {
+	return &reservation.ID,
+		&reservation.ProductID,
+		&reservation.OrderID,
+		&reservation.UserID,
+		&reservation.Quantity,
+		&reservation.Status,
+		&reservation.ExpiresAt,
+		&reservation.CreatedAt,
+		&reservation.UpdatedAt,
+		&reservation.WarehouseID
+}
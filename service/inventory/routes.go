@@ -0,0 +1,221 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/middlewares"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+type Handler struct {
+	store             types.InventoryStore
+	notificationStore types.StockNotificationStore
+}
+
+func NewHandler(store types.InventoryStore, notificationStore types.StockNotificationStore) *Handler {
+	return &Handler{
+		store:             store,
+		notificationStore: notificationStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/products/{id}/stock/movements", auth.AdminMiddleware(h.RecordMovement)).Methods("POST")
+	router.HandleFunc("/admin/products/{id}/stock/movements", auth.AdminMiddleware(middlewares.PaginationMiddleware(h.GetMovements))).Methods("GET")
+	router.HandleFunc("/admin/products/{id}/stock/reconciliation", auth.AdminMiddleware(h.GetReconciliation)).Methods("GET")
+	router.HandleFunc("/admin/products/{id}/stock/reconciliation", auth.AdminMiddleware(h.Reconcile)).Methods("POST")
+	router.HandleFunc("/admin/products/{id}/stock/threshold", auth.AdminMiddleware(h.SetLowStockThreshold)).Methods("PUT")
+	router.HandleFunc("/products/{id}/notify-me", auth.AuthenticationMiddleware(h.NotifyMe)).Methods("POST")
+}
+
+func (h *Handler) RecordMovement(w http.ResponseWriter, r *http.Request) {
+	productId, err := getProductIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.StockMovementPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	movement := types.StockMovement{
+		ProductID:      productId,
+		QuantityChange: payload.QuantityChange,
+		Reason:         payload.Reason,
+		ActorID:        &tokenPayload.UserId,
+		Note:           payload.Note,
+	}
+	if payload.OrderID != 0 {
+		movement.OrderID = &payload.OrderID
+	}
+	if payload.PurchaseOrderRef != "" {
+		movement.PurchaseOrderRef = &payload.PurchaseOrderRef
+	}
+	if payload.WarehouseID != 0 {
+		movement.WarehouseID = &payload.WarehouseID
+	}
+
+	recorded, err := h.store.RecordMovement(movement)
+	if errors.Is(err, types.ErrInsufficientStock) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    recorded,
+	})
+}
+
+func (h *Handler) GetMovements(w http.ResponseWriter, r *http.Request) {
+	productId, err := getProductIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	pagination := middlewares.GetPagination(r)
+	offset := middlewares.CalculateOffset(pagination)
+
+	movements, count, err := h.store.GetProductMovements(productId, pagination.Limit, offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"movements": movements,
+		"page":      pagination.Page,
+		"limit":     pagination.Limit,
+		"count":     count,
+	})
+}
+
+// reports the drift between the product quantity and its ledger without changing anything.
+func (h *Handler) GetReconciliation(w http.ResponseWriter, r *http.Request) {
+	h.reconcile(w, r, false)
+}
+
+// resets the product quantity to the ledger sum.
+func (h *Handler) Reconcile(w http.ResponseWriter, r *http.Request) {
+	h.reconcile(w, r, true)
+}
+
+func (h *Handler) reconcile(w http.ResponseWriter, r *http.Request, fix bool) {
+	productId, err := getProductIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reconciliation, err := h.store.ReconcileProduct(productId, fix)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    reconciliation,
+	})
+}
+
+func (h *Handler) SetLowStockThreshold(w http.ResponseWriter, r *http.Request) {
+	productId, err := getProductIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.LowStockThresholdPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.SetLowStockThreshold(productId, payload.Threshold); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    payload,
+	})
+}
+
+// subscribes the customer to a back in stock email for a product that sold out.
+func (h *Handler) NotifyMe(w http.ResponseWriter, r *http.Request) {
+	productId, err := getProductIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	subscription, created, err := h.notificationStore.Subscribe(productId, tokenPayload.UserId, tokenPayload.Email)
+	if errors.Is(err, types.ErrProductInStock) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	utils.WriteJSON(w, status, map[string]any{
+		"message": "success",
+		"data":    subscription,
+	})
+}
+
+func getProductIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("product id must be unsigned integer")
+	}
+
+	return id, nil
+}
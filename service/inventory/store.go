@@ -0,0 +1,357 @@
+package inventory
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// returns a store that runs its queries inside the given transaction.
+func (s *Store) WithTx(tx myDB.DBTX) types.InventoryStore {
+	return &Store{
+		db: tx,
+	}
+}
+
+func (s *Store) RecordMovement(movement types.StockMovement) (*types.StockMovement, error) {
+	if err := validateMovementDirection(movement); err != nil {
+		return nil, err
+	}
+
+	var recorded *types.StockMovement
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		var err error
+		recorded, err = ApplyMovement(tx, movement)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recorded, nil
+}
+
+// ApplyMovement locks the product and its warehouse stock rows, moves both quantities and appends
+// the movement to the ledger, movements without a warehouse go to the default one.
+// It must run inside a transaction so the quantities and the ledger never disagree,
+// it's exported for the product store which changes quantities as part of its own updates.
+func ApplyMovement(q myDB.DBTX, movement types.StockMovement) (*types.StockMovement, error) {
+	var quantity int
+	var lowStockThreshold *int
+	err := q.QueryRow("SELECT quantity, lowStockThreshold FROM products WHERE id = ? FOR UPDATE", movement.ProductID).
+		Scan(&quantity, &lowStockThreshold)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no product was found for id %v", movement.ProductID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if movement.WarehouseID == nil {
+		warehouseId, err := DefaultWarehouseID(q)
+		if err != nil {
+			return nil, err
+		}
+		movement.WarehouseID = &warehouseId
+	}
+
+	locationQuantity, err := lockWarehouseStock(q, *movement.WarehouseID, movement.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	quantityAfter := quantity + movement.QuantityChange
+	locationQuantityAfter := locationQuantity + movement.QuantityChange
+	if quantityAfter < 0 || locationQuantityAfter < 0 {
+		return nil, fmt.Errorf("%w for product with id %v in warehouse %v, requested %v but only %v are available",
+			types.ErrInsufficientStock, movement.ProductID, *movement.WarehouseID, -movement.QuantityChange, locationQuantity)
+	}
+
+	_, err = q.Exec(`
+	INSERT INTO warehouseStock (warehouseId, productId, quantity) VALUES (?,?,?)
+	ON DUPLICATE KEY UPDATE quantity = ?`,
+		*movement.WarehouseID, movement.ProductID, locationQuantityAfter, locationQuantityAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = q.Exec("UPDATE products SET quantity = ?, version = version + 1 WHERE id = ?", quantityAfter, movement.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordStockAlerts(q, movement.ProductID, lowStockThreshold, quantity, quantityAfter); err != nil {
+		return nil, err
+	}
+
+	result, err := q.Exec(`
+	INSERT INTO stockMovements (productId, quantityChange, quantityAfter, reason, actorId, orderId, purchaseOrderRef, note, warehouseId)
+	VALUES (?,?,?,?,?,?,?,?,?)`,
+		movement.ProductID, movement.QuantityChange, quantityAfter, movement.Reason,
+		movement.ActorID, movement.OrderID, movement.PurchaseOrderRef, movement.Note, movement.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	movementId, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	row := q.QueryRow("SELECT * FROM stockMovements WHERE id = ?", movementId)
+	return scanRowIntoMovement(row)
+}
+
+// DefaultWarehouseID returns the active warehouse with the highest priority,
+// it receives the stock that isn't moved in or out of a specific location.
+func DefaultWarehouseID(q myDB.DBTX) (int, error) {
+	var id int
+	err := q.QueryRow("SELECT id FROM warehouses WHERE active ORDER BY priority, id LIMIT 1").Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("there is no active warehouse to hold the stock")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// returns the quantity of the product in the warehouse, 0 when the warehouse never held it.
+func lockWarehouseStock(q myDB.DBTX, warehouseID, productID int) (int, error) {
+	var quantity int
+	err := q.QueryRow("SELECT quantity FROM warehouseStock WHERE warehouseId = ? AND productId = ? FOR UPDATE", warehouseID, productID).
+		Scan(&quantity)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return quantity, nil
+}
+
+// newest movements first.
+func (s *Store) GetProductMovements(productID, limit, offset int) ([]types.StockMovement, int, error) {
+	rows, err := s.db.Query("SELECT * FROM stockMovements WHERE productId = ? ORDER BY id DESC LIMIT ? OFFSET ?", productID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	movements := make([]types.StockMovement, 0)
+	for rows.Next() {
+		movement := new(types.StockMovement)
+		if err := rows.Scan(movementAllFieldsScanner(movement)); err != nil {
+			return nil, 0, err
+		}
+
+		movements = append(movements, *movement)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM stockMovements WHERE productId = ?", productID).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return movements, count, nil
+}
+
+// null disables the low stock alerts of the product.
+func (s *Store) SetLowStockThreshold(productID int, threshold *int) error {
+	result, err := s.db.Exec("UPDATE products SET lowStockThreshold = ? WHERE id = ?", threshold, productID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		var exists bool
+		err := s.db.QueryRow("SELECT COUNT(*) > 0 FROM products WHERE id = ?", productID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("no product was found for id %v", productID)
+		}
+	}
+
+	return nil
+}
+
+// ReconcileProduct compares the product quantity and its warehouses stock with the sum of its ledger,
+// when fix is true both are reset to what the ledger says.
+func (s *Store) ReconcileProduct(productID int, fix bool) (*types.StockReconciliation, error) {
+	reconciliation := &types.StockReconciliation{ProductID: productID}
+
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		err := tx.QueryRow("SELECT quantity FROM products WHERE id = ? FOR UPDATE", productID).Scan(&reconciliation.Quantity)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no product was found for id %v", productID)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow("SELECT COALESCE(SUM(quantity), 0) FROM warehouseStock WHERE productId = ?", productID).
+			Scan(&reconciliation.WarehouseQuantity)
+		if err != nil {
+			return err
+		}
+
+		ledger, err := getLedgerByWarehouse(tx, productID)
+		if err != nil {
+			return err
+		}
+		for _, quantity := range ledger {
+			reconciliation.LedgerQuantity += quantity
+		}
+
+		reconciliation.Difference = reconciliation.Quantity - reconciliation.LedgerQuantity
+		inSync := reconciliation.Difference == 0 && reconciliation.WarehouseQuantity == reconciliation.LedgerQuantity
+		if !fix || inSync {
+			return nil
+		}
+
+		for warehouseId, quantity := range ledger {
+			if quantity < 0 {
+				return fmt.Errorf("ledger of product with id %v in warehouse %v sums to %v, it must be corrected with an adjustment first",
+					productID, warehouseId, quantity)
+			}
+		}
+
+		_, err = tx.Exec("UPDATE warehouseStock SET quantity = 0 WHERE productId = ?", productID)
+		if err != nil {
+			return err
+		}
+
+		for warehouseId, quantity := range ledger {
+			_, err = tx.Exec(`
+			INSERT INTO warehouseStock (warehouseId, productId, quantity) VALUES (?,?,?)
+			ON DUPLICATE KEY UPDATE quantity = ?`, warehouseId, productID, quantity, quantity)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec("UPDATE products SET quantity = ?, version = version + 1 WHERE id = ?", reconciliation.LedgerQuantity, productID)
+		if err != nil {
+			return err
+		}
+		reconciliation.Fixed = true
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reconciliation, nil
+}
+
+// returns the ledger sum of the product per warehouse, movements recorded without one count for the default warehouse.
+func getLedgerByWarehouse(q myDB.DBTX, productID int) (map[int]int, error) {
+	rows, err := q.Query("SELECT warehouseId, SUM(quantityChange) FROM stockMovements WHERE productId = ? GROUP BY warehouseId", productID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ledger := make(map[int]int)
+	var unassigned int
+	for rows.Next() {
+		var warehouseId *int
+		var quantity int
+		if err := rows.Scan(&warehouseId, &quantity); err != nil {
+			return nil, err
+		}
+
+		if warehouseId == nil {
+			unassigned += quantity
+			continue
+		}
+		ledger[*warehouseId] += quantity
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if unassigned != 0 {
+		defaultId, err := DefaultWarehouseID(q)
+		if err != nil {
+			return nil, err
+		}
+		ledger[defaultId] += unassigned
+	}
+
+	return ledger, nil
+}
+
+// sales and damages take stock out, restocks, returns and cancellations put it back, adjustments go both ways.
+func validateMovementDirection(movement types.StockMovement) error {
+	switch movement.Reason {
+	case types.StockReasonSale, types.StockReasonDamage:
+		if movement.QuantityChange >= 0 {
+			return fmt.Errorf("%s movements must have a negative quantity change", movement.Reason)
+		}
+	case types.StockReasonRestock, types.StockReasonReturn, types.StockReasonCancellation:
+		if movement.QuantityChange <= 0 {
+			return fmt.Errorf("%s movements must have a positive quantity change", movement.Reason)
+		}
+	case types.StockReasonAdjustment:
+		if movement.QuantityChange == 0 {
+			return fmt.Errorf("adjustment movements must change the quantity")
+		}
+	default:
+		return fmt.Errorf("unknown stock movement reason '%s'", movement.Reason)
+	}
+
+	return nil
+}
+
+func scanRowIntoMovement(row *sql.Row) (*types.StockMovement, error) {
+	movement := new(types.StockMovement)
+	err := row.Scan(movementAllFieldsScanner(movement))
+	if err != nil {
+		return nil, err
+	}
+
+	return movement, nil
+}
+
+func movementAllFieldsScanner(movement *types.StockMovement) (*int, *int, *int, *int, *string, **int, **int, **string, *string, *time.Time, **int) {
+	return &movement.ID,
+		&movement.ProductID,
+		&movement.QuantityChange,
+		&movement.QuantityAfter,
+		&movement.Reason,
+		&movement.ActorID,
+		&movement.OrderID,
+		&movement.PurchaseOrderRef,
+		&movement.Note,
+		&movement.CreatedAt,
+		&movement.WarehouseID
+}
@@ -0,0 +1,36 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestValidateMovementDirection(t *testing.T) {
+	cases := []struct {
+		reason    string
+		change    int
+		expectErr bool
+	}{
+		{reason: types.StockReasonSale, change: -2},
+		{reason: types.StockReasonSale, change: 2, expectErr: true},
+		{reason: types.StockReasonDamage, change: 1, expectErr: true},
+		{reason: types.StockReasonRestock, change: 10},
+		{reason: types.StockReasonRestock, change: -10, expectErr: true},
+		{reason: types.StockReasonReturn, change: 1},
+		{reason: types.StockReasonCancellation, change: 0, expectErr: true},
+		{reason: types.StockReasonAdjustment, change: -4},
+		{reason: types.StockReasonAdjustment, change: 0, expectErr: true},
+		{reason: "theft", change: -1, expectErr: true},
+	}
+
+	for _, c := range cases {
+		err := validateMovementDirection(types.StockMovement{Reason: c.reason, QuantityChange: c.change})
+		if c.expectErr && err == nil {
+			t.Errorf("expected %s movement of %d to be rejected", c.reason, c.change)
+		}
+		if !c.expectErr && err != nil {
+			t.Errorf("expected %s movement of %d to be accepted got %v", c.reason, c.change, err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package inventory
+
+import (
+	"log"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// SweepExpiredReservations releases the expired reservations and cancels their orders.
+func SweepExpiredReservations(db myDB.DBTX, inventoryStore types.InventoryStore, orderStore types.OrderStore) error {
+	return myDB.InTx(db, func(tx myDB.DBTX) error {
+		orderIDs, err := inventoryStore.WithTx(tx).ReleaseExpiredReservations()
+		if err != nil {
+			return err
+		}
+
+		for _, orderID := range orderIDs {
+			if err := orderStore.WithTx(tx).UpdateOrderStatus(orderID, types.OrderStatusCancelled); err != nil {
+				return err
+			}
+		}
+
+		if len(orderIDs) > 0 {
+			log.Printf("reservation sweeper: cancelled %d expired orders", len(orderIDs))
+		}
+
+		return nil
+	})
+}
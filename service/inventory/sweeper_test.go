@@ -0,0 +1,126 @@
+package inventory
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestSweepExpiredReservations(t *testing.T) {
+	t.Run("Should cancel the orders of the expired reservations", func(t *testing.T) {
+		inventoryStore := &mockInventoryStore{expiredOrderIDs: []int{3, 7}}
+		orderStore := &mockOrderStore{statuses: map[int]string{}}
+
+		if err := SweepExpiredReservations(nil, inventoryStore, orderStore); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, orderID := range []int{3, 7} {
+			if orderStore.statuses[orderID] != types.OrderStatusCancelled {
+				t.Errorf("expected order %d to be cancelled got '%s'", orderID, orderStore.statuses[orderID])
+			}
+		}
+	})
+
+	t.Run("Should stop when the order can't be cancelled", func(t *testing.T) {
+		inventoryStore := &mockInventoryStore{expiredOrderIDs: []int{3}}
+		orderStore := &mockOrderStore{fail: true}
+
+		if err := SweepExpiredReservations(nil, inventoryStore, orderStore); err == nil {
+			t.Error("expected the sweep to fail")
+		}
+	})
+}
+
+type mockInventoryStore struct {
+	expiredOrderIDs []int
+}
+
+func (m *mockInventoryStore) WithTx(tx myDB.DBTX) types.InventoryStore {
+	return m
+}
+
+func (m *mockInventoryStore) RecordMovement(movement types.StockMovement) (*types.StockMovement, error) {
+	return &movement, nil
+}
+
+func (m *mockInventoryStore) GetProductMovements(productID, limit, offset int) ([]types.StockMovement, int, error) {
+	return nil, 0, nil
+}
+
+func (m *mockInventoryStore) ReconcileProduct(productID int, fix bool) (*types.StockReconciliation, error) {
+	return nil, nil
+}
+
+func (m *mockInventoryStore) ReserveStock(orderID, userID int, allocations []types.StockAllocation, ttl time.Duration) ([]types.StockReservation, error) {
+	return nil, nil
+}
+
+func (m *mockInventoryStore) CommitReservations(orderID int) error {
+	return nil
+}
+
+func (m *mockInventoryStore) ReleaseReservations(orderID int) error {
+	return nil
+}
+
+func (m *mockInventoryStore) ReleaseExpiredReservations() ([]int, error) {
+	return m.expiredOrderIDs, nil
+}
+
+func (m *mockInventoryStore) SetLowStockThreshold(productID int, threshold *int) error {
+	return nil
+}
+
+type mockOrderStore struct {
+	statuses map[int]string
+	fail     bool
+}
+
+func (m *mockOrderStore) WithTx(tx myDB.DBTX) types.OrderStore {
+	return m
+}
+
+func (m *mockOrderStore) CreateOrder(order types.Order) (types.Order, error) {
+	return order, nil
+}
+
+func (m *mockOrderStore) CreateOrderItem(orderItem types.OrderItem) (types.OrderItem, error) {
+	return orderItem, nil
+}
+
+func (m *mockOrderStore) CreateOrderItemTaxes(orderItemID int, taxes []types.TaxLine) error {
+	return nil
+}
+
+func (m *mockOrderStore) GetOrderById(id int) (*types.Order, error) {
+	return &types.Order{ID: id, Status: m.statuses[id]}, nil
+}
+
+func (m *mockOrderStore) GetOrderItems(orderID int) ([]types.OrderItem, error) {
+	return nil, nil
+}
+
+func (m *mockOrderStore) UpdateOrderStatus(orderID int, status string) error {
+	if m.fail {
+		return fmt.Errorf("no order was found for id %v", orderID)
+	}
+
+	m.statuses[orderID] = status
+	return nil
+}
+
+func (m *mockOrderStore) CreateShipment(shipment types.Shipment) (*types.Shipment, error) {
+	return &shipment, nil
+}
+
+func (m *mockOrderStore) GetOrderShipments(orderID int) ([]types.Shipment, error) {
+	return nil, nil
+}
+
+func (m *mockOrderStore) UpdateShipmentStatus(shipmentID int, status string) (*types.Shipment, error) {
+	return nil, nil
+}
@@ -0,0 +1,204 @@
+package invoice
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// buildInvoice lays out the completed order as an invoice, its totals are the ones the order was charged.
+// The items of the products that were deleted since are described by their id.
+func buildInvoice(order *types.Order, orderItems []types.OrderItem, productsMap map[int]types.Product, seller types.InvoiceParty,
+	buyer *types.User, issuedAt time.Time) types.Invoice {
+	invoice := types.Invoice{
+		Kind:          types.InvoiceKindInvoice,
+		OrderID:       order.ID,
+		UserID:        order.UserID,
+		Seller:        seller,
+		Buyer:         buyerParty(order, buyer),
+		Lines:         make([]types.InvoiceLine, 0, len(orderItems)),
+		Taxes:         make([]types.TaxSummary, 0),
+		Subtotal:      order.Subtotal,
+		DiscountTotal: order.DiscountTotal,
+		ShippingTotal: order.ShippingTotal,
+		TaxTotal:      order.TaxTotal,
+		Total:         order.Total,
+		IssuedAt:      issuedAt,
+	}
+	if order.Pricing != nil {
+		invoice.TaxInclusive = order.Pricing.TaxInclusive
+		invoice.Taxes = order.Pricing.Taxes
+		if order.Pricing.ShippingRate != nil {
+			invoice.ShippingMethod = order.Pricing.ShippingRate.Name
+		}
+	}
+
+	for i, orderItem := range orderItems {
+		orderItemId, productId := orderItem.ID, orderItem.ProductID
+		line := types.InvoiceLine{
+			OrderItemID: &orderItemId,
+			ProductID:   &productId,
+			Description: fmt.Sprintf("Product #%d", orderItem.ProductID),
+			Quantity:    orderItem.Quantity,
+			UnitPrice:   orderItem.Price,
+			Subtotal:    orderItem.Price.Mul(int64(orderItem.Quantity)),
+			Discount:    money.Zero(),
+			Tax:         money.Zero(),
+		}
+		if product, ok := productsMap[orderItem.ProductID]; ok {
+			line.Description = product.Name
+		}
+
+		// the lines of the breakdown follow the order items, the orders placed before it was stored
+		// were charged the prices.
+		if order.Pricing != nil && i < len(order.Pricing.Lines) {
+			priced := order.Pricing.Lines[i]
+			line.UnitPrice = priced.UnitPrice
+			line.Subtotal = priced.Subtotal
+			line.Discount = priced.Discount
+			for _, tax := range priced.Taxes {
+				line.Tax = line.Tax.Add(tax.Amount)
+			}
+		}
+		line.Total = lineTotal(line, invoice.TaxInclusive)
+
+		invoice.Lines = append(invoice.Lines, line)
+	}
+
+	return invoice
+}
+
+// buildReturnCreditNote credits the items received back by the return, every item is credited the
+// amount the return refunds for it with the share of tax of its invoice line.
+func buildReturnCreditNote(invoice *types.Invoice, ret *types.Return, reason string, issuedAt time.Time) (types.Invoice, error) {
+	if ret.OrderID != invoice.OrderID {
+		return types.Invoice{}, fmt.Errorf("return with id %v isn't for the order of invoice %s", ret.ID, invoice.Number)
+	}
+	if ret.Status != types.ReturnStatusReceived && ret.Status != types.ReturnStatusPartiallyRefunded && ret.Status != types.ReturnStatusRefunded {
+		return types.Invoice{}, fmt.Errorf("return with id %v is %s, only received returns can be credited", ret.ID, ret.Status)
+	}
+
+	invoiceLines := make(map[int]types.InvoiceLine)
+	for _, line := range invoice.Lines {
+		if line.OrderItemID != nil {
+			invoiceLines[*line.OrderItemID] = line
+		}
+	}
+
+	creditNote := newCreditNote(invoice, reason, issuedAt)
+	for _, item := range ret.Items {
+		invoiceLine, ok := invoiceLines[item.OrderItemID]
+		if !ok || item.ReceivedQuantity == 0 || item.RefundAmount.IsZero() {
+			continue
+		}
+
+		tax := money.Zero()
+		if invoiceLine.Total.IsPositive() {
+			tax = invoiceLine.Tax.MulDiv(item.RefundAmount.Amount, invoiceLine.Total.Amount, money.TaxRounding)
+		}
+		creditNote.Lines = append(creditNote.Lines, creditLine(invoiceLine.Description, invoiceLine.OrderItemID,
+			invoiceLine.ProductID, item.ReceivedQuantity, invoiceLine.UnitPrice, item.RefundAmount, tax, invoice.TaxInclusive))
+	}
+	if len(creditNote.Lines) == 0 {
+		return types.Invoice{}, fmt.Errorf("return with id %v has nothing to credit", ret.ID)
+	}
+
+	return totalCreditNote(creditNote), nil
+}
+
+// buildAmountCreditNote credits an amount of the invoice as a single line, with the share of the
+// invoice tax it carries.
+func buildAmountCreditNote(invoice *types.Invoice, amount money.Money, reason string, issuedAt time.Time) (types.Invoice, error) {
+	if !amount.IsPositive() {
+		return types.Invoice{}, fmt.Errorf("the amount to credit must be more than 0")
+	}
+
+	tax := money.Zero()
+	if invoice.Total.IsPositive() {
+		tax = invoice.TaxTotal.MulDiv(amount.Amount, invoice.Total.Amount, money.TaxRounding)
+	}
+	unitPrice := amount
+	if !invoice.TaxInclusive {
+		unitPrice = amount.Sub(tax)
+	}
+
+	creditNote := newCreditNote(invoice, reason, issuedAt)
+	creditNote.Lines = append(creditNote.Lines, creditLine(reason, nil, nil, 1, unitPrice, amount, tax, invoice.TaxInclusive))
+
+	return totalCreditNote(creditNote), nil
+}
+
+func newCreditNote(invoice *types.Invoice, reason string, issuedAt time.Time) types.Invoice {
+	invoiceId := invoice.ID
+	return types.Invoice{
+		Kind:              types.InvoiceKindCreditNote,
+		OrderID:           invoice.OrderID,
+		UserID:            invoice.UserID,
+		CreditedInvoiceID: &invoiceId,
+		CreditedNumber:    invoice.Number,
+		Reason:            reason,
+		Seller:            invoice.Seller,
+		Buyer:             invoice.Buyer,
+		Lines:             make([]types.InvoiceLine, 0),
+		TaxInclusive:      invoice.TaxInclusive,
+		Taxes:             make([]types.TaxSummary, 0),
+		IssuedAt:          issuedAt,
+	}
+}
+
+// creditLine credits total for quantity units, what total falls short of their price is shown as discount.
+func creditLine(description string, orderItemID, productID *int, quantity int, unitPrice, total, tax money.Money,
+	taxInclusive bool) types.InvoiceLine {
+	subtotal := unitPrice.Mul(int64(quantity))
+	discount := subtotal.Sub(total)
+	if !taxInclusive {
+		discount = discount.Add(tax)
+	}
+
+	return types.InvoiceLine{
+		OrderItemID: orderItemID,
+		ProductID:   productID,
+		Description: description,
+		Quantity:    quantity,
+		UnitPrice:   unitPrice,
+		Subtotal:    subtotal,
+		Discount:    money.Max(discount, money.Zero()),
+		Tax:         tax,
+		Total:       total,
+	}
+}
+
+func totalCreditNote(creditNote types.Invoice) types.Invoice {
+	creditNote.Subtotal, creditNote.DiscountTotal = money.Zero(), money.Zero()
+	creditNote.ShippingTotal, creditNote.TaxTotal, creditNote.Total = money.Zero(), money.Zero(), money.Zero()
+	for _, line := range creditNote.Lines {
+		creditNote.Subtotal = creditNote.Subtotal.Add(line.Subtotal)
+		creditNote.DiscountTotal = creditNote.DiscountTotal.Add(line.Discount)
+		creditNote.TaxTotal = creditNote.TaxTotal.Add(line.Tax)
+		creditNote.Total = creditNote.Total.Add(line.Total)
+	}
+
+	return creditNote
+}
+
+func lineTotal(line types.InvoiceLine, taxInclusive bool) money.Money {
+	total := line.Subtotal.Sub(line.Discount)
+	if !taxInclusive {
+		total = total.Add(line.Tax)
+	}
+
+	return total
+}
+
+func buyerParty(order *types.Order, buyer *types.User) types.InvoiceParty {
+	party := types.InvoiceParty{Address: order.Address}
+	if buyer != nil {
+		party.Name = strings.TrimSpace(buyer.FirstName + " " + buyer.LastName)
+		party.Email = buyer.Email
+	}
+
+	return party
+}
@@ -0,0 +1,137 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestBuildInvoice(t *testing.T) {
+	issuedAt := time.Date(2024, 11, 4, 9, 0, 0, 0, time.UTC)
+	orderItems := []types.OrderItem{
+		{ID: 10, ProductID: 5, Quantity: 2, Price: money.FromMinor(1000)},
+		{ID: 11, ProductID: 6, Quantity: 1, Price: money.FromMinor(500)},
+	}
+	productsMap := map[int]types.Product{5: {ID: 5, Name: "Keyboard"}}
+	buyer := &types.User{FirstName: "Sam", LastName: "Doe", Email: "sam@gmail.com"}
+
+	t.Run("Should invoice the lines and totals the order was charged", func(t *testing.T) {
+		order := &types.Order{
+			ID: 1, UserID: 7, Address: "1 Main St, Amman, JO",
+			Subtotal: money.FromMinor(2500), DiscountTotal: money.FromMinor(200), ShippingTotal: money.FromMinor(300),
+			TaxTotal: money.FromMinor(230), Total: money.FromMinor(2830),
+			Pricing: &types.PriceBreakdown{
+				Lines: []types.PriceLine{
+					{UnitPrice: money.FromMinor(1000), Subtotal: money.FromMinor(2000), Discount: money.FromMinor(200),
+						Taxes: []types.TaxLine{{Amount: money.FromMinor(180)}}},
+					{UnitPrice: money.FromMinor(500), Subtotal: money.FromMinor(500), Discount: money.Zero(),
+						Taxes: []types.TaxLine{{Amount: money.FromMinor(50)}}},
+				},
+				Taxes:        []types.TaxSummary{{Name: "VAT", Rate: 10, Amount: money.FromMinor(230)}},
+				ShippingRate: &types.ShippingRate{Name: "Standard"},
+			},
+		}
+
+		invoice := buildInvoice(order, orderItems, productsMap, types.InvoiceParty{Name: "Shop"}, buyer, issuedAt)
+
+		if invoice.Kind != types.InvoiceKindInvoice || invoice.Total.Amount != 2830 || invoice.ShippingMethod != "Standard" {
+			t.Errorf("expected the order totals got %+v", invoice)
+		}
+		if invoice.Buyer.Name != "Sam Doe" || invoice.Buyer.Address != order.Address {
+			t.Errorf("expected the buyer details got %+v", invoice.Buyer)
+		}
+
+		expected := []struct {
+			description string
+			total       int64
+		}{
+			{"Keyboard", 1980},
+			{"Product #6", 550},
+		}
+		for i, line := range invoice.Lines {
+			if line.Description != expected[i].description || line.Total.Amount != expected[i].total {
+				t.Errorf("expected line %d to be %s for %d got %s for %s", i, expected[i].description, expected[i].total, line.Description, line.Total)
+			}
+		}
+	})
+
+	t.Run("Should invoice the prices of an order without a breakdown", func(t *testing.T) {
+		order := &types.Order{ID: 1, UserID: 7, Subtotal: money.FromMinor(2500), Total: money.FromMinor(2500)}
+
+		invoice := buildInvoice(order, orderItems, productsMap, types.InvoiceParty{}, buyer, issuedAt)
+		if invoice.Lines[0].Total.Amount != 2000 || invoice.Lines[1].Total.Amount != 500 {
+			t.Errorf("expected the lines at their prices got %s and %s", invoice.Lines[0].Total, invoice.Lines[1].Total)
+		}
+	})
+}
+
+func TestBuildCreditNotes(t *testing.T) {
+	issuedAt := time.Date(2024, 11, 5, 9, 0, 0, 0, time.UTC)
+	orderItemId, productId := 10, 5
+	invoice := &types.Invoice{
+		ID: 3, Kind: types.InvoiceKindInvoice, Number: "INV-2024-000003", OrderID: 1, UserID: 7,
+		Lines: []types.InvoiceLine{{
+			OrderItemID: &orderItemId, ProductID: &productId, Description: "Keyboard", Quantity: 2,
+			UnitPrice: money.FromMinor(1000), Subtotal: money.FromMinor(2000), Discount: money.FromMinor(200),
+			Tax: money.FromMinor(180), Total: money.FromMinor(1980),
+		}},
+		TaxTotal: money.FromMinor(180),
+		Total:    money.FromMinor(1980),
+	}
+
+	t.Run("Should credit the items received back by a return", func(t *testing.T) {
+		ret := &types.Return{ID: 2, OrderID: 1, Status: types.ReturnStatusRefunded, Items: []types.ReturnItem{
+			{OrderItemID: 10, Quantity: 1, ReceivedQuantity: 1, RefundAmount: money.FromMinor(990)},
+		}}
+
+		creditNote, err := buildReturnCreditNote(invoice, ret, "returned", issuedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line := creditNote.Lines[0]
+		if line.Quantity != 1 || line.Total.Amount != 990 || line.Tax.Amount != 90 || line.Discount.Amount != 100 {
+			t.Errorf("expected 1 unit credited for 990 with 90 of tax got %+v", line)
+		}
+		if creditNote.Kind != types.InvoiceKindCreditNote || *creditNote.CreditedInvoiceID != 3 || creditNote.CreditedNumber != invoice.Number {
+			t.Errorf("expected a credit note of the invoice got %+v", creditNote)
+		}
+		if creditNote.Total.Amount != 990 || creditNote.TaxTotal.Amount != 90 {
+			t.Errorf("expected the totals of the lines got %s and %s", creditNote.Total, creditNote.TaxTotal)
+		}
+	})
+
+	t.Run("Should refuse a return that wasn't received", func(t *testing.T) {
+		ret := &types.Return{ID: 2, OrderID: 1, Status: types.ReturnStatusApproved}
+		if _, err := buildReturnCreditNote(invoice, ret, "returned", issuedAt); err == nil {
+			t.Error("expected the credit note to be refused")
+		}
+	})
+
+	t.Run("Should refuse a return of another order", func(t *testing.T) {
+		ret := &types.Return{ID: 2, OrderID: 2, Status: types.ReturnStatusReceived}
+		if _, err := buildReturnCreditNote(invoice, ret, "returned", issuedAt); err == nil {
+			t.Error("expected the credit note to be refused")
+		}
+	})
+
+	t.Run("Should credit an amount with its share of tax", func(t *testing.T) {
+		creditNote, err := buildAmountCreditNote(invoice, money.FromMinor(198), "price match", issuedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line := creditNote.Lines[0]
+		if line.Total.Amount != 198 || line.Tax.Amount != 18 || line.UnitPrice.Amount != 180 || line.Description != "price match" {
+			t.Errorf("expected 198 credited with 18 of tax got %+v", line)
+		}
+	})
+
+	t.Run("Should refuse an amount that isn't positive", func(t *testing.T) {
+		if _, err := buildAmountCreditNote(invoice, money.Zero(), "nothing", issuedAt); err == nil {
+			t.Error("expected the credit note to be refused")
+		}
+	})
+}
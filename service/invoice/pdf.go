@@ -0,0 +1,174 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// the size of an A4 page in points, the origin of the coordinates is its bottom left corner.
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+)
+
+// the widths of the printable ASCII characters of Helvetica in thousandths of the font size,
+// from the space to the tilde. The other characters are taken as wide as an 'n'.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// pdfDocument writes a PDF with the two standard Helvetica fonts, text and lines only. It's all the
+// invoices need and it keeps the PDFs free of any dependency.
+type pdfDocument struct {
+	pages []*bytes.Buffer
+}
+
+func (d *pdfDocument) addPage() {
+	d.pages = append(d.pages, new(bytes.Buffer))
+}
+
+func (d *pdfDocument) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.addPage()
+	}
+
+	return d.pages[len(d.pages)-1]
+}
+
+// text writes value with its baseline starting at x and y.
+func (d *pdfDocument) text(x, y, size float64, bold bool, value string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, escapePDFText(value))
+}
+
+// textRight writes value so it ends at x.
+func (d *pdfDocument) textRight(x, y, size float64, bold bool, value string) {
+	d.text(x-textWidth(value, size), y, size, bold, value)
+}
+
+func (d *pdfDocument) line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.page(), "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, y1, x2, y2)
+}
+
+// bytes lays out the objects, the catalog, the page tree and the fonts first then every page with its content.
+func (d *pdfDocument) bytes() []byte {
+	if len(d.pages) == 0 {
+		d.addPage()
+	}
+
+	const fixedObjects = 4
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	}
+
+	kids := make([]string, 0, len(d.pages))
+	for i, content := range d.pages {
+		pageObject := fixedObjects + 2*i + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageObject))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				pageWidth, pageHeight, pageObject+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return out.Bytes()
+}
+
+// escapePDFText encodes the text in WinAnsi, the characters it doesn't have become '?'.
+func escapePDFText(value string) string {
+	var out strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r == '€':
+			out.WriteString("\\200")
+		case r >= 32 && r < 127:
+			out.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&out, "\\%03o", r)
+		default:
+			out.WriteByte('?')
+		}
+	}
+
+	return out.String()
+}
+
+func textWidth(value string, size float64) float64 {
+	width := 0
+	for _, r := range value {
+		if r >= 32 && r < 127 {
+			width += helveticaWidths[r-32]
+		} else {
+			width += helveticaWidths['n'-32]
+		}
+	}
+
+	return float64(width) * size / 1000
+}
+
+// fitText cuts value so it's no wider than width, ending it with "...".
+func fitText(value string, size, width float64) string {
+	if textWidth(value, size) <= width {
+		return value
+	}
+
+	runes := []rune(value)
+	for len(runes) > 0 && textWidth(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+
+	return string(runes) + "..."
+}
+
+// wrapText splits value in lines no wider than width, between its words.
+func wrapText(value string, size, width float64) []string {
+	lines := make([]string, 0)
+	current := ""
+	for _, word := range strings.Fields(value) {
+		candidate := strings.TrimSpace(current + " " + word)
+		if current != "" && textWidth(candidate, size) > width {
+			lines = append(lines, current)
+			candidate = word
+		}
+		current = candidate
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+
+	return lines
+}
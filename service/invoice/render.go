@@ -0,0 +1,175 @@
+package invoice
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+//go:embed templates/invoice.html
+var invoiceHTML string
+
+var invoiceTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"title":      title,
+	"totalLabel": totalLabel,
+}).Parse(invoiceHTML))
+
+// RenderHTML writes the invoice or credit note as an HTML page.
+func RenderHTML(w io.Writer, invoice *types.Invoice) error {
+	return invoiceTemplate.Execute(w, invoice)
+}
+
+// the columns of the lines table of the PDF, the description is left aligned and the others right aligned.
+var pdfColumns = []struct {
+	title string
+	x     float64
+}{
+	{"Description", 50},
+	{"Qty", 320},
+	{"Unit price", 390},
+	{"Discount", 445},
+	{"Tax", 495},
+	{"Total", 545},
+}
+
+const (
+	pdfMargin     = 50.0
+	pdfBottom     = 60.0
+	pdfFontSize   = 10.0
+	pdfLineHeight = 14.0
+)
+
+// RenderPDF lays out the same content as RenderHTML on A4 pages, the lines table goes on to the next
+// pages when it doesn't fit.
+func RenderPDF(invoice *types.Invoice) []byte {
+	doc := new(pdfDocument)
+	doc.addPage()
+
+	y := pageHeight - pdfMargin
+	doc.text(pdfMargin, y, 18, true, fmt.Sprintf("%s %s", title(invoice), invoice.Number))
+	y -= 20
+	doc.text(pdfMargin, y, pdfFontSize, false, fmt.Sprintf("Issued on %s for order #%d", invoice.IssuedAt.Format("2006-01-02"), invoice.OrderID))
+	if invoice.CreditedNumber != "" {
+		y -= pdfLineHeight
+		doc.text(pdfMargin, y, pdfFontSize, false, "Credits invoice "+invoice.CreditedNumber)
+	}
+	if invoice.Reason != "" {
+		y -= pdfLineHeight
+		doc.text(pdfMargin, y, pdfFontSize, false, fitText("Reason: "+invoice.Reason, pdfFontSize, pageWidth-2*pdfMargin))
+	}
+
+	y -= 30
+	sellerBottom := writeParty(doc, pdfMargin, y, "SELLER", invoice.Seller)
+	buyerBottom := writeParty(doc, pageWidth/2, y, "BUYER", invoice.Buyer)
+	y = min(sellerBottom, buyerBottom) - 20
+
+	y = writeLinesHeader(doc, y)
+	for _, line := range invoice.Lines {
+		if y < pdfBottom {
+			doc.addPage()
+			y = writeLinesHeader(doc, pageHeight-pdfMargin)
+		}
+
+		descriptionWidth := pdfColumns[1].x - pdfColumns[0].x - 40
+		doc.text(pdfColumns[0].x, y, pdfFontSize, false, fitText(line.Description, pdfFontSize, descriptionWidth))
+		values := []string{fmt.Sprint(line.Quantity), line.UnitPrice.String(), line.Discount.String(), line.Tax.String(), line.Total.String()}
+		for i, value := range values {
+			doc.textRight(pdfColumns[i+1].x, y, pdfFontSize, false, value)
+		}
+		y -= pdfLineHeight
+	}
+
+	totals := [][2]string{
+		{"Subtotal", invoice.Subtotal.String()},
+		{"Discount", invoice.DiscountTotal.String()},
+	}
+	if invoice.ShippingMethod != "" || invoice.ShippingTotal.IsPositive() {
+		label := "Shipping"
+		if invoice.ShippingMethod != "" {
+			label += " (" + invoice.ShippingMethod + ")"
+		}
+		totals = append(totals, [2]string{label, invoice.ShippingTotal.String()})
+	}
+	for _, tax := range invoice.Taxes {
+		totals = append(totals, [2]string{fmt.Sprintf("%s (%v%%)", tax.Name, tax.Rate), tax.Amount.String()})
+	}
+	taxLabel := "Tax"
+	if invoice.TaxInclusive {
+		taxLabel += " (included)"
+	}
+	totals = append(totals, [2]string{taxLabel, invoice.TaxTotal.String()})
+
+	// the totals are kept together on the last page.
+	if y-float64(len(totals)+2)*pdfLineHeight < pdfBottom {
+		doc.addPage()
+		y = pageHeight - pdfMargin
+	}
+
+	y -= 10
+	labelX := pdfColumns[3].x - 60
+	for _, total := range totals {
+		doc.text(labelX, y, pdfFontSize, false, total[0])
+		doc.textRight(pdfColumns[5].x, y, pdfFontSize, false, total[1])
+		y -= pdfLineHeight
+	}
+	doc.line(labelX, y+pdfLineHeight-3, pdfColumns[5].x, y+pdfLineHeight-3, 1)
+	y -= 2
+	doc.text(labelX, y, pdfFontSize+1, true, totalLabel(invoice))
+	doc.textRight(pdfColumns[5].x, y, pdfFontSize+1, true, invoice.Total.String())
+
+	return doc.bytes()
+}
+
+// writeParty writes the details of the party under its heading and returns where they end.
+func writeParty(doc *pdfDocument, x, y float64, heading string, party types.InvoiceParty) float64 {
+	width := pageWidth/2 - pdfMargin - 10
+	doc.text(x, y, 8, true, heading)
+	y -= pdfLineHeight
+	doc.text(x, y, pdfFontSize, true, fitText(party.Name, pdfFontSize, width))
+
+	lines := wrapText(party.Address, pdfFontSize, width)
+	if party.Email != "" {
+		lines = append(lines, party.Email)
+	}
+	if party.TaxID != "" {
+		lines = append(lines, "Tax ID: "+party.TaxID)
+	}
+	for _, line := range lines {
+		y -= pdfLineHeight
+		doc.text(x, y, pdfFontSize, false, fitText(line, pdfFontSize, width))
+	}
+
+	return y
+}
+
+func writeLinesHeader(doc *pdfDocument, y float64) float64 {
+	for i, column := range pdfColumns {
+		if i == 0 {
+			doc.text(column.x, y, pdfFontSize, true, column.title)
+		} else {
+			doc.textRight(column.x, y, pdfFontSize, true, column.title)
+		}
+	}
+	doc.line(pdfMargin, y-5, pageWidth-pdfMargin, y-5, 0.5)
+
+	return y - 20
+}
+
+func title(invoice *types.Invoice) string {
+	if invoice.Kind == types.InvoiceKindCreditNote {
+		return "Credit note"
+	}
+
+	return "Invoice"
+}
+
+func totalLabel(invoice *types.Invoice) string {
+	if invoice.Kind == types.InvoiceKindCreditNote {
+		return "Total credited"
+	}
+
+	return "Total"
+}
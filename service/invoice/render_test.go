@@ -0,0 +1,113 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestRenderHTML(t *testing.T) {
+	invoice := testInvoice(1)
+
+	var page bytes.Buffer
+	if err := RenderHTML(&page, invoice); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"Invoice INV-2024-000001", "Keyboard &lt;US&gt;", "19.80", "Tax ID: JO123", "VAT (10%)"} {
+		if !strings.Contains(page.String(), expected) {
+			t.Errorf("expected the page to contain %q", expected)
+		}
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	t.Run("Should write a PDF whose cross-reference table points at its objects", func(t *testing.T) {
+		pdf := RenderPDF(testInvoice(1))
+		checkPDF(t, pdf, 1)
+
+		if !bytes.Contains(pdf, []byte("(Keyboard <US>)")) || !bytes.Contains(pdf, []byte("(19.80)")) {
+			t.Error("expected the PDF to contain the lines")
+		}
+	})
+
+	t.Run("Should go on to the next pages when the lines don't fit", func(t *testing.T) {
+		checkPDF(t, RenderPDF(testInvoice(120)), 3)
+	})
+
+	t.Run("Should escape the text", func(t *testing.T) {
+		cases := map[string]string{
+			`a (b) \c`: `a \(b\) \\c`,
+			"5 €":      `5 \200`,
+			"café":     `caf\351`,
+			"日本":       "??",
+		}
+		for value, expected := range cases {
+			if escaped := escapePDFText(value); escaped != expected {
+				t.Errorf("expected %q to be escaped as %q got %q", value, expected, escaped)
+			}
+		}
+	})
+}
+
+func checkPDF(t *testing.T, pdf []byte, pages int) {
+	t.Helper()
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("expected a PDF header and trailer")
+	}
+	if !bytes.Contains(pdf, []byte(fmt.Sprintf("/Count %d ", pages))) {
+		t.Errorf("expected %d pages", pages)
+	}
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if startxref == nil {
+		t.Fatal("expected a startxref")
+	}
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("expected the xref table at %d", xref)
+	}
+
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	for i, offset := range offsets {
+		at, _ := strconv.Atoi(string(offset[1]))
+		if !bytes.HasPrefix(pdf[at:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))) {
+			t.Errorf("expected object %d at %d", i+1, at)
+		}
+	}
+}
+
+func testInvoice(lines int) *types.Invoice {
+	invoice := &types.Invoice{
+		Kind:     types.InvoiceKindInvoice,
+		Number:   "INV-2024-000001",
+		OrderID:  1,
+		Seller:   types.InvoiceParty{Name: "Shop", Address: "2 Market St, Amman, JO", TaxID: "JO123"},
+		Buyer:    types.InvoiceParty{Name: "Sam Doe", Address: "1 Main St, Amman, JO", Email: "sam@gmail.com"},
+		Taxes:    []types.TaxSummary{{Name: "VAT", Rate: 10, Amount: money.FromMinor(180)}},
+		TaxTotal: money.FromMinor(180),
+		Total:    money.FromMinor(1980),
+		IssuedAt: time.Date(2024, 11, 4, 9, 0, 0, 0, time.UTC),
+	}
+	for i := 0; i < lines; i++ {
+		invoice.Lines = append(invoice.Lines, types.InvoiceLine{
+			Description: "Keyboard <US>",
+			Quantity:    2,
+			UnitPrice:   money.FromMinor(1000),
+			Subtotal:    money.FromMinor(2000),
+			Discount:    money.FromMinor(200),
+			Tax:         money.FromMinor(180),
+			Total:       money.FromMinor(1980),
+		})
+	}
+
+	return invoice
+}
@@ -0,0 +1,310 @@
+package invoice
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+type Handler struct {
+	store        types.InvoiceStore
+	orderStore   types.OrderStore
+	productStore types.ProductStore
+	userStore    types.UserStore
+	returnStore  types.ReturnStore
+	seller       types.InvoiceParty
+	now          func() time.Time
+}
+
+func NewHandler(store types.InvoiceStore, orderStore types.OrderStore, productStore types.ProductStore, userStore types.UserStore,
+	returnStore types.ReturnStore) *Handler {
+	return &Handler{
+		store:        store,
+		orderStore:   orderStore,
+		productStore: productStore,
+		userStore:    userStore,
+		returnStore:  returnStore,
+		seller: types.InvoiceParty{
+			Name:    config.Envs.SellerName,
+			Address: config.Envs.SellerAddress,
+			TaxID:   config.Envs.SellerTaxID,
+		},
+		// invoices are dated to the second, like the issuedAt column keeps them.
+		now: func() time.Time { return time.Now().UTC().Truncate(time.Second) },
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/orders/{id}/invoice", auth.AuthenticationMiddleware(h.GetOrderInvoice)).Methods("GET")
+	router.HandleFunc("/orders/{id}/invoice/credit-notes", auth.AuthenticationMiddleware(h.GetOrderCreditNotes)).Methods("GET")
+	router.HandleFunc("/invoices/{id}", auth.AuthenticationMiddleware(h.GetInvoice)).Methods("GET")
+
+	router.HandleFunc("/admin/invoices/{id}/credit-notes", auth.AdminMiddleware(h.IssueCreditNote)).Methods("POST")
+}
+
+// GetOrderInvoice renders the invoice of a completed order, ?format=pdf or ?format=json for the other
+// formats. The order is invoiced the first time its invoice is asked for, the same invoice is returned
+// after that.
+func (h *Handler) GetOrderInvoice(w http.ResponseWriter, r *http.Request) {
+	format, err := getFormat(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	order, ok := h.getVisibleOrder(w, r)
+	if !ok {
+		return
+	}
+
+	invoice, err := h.store.GetOrderInvoice(order.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if invoice == nil {
+		if order.Status != types.OrderStatusCompleted {
+			utils.WriteError(w, http.StatusConflict, fmt.Errorf("order with id %v is %s, only completed orders are invoiced", order.ID, order.Status))
+			return
+		}
+
+		invoice, err = h.issueOrderInvoice(order)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	writeInvoice(w, invoice, format)
+}
+
+func (h *Handler) GetOrderCreditNotes(w http.ResponseWriter, r *http.Request) {
+	order, ok := h.getVisibleOrder(w, r)
+	if !ok {
+		return
+	}
+
+	creditNotes := make([]types.Invoice, 0)
+	invoice, err := h.store.GetOrderInvoice(order.ID)
+	if err == nil && invoice != nil {
+		creditNotes, err = h.store.GetCreditNotes(invoice.ID)
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    creditNotes,
+	})
+}
+
+// GetInvoice renders an invoice or a credit note, in the same formats as the order invoice.
+func (h *Handler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	format, err := getFormat(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	invoice, err := h.store.GetInvoiceById(id)
+	if err == nil && invoice.UserID != tokenPayload.UserId && tokenPayload.Role != types.UserRoleAdmin {
+		err = fmt.Errorf("no invoice was found for id %v", id)
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	writeInvoice(w, invoice, format)
+}
+
+// IssueCreditNote corrects an invoice, which can't be changed once issued. The credit note credits the
+// items a return received back, or an amount of the invoice.
+func (h *Handler) IssueCreditNote(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.CreditNotePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	invoice, err := h.store.GetInvoiceById(id)
+	if err == nil && invoice.Kind != types.InvoiceKindInvoice {
+		err = fmt.Errorf("no invoice was found for id %v", id)
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var creditNote types.Invoice
+	if payload.ReturnID != 0 {
+		ret, err := h.returnStore.GetReturnById(payload.ReturnID)
+		if err != nil {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+
+		creditNote, err = buildReturnCreditNote(invoice, ret, payload.Reason, h.now())
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		creditNote, err = buildAmountCreditNote(invoice, *payload.Amount, payload.Reason, h.now())
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	issued, err := h.store.IssueInvoice(creditNote)
+	if errors.Is(err, types.ErrInvoiceCredit) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    issued,
+	})
+}
+
+func (h *Handler) issueOrderInvoice(order *types.Order) (*types.Invoice, error) {
+	orderItems, err := h.orderStore.GetOrderItems(order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	productsMap := make(map[int]types.Product)
+	productIds := make([]int, 0, len(orderItems))
+	for _, orderItem := range orderItems {
+		productIds = append(productIds, orderItem.ProductID)
+	}
+	if len(productIds) > 0 {
+		products, err := h.productStore.GetProductsByID(productIds)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range products {
+			productsMap[product.ID] = product
+		}
+	}
+
+	buyer, err := h.userStore.GetUserByID(order.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.store.IssueInvoice(buildInvoice(order, orderItems, productsMap, h.seller, buyer, h.now()))
+}
+
+// getVisibleOrder writes the error itself when the order is neither the customer's nor asked for by an admin.
+func (h *Handler) getVisibleOrder(w http.ResponseWriter, r *http.Request) (*types.Order, bool) {
+	orderId, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return nil, false
+	}
+
+	order, err := h.orderStore.GetOrderById(orderId)
+	if err == nil && order.UserID != tokenPayload.UserId && tokenPayload.Role != types.UserRoleAdmin {
+		err = fmt.Errorf("no order was found for id %v", orderId)
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return nil, false
+	}
+
+	return order, true
+}
+
+func writeInvoice(w http.ResponseWriter, invoice *types.Invoice, format string) {
+	filename := fmt.Sprintf("%s.%s", invoice.Number, format)
+	switch format {
+	case "json":
+		utils.WriteJSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    invoice,
+		})
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		w.Write(RenderPDF(invoice))
+	default:
+		// rendered first so a template error doesn't leave half a page.
+		var page bytes.Buffer
+		if err := RenderHTML(&page, invoice); err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page.Bytes())
+	}
+}
+
+func getFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		return "html", nil
+	}
+	if format != "html" && format != "pdf" && format != "json" {
+		return "", fmt.Errorf("format must be one of html, pdf or json")
+	}
+
+	return format, nil
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("id must be unsigned integer")
+	}
+
+	return id, nil
+}
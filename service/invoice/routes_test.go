@@ -0,0 +1,164 @@
+package invoice
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestGetOrderInvoice(t *testing.T) {
+	newHandler := func(status string) (*Handler, *mockInvoiceStore) {
+		store := &mockInvoiceStore{}
+		handler := &Handler{
+			store: store,
+			orderStore: &mockOrderStore{order: types.Order{ID: 1, UserID: 7, Status: status, Total: money.FromMinor(2000)},
+				items: []types.OrderItem{{ID: 10, ProductID: 5, Quantity: 2, Price: money.FromMinor(1000)}}},
+			productStore: &mockProductStore{},
+			userStore:    &mockUserStore{},
+			seller:       types.InvoiceParty{Name: "Shop"},
+			now:          func() time.Time { return time.Date(2024, 11, 4, 9, 0, 0, 0, time.UTC) },
+		}
+
+		return handler, store
+	}
+
+	t.Run("Should issue the invoice of a completed order once", func(t *testing.T) {
+		handler, store := newHandler(types.OrderStatusCompleted)
+
+		for i := 0; i < 2; i++ {
+			recorder := get(t, handler.GetOrderInvoice, "/orders/1/invoice", types.User{ID: 7, Role: types.UserRoleCustomer})
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+			}
+			if recorder.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+				t.Errorf("expected an HTML page got %s", recorder.Header().Get("Content-Type"))
+			}
+		}
+
+		if store.issued != 1 {
+			t.Errorf("expected the invoice to be issued once got %d", store.issued)
+		}
+	})
+
+	t.Run("Should render the invoice as a PDF", func(t *testing.T) {
+		handler, _ := newHandler(types.OrderStatusCompleted)
+
+		recorder := get(t, handler.GetOrderInvoice, "/orders/1/invoice?format=pdf", types.User{ID: 7, Role: types.UserRoleCustomer})
+		if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/pdf" {
+			t.Fatalf("expected a PDF got %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+		}
+		checkPDF(t, recorder.Body.Bytes(), 1)
+	})
+
+	t.Run("Should let admins get the invoice of any order", func(t *testing.T) {
+		handler, _ := newHandler(types.OrderStatusCompleted)
+
+		recorder := get(t, handler.GetOrderInvoice, "/orders/1/invoice?format=json", types.User{ID: 1, Role: types.UserRoleAdmin})
+		if recorder.Code != http.StatusOK {
+			t.Errorf("expected status code %d got %d", http.StatusOK, recorder.Code)
+		}
+	})
+
+	t.Run("Should not find the order of another customer", func(t *testing.T) {
+		handler, store := newHandler(types.OrderStatusCompleted)
+
+		recorder := get(t, handler.GetOrderInvoice, "/orders/1/invoice", types.User{ID: 8, Role: types.UserRoleCustomer})
+		if recorder.Code != http.StatusNotFound || store.issued != 0 {
+			t.Errorf("expected status code %d got %d", http.StatusNotFound, recorder.Code)
+		}
+	})
+
+	t.Run("Should not invoice an order that isn't completed", func(t *testing.T) {
+		handler, store := newHandler(types.OrderStatusPending)
+
+		recorder := get(t, handler.GetOrderInvoice, "/orders/1/invoice", types.User{ID: 7, Role: types.UserRoleCustomer})
+		if recorder.Code != http.StatusConflict || store.issued != 0 {
+			t.Errorf("expected status code %d got %d", http.StatusConflict, recorder.Code)
+		}
+	})
+
+	t.Run("Should refuse an unknown format", func(t *testing.T) {
+		handler, _ := newHandler(types.OrderStatusCompleted)
+
+		recorder := get(t, handler.GetOrderInvoice, "/orders/1/invoice?format=docx", types.User{ID: 7, Role: types.UserRoleCustomer})
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+}
+
+func get(t *testing.T, handlerFunc http.HandlerFunc, path string, user types.User) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", token)
+
+	recorder := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/orders/{id}/invoice", auth.AuthenticationMiddleware(handlerFunc))
+	router.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+type mockInvoiceStore struct {
+	types.InvoiceStore
+	invoice *types.Invoice
+	issued  int
+}
+
+func (m *mockInvoiceStore) IssueInvoice(invoice types.Invoice) (*types.Invoice, error) {
+	m.issued++
+	invoice.ID = m.issued
+	invoice.Number = "INV-2024-000001"
+	m.invoice = &invoice
+	return &invoice, nil
+}
+
+func (m *mockInvoiceStore) GetOrderInvoice(orderID int) (*types.Invoice, error) {
+	return m.invoice, nil
+}
+
+type mockOrderStore struct {
+	types.OrderStore
+	order types.Order
+	items []types.OrderItem
+}
+
+func (m *mockOrderStore) GetOrderById(id int) (*types.Order, error) {
+	order := m.order
+	return &order, nil
+}
+
+func (m *mockOrderStore) GetOrderItems(orderID int) ([]types.OrderItem, error) {
+	return m.items, nil
+}
+
+type mockProductStore struct {
+	types.ProductStore
+}
+
+func (m *mockProductStore) GetProductsByID(productIDs []int) ([]types.Product, error) {
+	return []types.Product{{ID: 5, Name: "Keyboard"}}, nil
+}
+
+type mockUserStore struct {
+	types.UserStore
+}
+
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	return &types.User{ID: id, FirstName: "Sam", LastName: "Doe", Email: "sam@gmail.com"}, nil
+}
@@ -0,0 +1,220 @@
+package invoice
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// the prefixes of the invoice numbers, e.g. INV-2024-000042.
+var numberPrefixes = map[string]string{
+	types.InvoiceKindInvoice:    "INV",
+	types.InvoiceKindCreditNote: "CN",
+}
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// IssueInvoice numbers the invoice and stores it. The number is taken in the same transaction as the
+// invoice is stored, so a number is never used twice nor skipped when the invoice fails to be stored.
+// An order is invoiced once, its invoice is returned when it already has one. A credit note is refused
+// with ErrInvoiceCredit when the invoice would be credited for more than its total.
+func (s *Store) IssueInvoice(invoice types.Invoice) (*types.Invoice, error) {
+	var invoiceId int64
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		var err error
+		switch invoice.Kind {
+		case types.InvoiceKindInvoice:
+			invoiceId, err = existingOrderInvoice(tx, invoice.OrderID)
+		case types.InvoiceKindCreditNote:
+			err = checkCredit(tx, invoice)
+		default:
+			err = fmt.Errorf("unknown invoice kind '%s'", invoice.Kind)
+		}
+		if err != nil || invoiceId != 0 {
+			return err
+		}
+
+		invoice.Year = invoice.IssuedAt.Year()
+		invoice.Sequence, err = nextNumber(tx, invoice.Kind, invoice.Year)
+		if err != nil {
+			return err
+		}
+		invoice.Number = fmt.Sprintf("%s-%d-%06d", numberPrefixes[invoice.Kind], invoice.Year, invoice.Sequence)
+
+		document, err := json.Marshal(invoice)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`
+		INSERT INTO invoices (kind, number, year, sequence, orderId, userId, creditedInvoiceId, total, document, issuedAt)
+		VALUES (?,?,?,?,?,?,?,?,?,?)`,
+			invoice.Kind, invoice.Number, invoice.Year, invoice.Sequence, invoice.OrderID, invoice.UserID,
+			invoice.CreditedInvoiceID, invoice.Total, document, invoice.IssuedAt)
+		if err != nil {
+			return err
+		}
+
+		invoiceId, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetInvoiceById(int(invoiceId))
+}
+
+func (s *Store) GetInvoiceById(id int) (*types.Invoice, error) {
+	invoice, err := scanIntoInvoice(s.db.QueryRow("SELECT * FROM invoices WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no invoice was found for id %v", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+// GetOrderInvoice returns nil when the order wasn't invoiced yet.
+func (s *Store) GetOrderInvoice(orderID int) (*types.Invoice, error) {
+	invoice, err := scanIntoInvoice(s.db.QueryRow("SELECT * FROM invoices WHERE orderId = ? AND kind = ?",
+		orderID, types.InvoiceKindInvoice))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+func (s *Store) GetCreditNotes(invoiceID int) ([]types.Invoice, error) {
+	rows, err := s.db.Query("SELECT * FROM invoices WHERE creditedInvoiceId = ? ORDER BY id", invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	creditNotes := make([]types.Invoice, 0)
+	for rows.Next() {
+		creditNote, err := scanIntoInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		creditNotes = append(creditNotes, *creditNote)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return creditNotes, nil
+}
+
+// existingOrderInvoice locks the order so it's invoiced once, and returns the id of its invoice when it has one.
+func existingOrderInvoice(tx myDB.DBTX, orderID int) (int64, error) {
+	var id int64
+	err := tx.QueryRow("SELECT id FROM orders WHERE id = ? FOR UPDATE", orderID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("no order was found for id %v", orderID)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRow("SELECT id FROM invoices WHERE orderId = ? AND kind = ?", orderID, types.InvoiceKindInvoice).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return id, err
+}
+
+// checkCredit locks the credited invoice so its credit notes are issued one after the other.
+func checkCredit(tx myDB.DBTX, creditNote types.Invoice) error {
+	if creditNote.CreditedInvoiceID == nil {
+		return fmt.Errorf("a credit note must credit an invoice")
+	}
+
+	var total money.Money
+	err := tx.QueryRow("SELECT total FROM invoices WHERE id = ? AND kind = ? FOR UPDATE",
+		*creditNote.CreditedInvoiceID, types.InvoiceKindInvoice).Scan(&total)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no invoice was found for id %v", *creditNote.CreditedInvoiceID)
+	}
+	if err != nil {
+		return err
+	}
+
+	var credited money.Money
+	err = tx.QueryRow("SELECT COALESCE(SUM(total), 0) FROM invoices WHERE creditedInvoiceId = ?", *creditNote.CreditedInvoiceID).
+		Scan(&credited)
+	if err != nil {
+		return err
+	}
+
+	if left := total.Sub(credited); creditNote.Total.Cmp(left) > 0 {
+		return fmt.Errorf("%w, %s is left to credit", types.ErrInvoiceCredit, left)
+	}
+
+	return nil
+}
+
+// nextNumber takes the next number of the kind for the year, the sequence row stays locked until the
+// transaction ends so the numbers are handed out one after the other.
+func nextNumber(tx myDB.DBTX, kind string, year int) (int, error) {
+	_, err := tx.Exec(`
+	INSERT INTO invoiceSequences (kind, year, lastNumber) VALUES (?,?,1)
+	ON DUPLICATE KEY UPDATE lastNumber = lastNumber + 1`, kind, year)
+	if err != nil {
+		return 0, err
+	}
+
+	var number int
+	err = tx.QueryRow("SELECT lastNumber FROM invoiceSequences WHERE kind = ? AND year = ?", kind, year).Scan(&number)
+	if err != nil {
+		return 0, err
+	}
+
+	return number, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// the columns are what the invoice is looked up by, everything it shows comes from its document.
+func scanIntoInvoice(row scanner) (*types.Invoice, error) {
+	invoice := new(types.Invoice)
+	var document []byte
+	var total money.Money
+	err := row.Scan(&invoice.ID, &invoice.Kind, &invoice.Number, &invoice.Year, &invoice.Sequence, &invoice.OrderID,
+		&invoice.UserID, &invoice.CreditedInvoiceID, &total, &document, &invoice.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, issuedAt := invoice.ID, invoice.IssuedAt
+	if err := json.Unmarshal(document, invoice); err != nil {
+		return nil, err
+	}
+	invoice.ID, invoice.IssuedAt = id, issuedAt
+
+	return invoice, nil
+}
@@ -0,0 +1,166 @@
+package job
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the shorthands of the schedules that are used the most.
+var scheduleDescriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// Schedule is a cron expression, "minute hour day-of-month month day-of-week" in UTC. Each field is *, a value,
+// a range like 1-5 or a list of them, and can step like */15 or 0-30/10. When both the day of the month and
+// the day of the week are restricted a day matching either of them matches, like cron does.
+// "@every 10s" runs at a fixed interval instead, for the jobs that run more often than every minute.
+type Schedule struct {
+	minutes, hours, days, months, weekdays uint64
+	// whether the field was * so the other day field decides alone.
+	anyDay, anyWeekday bool
+	every              time.Duration
+}
+
+func ParseSchedule(spec string) (Schedule, error) {
+	if interval, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil || every < time.Second {
+			return Schedule{}, fmt.Errorf("schedule '%s' must run every second or less often", spec)
+		}
+
+		return Schedule{every: every}, nil
+	}
+
+	if expanded, ok := scheduleDescriptors[spec]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("schedule '%s' must have 5 fields", spec)
+	}
+
+	var schedule Schedule
+	var err error
+	if schedule.minutes, err = parseField(fields[0], 0, 59); err != nil {
+		return Schedule{}, fmt.Errorf("schedule '%s': minute: %v", spec, err)
+	}
+	if schedule.hours, err = parseField(fields[1], 0, 23); err != nil {
+		return Schedule{}, fmt.Errorf("schedule '%s': hour: %v", spec, err)
+	}
+	if schedule.days, err = parseField(fields[2], 1, 31); err != nil {
+		return Schedule{}, fmt.Errorf("schedule '%s': day of month: %v", spec, err)
+	}
+	if schedule.months, err = parseField(fields[3], 1, 12); err != nil {
+		return Schedule{}, fmt.Errorf("schedule '%s': month: %v", spec, err)
+	}
+	// 7 is sunday as well.
+	if schedule.weekdays, err = parseField(fields[4], 0, 7); err != nil {
+		return Schedule{}, fmt.Errorf("schedule '%s': day of week: %v", spec, err)
+	}
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+	schedule.anyDay = strings.HasPrefix(fields[2], "*")
+	schedule.anyWeekday = strings.HasPrefix(fields[4], "*")
+
+	return schedule, nil
+}
+
+// Next returns the first time after t the schedule runs at, or the zero time when it never does.
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.UTC().Truncate(time.Second).Add(s.every)
+	}
+
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+
+	// a schedule like "0 0 30 2 *" never runs, the search stops after a few years.
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hours&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s Schedule) matchesDay(t time.Time) bool {
+	day := s.days&(1<<uint(t.Day())) != 0
+	weekday := s.weekdays&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// parseField returns the values of the field as the bits of a set.
+func parseField(field string, lowest, highest int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step '%s'", stepPart)
+			}
+		}
+
+		from, to := lowest, highest
+		if rangePart != "*" {
+			start, end, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			if from, err = strconv.Atoi(start); err != nil {
+				return 0, fmt.Errorf("invalid value '%s'", start)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(end); err != nil {
+					return 0, fmt.Errorf("invalid value '%s'", end)
+				}
+			} else if hasStep {
+				to = highest
+			}
+		}
+		if from < lowest || to > highest || from > to {
+			return 0, fmt.Errorf("'%s' is out of %d-%d", part, lowest, highest)
+		}
+
+		for value := from; value <= to; value += step {
+			set |= 1 << uint(value)
+		}
+	}
+
+	return set, nil
+}
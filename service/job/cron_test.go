@@ -0,0 +1,50 @@
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	from := time.Date(2024, 11, 8, 9, 41, 30, 0, time.UTC) // a friday
+
+	cases := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, 11, 8, 9, 42, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 11, 8, 9, 45, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 11, 8, 10, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 11, 9, 0, 0, 0, 0, time.UTC)},
+		{"30 8-17/3 * * *", time.Date(2024, 11, 8, 11, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 11, 11, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 11, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 1 *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// either day matches when both are restricted.
+		{"0 0 20 * 0", time.Date(2024, 11, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+		{"@every 10s", time.Date(2024, 11, 8, 9, 41, 40, 0, time.UTC)},
+		{"@every 1m30s", time.Date(2024, 11, 8, 9, 43, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		schedule, err := ParseSchedule(c.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", c.spec, err)
+		}
+
+		if next := schedule.Next(from); !next.Equal(c.expected) {
+			t.Errorf("expected %s to run next at %v got %v", c.spec, c.expected, next)
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@yearly", "@every 0s", "@every 500ms", "@every often"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("expected '%s' to be refused", spec)
+		}
+	}
+}
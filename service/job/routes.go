@@ -0,0 +1,111 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/middlewares"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+type Handler struct {
+	store types.JobStore
+}
+
+func NewHandler(store types.JobStore) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/jobs", auth.AdminMiddleware(middlewares.PaginationMiddleware(h.GetJobs))).Methods("GET")
+	router.HandleFunc("/admin/jobs/{id}", auth.AdminMiddleware(h.GetJob)).Methods("GET")
+	router.HandleFunc("/admin/jobs/{id}/retry", auth.AdminMiddleware(h.RetryJob)).Methods("POST")
+}
+
+// GetJobs lists the jobs, ?status=failed for the ones that ran out of attempts along with their last error.
+func (h *Handler) GetJobs(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if err := utils.Validate.Var(status, "omitempty,oneof=queued running succeeded failed"); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("status must be one of queued, running, succeeded or failed"))
+		return
+	}
+
+	pagination := middlewares.GetPagination(r)
+	offset := middlewares.CalculateOffset(pagination)
+
+	jobs, count, err := h.store.GetJobs(status, pagination.Limit, offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK,
+		map[string]any{
+			"jobs":  jobs,
+			"page":  pagination.Page,
+			"limit": pagination.Limit,
+			"count": count,
+		})
+}
+
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := h.store.GetJobById(int64(id))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    job,
+	})
+}
+
+// RetryJob queues a failed job again, it gets all of its attempts back.
+func (h *Handler) RetryJob(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := h.store.RetryJob(int64(id))
+	if errors.Is(err, types.ErrJobNotFailed) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    job,
+	})
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("id must be unsigned integer")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,84 @@
+package job
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestRetryJob(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   string
+		expected int
+	}{
+		{"Should queue a failed job again", types.JobStatusFailed, http.StatusOK},
+		{"Should not retry a job that didn't fail", types.JobStatusSucceeded, http.StatusConflict},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := &mockRetryJobStore{job: types.Job{ID: 3, Type: "orders.export", Status: c.status, Attempts: 5}}
+			router := mux.NewRouter()
+			NewHandler(store).RegisterRoutes(router)
+
+			req, err := http.NewRequest(http.MethodPost, "/admin/jobs/3/retry", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), types.User{ID: 1, Role: types.UserRoleAdmin})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", token)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != c.expected {
+				t.Errorf("expected status code %d got %d: %s", c.expected, recorder.Code, recorder.Body)
+			}
+		})
+	}
+
+	t.Run("Should refuse the customers", func(t *testing.T) {
+		router := mux.NewRouter()
+		NewHandler(&mockRetryJobStore{}).RegisterRoutes(router)
+
+		req, _ := http.NewRequest(http.MethodPost, "/admin/jobs/3/retry", nil)
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), types.User{ID: 7, Role: types.UserRoleCustomer})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", token)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d got %d", http.StatusForbidden, recorder.Code)
+		}
+	})
+}
+
+type mockRetryJobStore struct {
+	types.JobStore
+	job types.Job
+}
+
+func (m *mockRetryJobStore) RetryJob(id int64) (*types.Job, error) {
+	if m.job.Status != types.JobStatusFailed {
+		return nil, fmt.Errorf("%w, job with id %v is %s", types.ErrJobNotFailed, id, m.job.Status)
+	}
+
+	job := m.job
+	job.Status = types.JobStatusQueued
+	job.Attempts = 0
+	return &job, nil
+}
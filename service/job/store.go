@@ -0,0 +1,272 @@
+package job
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) EnqueueJob(job types.Job) (*types.Job, bool, error) {
+	return enqueueJob(s.db, job)
+}
+
+func enqueueJob(q myDB.DBTX, job types.Job) (*types.Job, bool, error) {
+	// the job holding the key can finish between the insert and the select, the insert is tried again then.
+	for i := 0; i < 2; i++ {
+		result, err := q.Exec(`
+		INSERT IGNORE INTO jobs (type, payload, status, maxAttempts, uniqueKey, runAt) VALUES (?,?,?,?,?,?)`,
+			job.Type, job.Payload, types.JobStatusQueued, job.MaxAttempts, job.UniqueKey, job.RunAt)
+		if err != nil {
+			return nil, false, err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, false, err
+		}
+		if rowsAffected > 0 {
+			id, err := result.LastInsertId()
+			if err != nil {
+				return nil, false, err
+			}
+
+			created, err := getJobById(q, id)
+			return created, true, err
+		}
+		if job.UniqueKey == nil {
+			return nil, false, fmt.Errorf("the job of type '%s' wasn't queued", job.Type)
+		}
+
+		existing := new(types.Job)
+		err = q.QueryRow("SELECT * FROM jobs WHERE uniqueKey = ?", *job.UniqueKey).Scan(jobAllFieldsScanner(existing))
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		return existing, false, nil
+	}
+
+	return nil, false, fmt.Errorf("the job with unique key '%s' wasn't queued", *job.UniqueKey)
+}
+
+func (s *Store) ClaimJobs(limit int, lease time.Duration) ([]types.Job, error) {
+	jobs := make([]types.Job, 0)
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		rows, err := tx.Query(`
+		SELECT * FROM jobs
+		WHERE (status = ? AND runAt <= CURRENT_TIMESTAMP) OR (status = ? AND lockedUntil <= CURRENT_TIMESTAMP)
+		ORDER BY runAt, id
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED`, types.JobStatusQueued, types.JobStatusRunning, limit)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			job := new(types.Job)
+			if err := rows.Scan(jobAllFieldsScanner(job)); err != nil {
+				return err
+			}
+
+			jobs = append(jobs, *job)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+
+		args := []any{types.JobStatusRunning, int(lease.Seconds())}
+		for _, job := range jobs {
+			args = append(args, job.ID)
+		}
+
+		_, err = tx.Exec(`
+		UPDATE jobs SET status = ?, attempts = attempts + 1, lockedUntil = DATE_ADD(CURRENT_TIMESTAMP, INTERVAL ? SECOND)
+		WHERE id IN (?`+strings.Repeat(",?", len(jobs)-1)+")", args...)
+		if err != nil {
+			return err
+		}
+
+		for i := range jobs {
+			jobs[i].Status = types.JobStatusRunning
+			jobs[i].Attempts++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (s *Store) CompleteJob(id int64) error {
+	_, err := s.db.Exec(`
+	UPDATE jobs SET status = ?, uniqueKey = NULL, lockedUntil = NULL, lastError = '', finishedAt = CURRENT_TIMESTAMP
+	WHERE id = ?`, types.JobStatusSucceeded, id)
+	return err
+}
+
+func (s *Store) FailJob(id int64, reason string, retryAt *time.Time) error {
+	if retryAt != nil {
+		_, err := s.db.Exec("UPDATE jobs SET status = ?, runAt = ?, lockedUntil = NULL, lastError = LEFT(?, 1024) WHERE id = ?",
+			types.JobStatusQueued, *retryAt, reason, id)
+		return err
+	}
+
+	_, err := s.db.Exec(`
+	UPDATE jobs SET status = ?, uniqueKey = NULL, lockedUntil = NULL, lastError = LEFT(?, 1024), finishedAt = CURRENT_TIMESTAMP
+	WHERE id = ?`, types.JobStatusFailed, reason, id)
+	return err
+}
+
+// returns the jobs with the given status, or all of them when it's empty, the latest first.
+func (s *Store) GetJobs(status string, limit, offset int) ([]types.Job, int, error) {
+	rows, err := s.db.Query("SELECT * FROM jobs WHERE (? = '' OR status = ?) ORDER BY id DESC LIMIT ? OFFSET ?",
+		status, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	jobs := make([]types.Job, 0)
+	for rows.Next() {
+		job := new(types.Job)
+		if err := rows.Scan(jobAllFieldsScanner(job)); err != nil {
+			return nil, 0, err
+		}
+
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM jobs WHERE (? = '' OR status = ?)", status, status).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, count, nil
+}
+
+func (s *Store) GetJobById(id int64) (*types.Job, error) {
+	return getJobById(s.db, id)
+}
+
+func getJobById(q myDB.DBTX, id int64) (*types.Job, error) {
+	job := new(types.Job)
+	err := q.QueryRow("SELECT * FROM jobs WHERE id = ?", id).Scan(jobAllFieldsScanner(job))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no job was found for id %v", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (s *Store) RetryJob(id int64) (*types.Job, error) {
+	job, err := s.GetJobById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`
+	UPDATE jobs SET status = ?, attempts = 0, runAt = CURRENT_TIMESTAMP, lastError = '', finishedAt = NULL
+	WHERE id = ? AND status = ?`, types.JobStatusQueued, id, types.JobStatusFailed)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("%w, job with id %v is %s", types.ErrJobNotFailed, id, job.Status)
+	}
+
+	return s.GetJobById(id)
+}
+
+// DeleteFinishedJobs deletes the jobs that succeeded before the given time, the failed jobs are kept
+// until they are retried.
+func (s *Store) DeleteFinishedJobs(before time.Time) (int, error) {
+	result, err := s.db.Exec("DELETE FROM jobs WHERE status = ? AND finishedAt < ?", types.JobStatusSucceeded, before)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+func (s *Store) EnsureJobSchedule(name string, nextRunAt time.Time) error {
+	_, err := s.db.Exec("INSERT IGNORE INTO jobSchedules (name, nextRunAt) VALUES (?,?)", name, nextRunAt)
+	return err
+}
+
+func (s *Store) AdvanceJobSchedule(name string, now, next time.Time, job types.Job) (bool, error) {
+	advanced := false
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		result, err := tx.Exec("UPDATE jobSchedules SET nextRunAt = ?, lastRunAt = ? WHERE name = ? AND nextRunAt <= ?",
+			next, now, name, now)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		advanced = true
+		_, _, err = enqueueJob(tx, job)
+		return err
+	})
+
+	return advanced, err
+}
+
+func jobAllFieldsScanner(job *types.Job) (*int64, *string, *[]byte, *string, *int, *int, **string, *time.Time, **time.Time,
+	*string, **time.Time, *time.Time, *time.Time) {
+	return &job.ID,
+		&job.Type,
+		(*[]byte)(&job.Payload),
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.UniqueKey,
+		&job.RunAt,
+		&job.LockedUntil,
+		&job.LastError,
+		&job.FinishedAt,
+		&job.CreatedAt,
+		&job.UpdatedAt
+}
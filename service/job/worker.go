@@ -0,0 +1,308 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+const (
+	defaultMaxAttempts = 5
+	// a job running longer than this is cancelled and counts as a failed attempt.
+	defaultTimeout = 5 * time.Minute
+	// the schedules are checked this often, or as often as the most frequent "@every" schedule runs.
+	scheduleInterval = 30 * time.Second
+)
+
+// the failed jobs are retried after 10s, 20s, 40s... up to an hour.
+var retryBackoff = Backoff{First: 10 * time.Second, Max: time.Hour}
+
+// HandlerFunc runs a job, the job is retried when it returns an error unless the error is Permanent.
+type HandlerFunc func(ctx context.Context, job types.Job) error
+
+// Options change how a job is queued, the zero value queues it to run now and up to 5 times.
+type Options struct {
+	// RunAt delays the job until then, Delay delays it from now.
+	RunAt time.Time
+	Delay time.Duration
+	// UniqueKey deduplicates the job with the job of the same key that is still queued or running.
+	UniqueKey   string
+	MaxAttempts int
+}
+
+// Enqueue queues a job of the type, with payload as what its handler decodes.
+func Enqueue(store types.JobStore, jobType string, payload any, options Options) (*types.Job, error) {
+	job, err := newJob(jobType, payload, options, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	queued, _, err := store.EnqueueJob(job)
+	return queued, err
+}
+
+func newJob(jobType string, payload any, options Options, now time.Time) (types.Job, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return types.Job{}, err
+	}
+
+	job := types.Job{
+		Type:        jobType,
+		Payload:     encoded,
+		MaxAttempts: options.MaxAttempts,
+		RunAt:       options.RunAt,
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = defaultMaxAttempts
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = now.Add(options.Delay)
+	}
+	if options.UniqueKey != "" {
+		job.UniqueKey = &options.UniqueKey
+	}
+
+	return job, nil
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks the error of a job that would fail again, the job fails right away instead of being retried.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+type recurringJob struct {
+	name     string
+	schedule Schedule
+	job      types.Job
+}
+
+// Worker runs the queued jobs with a pool of goroutines, a job is claimed by a single worker even when
+// several processes run one. The recurring jobs are queued by whichever worker sees them due first.
+type Worker struct {
+	store       types.JobStore
+	handlers    map[string]HandlerFunc
+	recurring   []recurringJob
+	concurrency int
+	timeout     time.Duration
+	now         func() time.Time
+}
+
+func NewWorker(store types.JobStore, concurrency int) *Worker {
+	return &Worker{
+		store:       store,
+		handlers:    make(map[string]HandlerFunc),
+		concurrency: max(concurrency, 1),
+		timeout:     defaultTimeout,
+		now:         time.Now,
+	}
+}
+
+// Register sets the handler of the jobs of the type.
+func (w *Worker) Register(jobType string, handler HandlerFunc) {
+	w.handlers[jobType] = handler
+}
+
+// Handle registers a handler that gets the payload of the job decoded as T.
+func Handle[T any](w *Worker, jobType string, handler func(ctx context.Context, payload T) error) {
+	w.Register(jobType, func(ctx context.Context, job types.Job) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return Permanent(fmt.Errorf("invalid payload: %v", err))
+		}
+
+		return handler(ctx, payload)
+	})
+}
+
+// Schedule queues a job of the type on the cron schedule spec, name identifies the schedule across restarts.
+// A run isn't queued while the previous run is still queued or running.
+func (w *Worker) Schedule(name, spec, jobType string, payload any) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+
+	job, err := newJob(jobType, payload, Options{UniqueKey: "schedule:" + name}, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	w.recurring = append(w.recurring, recurringJob{name: name, schedule: schedule, job: job})
+	return nil
+}
+
+// Run works the jobs until ctx is done and returns once the running jobs are finished, the queue is
+// polled every pollInterval when it's empty.
+func (w *Worker) Run(ctx context.Context, pollInterval time.Duration) {
+	var wg sync.WaitGroup
+
+	for _, recurring := range w.recurring {
+		if err := w.store.EnsureJobSchedule(recurring.name, recurring.schedule.Next(w.now())); err != nil {
+			log.Printf("job worker: schedule %s: %v", recurring.name, err)
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.runSchedules(ctx)
+	}()
+
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				worked, err := w.Work(ctx)
+				if err != nil {
+					log.Println("job worker:", err)
+				}
+				if worked && err == nil {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(pollInterval):
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// Start runs the worker in the background until ctx is done.
+func (w *Worker) Start(ctx context.Context, pollInterval time.Duration) {
+	go w.Run(ctx, pollInterval)
+}
+
+// Work runs a single due job, worked is false when no job was due.
+func (w *Worker) Work(ctx context.Context) (bool, error) {
+	if ctx.Err() != nil {
+		return false, nil
+	}
+
+	// the lease outlasts the timeout so a job isn't claimed again while it's still running.
+	jobs, err := w.store.ClaimJobs(1, w.timeout+time.Minute)
+	if err != nil || len(jobs) == 0 {
+		return false, err
+	}
+
+	job := jobs[0]
+	if err := w.run(ctx, job); err != nil {
+		return true, w.fail(job, err)
+	}
+
+	return true, w.store.CompleteJob(job.ID)
+}
+
+func (w *Worker) run(ctx context.Context, job types.Job) (err error) {
+	handler, ok := w.handlers[job.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler is registered for jobs of type '%s'", job.Type))
+	}
+
+	// a job that panics fails like any other instead of taking the worker down.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+
+	// the job isn't cancelled when the worker stops, it's finished first.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), w.timeout)
+	defer cancel()
+
+	return handler(ctx, job)
+}
+
+func (w *Worker) fail(job types.Job, err error) error {
+	log.Printf("job worker: job %d of type %s failed on attempt %d: %v", job.ID, job.Type, job.Attempts, err)
+
+	var permanent permanentError
+	if errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts {
+		return w.store.FailJob(job.ID, err.Error(), nil)
+	}
+
+	retryAt := w.now().Add(retryBackoff.Delay(job.Attempts))
+	return w.store.FailJob(job.ID, err.Error(), &retryAt)
+}
+
+func (w *Worker) runSchedules(ctx context.Context) {
+	if len(w.recurring) == 0 {
+		return
+	}
+
+	interval := scheduleInterval
+	for _, recurring := range w.recurring {
+		if recurring.schedule.every > 0 {
+			interval = min(interval, recurring.schedule.every)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.queueDueSchedules()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// queueDueSchedules queues the jobs of the schedules that are due, a schedule that was missed while no
+// worker was running runs once and moves to its next time from now.
+func (w *Worker) queueDueSchedules() {
+	now := w.now()
+	for _, recurring := range w.recurring {
+		job := recurring.job
+		job.RunAt = now
+
+		if _, err := w.store.AdvanceJobSchedule(recurring.name, now, recurring.schedule.Next(now), job); err != nil {
+			log.Printf("job worker: schedule %s: %v", recurring.name, err)
+		}
+	}
+}
+
+// Backoff is how long to wait before retrying, the delay doubles after every failed attempt from First up to Max.
+// The jobs, the webhook deliveries and the notifications are all retried with it.
+type Backoff struct {
+	First time.Duration
+	Max   time.Duration
+}
+
+// Delay is the wait before the next attempt once the given number of attempts failed.
+func (b Backoff) Delay(attempts int) time.Duration {
+	delay := b.First
+	for i := 1; i < attempts && delay < b.Max; i++ {
+		delay *= 2
+	}
+
+	return min(delay, b.Max)
+}
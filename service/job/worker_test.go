@@ -0,0 +1,262 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestWorker(t *testing.T) {
+	now := time.Date(2024, 11, 8, 9, 0, 0, 0, time.UTC)
+
+	newWorker := func(jobs ...types.Job) (*Worker, *mockJobStore) {
+		store := &mockJobStore{jobs: jobs}
+		worker := NewWorker(store, 2)
+		worker.now = func() time.Time { return now }
+
+		return worker, store
+	}
+
+	type orderPayload struct {
+		OrderID int `json:"orderId"`
+	}
+
+	t.Run("Should hand the decoded payload to the handler of the job", func(t *testing.T) {
+		worker, store := newWorker(types.Job{ID: 1, Type: "orders.export", Payload: []byte(`{"orderId":7}`), Attempts: 1, MaxAttempts: 3})
+
+		var received orderPayload
+		Handle(worker, "orders.export", func(ctx context.Context, payload orderPayload) error {
+			received = payload
+			return nil
+		})
+
+		worked, err := worker.Work(context.Background())
+		if err != nil || !worked {
+			t.Fatalf("expected a job to be worked got %v %v", worked, err)
+		}
+		if received.OrderID != 7 || len(store.completed) != 1 {
+			t.Errorf("expected the job to complete with order 7 got %+v", received)
+		}
+	})
+
+	t.Run("Should retry a failed job later", func(t *testing.T) {
+		worker, store := newWorker(types.Job{ID: 1, Type: "orders.export", Payload: []byte(`{}`), Attempts: 2, MaxAttempts: 3})
+		worker.Register("orders.export", func(ctx context.Context, job types.Job) error {
+			return errors.New("timed out")
+		})
+
+		if _, err := worker.Work(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		failed := store.failed[0]
+		if failed.reason != "timed out" || failed.retryAt == nil || !failed.retryAt.Equal(now.Add(20*time.Second)) {
+			t.Errorf("expected the job to be retried in 20s got %+v", failed)
+		}
+	})
+
+	t.Run("Should fail a job once it runs out of attempts", func(t *testing.T) {
+		worker, store := newWorker(types.Job{ID: 1, Type: "orders.export", Payload: []byte(`{}`), Attempts: 3, MaxAttempts: 3})
+		worker.Register("orders.export", func(ctx context.Context, job types.Job) error {
+			return errors.New("timed out")
+		})
+
+		if _, err := worker.Work(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if store.failed[0].retryAt != nil {
+			t.Error("expected the job to fail")
+		}
+	})
+
+	t.Run("Should not retry a permanent error", func(t *testing.T) {
+		cases := map[string]func(worker *Worker){
+			"an invalid payload": func(worker *Worker) {
+				Handle(worker, "orders.export", func(ctx context.Context, payload orderPayload) error { return nil })
+			},
+			"a permanent error": func(worker *Worker) {
+				worker.Register("orders.export", func(ctx context.Context, job types.Job) error {
+					return Permanent(errors.New("the order is gone"))
+				})
+			},
+			"no handler": func(worker *Worker) {},
+		}
+
+		for name, register := range cases {
+			worker, store := newWorker(types.Job{ID: 1, Type: "orders.export", Payload: []byte(`[]`), Attempts: 1, MaxAttempts: 3})
+			register(worker)
+
+			if _, err := worker.Work(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if len(store.failed) != 1 || store.failed[0].retryAt != nil {
+				t.Errorf("expected %s to fail the job got %+v", name, store.failed)
+			}
+		}
+	})
+
+	t.Run("Should fail a job that panics", func(t *testing.T) {
+		worker, store := newWorker(types.Job{ID: 1, Type: "orders.export", Payload: []byte(`{}`), Attempts: 1, MaxAttempts: 3})
+		worker.Register("orders.export", func(ctx context.Context, job types.Job) error {
+			panic("nil map")
+		})
+
+		if _, err := worker.Work(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(store.failed) != 1 || store.failed[0].reason != "job panicked: nil map" {
+			t.Errorf("expected the panic to fail the job got %+v", store.failed)
+		}
+	})
+
+	t.Run("Should work the queued jobs until it's stopped", func(t *testing.T) {
+		jobs := make([]types.Job, 0)
+		for i := 1; i <= 10; i++ {
+			jobs = append(jobs, types.Job{ID: int64(i), Type: "orders.export", Payload: []byte(`{}`), Attempts: 1, MaxAttempts: 3})
+		}
+		worker, store := newWorker(jobs...)
+
+		// stopped while running the last job, which is finished before Run returns.
+		ctx, cancel := context.WithCancel(context.Background())
+		var started atomic.Int32
+		worker.Register("orders.export", func(ctx context.Context, job types.Job) error {
+			if started.Add(1) == 10 {
+				cancel()
+			}
+			return nil
+		})
+
+		done := make(chan struct{})
+		go func() {
+			worker.Run(ctx, 10*time.Millisecond)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the worker to stop")
+		}
+		if store.completedCount() != 10 {
+			t.Errorf("expected the 10 jobs to complete got %d", store.completedCount())
+		}
+	})
+
+	t.Run("Should queue the recurring jobs when they are due", func(t *testing.T) {
+		worker, store := newWorker()
+		if err := worker.Schedule("export", "@hourly", "orders.export", orderPayload{OrderID: 7}); err != nil {
+			t.Fatal(err)
+		}
+
+		store.schedules = map[string]time.Time{"export": now}
+		worker.queueDueSchedules()
+
+		if len(store.enqueued) != 1 || *store.enqueued[0].UniqueKey != "schedule:export" || string(store.enqueued[0].Payload) != `{"orderId":7}` {
+			t.Fatalf("expected the recurring job to be queued got %+v", store.enqueued)
+		}
+		if !store.schedules["export"].Equal(now.Add(time.Hour)) {
+			t.Errorf("expected the next run in an hour got %v", store.schedules["export"])
+		}
+
+		worker.queueDueSchedules()
+		if len(store.enqueued) != 1 {
+			t.Error("expected the job not to be queued before it's due again")
+		}
+	})
+}
+
+func TestEnqueue(t *testing.T) {
+	store := &mockJobStore{}
+
+	job, err := Enqueue(store, "orders.export", map[string]int{"orderId": 7}, Options{Delay: time.Hour, UniqueKey: "export:7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job.MaxAttempts != defaultMaxAttempts || *job.UniqueKey != "export:7" || time.Until(job.RunAt) < 59*time.Minute {
+		t.Errorf("expected a delayed unique job got %+v", job)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		9:  2560 * time.Second,
+		10: retryBackoff.Max,
+	}
+	for attempts, expected := range cases {
+		if delay := retryBackoff.Delay(attempts); delay != expected {
+			t.Errorf("expected %v after %d attempts got %v", expected, attempts, delay)
+		}
+	}
+}
+
+type failedJob struct {
+	id      int64
+	reason  string
+	retryAt *time.Time
+}
+
+type mockJobStore struct {
+	types.JobStore
+	mu        sync.Mutex
+	jobs      []types.Job
+	enqueued  []types.Job
+	completed []int64
+	failed    []failedJob
+	schedules map[string]time.Time
+}
+
+func (m *mockJobStore) EnqueueJob(job types.Job) (*types.Job, bool, error) {
+	m.enqueued = append(m.enqueued, job)
+	return &job, true, nil
+}
+
+func (m *mockJobStore) ClaimJobs(limit int, lease time.Duration) ([]types.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	claimed := m.jobs[:min(limit, len(m.jobs))]
+	m.jobs = m.jobs[len(claimed):]
+	return claimed, nil
+}
+
+func (m *mockJobStore) CompleteJob(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.completed = append(m.completed, id)
+	return nil
+}
+
+func (m *mockJobStore) completedCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.completed)
+}
+
+func (m *mockJobStore) FailJob(id int64, reason string, retryAt *time.Time) error {
+	m.failed = append(m.failed, failedJob{id, reason, retryAt})
+	return nil
+}
+
+func (m *mockJobStore) EnsureJobSchedule(name string, nextRunAt time.Time) error {
+	return nil
+}
+
+func (m *mockJobStore) AdvanceJobSchedule(name string, now, next time.Time, job types.Job) (bool, error) {
+	if m.schedules[name].After(now) {
+		return false, nil
+	}
+
+	m.schedules[name] = next
+	m.EnqueueJob(job)
+	return true, nil
+}
@@ -0,0 +1,94 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// Dispatcher is the broker that queues the emails the domain events notify the users of, the relay
+// publishes to it along with the event broker. The events that don't concern a user are ignored.
+type Dispatcher struct {
+	store     types.NotificationStore
+	templates *Templates
+}
+
+func NewDispatcher(store types.NotificationStore, templates *Templates) *Dispatcher {
+	return &Dispatcher{
+		store:     store,
+		templates: templates,
+	}
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, event types.DomainEvent) error {
+	template, userId, err := notificationFor(event)
+	if err != nil || template == "" {
+		return err
+	}
+
+	eventId := event.ID
+	// a duplicate of an event that was already published isn't queued again.
+	_, err = d.store.EnqueueNotification(types.Notification{
+		UserID:          userId,
+		Template:        template,
+		TemplateVersion: d.templates.Latest(template),
+		EventID:         &eventId,
+		Payload:         event.Payload,
+	})
+	return err
+}
+
+func (d *Dispatcher) Close() error {
+	return nil
+}
+
+// notificationFor returns the template the user is notified with of the event, or an empty template
+// when there is nothing to notify of.
+func notificationFor(event types.DomainEvent) (string, int, error) {
+	switch event.Type {
+	case types.EventUserRegistered:
+		var user types.User
+		if err := json.Unmarshal(event.Payload, &user); err != nil {
+			return "", 0, err
+		}
+
+		return TemplateWelcome, user.ID, nil
+	case types.EventOrderPlaced:
+		var placed types.OrderPlacedEvent
+		if err := json.Unmarshal(event.Payload, &placed); err != nil {
+			return "", 0, err
+		}
+
+		return TemplateOrderConfirmation, placed.Order.UserID, nil
+	case types.EventOrderStatusChanged:
+		var changed types.OrderStatusChangedEvent
+		if err := json.Unmarshal(event.Payload, &changed); err != nil {
+			return "", 0, err
+		}
+		if changed.To != types.OrderStatusCompleted && changed.To != types.OrderStatusCancelled {
+			return "", 0, nil
+		}
+
+		return TemplateOrderStatus, changed.UserID, nil
+	case types.EventShipmentStatusChanged:
+		var changed types.ShipmentStatusChangedEvent
+		if err := json.Unmarshal(event.Payload, &changed); err != nil {
+			return "", 0, err
+		}
+		if changed.To != types.ShipmentStatusShipped && changed.To != types.ShipmentStatusDelivered {
+			return "", 0, nil
+		}
+
+		return TemplateShipmentUpdate, changed.UserID, nil
+	case types.EventCartAbandoned:
+		var abandoned types.CartAbandonedEvent
+		if err := json.Unmarshal(event.Payload, &abandoned); err != nil {
+			return "", 0, err
+		}
+
+		return TemplateAbandonedCart, abandoned.UserID, nil
+	default:
+		return "", 0, nil
+	}
+}
@@ -0,0 +1,299 @@
+package notification
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/middlewares"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+// the page the unsubscribe link of an email opens, the user confirms with the form so that the link
+// scanners of the mail providers don't unsubscribe them.
+var unsubscribePage = htmltemplate.Must(htmltemplate.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body style="font-family:Helvetica,Arial,sans-serif;max-width:480px;margin:48px auto">
+{{if .Done}}<p>You won't receive {{.Category}} emails anymore. You can turn them back on from your notification preferences.</p>
+{{else}}<p>Stop receiving {{.Category}} emails?</p>
+<form method="POST"><button type="submit">Unsubscribe</button></form>{{end}}
+</body>
+</html>
+`))
+
+type Handler struct {
+	store     types.NotificationStore
+	templates *Templates
+	secret    string
+}
+
+func NewHandler(store types.NotificationStore, templates *Templates) *Handler {
+	return &Handler{
+		store:     store,
+		templates: templates,
+		secret:    config.Envs.JWTSecret,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/notification-preferences", auth.AuthenticationMiddleware(h.GetPreferences)).Methods("GET")
+	router.HandleFunc("/notification-preferences", auth.AuthenticationMiddleware(h.UpdatePreferences)).Methods("PATCH")
+	router.HandleFunc("/notifications/unsubscribe", h.ConfirmUnsubscribe).Methods("GET")
+	router.HandleFunc("/notifications/unsubscribe", h.Unsubscribe).Methods("POST")
+
+	router.HandleFunc("/admin/notifications", auth.AdminMiddleware(middlewares.PaginationMiddleware(h.GetNotifications))).Methods("GET")
+	router.HandleFunc("/admin/notification-templates", auth.AdminMiddleware(h.GetTemplates)).Methods("GET")
+	router.HandleFunc("/admin/notification-templates/{name}/preview", auth.AdminMiddleware(h.PreviewTemplate)).Methods("GET")
+}
+
+func (h *Handler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	preferences, err := h.store.GetNotificationPreferences(tokenPayload.UserId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    preferences,
+	})
+}
+
+func (h *Handler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	var payload types.NotificationPreferencesPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	preferences, err := h.store.GetNotificationPreferences(tokenPayload.UserId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if payload.Locale != nil {
+		preferences.Locale = *payload.Locale
+	}
+	if payload.Account != nil {
+		preferences.Account = *payload.Account
+	}
+	if payload.Orders != nil {
+		preferences.Orders = *payload.Orders
+	}
+	if payload.Shipping != nil {
+		preferences.Shipping = *payload.Shipping
+	}
+	if payload.Marketing != nil {
+		preferences.Marketing = *payload.Marketing
+	}
+
+	if err := h.store.SaveNotificationPreferences(*preferences); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    preferences,
+	})
+}
+
+// ConfirmUnsubscribe shows the page of the unsubscribe link of an email.
+func (h *Handler) ConfirmUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	_, category, err := parseUnsubscribeToken(h.secret, r.URL.Query().Get("token"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeUnsubscribePage(w, category, false)
+}
+
+// Unsubscribe opts the user of the token out of its category, it's posted by the confirmation page and
+// by the mail clients that unsubscribe in one click.
+func (h *Handler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	userId, category, err := parseUnsubscribeToken(h.secret, r.URL.Query().Get("token"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	preferences, err := h.store.GetNotificationPreferences(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	*preferenceFields[category](preferences) = false
+	if err := h.store.SaveNotificationPreferences(*preferences); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeUnsubscribePage(w, category, true)
+}
+
+func (h *Handler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if err := utils.Validate.Var(status, "omitempty,oneof=queued sent skipped failed"); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("status must be one of queued, sent, skipped or failed"))
+		return
+	}
+
+	pagination := middlewares.GetPagination(r)
+	offset := middlewares.CalculateOffset(pagination)
+
+	notifications, count, err := h.store.GetNotifications(status, pagination.Limit, offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK,
+		map[string]any{
+			"notifications": notifications,
+			"page":          pagination.Page,
+			"limit":         pagination.Limit,
+			"count":         count,
+		})
+}
+
+func (h *Handler) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    h.templates.List(),
+	})
+}
+
+// PreviewTemplate renders a template with sample data, ?version= defaults to the latest version, ?locale= to
+// the default locale and ?format= to html, text or json for the subject and both bodies.
+func (h *Handler) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	query := r.URL.Query()
+
+	version := h.templates.Latest(name)
+	if query.Get("version") != "" {
+		var err error
+		if version, err = strconv.Atoi(query.Get("version")); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("version must be an integer"))
+			return
+		}
+	}
+
+	locale := query.Get("locale")
+	if locale == "" {
+		locale = defaultLocale
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "text" && format != "json" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("format must be one of html, text or json"))
+		return
+	}
+
+	if !h.templates.exists(name, version, locale) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("no version %d of template '%s' was found in locale '%s'", version, name, locale))
+		return
+	}
+
+	rendered, err := h.templates.Render(name, version, locale, sampleData(name))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		utils.WriteJSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    rendered,
+		})
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "Subject: %s\n\n%s", rendered.Subject, rendered.Text)
+	default:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(rendered.HTML))
+	}
+}
+
+// sampleData is what the templates are previewed with.
+func sampleData(name string) templateData {
+	data := templateData{
+		ShopName:       config.Envs.SellerName,
+		UnsubscribeURL: "https://example.com/unsubscribe",
+		Currency:       config.Envs.Currency,
+		User:           &types.User{ID: 1, FirstName: "Sam", LastName: "Doe", Email: "sam@example.com"},
+		Order: &types.Order{
+			ID:            1001,
+			UserID:        1,
+			Subtotal:      money.FromMinor(4500),
+			DiscountTotal: money.FromMinor(500),
+			ShippingTotal: money.FromMinor(300),
+			TaxTotal:      money.FromMinor(430),
+			Total:         money.FromMinor(4730),
+			Address:       "1 Main St, Amman, JO",
+			CreatedAt:     time.Date(2024, 11, 7, 9, 0, 0, 0, time.UTC),
+		},
+		Items: []itemData{
+			{Name: "Keyboard", Quantity: 2, Total: money.FromMinor(3000)},
+			{Name: "Mouse", Quantity: 1, Total: money.FromMinor(1500)},
+		},
+		ShipmentID: 12,
+	}
+
+	switch name {
+	case TemplateOrderStatus:
+		data.Status = types.OrderStatusCompleted
+	case TemplateShipmentUpdate:
+		data.Status = types.ShipmentStatusShipped
+	case TemplateAbandonedCart:
+		endsAt := time.Date(2024, 11, 10, 9, 0, 0, 0, time.UTC)
+		data.Reminder = &types.CartAbandonedEvent{
+			ReminderID:       7,
+			CartID:           3,
+			UserID:           1,
+			Step:             3,
+			Items:            []types.CartItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}},
+			CouponCode:       "BACK7K2QM9XA",
+			CouponPercentage: 10,
+			CouponEndsAt:     &endsAt,
+			RestoreURL:       "https://example.com/cart/restore",
+		}
+	}
+
+	return data
+}
+
+func writeUnsubscribePage(w http.ResponseWriter, category string, done bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	unsubscribePage.Execute(w, map[string]any{"Category": category, "Done": done})
+}
@@ -0,0 +1,137 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestHandler(t *testing.T) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRouter := func() (*mux.Router, *mockNotificationStore) {
+		store := &mockNotificationStore{}
+		router := mux.NewRouter()
+		NewHandler(store, templates).RegisterRoutes(router)
+
+		return router, store
+	}
+
+	t.Run("Should update only the preferences that are sent", func(t *testing.T) {
+		router, store := newRouter()
+
+		recorder := request(t, router, http.MethodPatch, "/notification-preferences", `{"shipping":false,"locale":"ar"}`,
+			&types.User{ID: 3, Role: types.UserRoleCustomer})
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+
+		expected := types.NotificationPreferences{UserID: 3, Locale: "ar", Account: true, Orders: true, Shipping: false, Marketing: true}
+		if *store.preferences != expected {
+			t.Errorf("expected %+v got %+v", expected, *store.preferences)
+		}
+	})
+
+	t.Run("Should refuse a locale without templates", func(t *testing.T) {
+		router, _ := newRouter()
+
+		recorder := request(t, router, http.MethodPatch, "/notification-preferences", `{"locale":"xx"}`,
+			&types.User{ID: 3, Role: types.UserRoleCustomer})
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Should confirm before unsubscribing", func(t *testing.T) {
+		router, store := newRouter()
+		path := "/notifications/unsubscribe?token=" + url.QueryEscape(UnsubscribeToken(config.Envs.JWTSecret, 3, types.NotificationCategoryOrders))
+
+		recorder := request(t, router, http.MethodGet, path, "", nil)
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "<form") || store.preferences != nil {
+			t.Fatalf("expected the confirmation page got %d: %s", recorder.Code, recorder.Body)
+		}
+
+		recorder = request(t, router, http.MethodPost, path, "List-Unsubscribe=One-Click", nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+		if store.preferences.UserID != 3 || store.preferences.Orders || !store.preferences.Shipping {
+			t.Errorf("expected the user to be unsubscribed from the orders only got %+v", store.preferences)
+		}
+	})
+
+	t.Run("Should refuse a tampered token", func(t *testing.T) {
+		router, store := newRouter()
+		token := UnsubscribeToken(config.Envs.JWTSecret, 3, types.NotificationCategoryOrders)
+		_, signature, _ := strings.Cut(token, ".")
+		tampered := UnsubscribeToken("another secret", 4, types.NotificationCategoryOrders)
+		tampered, _, _ = strings.Cut(tampered, ".")
+
+		recorder := request(t, router, http.MethodPost, "/notifications/unsubscribe?token="+tampered+"."+signature, "", nil)
+		if recorder.Code != http.StatusBadRequest || store.preferences != nil {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Should preview a template with sample data", func(t *testing.T) {
+		router, _ := newRouter()
+
+		recorder := request(t, router, http.MethodGet, "/admin/notification-templates/shipment_update/preview?format=json", "",
+			&types.User{ID: 1, Role: types.UserRoleAdmin})
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+
+		var response struct {
+			Data Rendered `json:"data"`
+		}
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Data.Subject != "Your order #1001 has shipped" {
+			t.Errorf("expected the subject of the shipped order got %q", response.Data.Subject)
+		}
+	})
+
+	t.Run("Should not preview a template that doesn't exist", func(t *testing.T) {
+		router, _ := newRouter()
+
+		recorder := request(t, router, http.MethodGet, "/admin/notification-templates/welcome/preview?version=2", "",
+			&types.User{ID: 1, Role: types.UserRoleAdmin})
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d got %d", http.StatusNotFound, recorder.Code)
+		}
+	})
+}
+
+func request(t *testing.T, router *mux.Router, method, path, body string, user *types.User) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user != nil {
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), *user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", token)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	return recorder
+}
@@ -0,0 +1,316 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/job"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+const (
+	sendBatchSize = 20
+	// a claimed notification isn't picked up by another sender for this long, it's longer than sending can take.
+	sendLease       = time.Minute
+	maxSendAttempts = 5
+)
+
+var retryBackoff = job.Backoff{First: time.Minute, Max: time.Hour}
+
+// the preference of each category.
+var preferenceFields = map[string]func(preferences *types.NotificationPreferences) *bool{
+	types.NotificationCategoryAccount:   func(preferences *types.NotificationPreferences) *bool { return &preferences.Account },
+	types.NotificationCategoryOrders:    func(preferences *types.NotificationPreferences) *bool { return &preferences.Orders },
+	types.NotificationCategoryShipping:  func(preferences *types.NotificationPreferences) *bool { return &preferences.Shipping },
+	types.NotificationCategoryMarketing: func(preferences *types.NotificationPreferences) *bool { return &preferences.Marketing },
+}
+
+// templateData is what the templates are rendered with, only the fields of the template's event are set.
+type templateData struct {
+	ShopName       string
+	UnsubscribeURL string
+	Currency       string
+	User           *types.User
+	Order          *types.Order
+	Items          []itemData
+	Status         string
+	ShipmentID     int
+	Reminder       *types.CartAbandonedEvent
+}
+
+type itemData struct {
+	Name     string
+	Quantity int
+	Total    money.Money
+}
+
+// Sender renders the queued notifications in the locale of their users and sends them. A notification that
+// can't be sent is tried again later, waiting twice as long after every attempt, and has failed once
+// maxSendAttempts failed. The notifications of a category the user opted out of are skipped.
+type Sender struct {
+	store        types.NotificationStore
+	userStore    types.UserStore
+	orderStore   types.OrderStore
+	productStore types.ProductStore
+	mailer       types.Mailer
+	templates    *Templates
+	now          func() time.Time
+}
+
+func NewSender(store types.NotificationStore, userStore types.UserStore, orderStore types.OrderStore,
+	productStore types.ProductStore, mailer types.Mailer, templates *Templates) *Sender {
+	return &Sender{
+		store:        store,
+		userStore:    userStore,
+		orderStore:   orderStore,
+		productStore: productStore,
+		mailer:       mailer,
+		templates:    templates,
+		now:          time.Now,
+	}
+}
+
+// SendAll sends the due notifications batch after batch until none is left, it returns how many were
+// attempted.
+func (s *Sender) SendAll(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		sent, err := s.Send(ctx)
+		total += sent
+		if err != nil || sent < sendBatchSize || ctx.Err() != nil {
+			return total, err
+		}
+	}
+}
+
+// Send sends a batch of due notifications and returns how many were attempted.
+func (s *Sender) Send(ctx context.Context) (int, error) {
+	notifications, err := s.store.ClaimDueNotifications(sendBatchSize, sendLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, notification := range notifications {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+
+		status, err := s.send(notification)
+		notification = s.outcome(notification, status, err)
+		if err := s.store.UpdateNotification(notification); err != nil {
+			log.Printf("notification sender: notification %d: %v", notification.ID, err)
+		}
+	}
+
+	return len(notifications), nil
+}
+
+// send returns the status of the notification once it's sent or skipped.
+func (s *Sender) send(notification types.Notification) (string, error) {
+	preferences, err := s.store.GetNotificationPreferences(notification.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	category := templateCategories[notification.Template]
+	if !*preferenceFields[category](preferences) {
+		return types.NotificationStatusSkipped, nil
+	}
+
+	user, err := s.userStore.GetUserByID(notification.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := s.templateData(notification, user)
+	if err != nil {
+		return "", err
+	}
+
+	rendered, err := s.templates.Render(notification.Template, notification.TemplateVersion, preferences.Locale, data)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.mailer.Send(types.Email{
+		To:       []string{user.Email},
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
+		Headers: map[string]string{
+			// lets the mail clients show an unsubscribe button that posts to the link (RFC 8058).
+			"List-Unsubscribe":      "<" + data.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return types.NotificationStatusSent, nil
+}
+
+func (s *Sender) templateData(notification types.Notification, user *types.User) (templateData, error) {
+	data := templateData{
+		ShopName:       config.Envs.SellerName,
+		UnsubscribeURL: unsubscribeURL(config.Envs.PublicAPIURL, config.Envs.JWTSecret, user.ID, templateCategories[notification.Template]),
+		Currency:       config.Envs.Currency,
+		User:           user,
+	}
+
+	switch notification.Template {
+	case TemplateOrderConfirmation:
+		var placed types.OrderPlacedEvent
+		if err := json.Unmarshal(notification.Payload, &placed); err != nil {
+			return data, err
+		}
+
+		items, err := s.itemsData(placed.Items)
+		if err != nil {
+			return data, err
+		}
+		data.Order = &placed.Order
+		data.Items = items
+	case TemplateOrderStatus:
+		var changed types.OrderStatusChangedEvent
+		if err := json.Unmarshal(notification.Payload, &changed); err != nil {
+			return data, err
+		}
+
+		order, err := s.orderStore.GetOrderById(changed.OrderID)
+		if err != nil {
+			return data, err
+		}
+		data.Order = order
+		data.Status = changed.To
+	case TemplateShipmentUpdate:
+		var changed types.ShipmentStatusChangedEvent
+		if err := json.Unmarshal(notification.Payload, &changed); err != nil {
+			return data, err
+		}
+
+		order, err := s.orderStore.GetOrderById(changed.OrderID)
+		if err != nil {
+			return data, err
+		}
+		data.Order = order
+		data.Status = changed.To
+		data.ShipmentID = changed.ShipmentID
+	case TemplateAbandonedCart:
+		var abandoned types.CartAbandonedEvent
+		if err := json.Unmarshal(notification.Payload, &abandoned); err != nil {
+			return data, err
+		}
+
+		items, err := s.cartItemsData(abandoned.Items)
+		if err != nil {
+			return data, err
+		}
+		data.Reminder = &abandoned
+		data.Items = items
+	}
+
+	return data, nil
+}
+
+func (s *Sender) itemsData(orderItems []types.OrderItem) ([]itemData, error) {
+	productIds := make([]int, 0, len(orderItems))
+	for _, orderItem := range orderItems {
+		productIds = append(productIds, orderItem.ProductID)
+	}
+
+	names := make(map[int]string)
+	if len(productIds) > 0 {
+		products, err := s.productStore.GetProductsByID(productIds)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range products {
+			names[product.ID] = product.Name
+		}
+	}
+
+	items := make([]itemData, 0, len(orderItems))
+	for _, orderItem := range orderItems {
+		name, ok := names[orderItem.ProductID]
+		if !ok {
+			// the product was deleted since.
+			name = fmt.Sprintf("Product #%d", orderItem.ProductID)
+		}
+
+		items = append(items, itemData{
+			Name:     name,
+			Quantity: orderItem.Quantity,
+			Total:    orderItem.Price.Mul(int64(orderItem.Quantity)),
+		})
+	}
+
+	return items, nil
+}
+
+// cartItemsData prices the cart items at the current prices, the products deleted since are left out.
+func (s *Sender) cartItemsData(cartItems []types.CartItem) ([]itemData, error) {
+	productIds := make([]int, 0, len(cartItems))
+	for _, cartItem := range cartItems {
+		productIds = append(productIds, cartItem.ProductID)
+	}
+
+	products := make(map[int]types.Product)
+	if len(productIds) > 0 {
+		found, err := s.productStore.GetProductsByID(productIds)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range found {
+			products[product.ID] = product
+		}
+	}
+
+	items := make([]itemData, 0, len(cartItems))
+	for _, cartItem := range cartItems {
+		product, ok := products[cartItem.ProductID]
+		if !ok {
+			continue
+		}
+
+		items = append(items, itemData{
+			Name:     product.Name,
+			Quantity: cartItem.Quantity,
+			Total:    product.Price.Mul(int64(cartItem.Quantity)),
+		})
+	}
+
+	return items, nil
+}
+
+// outcome returns the notification as it's saved after the attempt to send it.
+func (s *Sender) outcome(notification types.Notification, status string, err error) types.Notification {
+	notification.Attempts++
+	notification.NextAttemptAt = nil
+	notification.Status = status
+
+	if err == nil {
+		notification.LastError = ""
+		if status == types.NotificationStatusSent {
+			sentAt := s.now()
+			notification.SentAt = &sentAt
+		}
+		return notification
+	}
+
+	notification.LastError = err.Error()
+	if notification.Attempts >= maxSendAttempts {
+		notification.Status = types.NotificationStatusFailed
+		return notification
+	}
+
+	nextAttemptAt := s.now().Add(retryBackoff.Delay(notification.Attempts))
+	notification.Status = types.NotificationStatusQueued
+	notification.NextAttemptAt = &nextAttemptAt
+	return notification
+}
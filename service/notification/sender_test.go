@@ -0,0 +1,254 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestSender(t *testing.T) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 11, 7, 9, 0, 0, 0, time.UTC)
+	placed, _ := json.Marshal(types.OrderPlacedEvent{
+		Order: types.Order{ID: 7, UserID: 3, Total: money.FromMinor(2000)},
+		Items: []types.OrderItem{{ProductID: 5, Quantity: 2, Price: money.FromMinor(1000)}},
+	})
+
+	newSender := func(preferences *types.NotificationPreferences, mailer *mockMailer, attempts int) (*Sender, *mockNotificationStore) {
+		store := &mockNotificationStore{
+			preferences: preferences,
+			notifications: []types.Notification{{
+				ID: 1, UserID: 3, Template: TemplateOrderConfirmation, TemplateVersion: 1, Payload: placed,
+				Status: types.NotificationStatusQueued, Attempts: attempts,
+			}},
+		}
+		sender := NewSender(store, &mockUserStore{}, &mockOrderStore{}, &mockProductStore{}, mailer, templates)
+		sender.now = func() time.Time { return now }
+
+		return sender, store
+	}
+
+	t.Run("Should send the email in the locale of the user", func(t *testing.T) {
+		preferences := DefaultPreferences(3)
+		preferences.Locale = "ar"
+		mailer := &mockMailer{}
+		sender, store := newSender(preferences, mailer, 0)
+
+		if _, err := sender.Send(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(mailer.sent) != 1 {
+			t.Fatalf("expected an email to be sent got %d", len(mailer.sent))
+		}
+		email := mailer.sent[0]
+		if email.To[0] != "sam@gmail.com" || !strings.Contains(email.Body, "Keyboard") || email.HTMLBody == "" {
+			t.Errorf("expected the order confirmation got %+v", email)
+		}
+		if !strings.Contains(email.Subject, "7") || strings.HasPrefix(email.Subject, "Your order") {
+			t.Errorf("expected the arabic subject got %q", email.Subject)
+		}
+		if !strings.Contains(email.Headers["List-Unsubscribe"], "/notifications/unsubscribe?token=") ||
+			email.Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+			t.Errorf("expected the unsubscribe headers got %v", email.Headers)
+		}
+
+		updated := store.updated[0]
+		if updated.Status != types.NotificationStatusSent || updated.SentAt == nil || updated.Attempts != 1 {
+			t.Errorf("expected the notification to be sent got %+v", updated)
+		}
+	})
+
+	t.Run("Should skip the categories the user opted out of", func(t *testing.T) {
+		preferences := DefaultPreferences(3)
+		preferences.Orders = false
+		mailer := &mockMailer{}
+		sender, store := newSender(preferences, mailer, 0)
+
+		if _, err := sender.Send(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(mailer.sent) != 0 || store.updated[0].Status != types.NotificationStatusSkipped {
+			t.Errorf("expected the notification to be skipped got %+v", store.updated[0])
+		}
+	})
+
+	t.Run("Should try a failed email again later", func(t *testing.T) {
+		sender, store := newSender(DefaultPreferences(3), &mockMailer{err: errors.New("connection refused")}, 1)
+
+		if _, err := sender.Send(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		updated := store.updated[0]
+		if updated.Status != types.NotificationStatusQueued || updated.LastError != "connection refused" {
+			t.Errorf("expected the notification to stay queued got %+v", updated)
+		}
+		if !updated.NextAttemptAt.Equal(now.Add(2 * time.Minute)) {
+			t.Errorf("expected the next attempt in 2m got %v", updated.NextAttemptAt)
+		}
+	})
+
+	t.Run("Should give up after the last attempt", func(t *testing.T) {
+		sender, store := newSender(DefaultPreferences(3), &mockMailer{err: errors.New("connection refused")}, maxSendAttempts-1)
+
+		if _, err := sender.Send(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if updated := store.updated[0]; updated.Status != types.NotificationStatusFailed || updated.NextAttemptAt != nil {
+			t.Errorf("expected the notification to fail got %+v", updated)
+		}
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		1: time.Minute,
+		2: 2 * time.Minute,
+		6: 32 * time.Minute,
+		7: retryBackoff.Max,
+	}
+	for attempts, expected := range cases {
+		if delay := retryBackoff.Delay(attempts); delay != expected {
+			t.Errorf("expected %v after %d attempts got %v", expected, attempts, delay)
+		}
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		eventType string
+		payload   any
+		template  string
+	}{
+		{"a registered user", types.EventUserRegistered, types.User{ID: 3}, TemplateWelcome},
+		{"a placed order", types.EventOrderPlaced, types.OrderPlacedEvent{Order: types.Order{ID: 7, UserID: 3}}, TemplateOrderConfirmation},
+		{"a cancelled order", types.EventOrderStatusChanged,
+			types.OrderStatusChangedEvent{OrderID: 7, UserID: 3, From: "pending", To: "cancelled"}, TemplateOrderStatus},
+		{"a delivered shipment", types.EventShipmentStatusChanged,
+			types.ShipmentStatusChangedEvent{ShipmentID: 2, OrderID: 7, UserID: 3, From: "shipped", To: "delivered"}, TemplateShipmentUpdate},
+		{"a cancelled shipment", types.EventShipmentStatusChanged,
+			types.ShipmentStatusChangedEvent{ShipmentID: 2, OrderID: 7, UserID: 3, From: "pending", To: "cancelled"}, ""},
+		{"an abandoned cart", types.EventCartAbandoned, types.CartAbandonedEvent{ReminderID: 4, CartID: 2, UserID: 3, Step: 1}, TemplateAbandonedCart},
+		{"a deleted product", types.EventProductDeleted, types.ProductDeletedEvent{ProductID: 5}, ""},
+	}
+
+	for _, c := range cases {
+		t.Run("Should notify the user of "+c.name, func(t *testing.T) {
+			store := &mockNotificationStore{}
+			payload, _ := json.Marshal(c.payload)
+
+			err := NewDispatcher(store, templates).Publish(context.Background(), types.DomainEvent{ID: 9, Type: c.eventType, Payload: payload})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.template == "" {
+				if len(store.enqueued) != 0 {
+					t.Errorf("expected nothing to be queued got %+v", store.enqueued)
+				}
+				return
+			}
+
+			if len(store.enqueued) != 1 {
+				t.Fatalf("expected a notification to be queued got %d", len(store.enqueued))
+			}
+			enqueued := store.enqueued[0]
+			if enqueued.Template != c.template || enqueued.UserID != 3 || *enqueued.EventID != 9 || enqueued.TemplateVersion != 1 {
+				t.Errorf("expected the %s template for user 3 got %+v", c.template, enqueued)
+			}
+		})
+	}
+}
+
+type mockNotificationStore struct {
+	types.NotificationStore
+	notifications []types.Notification
+	updated       []types.Notification
+	enqueued      []types.Notification
+	preferences   *types.NotificationPreferences
+}
+
+func (m *mockNotificationStore) EnqueueNotification(notification types.Notification) (bool, error) {
+	m.enqueued = append(m.enqueued, notification)
+	return true, nil
+}
+
+func (m *mockNotificationStore) ClaimDueNotifications(limit int, lease time.Duration) ([]types.Notification, error) {
+	return m.notifications, nil
+}
+
+func (m *mockNotificationStore) UpdateNotification(notification types.Notification) error {
+	m.updated = append(m.updated, notification)
+	return nil
+}
+
+func (m *mockNotificationStore) GetNotificationPreferences(userID int) (*types.NotificationPreferences, error) {
+	if m.preferences == nil {
+		return DefaultPreferences(userID), nil
+	}
+
+	preferences := *m.preferences
+	return &preferences, nil
+}
+
+func (m *mockNotificationStore) SaveNotificationPreferences(preferences types.NotificationPreferences) error {
+	m.preferences = &preferences
+	return nil
+}
+
+type mockMailer struct {
+	sent []types.Email
+	err  error
+}
+
+func (m *mockMailer) Send(email types.Email) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+type mockUserStore struct {
+	types.UserStore
+}
+
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	return &types.User{ID: id, FirstName: "Sam", LastName: "Doe", Email: "sam@gmail.com"}, nil
+}
+
+type mockOrderStore struct {
+	types.OrderStore
+}
+
+func (m *mockOrderStore) GetOrderById(id int) (*types.Order, error) {
+	return &types.Order{ID: id, UserID: 3}, nil
+}
+
+type mockProductStore struct {
+	types.ProductStore
+}
+
+func (m *mockProductStore) GetProductsByID(productIDs []int) ([]types.Product, error) {
+	return []types.Product{{ID: 5, Name: "Keyboard"}}, nil
+}
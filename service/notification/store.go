@@ -0,0 +1,174 @@
+package notification
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) EnqueueNotification(notification types.Notification) (bool, error) {
+	result, err := s.db.Exec(`
+	INSERT IGNORE INTO notifications (userId, template, templateVersion, eventId, payload, status)
+	VALUES (?,?,?,?,?,?)`,
+		notification.UserID, notification.Template, notification.TemplateVersion, notification.EventID,
+		notification.Payload, types.NotificationStatusQueued)
+	if err != nil {
+		return false, err
+	}
+
+	enqueued, err := result.RowsAffected()
+	return enqueued > 0, err
+}
+
+func (s *Store) ClaimDueNotifications(limit int, lease time.Duration) ([]types.Notification, error) {
+	notifications := make([]types.Notification, 0)
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		rows, err := tx.Query(`
+		SELECT * FROM notifications WHERE status = ? AND nextAttemptAt <= CURRENT_TIMESTAMP
+		ORDER BY nextAttemptAt, id
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED`, types.NotificationStatusQueued, limit)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			notification := new(types.Notification)
+			if err := rows.Scan(notificationAllFieldsScanner(notification)); err != nil {
+				return err
+			}
+
+			notifications = append(notifications, *notification)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(notifications) == 0 {
+			return nil
+		}
+
+		args := []any{int(lease.Seconds())}
+		for _, notification := range notifications {
+			args = append(args, notification.ID)
+		}
+
+		_, err = tx.Exec(`
+		UPDATE notifications SET nextAttemptAt = DATE_ADD(CURRENT_TIMESTAMP, INTERVAL ? SECOND)
+		WHERE id IN (?`+strings.Repeat(",?", len(notifications)-1)+")", args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// UpdateNotification saves the outcome of sending the notification.
+func (s *Store) UpdateNotification(notification types.Notification) error {
+	_, err := s.db.Exec(`
+	UPDATE notifications SET status = ?, attempts = ?, nextAttemptAt = ?, lastError = LEFT(?, 1024), sentAt = ?
+	WHERE id = ?`,
+		notification.Status, notification.Attempts, notification.NextAttemptAt, notification.LastError,
+		notification.SentAt, notification.ID)
+	return err
+}
+
+// returns the notifications with the given status, or all of them when it's empty, the latest first.
+func (s *Store) GetNotifications(status string, limit, offset int) ([]types.Notification, int, error) {
+	rows, err := s.db.Query("SELECT * FROM notifications WHERE (? = '' OR status = ?) ORDER BY id DESC LIMIT ? OFFSET ?",
+		status, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	notifications := make([]types.Notification, 0)
+	for rows.Next() {
+		notification := new(types.Notification)
+		if err := rows.Scan(notificationAllFieldsScanner(notification)); err != nil {
+			return nil, 0, err
+		}
+
+		notifications = append(notifications, *notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM notifications WHERE (? = '' OR status = ?)", status, status).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, count, nil
+}
+
+func (s *Store) GetNotificationPreferences(userID int) (*types.NotificationPreferences, error) {
+	preferences := &types.NotificationPreferences{UserID: userID}
+	err := s.db.QueryRow("SELECT locale, account, orders, shipping, marketing FROM notificationPreferences WHERE userId = ?", userID).
+		Scan(&preferences.Locale, &preferences.Account, &preferences.Orders, &preferences.Shipping, &preferences.Marketing)
+	if err == sql.ErrNoRows {
+		return DefaultPreferences(userID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+func (s *Store) SaveNotificationPreferences(preferences types.NotificationPreferences) error {
+	_, err := s.db.Exec(`
+	INSERT INTO notificationPreferences (userId, locale, account, orders, shipping, marketing) VALUES (?,?,?,?,?,?)
+	ON DUPLICATE KEY UPDATE
+		locale = VALUES(locale), account = VALUES(account), orders = VALUES(orders), shipping = VALUES(shipping),
+		marketing = VALUES(marketing)`,
+		preferences.UserID, preferences.Locale, preferences.Account, preferences.Orders, preferences.Shipping, preferences.Marketing)
+	return err
+}
+
+// DefaultPreferences are the preferences of a user who never changed them, every email is sent in the default locale.
+func DefaultPreferences(userID int) *types.NotificationPreferences {
+	return &types.NotificationPreferences{
+		UserID:    userID,
+		Locale:    defaultLocale,
+		Account:   true,
+		Orders:    true,
+		Shipping:  true,
+		Marketing: true,
+	}
+}
+
+func notificationAllFieldsScanner(notification *types.Notification) (*int64, *int, *string, *int, **int64, *[]byte, *string,
+	*int, **time.Time, *string, **time.Time, *time.Time) {
+	return &notification.ID,
+		&notification.UserID,
+		&notification.Template,
+		&notification.TemplateVersion,
+		&notification.EventID,
+		(*[]byte)(&notification.Payload),
+		&notification.Status,
+		&notification.Attempts,
+		&notification.NextAttemptAt,
+		&notification.LastError,
+		&notification.SentAt,
+		&notification.CreatedAt
+}
@@ -0,0 +1,196 @@
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// the templates are laid out as templates/<name>/v<version>/<locale>.txt and .html, each locale wraps them
+// in its own layout from templates/layouts. The text template defines the subject.
+//
+//go:embed templates
+var templatesFS embed.FS
+
+// the locale used when a template doesn't exist in the locale of the user.
+const defaultLocale = "en"
+
+const (
+	TemplateWelcome           = "welcome"
+	TemplateOrderConfirmation = "order_confirmation"
+	TemplateOrderStatus       = "order_status"
+	TemplateShipmentUpdate    = "shipment_update"
+	TemplateAbandonedCart     = "abandoned_cart"
+)
+
+// the preference that lets the users opt out of each template.
+var templateCategories = map[string]string{
+	TemplateWelcome:           types.NotificationCategoryAccount,
+	TemplateOrderConfirmation: types.NotificationCategoryOrders,
+	TemplateOrderStatus:       types.NotificationCategoryOrders,
+	TemplateShipmentUpdate:    types.NotificationCategoryShipping,
+	TemplateAbandonedCart:     types.NotificationCategoryMarketing,
+}
+
+type templateKey struct {
+	name    string
+	version int
+	locale  string
+}
+
+// Templates holds every version of the templates, a notification is rendered with the version it was queued
+// with so changing a template doesn't change the emails already on their way.
+type Templates struct {
+	text   map[templateKey]*texttemplate.Template
+	html   map[templateKey]*htmltemplate.Template
+	latest map[string]int
+}
+
+// Rendered is an email as rendered for a user.
+type Rendered struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+// TemplateInfo describes a template for the staff.
+type TemplateInfo struct {
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Versions []int    `json:"versions"`
+	Locales  []string `json:"locales"`
+}
+
+func LoadTemplates() (*Templates, error) {
+	return loadTemplates(templatesFS)
+}
+
+func loadTemplates(fsys fs.FS) (*Templates, error) {
+	templates := &Templates{
+		text:   make(map[templateKey]*texttemplate.Template),
+		html:   make(map[templateKey]*htmltemplate.Template),
+		latest: make(map[string]int),
+	}
+
+	files, err := fs.Glob(fsys, "templates/*/v*/*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		parts := strings.Split(file, "/")
+		name, locale := parts[1], strings.TrimSuffix(parts[3], ".txt")
+		version, err := strconv.Atoi(strings.TrimPrefix(parts[2], "v"))
+		if err != nil {
+			return nil, fmt.Errorf("template %s: invalid version '%s'", file, parts[2])
+		}
+		if _, ok := templateCategories[name]; !ok {
+			return nil, fmt.Errorf("template %s: unknown template '%s'", file, name)
+		}
+
+		key := templateKey{name, version, locale}
+		dir := path.Dir(file)
+
+		textTemplate, err := texttemplate.ParseFS(fsys, "templates/layouts/"+locale+".txt", file)
+		if err != nil {
+			return nil, err
+		}
+		htmlTemplate, err := htmltemplate.ParseFS(fsys, "templates/layouts/"+locale+".html", path.Join(dir, locale+".html"))
+		if err != nil {
+			return nil, err
+		}
+		if textTemplate.Lookup("subject") == nil {
+			return nil, fmt.Errorf("template %s: no subject is defined", file)
+		}
+
+		templates.text[key] = textTemplate
+		templates.html[key] = htmlTemplate
+		templates.latest[name] = max(templates.latest[name], version)
+	}
+
+	for name := range templateCategories {
+		if _, ok := templates.text[templateKey{name, templates.latest[name], defaultLocale}]; !ok {
+			return nil, fmt.Errorf("template %s has no %s version %d", name, defaultLocale, templates.latest[name])
+		}
+	}
+
+	return templates, nil
+}
+
+// Latest returns the version the new notifications of the template are rendered with.
+func (t *Templates) Latest(name string) int {
+	return t.latest[name]
+}
+
+// Render renders the template in the locale, or in the default locale when the template doesn't exist in it.
+func (t *Templates) Render(name string, version int, locale string, data any) (Rendered, error) {
+	key := templateKey{name, version, locale}
+	if _, ok := t.text[key]; !ok {
+		key.locale = defaultLocale
+	}
+
+	textTemplate, ok := t.text[key]
+	if !ok {
+		return Rendered{}, fmt.Errorf("no version %d of template '%s' was found", version, name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := textTemplate.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Rendered{}, err
+	}
+	if err := textTemplate.ExecuteTemplate(&text, "layout", data); err != nil {
+		return Rendered{}, err
+	}
+	if err := t.html[key].ExecuteTemplate(&html, "layout", data); err != nil {
+		return Rendered{}, err
+	}
+
+	return Rendered{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+func (t *Templates) List() []TemplateInfo {
+	infos := make(map[string]*TemplateInfo)
+	for key := range t.text {
+		info, ok := infos[key.name]
+		if !ok {
+			info = &TemplateInfo{Name: key.name, Category: templateCategories[key.name]}
+			infos[key.name] = info
+		}
+
+		if !slices.Contains(info.Versions, key.version) {
+			info.Versions = append(info.Versions, key.version)
+		}
+		if !slices.Contains(info.Locales, key.locale) {
+			info.Locales = append(info.Locales, key.locale)
+		}
+	}
+
+	list := make([]TemplateInfo, 0, len(infos))
+	for _, info := range infos {
+		sort.Ints(info.Versions)
+		sort.Strings(info.Locales)
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list
+}
+
+func (t *Templates) exists(name string, version int, locale string) bool {
+	_, ok := t.text[templateKey{name, version, locale}]
+	return ok
+}
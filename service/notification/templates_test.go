@@ -0,0 +1,117 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestTemplates(t *testing.T) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Should render every template in every locale", func(t *testing.T) {
+		for _, info := range templates.List() {
+			for _, version := range info.Versions {
+				for _, locale := range info.Locales {
+					rendered, err := templates.Render(info.Name, version, locale, sampleData(info.Name))
+					if err != nil {
+						t.Fatalf("%s v%d %s: %v", info.Name, version, locale, err)
+					}
+					if rendered.Subject == "" || !strings.Contains(rendered.Text, "https://example.com/unsubscribe") ||
+						!strings.Contains(rendered.HTML, "https://example.com/unsubscribe") {
+						t.Errorf("%s v%d %s: expected a subject and the unsubscribe link got %+v", info.Name, version, locale, rendered)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Should fill in the order", func(t *testing.T) {
+		rendered, err := templates.Render(TemplateOrderConfirmation, 1, "en", sampleData(TemplateOrderConfirmation))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rendered.Subject != "Your order #1001 is confirmed" {
+			t.Errorf("expected the subject of the order got %q", rendered.Subject)
+		}
+		for _, expected := range []string{"Keyboard x 2: 30.00", "Total: 47.30"} {
+			if !strings.Contains(rendered.Text, expected) {
+				t.Errorf("expected the text to contain %q got %s", expected, rendered.Text)
+			}
+		}
+	})
+
+	t.Run("Should offer the coupon of the reminder", func(t *testing.T) {
+		data := sampleData(TemplateAbandonedCart)
+		rendered, err := templates.Render(TemplateAbandonedCart, 1, "en", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rendered.Subject != "Take 10% off the cart you left" {
+			t.Errorf("expected the subject of the coupon got %q", rendered.Subject)
+		}
+		for _, expected := range []string{"BACK7K2QM9XA", "https://example.com/cart/restore"} {
+			if !strings.Contains(rendered.Text, expected) || !strings.Contains(rendered.HTML, expected) {
+				t.Errorf("expected the email to contain %q got %s", expected, rendered.Text)
+			}
+		}
+
+		data.Reminder.Step = 1
+		data.Reminder.CouponCode = ""
+		rendered, err = templates.Render(TemplateAbandonedCart, 1, "en", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rendered.Subject != "You left something in your cart" || strings.Contains(rendered.Text, "BACK7K2QM9XA") {
+			t.Errorf("expected the first reminder without a coupon got %q", rendered.Subject)
+		}
+	})
+
+	t.Run("Should escape the HTML", func(t *testing.T) {
+		data := sampleData(TemplateWelcome)
+		data.User = &types.User{FirstName: "<b>Sam</b>"}
+
+		rendered, err := templates.Render(TemplateWelcome, 1, "en", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(rendered.HTML, "<b>Sam</b>") || !strings.Contains(rendered.Text, "<b>Sam</b>") {
+			t.Error("expected the name to be escaped in the HTML only")
+		}
+	})
+
+	t.Run("Should fall back to the default locale", func(t *testing.T) {
+		rendered, err := templates.Render(TemplateWelcome, 1, "fr", sampleData(TemplateWelcome))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(rendered.Subject, "Welcome") {
+			t.Errorf("expected the english subject got %q", rendered.Subject)
+		}
+	})
+
+	t.Run("Should not render a version that doesn't exist", func(t *testing.T) {
+		if _, err := templates.Render(TemplateWelcome, 99, "en", sampleData(TemplateWelcome)); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("Should refuse a template without a subject", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"templates/layouts/en.txt":     {Data: []byte(`{{define "layout"}}{{template "content" .}}{{end}}`)},
+			"templates/layouts/en.html":    {Data: []byte(`{{define "layout"}}{{template "content" .}}{{end}}`)},
+			"templates/welcome/v1/en.txt":  {Data: []byte(`{{define "content"}}Hi{{end}}`)},
+			"templates/welcome/v1/en.html": {Data: []byte(`{{define "content"}}Hi{{end}}`)},
+		}
+		if _, err := loadTemplates(fsys); err == nil || !strings.Contains(err.Error(), "no subject") {
+			t.Errorf("expected the missing subject to be reported got %v", err)
+		}
+	})
+}
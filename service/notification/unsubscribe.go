@@ -0,0 +1,53 @@
+package notification
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// UnsubscribeToken lets the user opt out of a category from the link of an email without logging in,
+// it's the user id and the category signed with secret.
+func UnsubscribeToken(secret string, userID int, category string) string {
+	message := fmt.Sprintf("%d:%s", userID, category)
+	return base64.RawURLEncoding.EncodeToString([]byte(message)) + "." + signToken(secret, message)
+}
+
+// parseUnsubscribeToken returns the user and the category of a token signed with secret.
+func parseUnsubscribeToken(secret, token string) (int, string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, "", fmt.Errorf("invalid unsubscribe token")
+	}
+
+	message, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(signToken(secret, string(message)))) {
+		return 0, "", fmt.Errorf("invalid unsubscribe token")
+	}
+
+	id, category, _ := strings.Cut(string(message), ":")
+	userId, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid unsubscribe token")
+	}
+	if _, ok := preferenceFields[category]; !ok {
+		return 0, "", fmt.Errorf("invalid unsubscribe token")
+	}
+
+	return userId, category, nil
+}
+
+func unsubscribeURL(baseURL, secret string, userID int, category string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/notifications/unsubscribe?token=" + url.QueryEscape(UnsubscribeToken(secret, userID, category))
+}
+
+func signToken(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte("unsubscribe:"+secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
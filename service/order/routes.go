@@ -0,0 +1,73 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+type Handler struct {
+	store types.OrderStore
+}
+
+func NewHandler(store types.OrderStore) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/shipments/{id}/status", auth.AdminMiddleware(h.UpdateShipmentStatus)).Methods("POST")
+}
+
+// UpdateShipmentStatus marks a shipment as shipped, delivered or cancelled, the customer is notified of the change.
+func (h *Handler) UpdateShipmentStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.ShipmentStatusPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	shipment, err := h.store.UpdateShipmentStatus(id, payload.Status)
+	if errors.Is(err, types.ErrShipmentStatus) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    shipment,
+	})
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("id must be unsigned integer")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,170 @@
+package order
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/outbox"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func (s *Store) CreateShipment(shipment types.Shipment) (*types.Shipment, error) {
+	status := shipment.Status
+	if status == "" {
+		status = types.ShipmentStatusPending
+	}
+
+	result, err := s.db.Exec("INSERT INTO shipments (orderId, warehouseId, status) VALUES (?,?,?)",
+		shipment.OrderID, shipment.WarehouseID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	shipmentId, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	created := new(types.Shipment)
+	err = s.db.QueryRow("SELECT * FROM shipments WHERE id = ?", shipmentId).Scan(shipmentAllFieldsScanner(created))
+	if err != nil {
+		return nil, err
+	}
+
+	created.Items = make([]types.ShipmentItem, 0, len(shipment.Items))
+	for _, item := range shipment.Items {
+		result, err := s.db.Exec("INSERT INTO shipmentItems (shipmentId, productId, quantity) VALUES (?,?,?)",
+			created.ID, item.ProductID, item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		itemId, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+
+		created.Items = append(created.Items, types.ShipmentItem{
+			ID:         int(itemId),
+			ShipmentID: created.ID,
+			ProductID:  item.ProductID,
+			Quantity:   item.Quantity,
+		})
+	}
+
+	return created, nil
+}
+
+func (s *Store) GetOrderShipments(orderID int) ([]types.Shipment, error) {
+	rows, err := s.db.Query("SELECT * FROM shipments WHERE orderId = ? ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	shipments := make([]types.Shipment, 0)
+	shipmentsIndex := make(map[int]int)
+	for rows.Next() {
+		shipment := new(types.Shipment)
+		if err := rows.Scan(shipmentAllFieldsScanner(shipment)); err != nil {
+			return nil, err
+		}
+
+		shipment.Items = make([]types.ShipmentItem, 0)
+		shipmentsIndex[shipment.ID] = len(shipments)
+		shipments = append(shipments, *shipment)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	itemRows, err := s.db.Query(`
+	SELECT shipmentItems.* FROM shipmentItems JOIN shipments ON shipments.id = shipmentItems.shipmentId
+	WHERE shipments.orderId = ? ORDER BY shipmentItems.id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var item types.ShipmentItem
+		if err := itemRows.Scan(&item.ID, &item.ShipmentID, &item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+
+		index := shipmentsIndex[item.ShipmentID]
+		shipments[index].Items = append(shipments[index].Items, item)
+	}
+	if err = itemRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shipments, nil
+}
+
+func shipmentAllFieldsScanner(shipment *types.Shipment) (*int, *int, *int, *string, **time.Time, *time.Time, *time.Time) {
+	return &shipment.ID, &shipment.OrderID, &shipment.WarehouseID, &shipment.Status, &shipment.DeliveredAt, &shipment.CreatedAt,
+		&shipment.UpdatedAt
+}
+
+// the statuses a shipment can move to from each status.
+var shipmentTransitions = map[string][]string{
+	types.ShipmentStatusPending: {types.ShipmentStatusShipped, types.ShipmentStatusCancelled},
+	types.ShipmentStatusShipped: {types.ShipmentStatusDelivered},
+}
+
+// UpdateShipmentStatus moves the shipment along and records a shipment status changed event on its order,
+// types.ErrShipmentStatus is returned when the shipment can't move to status.
+func (s *Store) UpdateShipmentStatus(shipmentID int, status string) (*types.Shipment, error) {
+	shipment := new(types.Shipment)
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		err := tx.QueryRow("SELECT * FROM shipments WHERE id = ? FOR UPDATE", shipmentID).Scan(shipmentAllFieldsScanner(shipment))
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no shipment was found for id %v", shipmentID)
+		}
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(shipmentTransitions[shipment.Status], status) {
+			return fmt.Errorf("%w, shipment with id %v is %s and can't be %s", types.ErrShipmentStatus, shipmentID, shipment.Status, status)
+		}
+
+		var userId int
+		if err := tx.QueryRow("SELECT userId FROM orders WHERE id = ?", shipment.OrderID).Scan(&userId); err != nil {
+			return err
+		}
+
+		// the return window of the items starts once they're delivered.
+		_, err = tx.Exec(`
+		UPDATE shipments SET status = ?, deliveredAt = IF(? = ?, CURRENT_TIMESTAMP, deliveredAt)
+		WHERE id = ?`, status, status, types.ShipmentStatusDelivered, shipmentID)
+		if err != nil {
+			return err
+		}
+
+		event := types.ShipmentStatusChangedEvent{
+			ShipmentID: shipmentID,
+			OrderID:    shipment.OrderID,
+			UserID:     userId,
+			From:       shipment.Status,
+			To:         status,
+		}
+		shipment.Status = status
+		if status == types.ShipmentStatusDelivered {
+			deliveredAt := time.Now()
+			shipment.DeliveredAt = &deliveredAt
+		}
+
+		return outbox.Record(tx, types.AggregateOrder, shipment.OrderID, types.EventShipmentStatusChanged, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shipment, nil
+}
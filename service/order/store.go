@@ -2,13 +2,18 @@ package order
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/outbox"
 	"github.com/mohammadahmadkhader/golang-ecommerce/types"
 )
 
 type Store struct {
-	db *sql.DB
+	db myDB.DBTX
 }
 
 func NewStore(db *sql.DB) *Store {
@@ -17,8 +22,29 @@ func NewStore(db *sql.DB) *Store {
 	}
 }
 
+// returns a store that runs its queries inside the given transaction.
+func (s *Store) WithTx(tx myDB.DBTX) types.OrderStore {
+	return &Store{
+		db: tx,
+	}
+}
+
+// CreateOrder stores the order with its totals, the price breakdown is kept as it was at checkout.
 func (s *Store) CreateOrder(order types.Order) (types.Order, error) {
-	res, err := s.db.Exec("INSERT INTO orders (userId, total, status, address) VALUES (?,?,?,?)", order.UserID, order.Total, order.Status, order.Address)
+	var pricing []byte
+	if order.Pricing != nil {
+		var err error
+		pricing, err = json.Marshal(order.Pricing)
+		if err != nil {
+			return types.Order{}, err
+		}
+	}
+
+	res, err := s.db.Exec(`
+	INSERT INTO orders (userId, total, status, address, subtotal, discountTotal, shippingTotal, taxTotal, pricing, shippingMethodId, shippingMethod)
+	VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+		order.UserID, order.Total, order.Status, order.Address, order.Subtotal, order.DiscountTotal, order.ShippingTotal, order.TaxTotal, pricing,
+		order.ShippingMethodID, order.ShippingMethod)
 	if err != nil {
 		return types.Order{}, err
 	}
@@ -37,7 +63,7 @@ func (s *Store) CreateOrder(order types.Order) (types.Order, error) {
 	return *newOrder, nil
 }
 
-func (s *Store) CreateOrderItem(orderItem types.OrderItem, sd *sql.Tx) (types.OrderItem, error) {
+func (s *Store) CreateOrderItem(orderItem types.OrderItem) (types.OrderItem, error) {
 	res, err := s.db.Exec("INSERT INTO orderItems (orderId, productId, quantity, price) VALUES (?,?,?,?)",
 		orderItem.OrderID, orderItem.ProductID, orderItem.Quantity, orderItem.Price)
 	if err != nil {
@@ -55,13 +81,101 @@ func (s *Store) CreateOrderItem(orderItem types.OrderItem, sd *sql.Tx) (types.Or
 	return *newOrderItem, err
 }
 
+// the tax lines are kept per item so the order can be audited against the rates it was charged.
+func (s *Store) CreateOrderItemTaxes(orderItemID int, taxes []types.TaxLine) error {
+	for _, tax := range taxes {
+		_, err := s.db.Exec("INSERT INTO orderItemTaxes (orderItemId, name, rate, taxable, amount) VALUES (?,?,?,?,?)",
+			orderItemID, tax.Name, tax.Rate, tax.Taxable, tax.Amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) GetOrderById(id int) (*types.Order, error) {
+	order, err := scanRowIntoOrder(s.db.QueryRow("SELECT * FROM orders WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no order was found for id %v", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// the items are in the order they were created, which is the order of the lines of the price breakdown.
+func (s *Store) GetOrderItems(orderID int) ([]types.OrderItem, error) {
+	rows, err := s.db.Query("SELECT * FROM orderItems WHERE orderId = ? ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	orderItems := make([]types.OrderItem, 0)
+	for rows.Next() {
+		orderItem := new(types.OrderItem)
+		if err := rows.Scan(orderItemAllFieldsScanner(orderItem)); err != nil {
+			return nil, err
+		}
+
+		orderItems = append(orderItems, *orderItem)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orderItems, nil
+}
+
+// UpdateOrderStatus records an order status changed event along with the change, when the status is a new one.
+func (s *Store) UpdateOrderStatus(orderID int, status string) error {
+	return myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		var userId int
+		var currentStatus string
+		err := tx.QueryRow("SELECT userId, status FROM orders WHERE id = ? FOR UPDATE", orderID).Scan(&userId, &currentStatus)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no order was found for id %v", orderID)
+		}
+		if err != nil {
+			return err
+		}
+		if currentStatus == status {
+			return nil
+		}
+
+		if _, err := tx.Exec("UPDATE orders SET status = ? WHERE id = ?", status, orderID); err != nil {
+			return err
+		}
+
+		return outbox.Record(tx, types.AggregateOrder, orderID, types.EventOrderStatusChanged, types.OrderStatusChangedEvent{
+			OrderID: orderID,
+			UserID:  userId,
+			From:    currentStatus,
+			To:      status,
+		})
+	})
+}
+
 func scanRowIntoOrder(row *sql.Row) (*types.Order, error) {
 	order := new(types.Order)
-	err := row.Scan(orderAllFieldsScanner(order))
+	var pricing []byte
+	err := row.Scan(orderAllFieldsScanner(order, &pricing))
 	if err != nil {
 		return &types.Order{}, err
 	}
 
+	// orders placed before the breakdown was stored have none.
+	if pricing != nil {
+		order.Pricing = new(types.PriceBreakdown)
+		if err := json.Unmarshal(pricing, order.Pricing); err != nil {
+			return &types.Order{}, err
+		}
+	}
+
 	return order, nil
 }
 
@@ -75,10 +189,12 @@ func scanRowIntoOrderItem(row *sql.Row) (*types.OrderItem, error) {
 	return orderItem, nil
 }
 
-func orderAllFieldsScanner(order *types.Order) (*int, *int, *float64, *string, *string, *time.Time) {
-	return &order.ID, &order.UserID, &order.Total, &order.Status, &order.Address, &order.CreatedAt
+func orderAllFieldsScanner(order *types.Order, pricing *[]byte) (*int, *int, *money.Money, *string, *string, *time.Time, *time.Time,
+	*money.Money, *money.Money, *money.Money, *money.Money, *[]byte, **int, *string) {
+	return &order.ID, &order.UserID, &order.Total, &order.Status, &order.Address, &order.CreatedAt, &order.UpdatedAt,
+		&order.Subtotal, &order.DiscountTotal, &order.ShippingTotal, &order.TaxTotal, pricing, &order.ShippingMethodID, &order.ShippingMethod
 }
 
-func orderItemAllFieldsScanner(orderItem *types.OrderItem) (*int, *int, *int, *int, *float64, *time.Time) {
+func orderItemAllFieldsScanner(orderItem *types.OrderItem) (*int, *int, *int, *int, *money.Money, *time.Time) {
 	return &orderItem.ID, &orderItem.OrderID, &orderItem.ProductID, &orderItem.Quantity, &orderItem.Price, &orderItem.CreatedAt
 }
@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+const (
+	MemoryDriver = "memory"
+	FileDriver   = "file"
+	RedisDriver  = "redis"
+)
+
+// returns the broker selected by the EVENT_BROKER env variable.
+func NewBrokerFromConfig(cfg config.Config) (types.EventBroker, error) {
+	switch cfg.EventBroker {
+	case MemoryDriver:
+		return NewMemoryBroker(), nil
+	case FileDriver:
+		return NewFileBroker(cfg.EventLogPath)
+	case RedisDriver:
+		return NewRedisBroker(cfg.RedisAddress), nil
+	default:
+		return nil, fmt.Errorf("unknown event broker '%s'", cfg.EventBroker)
+	}
+}
+
+// FanOutBroker publishes every event to each of its brokers, the event is published once all of them have it.
+// When one fails the event is published to all of them again, so each broker must put up with duplicates.
+type FanOutBroker struct {
+	brokers []types.EventBroker
+}
+
+func NewFanOutBroker(brokers ...types.EventBroker) *FanOutBroker {
+	return &FanOutBroker{
+		brokers: brokers,
+	}
+}
+
+func (b *FanOutBroker) Publish(ctx context.Context, event types.DomainEvent) error {
+	for _, broker := range b.brokers {
+		if err := broker.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *FanOutBroker) Close() error {
+	var errs []error
+	for _, broker := range b.brokers {
+		errs = append(errs, broker.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// MemoryBroker hands the events to the subscribers of the same process, in the order they are published.
+// Nothing is kept, the events published before a subscriber subscribes are not delivered to it.
+type MemoryBroker struct {
+	mu          sync.RWMutex
+	subscribers []func(event types.DomainEvent)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Subscribe(fn func(event types.DomainEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers = append(b.subscribers, fn)
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, event types.DomainEvent) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, fn := range b.subscribers {
+		fn(event)
+	}
+
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
+
+// FileBroker appends the events to a file as newline delimited JSON, an event is published once it's
+// synced to the disk.
+type FileBroker struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileBroker(path string) (*FileBroker, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileBroker{file: file}, nil
+}
+
+func (b *FileBroker) Publish(ctx context.Context, event types.DomainEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return b.file.Sync()
+}
+
+func (b *FileBroker) Close() error {
+	return b.file.Close()
+}
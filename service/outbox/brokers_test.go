@@ -0,0 +1,215 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func testEvent(id int64) types.DomainEvent {
+	return types.DomainEvent{
+		ID:            id,
+		AggregateType: types.AggregateOrder,
+		AggregateID:   "7",
+		Type:          types.EventOrderStatusChanged,
+		Payload:       json.RawMessage(`{"orderId":7,"from":"pending","to":"completed"}`),
+		OccurredAt:    time.Date(2024, 11, 5, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestFileBroker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	broker, err := NewFileBroker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int64{1, 2} {
+		if err := broker.Publish(context.Background(), testEvent(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := broker.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected an event per line got %q", data)
+	}
+	for i, line := range lines {
+		var event types.DomainEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.ID != int64(i+1) || event.Type != types.EventOrderStatusChanged || string(event.Payload) != string(testEvent(1).Payload) {
+			t.Errorf("expected event %d got %+v", i+1, event)
+		}
+	}
+}
+
+func TestMemoryBroker(t *testing.T) {
+	broker := NewMemoryBroker()
+
+	received := make([]int64, 0)
+	broker.Subscribe(func(event types.DomainEvent) {
+		received = append(received, event.ID)
+	})
+
+	for _, id := range []int64{1, 2} {
+		if err := broker.Publish(context.Background(), testEvent(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(received) != 2 || received[0] != 1 || received[1] != 2 {
+		t.Errorf("expected the subscriber to receive the events in order got %v", received)
+	}
+}
+
+func TestRedisBroker(t *testing.T) {
+	t.Run("Should add the event to the stream of its aggregate type", func(t *testing.T) {
+		commands := make(chan []string, 1)
+		address := fakeRedis(t, func(command []string) string {
+			commands <- command
+			return "$15\r\n1730797200000-0\r\n"
+		})
+
+		broker := NewRedisBroker(address)
+		defer broker.Close()
+
+		if err := broker.Publish(context.Background(), testEvent(1)); err != nil {
+			t.Fatal(err)
+		}
+
+		command := <-commands
+		expected := []string{"XADD", "events:order", "*", "id", "1", "type", types.EventOrderStatusChanged, "aggregateId", "7",
+			"occurredAt", "2024-11-05T09:00:00Z", "payload", string(testEvent(1).Payload)}
+		if strings.Join(command, " ") != strings.Join(expected, " ") {
+			t.Errorf("expected the command %q got %q", expected, command)
+		}
+	})
+
+	t.Run("Should return the error replied by the server", func(t *testing.T) {
+		address := fakeRedis(t, func(command []string) string {
+			return "-ERR wrong type\r\n"
+		})
+
+		broker := NewRedisBroker(address)
+		defer broker.Close()
+
+		err := broker.Publish(context.Background(), testEvent(1))
+		if err == nil || !strings.Contains(err.Error(), "wrong type") {
+			t.Errorf("expected the server error got %v", err)
+		}
+	})
+
+	t.Run("Should fail when the server can't be reached", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		address := listener.Addr().String()
+		listener.Close()
+
+		if err := NewRedisBroker(address).Publish(context.Background(), testEvent(1)); err == nil {
+			t.Error("expected the publish to fail")
+		}
+	})
+}
+
+// fakeRedis reads the commands sent as arrays of bulk strings and writes the reply of handle to each.
+func fakeRedis(t *testing.T, handle func(command []string) string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				for {
+					var count int
+					if err := readLength(reader, "*%d", &count); err != nil {
+						return
+					}
+
+					command := make([]string, count)
+					for i := range command {
+						var size int
+						if err := readLength(reader, "$%d", &size); err != nil {
+							return
+						}
+
+						data := make([]byte, size+2)
+						if _, err := io.ReadFull(reader, data); err != nil {
+							return
+						}
+						command[i] = string(data[:size])
+					}
+
+					conn.Write([]byte(handle(command)))
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func readLength(reader *bufio.Reader, format string, length *int) error {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Sscanf(strings.TrimSuffix(line, "\r\n"), format, length)
+	return err
+}
+
+func TestFanOutBroker(t *testing.T) {
+	t.Run("Should publish the event to every broker", func(t *testing.T) {
+		first, second := &mockBroker{}, &mockBroker{}
+
+		if err := NewFanOutBroker(first, second).Publish(context.Background(), testEvent(1)); err != nil {
+			t.Fatal(err)
+		}
+		if len(first.published) != 1 || len(second.published) != 1 {
+			t.Errorf("expected both brokers to have the event got %v and %v", first.published, second.published)
+		}
+	})
+
+	t.Run("Should fail when a broker fails", func(t *testing.T) {
+		failing := &mockBroker{failing: map[int64]bool{1: true}}
+
+		if err := NewFanOutBroker(&mockBroker{}, failing).Publish(context.Background(), testEvent(1)); err == nil {
+			t.Error("expected the publish to fail")
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// how long a command may take when the context has no deadline.
+const redisTimeout = 5 * time.Second
+
+// RedisBroker adds the events to a Redis stream per aggregate type, events:order for the orders, with
+// XADD. A stream keeps the events in the order they were added, so the consumers read the events of an
+// aggregate in order. It speaks the protocol itself, any server that understands XADD will do.
+type RedisBroker struct {
+	address string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func NewRedisBroker(address string) *RedisBroker {
+	return &RedisBroker{
+		address: address,
+	}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, event types.DomainEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn == nil {
+		dialer := net.Dialer{Timeout: redisTimeout}
+		conn, err := dialer.DialContext(ctx, "tcp", b.address)
+		if err != nil {
+			return err
+		}
+
+		b.conn = conn
+		b.reader = bufio.NewReader(conn)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	b.conn.SetDeadline(deadline)
+
+	_, err := b.command("XADD", "events:"+event.AggregateType, "*",
+		"id", strconv.FormatInt(event.ID, 10),
+		"type", event.Type,
+		"aggregateId", event.AggregateID,
+		"occurredAt", event.OccurredAt.UTC().Format(time.RFC3339),
+		"payload", string(event.Payload))
+
+	// after a network error the connection may be halfway through a reply, the next publish dials again.
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		b.conn.Close()
+		b.conn = nil
+	}
+
+	return err
+}
+
+func (b *RedisBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn == nil {
+		return nil
+	}
+
+	err := b.conn.Close()
+	b.conn = nil
+	return err
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// command sends the arguments as an array of bulk strings and reads the reply.
+func (b *RedisBroker) command(args ...string) (string, error) {
+	var request strings.Builder
+	fmt.Fprintf(&request, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&request, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	if _, err := io.WriteString(b.conn, request.String()); err != nil {
+		return "", err
+	}
+
+	return readRedisReply(b.reader)
+}
+
+// readRedisReply reads a simple string, error, integer or bulk string reply, which are the replies of XADD.
+func readRedisReply(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", redisError(line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("redis: invalid bulk length '%s'", line[1:])
+		}
+		if size < 0 {
+			return "", nil
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return "", err
+		}
+
+		return string(data[:size]), nil
+	default:
+		return "", fmt.Errorf("redis: unexpected reply '%s'", line)
+	}
+}
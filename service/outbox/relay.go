@@ -0,0 +1,81 @@
+package outbox
+
+import (
+	"context"
+	"log"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// how many events are read from the outbox at once.
+const relayBatchSize = 100
+
+// Relay publishes the events recorded in the outbox to the broker. An event is marked as published
+// once the broker has it, a crash in between publishes it again, so the delivery is at least once and
+// the consumers tell the duplicates apart by the event id.
+type Relay struct {
+	store     types.OutboxStore
+	broker    types.EventBroker
+	batchSize int
+}
+
+func NewRelay(store types.OutboxStore, broker types.EventBroker) *Relay {
+	return &Relay{
+		store:     store,
+		broker:    broker,
+		batchSize: relayBatchSize,
+	}
+}
+
+// PublishAll publishes the pending events batch after batch until none is left, it returns how many
+// were published.
+func (r *Relay) PublishAll(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		published, err := r.Publish(ctx)
+		total += published
+		if err != nil || published < r.batchSize || ctx.Err() != nil {
+			return total, err
+		}
+	}
+}
+
+// Publish publishes a batch of pending events in the order they were recorded and returns how many were
+// published. When an event fails the later events of its aggregate are held back until it's published,
+// the events of the other aggregates go on.
+func (r *Relay) Publish(ctx context.Context) (int, error) {
+	published := make([]int64, 0)
+	_, err := r.store.WithLock(func() error {
+		events, err := r.store.GetUnpublishedEvents(r.batchSize)
+		if err != nil {
+			return err
+		}
+
+		blocked := make(map[string]bool)
+		for _, event := range events {
+			aggregate := event.AggregateType + "/" + event.AggregateID
+			if blocked[aggregate] {
+				continue
+			}
+
+			if err := r.broker.Publish(ctx, event); err != nil {
+				blocked[aggregate] = true
+				log.Printf("outbox relay: event %d: %v", event.ID, err)
+
+				if err := r.store.MarkEventFailed(event.ID, err.Error()); err != nil {
+					return err
+				}
+				continue
+			}
+
+			published = append(published, event.ID)
+		}
+
+		return r.store.MarkEventsPublished(published)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(published), nil
+}
@@ -0,0 +1,163 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestRelayPublish(t *testing.T) {
+	newEvents := func() []types.DomainEvent {
+		return []types.DomainEvent{
+			{ID: 1, AggregateType: types.AggregateOrder, AggregateID: "1", Type: types.EventOrderPlaced},
+			{ID: 2, AggregateType: types.AggregateOrder, AggregateID: "2", Type: types.EventOrderPlaced},
+			{ID: 3, AggregateType: types.AggregateOrder, AggregateID: "1", Type: types.EventOrderStatusChanged},
+			{ID: 4, AggregateType: types.AggregateProduct, AggregateID: "1", Type: types.EventProductUpdated},
+		}
+	}
+
+	t.Run("Should publish the events in the order they were recorded", func(t *testing.T) {
+		store := &mockOutboxStore{events: newEvents()}
+		broker := &mockBroker{}
+
+		published, err := NewRelay(store, broker).Publish(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if published != 4 || !slices.Equal(broker.published, []int64{1, 2, 3, 4}) {
+			t.Errorf("expected the 4 events in order got %v", broker.published)
+		}
+		if !slices.Equal(store.published, []int64{1, 2, 3, 4}) {
+			t.Errorf("expected the 4 events to be marked as published got %v", store.published)
+		}
+	})
+
+	t.Run("Should hold back the later events of an aggregate whose event failed", func(t *testing.T) {
+		store := &mockOutboxStore{events: newEvents()}
+		broker := &mockBroker{failing: map[int64]bool{1: true}}
+
+		published, err := NewRelay(store, broker).Publish(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if published != 2 || !slices.Equal(store.published, []int64{2, 4}) {
+			t.Errorf("expected the events of the other aggregates to be published got %v", store.published)
+		}
+		if store.failed[1] != 1 || store.failed[3] != 0 {
+			t.Errorf("expected only the failed event to be marked as failed got %v", store.failed)
+		}
+	})
+
+	t.Run("Should publish the held back events once the failed one goes through", func(t *testing.T) {
+		store := &mockOutboxStore{events: newEvents()}
+		broker := &mockBroker{failing: map[int64]bool{1: true}}
+		relay := NewRelay(store, broker)
+
+		if _, err := relay.Publish(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		broker.failing = nil
+		if _, err := relay.Publish(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(broker.published, []int64{2, 4, 1, 3}) {
+			t.Errorf("expected the events of order 1 to be published in order got %v", broker.published)
+		}
+	})
+
+	t.Run("Should not publish while another relay holds the lock", func(t *testing.T) {
+		store := &mockOutboxStore{events: newEvents(), locked: true}
+		broker := &mockBroker{}
+
+		published, err := NewRelay(store, broker).Publish(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if published != 0 || len(broker.published) != 0 {
+			t.Errorf("expected nothing to be published got %v", broker.published)
+		}
+	})
+}
+
+func TestRelayPublishAll(t *testing.T) {
+	t.Run("Should publish batch after batch until no event is left", func(t *testing.T) {
+		store := &mockOutboxStore{}
+		for id := int64(1); id <= 5; id++ {
+			store.events = append(store.events, types.DomainEvent{ID: id, AggregateType: types.AggregateOrder, AggregateID: fmt.Sprint(id)})
+		}
+		broker := &mockBroker{}
+		relay := NewRelay(store, broker)
+		relay.batchSize = 2
+
+		published, err := relay.PublishAll(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if published != 5 || !slices.Equal(broker.published, []int64{1, 2, 3, 4, 5}) {
+			t.Errorf("expected the 5 events to be published got %v", broker.published)
+		}
+	})
+}
+
+type mockOutboxStore struct {
+	events    []types.DomainEvent
+	published []int64
+	failed    map[int64]int
+	locked    bool
+}
+
+func (m *mockOutboxStore) GetUnpublishedEvents(limit int) ([]types.DomainEvent, error) {
+	events := make([]types.DomainEvent, 0)
+	for _, event := range m.events {
+		if !slices.Contains(m.published, event.ID) && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func (m *mockOutboxStore) MarkEventsPublished(ids []int64) error {
+	m.published = append(m.published, ids...)
+	return nil
+}
+
+func (m *mockOutboxStore) MarkEventFailed(id int64, reason string) error {
+	if m.failed == nil {
+		m.failed = make(map[int64]int)
+	}
+	m.failed[id]++
+	return nil
+}
+
+func (m *mockOutboxStore) WithLock(fn func() error) (bool, error) {
+	if m.locked {
+		return false, nil
+	}
+
+	return true, fn()
+}
+
+type mockBroker struct {
+	published []int64
+	failing   map[int64]bool
+}
+
+func (m *mockBroker) Publish(ctx context.Context, event types.DomainEvent) error {
+	if m.failing[event.ID] {
+		return fmt.Errorf("broker is unavailable")
+	}
+
+	m.published = append(m.published, event.ID)
+	return nil
+}
+
+func (m *mockBroker) Close() error {
+	return nil
+}
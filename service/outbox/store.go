@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// the named lock held by the relay publishing the events, only one relay publishes at a time so the
+// events of an aggregate are never published out of order.
+const relayLockName = "outbox-relay"
+
+// Record writes the event to the outbox with q, which should be the transaction making the change,
+// so the event is recorded if and only if the change is committed.
+func Record(q myDB.DBTX, aggregateType string, aggregateID int, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec("INSERT INTO outbox (aggregateType, aggregateId, type, payload) VALUES (?,?,?,?)",
+		aggregateType, strconv.Itoa(aggregateID), eventType, data)
+
+	return err
+}
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) GetUnpublishedEvents(limit int) ([]types.DomainEvent, error) {
+	rows, err := s.db.Query("SELECT * FROM outbox WHERE publishedAt IS NULL ORDER BY id LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := make([]types.DomainEvent, 0)
+	for rows.Next() {
+		var event types.DomainEvent
+		var payload []byte
+		err := rows.Scan(&event.ID, &event.AggregateType, &event.AggregateID, &event.Type, &payload, &event.Attempts,
+			&event.LastError, &event.OccurredAt, &event.PublishedAt)
+		if err != nil {
+			return nil, err
+		}
+		event.Payload = payload
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (s *Store) MarkEventsPublished(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	_, err := s.db.Exec("UPDATE outbox SET publishedAt = CURRENT_TIMESTAMP WHERE id IN (?"+strings.Repeat(",?", len(ids)-1)+")", args...)
+	return err
+}
+
+func (s *Store) MarkEventFailed(id int64, reason string) error {
+	_, err := s.db.Exec("UPDATE outbox SET attempts = attempts + 1, lastError = LEFT(?, 1024) WHERE id = ?", reason, id)
+	return err
+}
+
+// WithLock takes a MySQL named lock on a connection of its own, GET_LOCK belongs to the connection
+// and the pool would hand out a different one for every query.
+func (s *Store) WithLock(fn func() error) (bool, error) {
+	database, ok := s.db.(*sql.DB)
+	if !ok {
+		return true, fn()
+	}
+
+	ctx := context.Background()
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	defer conn.Close()
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", relayLockName).Scan(&acquired); err != nil {
+		return false, err
+	}
+	if acquired.Int64 != 1 {
+		return false, nil
+	}
+
+	defer conn.ExecContext(ctx, "DO RELEASE_LOCK(?)", relayLockName)
+
+	return true, fn()
+}
@@ -0,0 +1,187 @@
+package payment
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// the payment methods the fake gateway understands, any other method is declined.
+const (
+	FakeCardApproved  = "fake_card_approved"
+	FakeCardDeclined  = "fake_card_declined"
+	FakeCardChallenge = "fake_card_challenge"
+	FakeCardTimeout   = "fake_card_timeout"
+)
+
+// FakeChallengeCode is the answer that passes the challenge of FakeCardChallenge.
+const FakeChallengeCode = "123456"
+
+// FakeGateway is an in-process payment gateway with predictable outcomes, the payment method picks
+// what happens: approved, declined, a challenge the customer must answer with FakeChallengeCode,
+// or a gateway that times out. Its intents live in memory and are lost on restart.
+type FakeGateway struct {
+	mu      sync.Mutex
+	lastId  int
+	intents map[string]*fakeIntent
+}
+
+type fakeIntent struct {
+	types.PaymentIntent
+	method string
+}
+
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		intents: make(map[string]*fakeIntent),
+	}
+}
+
+func (g *FakeGateway) Name() string {
+	return FakeDriver
+}
+
+func (g *FakeGateway) CreateIntent(request types.PaymentIntentRequest) (types.PaymentIntent, error) {
+	if !request.Amount.IsPositive() {
+		return types.PaymentIntent{}, fmt.Errorf("the amount of a payment must be positive got %s", request.Amount)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.lastId++
+	intent := &fakeIntent{PaymentIntent: types.PaymentIntent{
+		ID:       fmt.Sprintf("fake_pi_%d", g.lastId),
+		Status:   types.PaymentStatusPending,
+		Amount:   request.Amount,
+		Captured: money.Zero(),
+		Refunded: money.Zero(),
+	}}
+	g.intents[intent.ID] = intent
+
+	return intent.PaymentIntent, nil
+}
+
+func (g *FakeGateway) Authorize(intentID string, authorization types.PaymentAuthorization) (types.PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, err := g.intent(intentID)
+	if err != nil {
+		return types.PaymentIntent{}, err
+	}
+
+	switch intent.Status {
+	case types.PaymentStatusPending:
+		intent.method = authorization.Method
+	case types.PaymentStatusRequiresAction:
+		intent.NextAction = ""
+		if authorization.ChallengeResponse != FakeChallengeCode {
+			intent.Status = types.PaymentStatusFailed
+			return intent.PaymentIntent, fmt.Errorf("%w: the challenge wasn't passed", types.ErrPaymentDeclined)
+		}
+
+		intent.Status = types.PaymentStatusAuthorized
+		return intent.PaymentIntent, nil
+	default:
+		return types.PaymentIntent{}, fmt.Errorf("payment intent %s can't be authorized, it's %s", intentID, intent.Status)
+	}
+
+	switch intent.method {
+	case FakeCardApproved:
+		intent.Status = types.PaymentStatusAuthorized
+	case FakeCardChallenge:
+		intent.Status = types.PaymentStatusRequiresAction
+		intent.NextAction = "challenge:" + intentID
+	case FakeCardTimeout:
+		// the intent is left pending like a request that never got an answer.
+		return types.PaymentIntent{}, fmt.Errorf("%w: the gateway timed out", types.ErrPaymentUnavailable)
+	case FakeCardDeclined:
+		intent.Status = types.PaymentStatusFailed
+		return intent.PaymentIntent, fmt.Errorf("%w: the card was declined", types.ErrPaymentDeclined)
+	default:
+		intent.Status = types.PaymentStatusFailed
+		return intent.PaymentIntent, fmt.Errorf("%w: unknown payment method '%s'", types.ErrPaymentDeclined, intent.method)
+	}
+
+	return intent.PaymentIntent, nil
+}
+
+// Capture collects amount out of the authorized one, what isn't captured is released.
+func (g *FakeGateway) Capture(intentID string, amount money.Money) (types.PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, err := g.intent(intentID)
+	if err != nil {
+		return types.PaymentIntent{}, err
+	}
+	if intent.Status != types.PaymentStatusAuthorized {
+		return types.PaymentIntent{}, fmt.Errorf("payment intent %s can't be captured, it's %s", intentID, intent.Status)
+	}
+	if !amount.IsPositive() || amount.Cmp(intent.Amount) > 0 {
+		return types.PaymentIntent{}, fmt.Errorf("can't capture %s of the %s authorized", amount, intent.Amount)
+	}
+
+	intent.Status = types.PaymentStatusCaptured
+	intent.Captured = amount
+
+	return intent.PaymentIntent, nil
+}
+
+func (g *FakeGateway) Void(intentID string) (types.PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, err := g.intent(intentID)
+	if err != nil {
+		return types.PaymentIntent{}, err
+	}
+
+	switch intent.Status {
+	case types.PaymentStatusPending, types.PaymentStatusRequiresAction, types.PaymentStatusAuthorized:
+		intent.Status = types.PaymentStatusVoided
+		intent.NextAction = ""
+	default:
+		return types.PaymentIntent{}, fmt.Errorf("payment intent %s can't be voided, it's %s", intentID, intent.Status)
+	}
+
+	return intent.PaymentIntent, nil
+}
+
+func (g *FakeGateway) Refund(intentID string, amount money.Money) (types.PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, err := g.intent(intentID)
+	if err != nil {
+		return types.PaymentIntent{}, err
+	}
+	if intent.Status != types.PaymentStatusCaptured && intent.Status != types.PaymentStatusPartiallyRefunded {
+		return types.PaymentIntent{}, fmt.Errorf("payment intent %s can't be refunded, it's %s", intentID, intent.Status)
+	}
+
+	refundable := intent.Captured.Sub(intent.Refunded)
+	if !amount.IsPositive() || amount.Cmp(refundable) > 0 {
+		return types.PaymentIntent{}, fmt.Errorf("can't refund %s, %s is left to refund", amount, refundable)
+	}
+
+	intent.Refunded = intent.Refunded.Add(amount)
+	intent.Status = types.PaymentStatusPartiallyRefunded
+	if intent.Refunded.Cmp(intent.Captured) == 0 {
+		intent.Status = types.PaymentStatusRefunded
+	}
+
+	return intent.PaymentIntent, nil
+}
+
+func (g *FakeGateway) intent(intentID string) (*fakeIntent, error) {
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return nil, fmt.Errorf("no payment intent was found for id %s", intentID)
+	}
+
+	return intent, nil
+}
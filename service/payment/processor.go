@@ -0,0 +1,216 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// Processor runs the payment of an order through authorize then capture. Between the two the
+// reservations of the order are committed, so an order paid after its stock expired is voided
+// rather than charged, and a failed payment gives the stock back and cancels the order.
+type Processor struct {
+	store          types.PaymentStore
+	provider       types.PaymentProvider
+	orderStore     types.OrderStore
+	inventoryStore types.InventoryStore
+}
+
+func NewProcessor(store types.PaymentStore, provider types.PaymentProvider, orderStore types.OrderStore,
+	inventoryStore types.InventoryStore) *Processor {
+	return &Processor{
+		store:          store,
+		provider:       provider,
+		orderStore:     orderStore,
+		inventoryStore: inventoryStore,
+	}
+}
+
+// Pay opens a payment of the order total with the customer's payment method. It returns
+// ErrPaymentRequiresAction when the customer must confirm it, the order then stays pending
+// until Confirm is called or its reservations expire. An order with nothing to pay has no payment.
+func (p *Processor) Pay(order *types.Order, method string) (*types.Payment, error) {
+	if !order.Total.IsPositive() {
+		if err := p.inventoryStore.CommitReservations(order.ID); err != nil {
+			return nil, p.cancelOrder(order, err)
+		}
+
+		return nil, p.completeOrder(order)
+	}
+
+	intent, err := p.provider.CreateIntent(types.PaymentIntentRequest{OrderID: order.ID, Amount: order.Total})
+	if err != nil {
+		return nil, p.cancelOrder(order, err)
+	}
+
+	payment, err := p.store.CreatePayment(types.Payment{
+		OrderID:        order.ID,
+		Provider:       p.provider.Name(),
+		ProviderRef:    intent.ID,
+		Status:         types.PaymentStatusPending,
+		Amount:         order.Total,
+		CapturedAmount: money.Zero(),
+		RefundedAmount: money.Zero(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, p.authorize(order, payment, types.PaymentAuthorization{Method: method})
+}
+
+// Confirm resumes a payment that required action with the customer's answer.
+func (p *Processor) Confirm(order *types.Order, payment *types.Payment, challengeResponse string) error {
+	if payment.Status != types.PaymentStatusRequiresAction {
+		return fmt.Errorf("payment with id %v doesn't need to be confirmed, it's %s", payment.ID, payment.Status)
+	}
+
+	// the order may have been cancelled by the reservation sweeper while the customer was away.
+	if order.Status != types.OrderStatusPending {
+		p.void(payment)
+		payment.Status = types.PaymentStatusVoided
+		payment.NextAction = ""
+		if err := p.store.UpdatePayment(*payment); err != nil {
+			return err
+		}
+
+		return types.ErrReservationExpired
+	}
+
+	return p.authorize(order, payment, types.PaymentAuthorization{ChallengeResponse: challengeResponse})
+}
+
+// Capture collects an authorized payment whose capture failed, e.g. because the provider was unavailable.
+func (p *Processor) Capture(order *types.Order, payment *types.Payment) error {
+	if payment.Status != types.PaymentStatusAuthorized {
+		return fmt.Errorf("payment with id %v can't be captured, it's %s", payment.ID, payment.Status)
+	}
+
+	return p.capture(order, payment)
+}
+
+// Refund gives back amount of a captured payment, the order itself is left as it is.
+func (p *Processor) Refund(payment *types.Payment, amount money.Money) error {
+	if payment.Status != types.PaymentStatusCaptured && payment.Status != types.PaymentStatusPartiallyRefunded {
+		return fmt.Errorf("payment with id %v can't be refunded, it's %s", payment.ID, payment.Status)
+	}
+
+	refundable := payment.CapturedAmount.Sub(payment.RefundedAmount)
+	if !amount.IsPositive() || amount.Cmp(refundable) > 0 {
+		return fmt.Errorf("the refund must be between 0 and %s", refundable)
+	}
+
+	intent, err := p.provider.Refund(payment.ProviderRef, amount)
+	if err != nil {
+		return err
+	}
+
+	payment.Status = intent.Status
+	payment.RefundedAmount = intent.Refunded
+
+	return p.store.UpdatePayment(*payment)
+}
+
+func (p *Processor) authorize(order *types.Order, payment *types.Payment, authorization types.PaymentAuthorization) error {
+	intent, err := p.provider.Authorize(payment.ProviderRef, authorization)
+	if err != nil {
+		return p.fail(order, payment, err)
+	}
+
+	payment.Status = intent.Status
+	payment.NextAction = intent.NextAction
+	if err := p.store.UpdatePayment(*payment); err != nil {
+		return err
+	}
+	if intent.Status == types.PaymentStatusRequiresAction {
+		return types.ErrPaymentRequiresAction
+	}
+
+	return p.commitAndCapture(order, payment)
+}
+
+// the stock is taken before the money, so a payment that came too late is voided instead of refunded.
+func (p *Processor) commitAndCapture(order *types.Order, payment *types.Payment) error {
+	if err := p.inventoryStore.CommitReservations(order.ID); err != nil {
+		p.void(payment)
+		payment.Status = types.PaymentStatusVoided
+		payment.FailureReason = err.Error()
+		if updateErr := p.store.UpdatePayment(*payment); updateErr != nil {
+			return updateErr
+		}
+
+		return p.cancelOrder(order, err)
+	}
+
+	return p.capture(order, payment)
+}
+
+// the stock is committed by then, when the capture fails the payment stays authorized and
+// the order pending so the capture can be retried.
+func (p *Processor) capture(order *types.Order, payment *types.Payment) error {
+	intent, err := p.provider.Capture(payment.ProviderRef, payment.Amount)
+	if err != nil {
+		payment.FailureReason = err.Error()
+		if updateErr := p.store.UpdatePayment(*payment); updateErr != nil {
+			return updateErr
+		}
+
+		return err
+	}
+
+	payment.Status = intent.Status
+	payment.CapturedAmount = intent.Captured
+	payment.FailureReason = ""
+	if err := p.store.UpdatePayment(*payment); err != nil {
+		return err
+	}
+
+	return p.completeOrder(order)
+}
+
+// a gateway that timed out may still have authorized the payment, it's voided to be sure it's never collected.
+func (p *Processor) fail(order *types.Order, payment *types.Payment, cause error) error {
+	if errors.Is(cause, types.ErrPaymentUnavailable) {
+		p.void(payment)
+	}
+
+	payment.Status = types.PaymentStatusFailed
+	payment.NextAction = ""
+	payment.FailureReason = cause.Error()
+	if err := p.store.UpdatePayment(*payment); err != nil {
+		return err
+	}
+
+	return p.cancelOrder(order, cause)
+}
+
+func (p *Processor) void(payment *types.Payment) {
+	if _, err := p.provider.Void(payment.ProviderRef); err != nil {
+		log.Printf("payment %d couldn't be voided: %v", payment.ID, err)
+	}
+}
+
+func (p *Processor) completeOrder(order *types.Order) error {
+	if err := p.orderStore.UpdateOrderStatus(order.ID, types.OrderStatusCompleted); err != nil {
+		return err
+	}
+	order.Status = types.OrderStatusCompleted
+
+	return nil
+}
+
+// cancelOrder gives the held stock back and cancels the order, cause is returned when that went fine.
+func (p *Processor) cancelOrder(order *types.Order, cause error) error {
+	if err := p.inventoryStore.ReleaseReservations(order.ID); err != nil {
+		return err
+	}
+	if err := p.orderStore.UpdateOrderStatus(order.ID, types.OrderStatusCancelled); err != nil {
+		return err
+	}
+	order.Status = types.OrderStatusCancelled
+
+	return cause
+}
@@ -0,0 +1,306 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestProcessorPay(t *testing.T) {
+	cases := []struct {
+		name          string
+		method        string
+		commitErr     error
+		err           error
+		orderStatus   string
+		paymentStatus string
+		intentStatus  string
+		released      bool
+	}{
+		{
+			name:          "Should capture an approved payment and complete the order",
+			method:        FakeCardApproved,
+			orderStatus:   types.OrderStatusCompleted,
+			paymentStatus: types.PaymentStatusCaptured,
+			intentStatus:  types.PaymentStatusCaptured,
+		},
+		{
+			name:          "Should cancel the order and release its stock when the card is declined",
+			method:        FakeCardDeclined,
+			err:           types.ErrPaymentDeclined,
+			orderStatus:   types.OrderStatusCancelled,
+			paymentStatus: types.PaymentStatusFailed,
+			intentStatus:  types.PaymentStatusFailed,
+			released:      true,
+		},
+		{
+			name:          "Should decline a payment method the gateway doesn't know",
+			method:        "tok_visa",
+			err:           types.ErrPaymentDeclined,
+			orderStatus:   types.OrderStatusCancelled,
+			paymentStatus: types.PaymentStatusFailed,
+			intentStatus:  types.PaymentStatusFailed,
+			released:      true,
+		},
+		{
+			name:          "Should leave the order pending while the challenge isn't answered",
+			method:        FakeCardChallenge,
+			err:           types.ErrPaymentRequiresAction,
+			orderStatus:   types.OrderStatusPending,
+			paymentStatus: types.PaymentStatusRequiresAction,
+			intentStatus:  types.PaymentStatusRequiresAction,
+		},
+		{
+			name:          "Should void the intent and cancel the order when the gateway times out",
+			method:        FakeCardTimeout,
+			err:           types.ErrPaymentUnavailable,
+			orderStatus:   types.OrderStatusCancelled,
+			paymentStatus: types.PaymentStatusFailed,
+			intentStatus:  types.PaymentStatusVoided,
+			released:      true,
+		},
+		{
+			name:          "Should void the authorization when the reservations expired",
+			method:        FakeCardApproved,
+			commitErr:     types.ErrReservationExpired,
+			err:           types.ErrReservationExpired,
+			orderStatus:   types.OrderStatusCancelled,
+			paymentStatus: types.PaymentStatusVoided,
+			intentStatus:  types.PaymentStatusVoided,
+			released:      true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gateway, store, orderStore, inventoryStore := newTestProcessorDeps()
+			inventoryStore.commitErr = c.commitErr
+			processor := NewProcessor(store, gateway, orderStore, inventoryStore)
+
+			order := &types.Order{ID: 1, Total: money.FromMinor(2500), Status: types.OrderStatusPending}
+			payment, err := processor.Pay(order, c.method)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expected %v got %v", c.err, err)
+			}
+
+			if order.Status != c.orderStatus || orderStore.statuses[1] != c.orderStatus {
+				t.Errorf("expected the order to be %s got %s and %s stored", c.orderStatus, order.Status, orderStore.statuses[1])
+			}
+			if stored := store.payments[payment.ID]; stored.Status != c.paymentStatus {
+				t.Errorf("expected the payment to be %s got %s", c.paymentStatus, stored.Status)
+			}
+			if intent := gateway.intents[payment.ProviderRef]; intent.Status != c.intentStatus {
+				t.Errorf("expected the intent to be %s got %s", c.intentStatus, intent.Status)
+			}
+			if inventoryStore.released != c.released {
+				t.Errorf("expected the reservations released to be %v", c.released)
+			}
+		})
+	}
+
+	t.Run("Should complete an order with nothing to pay without a payment", func(t *testing.T) {
+		gateway, store, orderStore, inventoryStore := newTestProcessorDeps()
+		processor := NewProcessor(store, gateway, orderStore, inventoryStore)
+
+		order := &types.Order{ID: 1, Total: money.Zero(), Status: types.OrderStatusPending}
+		payment, err := processor.Pay(order, FakeCardApproved)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if payment != nil || len(gateway.intents) != 0 || !inventoryStore.committed || order.Status != types.OrderStatusCompleted {
+			t.Errorf("expected the order to be completed without a payment got %+v and %s", payment, order.Status)
+		}
+	})
+}
+
+func TestProcessorConfirm(t *testing.T) {
+	cases := []struct {
+		name          string
+		response      string
+		orderStatus   string
+		err           error
+		paymentStatus string
+		resultStatus  string
+	}{
+		{
+			name:          "Should capture the payment once the challenge is passed",
+			response:      FakeChallengeCode,
+			orderStatus:   types.OrderStatusPending,
+			paymentStatus: types.PaymentStatusCaptured,
+			resultStatus:  types.OrderStatusCompleted,
+		},
+		{
+			name:          "Should cancel the order when the challenge is failed",
+			response:      "000000",
+			orderStatus:   types.OrderStatusPending,
+			err:           types.ErrPaymentDeclined,
+			paymentStatus: types.PaymentStatusFailed,
+			resultStatus:  types.OrderStatusCancelled,
+		},
+		{
+			name:          "Should void the payment of an order cancelled in the meantime",
+			response:      FakeChallengeCode,
+			orderStatus:   types.OrderStatusCancelled,
+			err:           types.ErrReservationExpired,
+			paymentStatus: types.PaymentStatusVoided,
+			resultStatus:  types.OrderStatusCancelled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gateway, store, orderStore, inventoryStore := newTestProcessorDeps()
+			processor := NewProcessor(store, gateway, orderStore, inventoryStore)
+
+			order := &types.Order{ID: 1, Total: money.FromMinor(2500), Status: types.OrderStatusPending}
+			payment, err := processor.Pay(order, FakeCardChallenge)
+			if !errors.Is(err, types.ErrPaymentRequiresAction) || payment.NextAction == "" {
+				t.Fatalf("expected the payment to require action got %v", err)
+			}
+
+			order.Status = c.orderStatus
+			err = processor.Confirm(order, payment, c.response)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expected %v got %v", c.err, err)
+			}
+
+			if payment.Status != c.paymentStatus || store.payments[payment.ID].Status != c.paymentStatus {
+				t.Errorf("expected the payment to be %s got %s", c.paymentStatus, payment.Status)
+			}
+			if order.Status != c.resultStatus {
+				t.Errorf("expected the order to be %s got %s", c.resultStatus, order.Status)
+			}
+		})
+	}
+
+	t.Run("Should refuse to confirm a payment that doesn't require action", func(t *testing.T) {
+		gateway, store, orderStore, inventoryStore := newTestProcessorDeps()
+		processor := NewProcessor(store, gateway, orderStore, inventoryStore)
+
+		order := &types.Order{ID: 1, Total: money.FromMinor(2500), Status: types.OrderStatusPending}
+		payment, _ := processor.Pay(order, FakeCardApproved)
+		if err := processor.Confirm(order, payment, FakeChallengeCode); err == nil {
+			t.Error("expected the confirmation to be refused")
+		}
+	})
+}
+
+func TestProcessorRefund(t *testing.T) {
+	gateway, store, orderStore, inventoryStore := newTestProcessorDeps()
+	processor := NewProcessor(store, gateway, orderStore, inventoryStore)
+
+	order := &types.Order{ID: 1, Total: money.FromMinor(2500), Status: types.OrderStatusPending}
+	payment, err := processor.Pay(order, FakeCardApproved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name     string
+		amount   int64
+		fail     bool
+		status   string
+		refunded int64
+	}{
+		{name: "Should refund part of the payment", amount: 1000, status: types.PaymentStatusPartiallyRefunded, refunded: 1000},
+		{name: "Should refuse to refund more than what's left", amount: 1501, fail: true, status: types.PaymentStatusPartiallyRefunded, refunded: 1000},
+		{name: "Should refuse a refund that isn't positive", amount: 0, fail: true, status: types.PaymentStatusPartiallyRefunded, refunded: 1000},
+		{name: "Should be refunded once what's left is refunded", amount: 1500, status: types.PaymentStatusRefunded, refunded: 2500},
+		{name: "Should refuse to refund a refunded payment", amount: 1, fail: true, status: types.PaymentStatusRefunded, refunded: 2500},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			err := processor.Refund(payment, money.FromMinor(step.amount))
+			if step.fail != (err != nil) {
+				t.Fatalf("expected the refund to fail to be %v got %v", step.fail, err)
+			}
+
+			stored := store.payments[payment.ID]
+			if stored.Status != step.status || stored.RefundedAmount.Amount != step.refunded {
+				t.Errorf("expected %s with %d refunded got %s with %s", step.status, step.refunded, stored.Status, stored.RefundedAmount)
+			}
+		})
+	}
+}
+
+func newTestProcessorDeps() (*FakeGateway, *mockPaymentStore, *mockOrderStore, *mockInventoryStore) {
+	return NewFakeGateway(),
+		&mockPaymentStore{payments: map[int]types.Payment{}},
+		&mockOrderStore{statuses: map[int]string{1: types.OrderStatusPending}},
+		&mockInventoryStore{}
+}
+
+type mockPaymentStore struct {
+	types.PaymentStore
+	payments map[int]types.Payment
+}
+
+func (m *mockPaymentStore) CreatePayment(payment types.Payment) (*types.Payment, error) {
+	payment.ID = len(m.payments) + 1
+	m.payments[payment.ID] = payment
+	return &payment, nil
+}
+
+func (m *mockPaymentStore) GetPaymentByProviderRef(provider, providerRef string) (*types.Payment, error) {
+	for _, payment := range m.payments {
+		if payment.Provider == provider && payment.ProviderRef == providerRef {
+			return &payment, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no %s payment was found for %s", provider, providerRef)
+}
+
+func (m *mockPaymentStore) UpdatePayment(payment types.Payment) error {
+	if _, ok := m.payments[payment.ID]; !ok {
+		return fmt.Errorf("no payment was found for id %v", payment.ID)
+	}
+
+	m.payments[payment.ID] = payment
+	return nil
+}
+
+type mockOrderStore struct {
+	types.OrderStore
+	statuses map[int]string
+}
+
+func (m *mockOrderStore) GetOrderById(id int) (*types.Order, error) {
+	status, ok := m.statuses[id]
+	if !ok {
+		return nil, fmt.Errorf("no order was found for id %v", id)
+	}
+
+	return &types.Order{ID: id, Total: money.FromMinor(2500), Status: status}, nil
+}
+
+func (m *mockOrderStore) UpdateOrderStatus(orderID int, status string) error {
+	m.statuses[orderID] = status
+	return nil
+}
+
+type mockInventoryStore struct {
+	types.InventoryStore
+	commitErr error
+	committed bool
+	released  bool
+}
+
+func (m *mockInventoryStore) CommitReservations(orderID int) error {
+	if m.commitErr != nil {
+		return m.commitErr
+	}
+
+	m.committed = true
+	return nil
+}
+
+func (m *mockInventoryStore) ReleaseReservations(orderID int) error {
+	m.released = true
+	return nil
+}
@@ -0,0 +1,20 @@
+package payment
+
+import (
+	"fmt"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+const FakeDriver = "fake"
+
+// returns the payment provider selected by the PAYMENT_PROVIDER env variable.
+func NewProviderFromConfig(cfg config.Config) (types.PaymentProvider, error) {
+	switch cfg.PaymentProvider {
+	case FakeDriver:
+		return NewFakeGateway(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider '%s'", cfg.PaymentProvider)
+	}
+}
@@ -0,0 +1,415 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/middlewares"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+// the largest webhook body that is read, the events are a few hundred bytes.
+const maxWebhookSize = 64 << 10
+
+// errWebhookEventBusy is returned when another request is applying the event or already did.
+var errWebhookEventBusy = errors.New("the webhook event is already being processed")
+
+type Handler struct {
+	store            types.PaymentStore
+	eventStore       types.WebhookEventStore
+	orderStore       types.OrderStore
+	processor        *Processor
+	webhookSecret    string
+	webhookTolerance time.Duration
+}
+
+func NewHandler(store types.PaymentStore, eventStore types.WebhookEventStore, orderStore types.OrderStore, processor *Processor) *Handler {
+	toleranceInSeconds, err := strconv.Atoi(config.Envs.PaymentWebhookToleranceInSeconds)
+	if err != nil || toleranceInSeconds <= 0 {
+		toleranceInSeconds = 5 * 60
+	}
+
+	return &Handler{
+		store:            store,
+		eventStore:       eventStore,
+		orderStore:       orderStore,
+		processor:        processor,
+		webhookSecret:    config.Envs.PaymentWebhookSecret,
+		webhookTolerance: time.Duration(toleranceInSeconds) * time.Second,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/payments/{id}/confirm", auth.AuthenticationMiddleware(h.ConfirmPayment)).Methods("POST")
+	router.HandleFunc("/webhooks/payments/{provider}", h.ReceiveWebhook).Methods("POST")
+
+	router.HandleFunc("/admin/orders/{id}/payments", auth.AdminMiddleware(h.GetOrderPayments)).Methods("GET")
+	router.HandleFunc("/admin/payments/{id}/capture", auth.AdminMiddleware(h.CapturePayment)).Methods("POST")
+	router.HandleFunc("/admin/payments/{id}/refund", auth.AdminMiddleware(h.RefundPayment)).Methods("POST")
+	router.HandleFunc("/admin/webhooks/payments/events", auth.AdminMiddleware(middlewares.PaginationMiddleware(h.GetWebhookEvents))).Methods("GET")
+	router.HandleFunc("/admin/webhooks/payments/events/{id}/retry", auth.AdminMiddleware(h.RetryWebhookEvent)).Methods("POST")
+}
+
+// ConfirmPayment answers the challenge of a payment that required action, only the customer
+// of the order can confirm it.
+func (h *Handler) ConfirmPayment(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.PaymentConfirmationPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payment, order, err := h.getPaymentAndOrder(id)
+	if err == nil && order.UserID != tokenPayload.UserId {
+		err = fmt.Errorf("no payment was found for id %v", id)
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	err = h.processor.Confirm(order, payment, payload.ChallengeResponse)
+	if errors.Is(err, types.ErrPaymentRequiresAction) {
+		utils.WriteJSON(w, http.StatusAccepted, map[string]any{
+			"order":   order,
+			"payment": payment,
+		})
+		return
+	}
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"order":   order,
+		"payment": payment,
+	})
+}
+
+func (h *Handler) GetOrderPayments(w http.ResponseWriter, r *http.Request) {
+	orderId, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := h.orderStore.GetOrderById(orderId); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	payments, err := h.store.GetOrderPayments(orderId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    payments,
+	})
+}
+
+// CapturePayment retries the capture of an authorized payment.
+func (h *Handler) CapturePayment(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payment, order, err := h.getPaymentAndOrder(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.processor.Capture(order, payment); err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    payment,
+	})
+}
+
+// RefundPayment refunds the given amount, or what's left of the captured amount without one.
+func (h *Handler) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.PaymentRefundPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payment, err := h.store.GetPaymentById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	amount := payment.CapturedAmount.Sub(payment.RefundedAmount)
+	if payload.Amount != nil {
+		amount = *payload.Amount
+	}
+
+	if err := h.processor.Refund(payment, amount); err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    payment,
+	})
+}
+
+// ReceiveWebhook stores the signed events of the provider and applies them. An event is stored
+// once, its replays are acknowledged without being applied again. An event that fails to apply
+// is acknowledged too, it's kept as failed to be retried by an admin.
+func (h *Handler) ReceiveWebhook(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+	if provider != h.processor.provider.Name() {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("unknown payment provider '%s'", provider))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = VerifyWebhookSignature(r.Header.Get(SignatureHeader), body, h.webhookSecret, h.webhookTolerance, time.Now())
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	var event types.PaymentEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(event); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	stored, created, err := h.eventStore.CreateWebhookEvent(types.WebhookEvent{
+		Provider: provider,
+		EventID:  event.ID,
+		Type:     event.Type,
+		Payload:  body,
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !created {
+		utils.WriteJSON(w, http.StatusOK, map[string]any{
+			"message": "the event was already received",
+			"data":    stored,
+		})
+		return
+	}
+
+	err = h.processEvent(stored, event)
+	if errors.Is(err, errWebhookEventBusy) {
+		utils.WriteJSON(w, http.StatusOK, map[string]any{
+			"message": "the event is already being processed",
+			"data":    stored,
+		})
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    stored,
+	})
+}
+
+// GetWebhookEvents lists the received events, ?status=failed lists the ones to retry.
+func (h *Handler) GetWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	err := utils.Validate.Var(status, "omitempty,oneof=pending processing processed ignored failed")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("status must be one of pending, processed, ignored or failed"))
+		return
+	}
+
+	pagination := middlewares.GetPagination(r)
+	offset := middlewares.CalculateOffset(pagination)
+
+	events, count, err := h.eventStore.GetWebhookEvents(status, pagination.Limit, offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK,
+		map[string]any{
+			"events": events,
+			"page":   pagination.Page,
+			"limit":  pagination.Limit,
+			"count":  count,
+		})
+}
+
+// RetryWebhookEvent applies a failed event again, the event tells whether it worked this time.
+// An event that is being applied meanwhile is refused with a conflict.
+func (h *Handler) RetryWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	stored, err := h.eventStore.GetWebhookEventById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if stored.Status == types.WebhookEventStatusProcessed || stored.Status == types.WebhookEventStatusIgnored {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("webhook event with id %v was already %s", id, stored.Status))
+		return
+	}
+
+	var event types.PaymentEvent
+	if err := json.Unmarshal(stored.Payload, &event); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = h.processEvent(stored, event)
+	if errors.Is(err, errWebhookEventBusy) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    stored,
+	})
+}
+
+// processEvent claims the event so concurrent requests can't apply it twice, then applies it and
+// records the outcome on the stored one. Only failing to claim or to record it is returned.
+func (h *Handler) processEvent(stored *types.WebhookEvent, event types.PaymentEvent) error {
+	claimed, err := h.eventStore.ClaimWebhookEvent(stored.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return fmt.Errorf("%w: webhook event with id %v", errWebhookEventBusy, stored.ID)
+	}
+
+	applied, err := h.processor.HandleEvent(event)
+
+	now := time.Now()
+	stored.Attempts++
+	stored.LastError = ""
+	switch {
+	case err != nil:
+		log.Printf("payment webhook event %d failed: %v", stored.ID, err)
+		stored.Status = types.WebhookEventStatusFailed
+		stored.LastError = truncate(err.Error(), 1024)
+	case !applied:
+		stored.Status = types.WebhookEventStatusIgnored
+		stored.ProcessedAt = &now
+	default:
+		stored.Status = types.WebhookEventStatusProcessed
+		stored.ProcessedAt = &now
+	}
+
+	return h.eventStore.UpdateWebhookEvent(*stored)
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+
+	return value[:length]
+}
+
+func (h *Handler) getPaymentAndOrder(paymentId int) (*types.Payment, *types.Order, error) {
+	payment, err := h.store.GetPaymentById(paymentId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order, err := h.orderStore.GetOrderById(payment.OrderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return payment, order, nil
+}
+
+func writePaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrPaymentDeclined):
+		utils.WriteError(w, http.StatusPaymentRequired, err)
+	case errors.Is(err, types.ErrPaymentUnavailable):
+		utils.WriteError(w, http.StatusServiceUnavailable, err)
+	case errors.Is(err, types.ErrReservationExpired), errors.Is(err, types.ErrInsufficientStock):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		utils.WriteError(w, http.StatusBadRequest, err)
+	}
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("id must be unsigned integer")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,131 @@
+package payment
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) CreatePayment(payment types.Payment) (*types.Payment, error) {
+	status := payment.Status
+	if status == "" {
+		status = types.PaymentStatusPending
+	}
+
+	result, err := s.db.Exec(`
+	INSERT INTO payments (orderId, provider, providerRef, status, amount, capturedAmount, refundedAmount, nextAction, failureReason)
+	VALUES (?,?,?,?,?,?,?,?,?)`,
+		payment.OrderID, payment.Provider, payment.ProviderRef, status, payment.Amount, payment.CapturedAmount,
+		payment.RefundedAmount, payment.NextAction, payment.FailureReason)
+	if err != nil {
+		return nil, err
+	}
+
+	paymentId, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetPaymentById(int(paymentId))
+}
+
+func (s *Store) GetPaymentById(id int) (*types.Payment, error) {
+	payment := new(types.Payment)
+	err := s.db.QueryRow("SELECT * FROM payments WHERE id = ?", id).Scan(paymentAllFieldsScanner(payment))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no payment was found for id %v", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func (s *Store) GetPaymentByProviderRef(provider, providerRef string) (*types.Payment, error) {
+	payment := new(types.Payment)
+	err := s.db.QueryRow("SELECT * FROM payments WHERE provider = ? AND providerRef = ?", provider, providerRef).
+		Scan(paymentAllFieldsScanner(payment))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no %s payment was found for %s", provider, providerRef)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func (s *Store) GetOrderPayments(orderID int) ([]types.Payment, error) {
+	rows, err := s.db.Query("SELECT * FROM payments WHERE orderId = ? ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	payments := make([]types.Payment, 0)
+	for rows.Next() {
+		payment := new(types.Payment)
+		if err := rows.Scan(paymentAllFieldsScanner(payment)); err != nil {
+			return nil, err
+		}
+
+		payments = append(payments, *payment)
+	}
+
+	return payments, rows.Err()
+}
+
+// UpdatePayment saves the state of the payment, its order, provider and amount never change.
+func (s *Store) UpdatePayment(payment types.Payment) error {
+	result, err := s.db.Exec(`
+	UPDATE payments SET status = ?, capturedAmount = ?, refundedAmount = ?, nextAction = ?, failureReason = ? WHERE id = ?`,
+		payment.Status, payment.CapturedAmount, payment.RefundedAmount, payment.NextAction, payment.FailureReason, payment.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		// MySQL reports no affected rows when nothing changed, only a missing payment is an error.
+		if _, err := s.GetPaymentById(payment.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func paymentAllFieldsScanner(payment *types.Payment) (*int, *int, *string, *string, *string, *money.Money, *money.Money, *money.Money,
+	*string, *string, *time.Time, *time.Time) {
+	return &payment.ID,
+		&payment.OrderID,
+		&payment.Provider,
+		&payment.ProviderRef,
+		&payment.Status,
+		&payment.Amount,
+		&payment.CapturedAmount,
+		&payment.RefundedAmount,
+		&payment.NextAction,
+		&payment.FailureReason,
+		&payment.CreatedAt,
+		&payment.UpdatedAt
+}
@@ -0,0 +1,115 @@
+package payment
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// CreateWebhookEvent stores the event unless the provider already sent it, in which case
+// the stored one is returned and created is false.
+func (s *Store) CreateWebhookEvent(event types.WebhookEvent) (*types.WebhookEvent, bool, error) {
+	result, err := s.db.Exec(`
+	INSERT IGNORE INTO webhookEvents (provider, eventId, type, payload, status) VALUES (?,?,?,?,?)`,
+		event.Provider, event.EventID, event.Type, []byte(event.Payload), types.WebhookEventStatusPending)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	stored := new(types.WebhookEvent)
+	err = s.db.QueryRow("SELECT * FROM webhookEvents WHERE provider = ? AND eventId = ?", event.Provider, event.EventID).
+		Scan(webhookEventAllFieldsScanner(stored))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return stored, rowsAffected > 0, nil
+}
+
+func (s *Store) GetWebhookEventById(id int) (*types.WebhookEvent, error) {
+	event := new(types.WebhookEvent)
+	err := s.db.QueryRow("SELECT * FROM webhookEvents WHERE id = ?", id).Scan(webhookEventAllFieldsScanner(event))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no webhook event was found for id %v", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// returns the events with the given status, or every event when it's empty, the latest first.
+func (s *Store) GetWebhookEvents(status string, limit, offset int) ([]types.WebhookEvent, int, error) {
+	rows, err := s.db.Query("SELECT * FROM webhookEvents WHERE ? = '' OR status = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		status, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	events := make([]types.WebhookEvent, 0)
+	for rows.Next() {
+		event := new(types.WebhookEvent)
+		if err := rows.Scan(webhookEventAllFieldsScanner(event)); err != nil {
+			return nil, 0, err
+		}
+
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM webhookEvents WHERE ? = '' OR status = ?", status, status).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return events, count, nil
+}
+
+func (s *Store) ClaimWebhookEvent(id int) (bool, error) {
+	result, err := s.db.Exec("UPDATE webhookEvents SET status = ? WHERE id = ? AND status IN (?, ?)",
+		types.WebhookEventStatusProcessing, id, types.WebhookEventStatusPending, types.WebhookEventStatusFailed)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (s *Store) UpdateWebhookEvent(event types.WebhookEvent) error {
+	_, err := s.db.Exec("UPDATE webhookEvents SET status = ?, attempts = ?, lastError = ?, processedAt = ? WHERE id = ?",
+		event.Status, event.Attempts, event.LastError, event.ProcessedAt, event.ID)
+
+	return err
+}
+
+func webhookEventAllFieldsScanner(event *types.WebhookEvent) (*int, *string, *string, *string, *[]byte, *string, *int, *string,
+	*time.Time, **time.Time) {
+	return &event.ID,
+		&event.Provider,
+		&event.EventID,
+		&event.Type,
+		(*[]byte)(&event.Payload),
+		&event.Status,
+		&event.Attempts,
+		&event.LastError,
+		&event.ReceivedAt,
+		&event.ProcessedAt
+}
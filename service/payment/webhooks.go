@@ -0,0 +1,200 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// SignatureHeader carries t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">. While a secret
+// is rotated the provider may send one v1 per secret, a single match is enough.
+const SignatureHeader = "Payment-Signature"
+
+// SignWebhook returns the signature header of a webhook body, it's what a provider sends and what
+// the tests and local tools use to call the webhook.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), webhookMAC(secret, timestamp.Unix(), body))
+}
+
+// VerifyWebhookSignature checks the body was signed with secret less than tolerance ago,
+// the timestamp being part of the signature an old delivery can't be replayed with a new one.
+func VerifyWebhookSignature(header string, body []byte, secret string, tolerance time.Duration, now time.Time) error {
+	if secret == "" {
+		return fmt.Errorf("%w: no webhook secret is configured", types.ErrWebhookSignature)
+	}
+
+	var timestamp int64
+	signatures := make([]string, 0, 1)
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			var err error
+			timestamp, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: malformed timestamp", types.ErrWebhookSignature)
+			}
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return fmt.Errorf("%w: the %s header must have a timestamp and a signature", types.ErrWebhookSignature, SignatureHeader)
+	}
+
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: the timestamp is outside the tolerance", types.ErrWebhookSignature)
+	}
+
+	expected := webhookMAC(secret, timestamp, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: no signature matches", types.ErrWebhookSignature)
+}
+
+func webhookMAC(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HandleEvent moves the payment and its order along with an event of the provider. Events can
+// come late, twice or out of order, those that don't move the payment forward are ignored and
+// applied is false.
+func (p *Processor) HandleEvent(event types.PaymentEvent) (bool, error) {
+	payment, err := p.store.GetPaymentByProviderRef(p.provider.Name(), event.IntentID)
+	if err != nil {
+		return false, err
+	}
+
+	order, err := p.orderStore.GetOrderById(payment.OrderID)
+	if err != nil {
+		return false, err
+	}
+
+	waiting := payment.Status == types.PaymentStatusPending || payment.Status == types.PaymentStatusRequiresAction
+
+	switch event.Type {
+	case types.PaymentEventAuthorized:
+		if waiting {
+			payment.Status = types.PaymentStatusAuthorized
+			payment.NextAction = ""
+			if err := p.store.UpdatePayment(*payment); err != nil {
+				return false, err
+			}
+
+			return true, ignoreExpired(p.commitAndCapture(order, payment))
+		}
+		// a capture that failed earlier is retried.
+		if payment.Status == types.PaymentStatusAuthorized && order.Status == types.OrderStatusPending {
+			return true, p.capture(order, payment)
+		}
+	case types.PaymentEventCaptured:
+		amount := payment.Amount
+		if event.Amount != nil {
+			amount = *event.Amount
+		}
+
+		switch payment.Status {
+		case types.PaymentStatusPending, types.PaymentStatusRequiresAction:
+			if err := p.inventoryStore.CommitReservations(order.ID); err != nil {
+				return true, p.refundUncollectable(order, payment, amount, err)
+			}
+			return true, p.captured(order, payment, amount)
+		case types.PaymentStatusAuthorized:
+			return true, p.captured(order, payment, amount)
+		case types.PaymentStatusVoided, types.PaymentStatusFailed:
+			return true, p.refundUncollectable(order, payment, amount, fmt.Errorf("the payment was already %s", payment.Status))
+		}
+	case types.PaymentEventFailed, types.PaymentEventVoided:
+		if waiting {
+			reason := event.Reason
+			if reason == "" {
+				reason = strings.TrimPrefix(event.Type, "payment.") + " by the provider"
+			}
+
+			err := p.fail(order, payment, fmt.Errorf("%w: %s", types.ErrPaymentDeclined, reason))
+			return true, ignoreDeclined(err)
+		}
+	case types.PaymentEventRefunded:
+		refunded := payment.Status == types.PaymentStatusCaptured || payment.Status == types.PaymentStatusPartiallyRefunded
+		if refunded && event.Amount != nil && event.Amount.Cmp(payment.RefundedAmount) > 0 {
+			payment.RefundedAmount = money.Min(*event.Amount, payment.CapturedAmount)
+			payment.Status = types.PaymentStatusPartiallyRefunded
+			if payment.RefundedAmount.Cmp(payment.CapturedAmount) == 0 {
+				payment.Status = types.PaymentStatusRefunded
+			}
+
+			return true, p.store.UpdatePayment(*payment)
+		}
+	}
+
+	return false, nil
+}
+
+func (p *Processor) captured(order *types.Order, payment *types.Payment, amount money.Money) error {
+	payment.Status = types.PaymentStatusCaptured
+	payment.CapturedAmount = amount
+	payment.NextAction = ""
+	payment.FailureReason = ""
+	if err := p.store.UpdatePayment(*payment); err != nil {
+		return err
+	}
+
+	return p.completeOrder(order)
+}
+
+// money collected for an order that can't be fulfilled anymore is given back.
+func (p *Processor) refundUncollectable(order *types.Order, payment *types.Payment, amount money.Money, cause error) error {
+	intent, err := p.provider.Refund(payment.ProviderRef, amount)
+	if err != nil {
+		return err
+	}
+
+	payment.Status = intent.Status
+	payment.CapturedAmount = intent.Captured
+	payment.RefundedAmount = intent.Refunded
+	payment.NextAction = ""
+	payment.FailureReason = cause.Error()
+	if err := p.store.UpdatePayment(*payment); err != nil {
+		return err
+	}
+
+	if order.Status != types.OrderStatusPending {
+		return nil
+	}
+
+	return ignoreExpired(p.cancelOrder(order, cause))
+}
+
+// the order was cancelled as it should, it's not a failure of the event.
+func ignoreExpired(err error) error {
+	if errors.Is(err, types.ErrReservationExpired) {
+		return nil
+	}
+
+	return err
+}
+
+func ignoreDeclined(err error) error {
+	if errors.Is(err, types.ErrPaymentDeclined) {
+		return nil
+	}
+
+	return err
+}
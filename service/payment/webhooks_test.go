@@ -0,0 +1,363 @@
+package payment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1730451600, 0)
+	body := []byte(`{"id":"evt_1","type":"payment.captured","intentId":"fake_pi_1"}`)
+
+	cases := []struct {
+		name   string
+		header string
+		secret string
+		valid  bool
+	}{
+		{
+			name:   "Should accept a body signed with the secret",
+			header: SignWebhook("whsec", now, body),
+			secret: "whsec",
+			valid:  true,
+		},
+		{
+			name:   "Should accept a signature within the tolerance",
+			header: SignWebhook("whsec", now.Add(-4*time.Minute), body),
+			secret: "whsec",
+			valid:  true,
+		},
+		{
+			name:   "Should accept any of the signatures while the secret is rotated",
+			header: SignWebhook("whsec", now, body) + ",v1=" + webhookMAC("new", now.Unix(), body),
+			secret: "new",
+			valid:  true,
+		},
+		{
+			name:   "Should refuse a body signed with another secret",
+			header: SignWebhook("other", now, body),
+			secret: "whsec",
+		},
+		{
+			name:   "Should refuse a signature older than the tolerance",
+			header: SignWebhook("whsec", now.Add(-6*time.Minute), body),
+			secret: "whsec",
+		},
+		{
+			name:   "Should refuse a signature from the future",
+			header: SignWebhook("whsec", now.Add(6*time.Minute), body),
+			secret: "whsec",
+		},
+		{
+			name:   "Should refuse an old signature given a new timestamp",
+			header: fmt.Sprintf("t=%d,v1=%s", now.Unix(), webhookMAC("whsec", now.Add(-time.Hour).Unix(), body)),
+			secret: "whsec",
+		},
+		{
+			name:   "Should refuse a header without a signature",
+			header: fmt.Sprintf("t=%d", now.Unix()),
+			secret: "whsec",
+		},
+		{
+			name:   "Should refuse everything without a secret",
+			header: SignWebhook("", now, body),
+			secret: "",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(c.header, body, c.secret, 5*time.Minute, now)
+			if c.valid && err != nil {
+				t.Errorf("expected the signature to be valid got %v", err)
+			}
+			if !c.valid && !errors.Is(err, types.ErrWebhookSignature) {
+				t.Errorf("expected ErrWebhookSignature got %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessorHandleEvent(t *testing.T) {
+	amount := money.FromMinor(2500)
+
+	cases := []struct {
+		name          string
+		method        string
+		passChallenge bool
+		event         types.PaymentEvent
+		applied       bool
+		orderStatus   string
+		paymentStatus string
+	}{
+		{
+			name:          "Should capture a challenged payment the provider authorized",
+			method:        FakeCardChallenge,
+			passChallenge: true,
+			event:         types.PaymentEvent{Type: types.PaymentEventAuthorized},
+			applied:       true,
+			orderStatus:   types.OrderStatusCompleted,
+			paymentStatus: types.PaymentStatusCaptured,
+		},
+		{
+			name:          "Should complete the order of a payment the provider captured",
+			method:        FakeCardChallenge,
+			event:         types.PaymentEvent{Type: types.PaymentEventCaptured, Amount: &amount},
+			applied:       true,
+			orderStatus:   types.OrderStatusCompleted,
+			paymentStatus: types.PaymentStatusCaptured,
+		},
+		{
+			name:          "Should cancel the order of a payment that failed",
+			method:        FakeCardChallenge,
+			event:         types.PaymentEvent{Type: types.PaymentEventFailed, Reason: "challenge abandoned"},
+			applied:       true,
+			orderStatus:   types.OrderStatusCancelled,
+			paymentStatus: types.PaymentStatusFailed,
+		},
+		{
+			name:          "Should ignore a failure reported after the capture",
+			method:        FakeCardApproved,
+			event:         types.PaymentEvent{Type: types.PaymentEventFailed},
+			orderStatus:   types.OrderStatusCompleted,
+			paymentStatus: types.PaymentStatusCaptured,
+		},
+		{
+			name:          "Should ignore an authorization reported after the capture",
+			method:        FakeCardApproved,
+			event:         types.PaymentEvent{Type: types.PaymentEventAuthorized},
+			orderStatus:   types.OrderStatusCompleted,
+			paymentStatus: types.PaymentStatusCaptured,
+		},
+		{
+			name:          "Should record a refund made at the provider",
+			method:        FakeCardApproved,
+			event:         types.PaymentEvent{Type: types.PaymentEventRefunded, Amount: &amount},
+			applied:       true,
+			orderStatus:   types.OrderStatusCompleted,
+			paymentStatus: types.PaymentStatusRefunded,
+		},
+		{
+			name:          "Should ignore the events it doesn't know",
+			method:        FakeCardApproved,
+			event:         types.PaymentEvent{Type: "payment.disputed"},
+			orderStatus:   types.OrderStatusCompleted,
+			paymentStatus: types.PaymentStatusCaptured,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gateway, store, orderStore, inventoryStore := newTestProcessorDeps()
+			processor := NewProcessor(store, gateway, orderStore, inventoryStore)
+
+			payment, _ := processor.Pay(&types.Order{ID: 1, Total: amount, Status: types.OrderStatusPending}, c.method)
+			if c.passChallenge {
+				gateway.Authorize(payment.ProviderRef, types.PaymentAuthorization{ChallengeResponse: FakeChallengeCode})
+			}
+
+			c.event.ID = "evt_1"
+			c.event.IntentID = payment.ProviderRef
+			applied, err := processor.HandleEvent(c.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if applied != c.applied {
+				t.Errorf("expected applied to be %v got %v", c.applied, applied)
+			}
+			if orderStore.statuses[1] != c.orderStatus {
+				t.Errorf("expected the order to be %s got %s", c.orderStatus, orderStore.statuses[1])
+			}
+			if stored := store.payments[payment.ID]; stored.Status != c.paymentStatus {
+				t.Errorf("expected the payment to be %s got %s", c.paymentStatus, stored.Status)
+			}
+		})
+	}
+
+	t.Run("Should fail on an intent it doesn't know", func(t *testing.T) {
+		gateway, store, orderStore, inventoryStore := newTestProcessorDeps()
+		processor := NewProcessor(store, gateway, orderStore, inventoryStore)
+
+		if _, err := processor.HandleEvent(types.PaymentEvent{ID: "evt_1", Type: types.PaymentEventCaptured, IntentID: "fake_pi_9"}); err == nil {
+			t.Error("expected the event to fail")
+		}
+	})
+}
+
+func TestReceiveWebhook(t *testing.T) {
+	gateway, store, orderStore, inventoryStore := newTestProcessorDeps()
+	processor := NewProcessor(store, gateway, orderStore, inventoryStore)
+	eventStore := &mockWebhookEventStore{events: map[string]*types.WebhookEvent{}}
+	handler := &Handler{
+		store:            store,
+		eventStore:       eventStore,
+		orderStore:       orderStore,
+		processor:        processor,
+		webhookSecret:    "whsec",
+		webhookTolerance: 5 * time.Minute,
+	}
+
+	payment, _ := processor.Pay(&types.Order{ID: 1, Total: money.FromMinor(2500), Status: types.OrderStatusPending}, FakeCardChallenge)
+	body := []byte(fmt.Sprintf(`{"id":"evt_1","type":"payment.captured","intentId":"%s","amount":25}`, payment.ProviderRef))
+
+	send := func(provider, signature string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/webhooks/payments/"+provider, bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(SignatureHeader, signature)
+
+		recorder := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.HandleFunc("/webhooks/payments/{provider}", handler.ReceiveWebhook)
+		router.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	t.Run("Should refuse an event that isn't signed", func(t *testing.T) {
+		recorder := send(FakeDriver, SignWebhook("other", time.Now(), body))
+		if recorder.Code != http.StatusUnauthorized || len(eventStore.events) != 0 {
+			t.Errorf("expected status code %d and no event got %d", http.StatusUnauthorized, recorder.Code)
+		}
+	})
+
+	t.Run("Should refuse an unknown provider", func(t *testing.T) {
+		recorder := send("stripe", SignWebhook("whsec", time.Now(), body))
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d got %d", http.StatusNotFound, recorder.Code)
+		}
+	})
+
+	t.Run("Should apply a signed event", func(t *testing.T) {
+		recorder := send(FakeDriver, SignWebhook("whsec", time.Now(), body))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+
+		event := eventStore.events["evt_1"]
+		if event.Status != types.WebhookEventStatusProcessed || event.Attempts != 1 {
+			t.Errorf("expected the event to be processed once got %s after %d attempts", event.Status, event.Attempts)
+		}
+		if orderStore.statuses[1] != types.OrderStatusCompleted {
+			t.Errorf("expected the order to be completed got %s", orderStore.statuses[1])
+		}
+	})
+
+	t.Run("Should ignore a replay of the event", func(t *testing.T) {
+		orderStore.statuses[1] = types.OrderStatusPending
+		recorder := send(FakeDriver, SignWebhook("whsec", time.Now(), body))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d", http.StatusOK, recorder.Code)
+		}
+
+		if eventStore.events["evt_1"].Attempts != 1 || orderStore.statuses[1] != types.OrderStatusPending {
+			t.Error("expected the replay not to be applied")
+		}
+	})
+}
+
+func TestRetryWebhookEvent(t *testing.T) {
+	gateway, store, orderStore, inventoryStore := newTestProcessorDeps()
+	processor := NewProcessor(store, gateway, orderStore, inventoryStore)
+	payment, _ := processor.Pay(&types.Order{ID: 1, Total: money.FromMinor(2500), Status: types.OrderStatusPending}, FakeCardChallenge)
+	payload := []byte(fmt.Sprintf(`{"id":"evt_1","type":"payment.captured","intentId":"%s","amount":25}`, payment.ProviderRef))
+
+	retry := func(status string) (*httptest.ResponseRecorder, *types.WebhookEvent) {
+		event := &types.WebhookEvent{ID: 1, EventID: "evt_1", Type: types.PaymentEventCaptured, Payload: payload, Status: status}
+		handler := &Handler{
+			store:      store,
+			eventStore: &mockWebhookEventStore{events: map[string]*types.WebhookEvent{"evt_1": event}},
+			orderStore: orderStore,
+			processor:  processor,
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/admin/webhooks/payments/events/1/retry", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.HandleFunc("/admin/webhooks/payments/events/{id}/retry", handler.RetryWebhookEvent)
+		router.ServeHTTP(recorder, req)
+
+		return recorder, event
+	}
+
+	t.Run("Should refuse an event that is being processed", func(t *testing.T) {
+		recorder, event := retry(types.WebhookEventStatusProcessing)
+		if recorder.Code != http.StatusConflict || event.Attempts != 0 {
+			t.Errorf("expected status code %d and no attempt got %d after %d attempts", http.StatusConflict, recorder.Code, event.Attempts)
+		}
+	})
+
+	t.Run("Should refuse an event that was processed", func(t *testing.T) {
+		recorder, event := retry(types.WebhookEventStatusProcessed)
+		if recorder.Code != http.StatusBadRequest || event.Attempts != 0 {
+			t.Errorf("expected status code %d and no attempt got %d after %d attempts", http.StatusBadRequest, recorder.Code, event.Attempts)
+		}
+	})
+
+	t.Run("Should apply a failed event again", func(t *testing.T) {
+		recorder, event := retry(types.WebhookEventStatusFailed)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+		if event.Status != types.WebhookEventStatusProcessed || event.Attempts != 1 {
+			t.Errorf("expected the event to be processed once got %s after %d attempts", event.Status, event.Attempts)
+		}
+	})
+}
+
+type mockWebhookEventStore struct {
+	types.WebhookEventStore
+	events map[string]*types.WebhookEvent
+}
+
+func (m *mockWebhookEventStore) CreateWebhookEvent(event types.WebhookEvent) (*types.WebhookEvent, bool, error) {
+	if stored, ok := m.events[event.EventID]; ok {
+		return stored, false, nil
+	}
+
+	event.ID = len(m.events) + 1
+	event.Status = types.WebhookEventStatusPending
+	m.events[event.EventID] = &event
+	return &event, true, nil
+}
+
+func (m *mockWebhookEventStore) GetWebhookEventById(id int) (*types.WebhookEvent, error) {
+	for _, event := range m.events {
+		if event.ID == id {
+			return event, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no webhook event was found for id %v", id)
+}
+
+func (m *mockWebhookEventStore) ClaimWebhookEvent(id int) (bool, error) {
+	for _, event := range m.events {
+		if event.ID == id && (event.Status == types.WebhookEventStatusPending || event.Status == types.WebhookEventStatusFailed) {
+			event.Status = types.WebhookEventStatusProcessing
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *mockWebhookEventStore) UpdateWebhookEvent(event types.WebhookEvent) error {
+	*m.events[event.EventID] = event
+	return nil
+}
@@ -0,0 +1,363 @@
+package product
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+const (
+	csvFormat            = "csv"
+	ndjsonFormat         = "ndjson"
+	maxImportSizeInBytes = 50 << 20
+	exportFlushEvery     = 200
+)
+
+// the columns of the csv files, imports ignore any extra column (e.g. the exported id).
+var productCSVColumns = []string{"sku", "name", "description", "image", "price", "quantity", "taxClass", "weightGrams", "lengthMm",
+	"widthMm", "heightMm", "categoryId"}
+
+// the columns an import can't do without, the tax class, the dimensions and the category fall back to the defaults.
+var requiredProductCSVColumns = productCSVColumns[:6]
+
+type productExportRow struct {
+	ID int `json:"id"`
+	types.ProductImportRow
+}
+
+func (h *Handler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	format, err := bulkFormat(r, r.Header.Get("Content-Type"))
+	if err != nil {
+		utils.WriteError(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSizeInBytes)
+
+	result := types.ProductImportResult{
+		DryRun: dryRun,
+		Errors: make([]types.ProductImportError, 0),
+	}
+	firstSeenRows := make(map[string]int)
+
+	err = readImportRows(format, r.Body, func(row int, payload types.ProductImportRow, parseErr error) {
+		result.Total++
+
+		created, err := h.importRow(row, payload, parseErr, firstSeenRows, dryRun)
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, types.ProductImportError{
+				Row:   row,
+				SKU:   payload.SKU,
+				Error: utils.ErrorMessage(err),
+			})
+			return
+		}
+
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    result,
+	})
+}
+
+// validates the row and upserts it unless it's a dry run, reports whether the product is (or would be) created.
+func (h *Handler) importRow(row int, payload types.ProductImportRow, parseErr error, firstSeenRows map[string]int, dryRun bool) (bool, error) {
+	if parseErr != nil {
+		return false, parseErr
+	}
+
+	payload.SKU = strings.TrimSpace(payload.SKU)
+	if payload.SKU == "" {
+		return false, fmt.Errorf("sku is required for imports")
+	}
+
+	if firstRow, ok := firstSeenRows[payload.SKU]; ok {
+		return false, fmt.Errorf("duplicate sku, it was already used on row %d", firstRow)
+	}
+	firstSeenRows[payload.SKU] = row
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return false, err
+	}
+
+	if dryRun {
+		_, err := h.store.GetProductBySKU(payload.SKU)
+		if errors.Is(err, types.ErrProductNotFound) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	_, created, err := h.store.UpsertProductBySKU(types.ProductCreatePayload(payload))
+	return created, err
+}
+
+// ExportProducts streams the catalog, rows are written as they are read from the database.
+func (h *Handler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	format, err := bulkFormat(r, "")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+	flusher, _ := w.(http.Flusher)
+	written := 0
+
+	switch format {
+	case csvFormat:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write(append([]string{"id"}, productCSVColumns...))
+
+		err = h.store.StreamProducts(func(product types.Product) error {
+			row := exportRow(product)
+			err := csvWriter.Write([]string{
+				strconv.Itoa(row.ID),
+				row.SKU,
+				row.Name,
+				row.Description,
+				row.Image,
+				row.Price.String(),
+				strconv.Itoa(row.Quantity),
+				row.TaxClass,
+				strconv.Itoa(row.WeightGrams),
+				strconv.Itoa(row.LengthMm),
+				strconv.Itoa(row.WidthMm),
+				strconv.Itoa(row.HeightMm),
+				strconv.Itoa(row.CategoryID),
+			})
+
+			written++
+			if written%exportFlushEvery == 0 {
+				csvWriter.Flush()
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			return err
+		})
+		csvWriter.Flush()
+
+	case ndjsonFormat:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+
+		err = h.store.StreamProducts(func(product types.Product) error {
+			written++
+			if flusher != nil && written%exportFlushEvery == 0 {
+				flusher.Flush()
+			}
+			return encoder.Encode(exportRow(product))
+		})
+	}
+
+	// the status and part of the body are already sent, so the export can only be cut short.
+	if err != nil {
+		log.Printf("products export was cut short after %d rows: %v\n", written, err)
+	}
+}
+
+func exportRow(product types.Product) productExportRow {
+	row := productExportRow{
+		ID: product.ID,
+		ProductImportRow: types.ProductImportRow{
+			Name:        product.Name,
+			Description: product.Description,
+			Image:       product.Image,
+			Price:       product.Price,
+			Quantity:    product.Quantity,
+			TaxClass:    product.TaxClass,
+			WeightGrams: product.WeightGrams,
+			LengthMm:    product.LengthMm,
+			WidthMm:     product.WidthMm,
+			HeightMm:    product.HeightMm,
+		},
+	}
+	if product.SKU != nil {
+		row.SKU = *product.SKU
+	}
+	if product.CategoryID != nil {
+		row.CategoryID = *product.CategoryID
+	}
+
+	return row
+}
+
+// the format comes from the "format" query param, otherwise from the given Content-Type, csv is the default.
+func bulkFormat(r *http.Request, contentType string) (string, error) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" && contentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		switch mediaType {
+		case "text/csv":
+			format = csvFormat
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			format = ndjsonFormat
+		default:
+			return "", fmt.Errorf("unsupported content type '%s', use text/csv or application/x-ndjson", mediaType)
+		}
+	}
+
+	switch format {
+	case "", csvFormat:
+		return csvFormat, nil
+	case ndjsonFormat, "jsonl":
+		return ndjsonFormat, nil
+	default:
+		return "", fmt.Errorf("unsupported format '%s', use csv or ndjson", format)
+	}
+}
+
+// reads the rows one by one and calls fn for each of them, a row that can't be parsed is passed
+// with its error so it ends up in the report. The returned error means the whole body is unreadable.
+func readImportRows(format string, body io.Reader, fn func(row int, payload types.ProductImportRow, parseErr error)) error {
+	if format == ndjsonFormat {
+		return readNDJSONRows(body, fn)
+	}
+
+	return readCSVRows(body, fn)
+}
+
+func readCSVRows(body io.Reader, fn func(row int, payload types.ProductImportRow, parseErr error)) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return fmt.Errorf("csv file is empty")
+	}
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredProductCSVColumns {
+		if _, ok := columns[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("csv header is missing the '%s' column", name)
+		}
+	}
+
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			fn(row, types.ProductImportRow{}, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		payload, err := csvRecordToPayload(record, columns)
+		fn(row, payload, err)
+	}
+}
+
+func csvRecordToPayload(record []string, columns map[string]int) (types.ProductImportRow, error) {
+	field := func(name string) string {
+		if i, ok := columns[strings.ToLower(name)]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	payload := types.ProductImportRow{
+		SKU:         field("sku"),
+		Name:        field("name"),
+		Description: field("description"),
+		Image:       field("image"),
+		TaxClass:    field("taxClass"),
+	}
+
+	price, err := money.Parse(field("price"), money.DefaultCurrency)
+	if err != nil {
+		return payload, fmt.Errorf("price must be a number with at most %d decimals", money.Exponent(money.DefaultCurrency))
+	}
+	payload.Price = price
+
+	quantity, err := strconv.Atoi(field("quantity"))
+	if err != nil {
+		return payload, fmt.Errorf("quantity must be an integer")
+	}
+	payload.Quantity = quantity
+
+	// the dimensions are left at 0 when their cells are empty.
+	for name, value := range map[string]*int{
+		"weightGrams": &payload.WeightGrams,
+		"lengthMm":    &payload.LengthMm,
+		"widthMm":     &payload.WidthMm,
+		"heightMm":    &payload.HeightMm,
+		"categoryId":  &payload.CategoryID,
+	} {
+		if field(name) == "" {
+			continue
+		}
+		if *value, err = strconv.Atoi(field(name)); err != nil {
+			return payload, fmt.Errorf("%s must be an integer", name)
+		}
+	}
+
+	return payload, nil
+}
+
+func readNDJSONRows(body io.Reader, fn func(row int, payload types.ProductImportRow, parseErr error)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	row := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		row++
+
+		var payload types.ProductImportRow
+		if err := json.Unmarshal([]byte(line), &payload); err != nil {
+			var unmarshalTypeErr *json.UnmarshalTypeError
+			if errors.As(err, &unmarshalTypeErr) {
+				err = fmt.Errorf("%s", utils.UnmarshalErrMsgHandler(unmarshalTypeErr))
+			}
+			fn(row, payload, err)
+			continue
+		}
+
+		fn(row, payload, nil)
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,160 @@
+package product
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestProductsImport(t *testing.T) {
+	existingSKU := "KB-001"
+	csvBody := strings.Join([]string{
+		"sku,name,description,image,price,quantity",
+		"KB-001,keyboard,mechanical keyboard,kb.png,49.99,3",
+		"MS-001,mouse,wireless mouse,ms.png,19.50,8",
+		"MS-001,mouse,duplicated row,ms.png,19.50,8",
+		",no sku,missing sku,x.png,1,1",
+		"HD-001,headset,gaming headset,hd.png,not-a-price,1",
+		"CB-001,ca,too short name,cb.png,5,1",
+	}, "\n")
+
+	newRouter := func(store *mockProductStore) *mux.Router {
+		handler := NewHandler(store, nil, nil)
+		router := mux.NewRouter()
+		router.HandleFunc("/products/import", handler.ImportProducts)
+		router.HandleFunc("/products/export", handler.ExportProducts)
+		return router
+	}
+
+	t.Run("Should report per row errors without writing on dry runs", func(t *testing.T) {
+		store := &mockProductStore{product: types.Product{ID: 1, SKU: &existingSKU}}
+		recorder := serve(t, newRouter(store), http.MethodPost, "/products/import?dryRun=true", []byte(csvBody), map[string]string{"Content-Type": "text/csv"})
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+
+		var res struct {
+			Data types.ProductImportResult `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+
+		result := res.Data
+		if result.Total != 6 || result.Created != 1 || result.Updated != 1 || result.Failed != 4 {
+			t.Errorf("unexpected report %+v", result)
+		}
+
+		failedRows := []int{}
+		for _, rowErr := range result.Errors {
+			failedRows = append(failedRows, rowErr.Row)
+		}
+		if len(failedRows) != 4 || failedRows[0] != 3 || failedRows[3] != 6 {
+			t.Errorf("expected rows 3 to 6 to fail got %v", failedRows)
+		}
+
+		if len(store.upserted) != 0 {
+			t.Errorf("expected nothing to be written on dry run, %d rows were upserted", len(store.upserted))
+		}
+	})
+
+	t.Run("Should upsert the valid NDJSON rows", func(t *testing.T) {
+		store := &mockProductStore{product: types.Product{ID: 1, SKU: &existingSKU}}
+		body := `{"sku":"KB-001","name":"keyboard","description":"mechanical","image":"kb.png","price":49.99,"quantity":3}
+
+{"sku":"MS-001","name":"mouse","description":"wireless","image":"ms.png","price":"19.50","quantity":8}
+`
+		recorder := serve(t, newRouter(store), http.MethodPost, "/products/import?format=ndjson", []byte(body), nil)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+		if len(store.upserted) != 1 || store.upserted[0].SKU != "KB-001" {
+			t.Errorf("expected only KB-001 to be upserted got %+v", store.upserted)
+		}
+	})
+
+	t.Run("Should import a product that is out of stock", func(t *testing.T) {
+		store := &mockProductStore{}
+		body := "sku,name,description,image,price,quantity\nKB-002,keyboard,mechanical keyboard,kb.png,49.99,0"
+		recorder := serve(t, newRouter(store), http.MethodPost, "/products/import", []byte(body), map[string]string{"Content-Type": "text/csv"})
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+		if len(store.upserted) != 1 || store.upserted[0].Quantity != 0 {
+			t.Errorf("expected the product to be upserted with a quantity of 0 got %+v: %s", store.upserted, recorder.Body.String())
+		}
+	})
+
+	t.Run("Should reject csv files without the required columns", func(t *testing.T) {
+		store := &mockProductStore{}
+		recorder := serve(t, newRouter(store), http.MethodPost, "/products/import", []byte("sku,name\nA,b"), map[string]string{"Content-Type": "text/csv"})
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Should export rows that can be imported back", func(t *testing.T) {
+		store := &mockProductStore{product: types.Product{ID: 1, SKU: &existingSKU, Name: "keyboard", Price: money.FromMinor(4990), Quantity: 3}}
+		recorder := serve(t, newRouter(store), http.MethodGet, "/products/export?format=ndjson", nil, nil)
+
+		if contentType := recorder.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+			t.Errorf("unexpected content type %s", contentType)
+		}
+
+		lines := 0
+		scanner := bufio.NewScanner(recorder.Body)
+		for scanner.Scan() {
+			var row productExportRow
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				t.Fatal(err)
+			}
+			if row.SKU != existingSKU || row.Price != money.FromMinor(4990) {
+				t.Errorf("unexpected row %+v", row)
+			}
+			lines++
+		}
+		if lines != 2 {
+			t.Errorf("expected 2 rows got %d", lines)
+		}
+	})
+
+	t.Run("Should keep the tax class and the dimensions through a csv export and import", func(t *testing.T) {
+		store := &mockProductStore{product: types.Product{ID: 1, SKU: &existingSKU, Name: "keyboard", Description: "mechanical keyboard",
+			Image: "kb.png", Price: money.FromMinor(4990), Quantity: 3, TaxClass: "reduced", WeightGrams: 900, LengthMm: 450, WidthMm: 140,
+			HeightMm: 40}}
+		exported := serve(t, newRouter(store), http.MethodGet, "/products/export?format=csv", nil, nil)
+
+		recorder := serve(t, newRouter(store), http.MethodPost, "/products/import", exported.Body.Bytes(), map[string]string{"Content-Type": "text/csv"})
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+
+		if len(store.upserted) != 1 {
+			t.Fatalf("expected the exported product to be upserted got %+v", store.upserted)
+		}
+		row := store.upserted[0]
+		if row.TaxClass != "reduced" || row.WeightGrams != 900 || row.LengthMm != 450 || row.WidthMm != 140 || row.HeightMm != 40 {
+			t.Errorf("expected the tax class and the dimensions to be kept got %+v", row)
+		}
+	})
+
+	t.Run("Should refuse dimensions that aren't integers", func(t *testing.T) {
+		store := &mockProductStore{}
+		body := "sku,name,description,image,price,quantity,weightGrams\nKB-002,keyboard,mechanical,kb.png,49.99,3,heavy"
+		recorder := serve(t, newRouter(store), http.MethodPost, "/products/import", []byte(body), map[string]string{"Content-Type": "text/csv"})
+
+		if recorder.Code != http.StatusOK || len(store.upserted) != 0 || !strings.Contains(recorder.Body.String(), "weightGrams must be an integer") {
+			t.Errorf("expected the row to fail got %d: %s", recorder.Code, recorder.Body.String())
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package product
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+var errMissingIfMatch = fmt.Errorf("If-Match header is required, use the ETag returned when fetching the product")
+
+// the version changes on every update of the product or of its images, the available quantity moves with
+// the reservations without changing it, so the strong ETag is made of both.
+func productETag(product types.Product) string {
+	return fmt.Sprintf(`"%d-%d"`, product.Version, product.Available)
+}
+
+func setProductETag(w http.ResponseWriter, product types.Product) {
+	w.Header().Set("ETag", productETag(product))
+}
+
+// reports whether any of the comma separated entity tags in the header matches the etag.
+// weak tags (W/"...") only match when weak is true, as If-None-Match uses the weak comparison.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// returns the version the client is allowed to update, the store checks it again
+// so a concurrent update between the read and the write is still detected.
+func checkIfMatch(r *http.Request, current types.Product) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return 0, errMissingIfMatch
+	}
+
+	if !etagMatches(header, productETag(current), false) {
+		return 0, types.ErrProductVersionMismatch
+	}
+
+	return current.Version, nil
+}
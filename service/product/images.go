@@ -0,0 +1,153 @@
+package product
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // registers the gif decoder for image.Decode
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+const (
+	thumbnailMaxSize = 320
+	// a decoded image takes 4 bytes per pixel, so a small file claiming to be huge is refused before
+	// it's decoded.
+	maxImagePixels = 40_000_000
+)
+
+// maps the accepted image mime types to the extension used for the stored files.
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+type processedImage struct {
+	original      []byte
+	originalType  string
+	thumbnail     []byte
+	thumbnailType string
+	extension     string
+}
+
+// detects the real mime type from the file content (the client Content-Type is ignored),
+// checks the dimensions from its header, then decodes the image and generates its thumbnail.
+func processImage(file io.Reader) (*processedImage, error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	mime := mimetype.Detect(content)
+	extension, ok := allowedImageTypes[mime.String()]
+	if !ok {
+		return nil, fmt.Errorf("image type '%s' is not supported, allowed types are jpeg, png and gif", mime.String())
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, fmt.Errorf("image is %dx%d, it must not exceed %v pixels", config.Width, config.Height, maxImagePixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+
+	thumbnail := resizeImage(img, thumbnailMaxSize)
+
+	var buf bytes.Buffer
+	thumbnailType := mime.String()
+	switch mime.String() {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 85})
+	default:
+		// gif thumbnails are stored as png since only the first frame is kept.
+		thumbnailType = "image/png"
+		err = png.Encode(&buf, thumbnail)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &processedImage{
+		original:      content,
+		originalType:  mime.String(),
+		thumbnail:     buf.Bytes(),
+		thumbnailType: thumbnailType,
+		extension:     extension,
+	}, nil
+}
+
+// scales the image down (never up) so its longest side is maxSize, every destination pixel
+// is the average of the source pixels it covers.
+func resizeImage(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return src
+	}
+
+	newWidth, newHeight := maxSize, maxSize
+	if width > height {
+		newHeight = max(1, height*maxSize/width)
+	} else {
+		newWidth = max(1, width*maxSize/height)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		srcY0 := bounds.Min.Y + y*height/newHeight
+		srcY1 := max(srcY0+1, bounds.Min.Y+(y+1)*height/newHeight)
+
+		for x := 0; x < newWidth; x++ {
+			srcX0 := bounds.Min.X + x*width/newWidth
+			srcX1 := max(srcX0+1, bounds.Min.X+(x+1)*width/newWidth)
+
+			var r, g, b, a, count uint64
+			for sy := srcY0; sy < srcY1; sy++ {
+				for sx := srcX0; sx < srcX1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / count),
+				G: uint16(g / count),
+				B: uint16(b / count),
+				A: uint16(a / count),
+			})
+		}
+	}
+
+	return dst
+}
+
+// returns the storage keys for the original image and its thumbnail.
+func newImageKeys(productID int, extension string) (string, string, error) {
+	randomBytes := make([]byte, 12)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", "", err
+	}
+
+	name := hex.EncodeToString(randomBytes)
+	thumbnailExtension := extension
+	if extension == ".gif" {
+		thumbnailExtension = ".png"
+	}
+
+	return fmt.Sprintf("products/%d/%s%s", productID, name, extension),
+		fmt.Sprintf("products/%d/%s_thumb%s", productID, name, thumbnailExtension), nil
+}
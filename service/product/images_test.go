@@ -0,0 +1,84 @@
+package product
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestProcessImage(t *testing.T) {
+	t.Run("Should reject files that are not images whatever their name is", func(t *testing.T) {
+		_, err := processImage(strings.NewReader("<html><body>not an image</body></html>"))
+		if err == nil {
+			t.Error("expected unsupported type error")
+		}
+	})
+
+	t.Run("Should generate a thumbnail that keeps the aspect ratio", func(t *testing.T) {
+		src := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+		for y := 0; y < 500; y++ {
+			for x := 0; x < 1000; x++ {
+				src.Set(x, y, color.RGBA{R: 200, G: 10, B: 10, A: 255})
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, src); err != nil {
+			t.Fatal(err)
+		}
+
+		processed, err := processImage(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if processed.originalType != "image/png" || processed.extension != ".png" {
+			t.Errorf("expected png got %s (%s)", processed.originalType, processed.extension)
+		}
+
+		thumbnail, err := png.Decode(bytes.NewReader(processed.thumbnail))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bounds := thumbnail.Bounds()
+		if bounds.Dx() != thumbnailMaxSize || bounds.Dy() != thumbnailMaxSize/2 {
+			t.Errorf("expected thumbnail %dx%d got %dx%d", thumbnailMaxSize, thumbnailMaxSize/2, bounds.Dx(), bounds.Dy())
+		}
+
+		r, g, b, _ := thumbnail.At(10, 10).RGBA()
+		if r>>8 != 200 || g>>8 != 10 || b>>8 != 10 {
+			t.Errorf("expected the thumbnail to keep the colors got %v %v %v", r>>8, g>>8, b>>8)
+		}
+	})
+
+	t.Run("Should not upscale small images", func(t *testing.T) {
+		small := image.NewRGBA(image.Rect(0, 0, 40, 20))
+		if resized := resizeImage(small, thumbnailMaxSize); resized.Bounds().Dx() != 40 {
+			t.Errorf("expected width 40 got %d", resized.Bounds().Dx())
+		}
+	})
+
+	t.Run("Should refuse an image whose header claims too many pixels before decoding it", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+			t.Fatal(err)
+		}
+
+		// the IHDR chunk right after the signature gets a size of 100000x100000 and its checksum fixed.
+		content := buf.Bytes()
+		binary.BigEndian.PutUint32(content[16:20], 100000)
+		binary.BigEndian.PutUint32(content[20:24], 100000)
+		binary.BigEndian.PutUint32(content[29:33], crc32.ChecksumIEEE(content[12:29]))
+
+		_, err := processImage(bytes.NewReader(content))
+		if err == nil || !strings.Contains(err.Error(), "must not exceed") {
+			t.Errorf("expected the image to be refused for its size got %v", err)
+		}
+	})
+}
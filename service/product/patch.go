@@ -0,0 +1,221 @@
+package product
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// the product members that can be changed through PATCH, only the sku can be removed with null
+// and a categoryId of 0 takes the product out of its category.
+var patchableProductFields = map[string]bool{
+	"sku":         true,
+	"name":        true,
+	"description": true,
+	"image":       true,
+	"price":       true,
+	"quantity":    true,
+	"taxClass":    true,
+	"weightGrams": true,
+	"lengthMm":    true,
+	"widthMm":     true,
+	"heightMm":    true,
+	"categoryId":  true,
+}
+
+// errPatchTestFailed is returned when a JSON Patch "test" operation does not match.
+var errPatchTestFailed = fmt.Errorf("patch test operation failed")
+
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// parses the PATCH body according to its Content-Type, application/json is treated as a merge patch.
+func parseProductPatch(r *http.Request, current types.Product) (types.ProductPatchPayload, error) {
+	if r.Body == nil {
+		return types.ProductPatchPayload{}, fmt.Errorf("request body is empty")
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return types.ProductPatchPayload{}, err
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch contentType {
+	case jsonPatchContentType:
+		return applyJSONPatch(body, current)
+	case mergePatchContentType, "application/json", "":
+		return applyMergePatch(body)
+	default:
+		return types.ProductPatchPayload{}, fmt.Errorf("unsupported content type '%s'", contentType)
+	}
+}
+
+// applyMergePatch follows RFC 7386, every member present in the body is applied even if it's a zero value.
+// null would remove a member, which is not allowed since all the product fields are required.
+func applyMergePatch(body []byte) (types.ProductPatchPayload, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		return types.ProductPatchPayload{}, err
+	}
+
+	for name, value := range members {
+		if !patchableProductFields[name] {
+			return types.ProductPatchPayload{}, fmt.Errorf("%s can't be patched", name)
+		}
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) && name != "sku" {
+			return types.ProductPatchPayload{}, fmt.Errorf("%s can't be removed", name)
+		}
+	}
+
+	var payload types.ProductPatchPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return types.ProductPatchPayload{}, err
+	}
+
+	if _, ok := members["sku"]; ok && payload.SKU == nil {
+		emptySKU := ""
+		payload.SKU = &emptySKU
+	}
+
+	return payload, nil
+}
+
+// applyJSONPatch follows RFC 6902, the operations are applied in order on the current product
+// and only the members they touch end up in the returned payload.
+func applyJSONPatch(body []byte, current types.Product) (types.ProductPatchPayload, error) {
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal(body, &operations); err != nil {
+		return types.ProductPatchPayload{}, err
+	}
+
+	document, err := productPatchDocument(current)
+	if err != nil {
+		return types.ProductPatchPayload{}, err
+	}
+
+	touched := make(map[string]bool)
+	for i, operation := range operations {
+		field, err := patchPathToField(operation.Path)
+		if err != nil {
+			return types.ProductPatchPayload{}, fmt.Errorf("operation %d: %v", i, err)
+		}
+
+		switch operation.Op {
+		case "add", "replace":
+			if len(operation.Value) == 0 {
+				return types.ProductPatchPayload{}, fmt.Errorf("operation %d: value is required", i)
+			}
+			if bytes.Equal(bytes.TrimSpace(operation.Value), []byte("null")) {
+				return types.ProductPatchPayload{}, fmt.Errorf("operation %d: %s can't be removed", i, field)
+			}
+			document[field] = operation.Value
+			touched[field] = true
+
+		case "remove":
+			return types.ProductPatchPayload{}, fmt.Errorf("operation %d: %s can't be removed", i, field)
+
+		case "copy", "move":
+			from, err := patchPathToField(operation.From)
+			if err != nil {
+				return types.ProductPatchPayload{}, fmt.Errorf("operation %d: %v", i, err)
+			}
+			if operation.Op == "move" && from != field {
+				return types.ProductPatchPayload{}, fmt.Errorf("operation %d: %s can't be removed", i, from)
+			}
+			document[field] = document[from]
+			touched[field] = true
+
+		case "test":
+			equal, err := jsonValuesEqual(document[field], operation.Value)
+			if err != nil {
+				return types.ProductPatchPayload{}, fmt.Errorf("operation %d: %v", i, err)
+			}
+			if !equal {
+				return types.ProductPatchPayload{}, fmt.Errorf("operation %d: %w, %s does not match", i, errPatchTestFailed, field)
+			}
+
+		default:
+			return types.ProductPatchPayload{}, fmt.Errorf("operation %d: unsupported op '%s'", i, operation.Op)
+		}
+	}
+
+	changes := make(map[string]json.RawMessage, len(touched))
+	for field := range touched {
+		changes[field] = document[field]
+	}
+
+	marshalled, err := json.Marshal(changes)
+	if err != nil {
+		return types.ProductPatchPayload{}, err
+	}
+
+	var payload types.ProductPatchPayload
+	if err := json.Unmarshal(marshalled, &payload); err != nil {
+		return types.ProductPatchPayload{}, err
+	}
+
+	return payload, nil
+}
+
+func productPatchDocument(product types.Product) (map[string]json.RawMessage, error) {
+	marshalled, err := json.Marshal(product)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(marshalled, &document); err != nil {
+		return nil, err
+	}
+
+	for name := range document {
+		if !patchableProductFields[name] {
+			delete(document, name)
+		}
+	}
+
+	return document, nil
+}
+
+// only top level members can be targeted, e.g. "/quantity".
+func patchPathToField(path string) (string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("invalid path '%s'", path)
+	}
+
+	field := strings.NewReplacer("~1", "/", "~0", "~").Replace(path[1:])
+	if !patchableProductFields[field] {
+		return "", fmt.Errorf("%s can't be patched", field)
+	}
+
+	return field, nil
+}
+
+func jsonValuesEqual(a, b json.RawMessage) (bool, error) {
+	var valueA, valueB any
+	if err := json.Unmarshal(a, &valueA); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(b, &valueB); err != nil {
+		return false, err
+	}
+
+	return reflect.DeepEqual(valueA, valueB), nil
+}
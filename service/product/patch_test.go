@@ -0,0 +1,131 @@
+package product
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestParseProductPatch(t *testing.T) {
+	current := types.Product{
+		ID:          1,
+		Name:        "keyboard",
+		Description: "mechanical keyboard",
+		Image:       "keyboard.png",
+		Price:       money.FromMinor(4999),
+		Quantity:    12,
+	}
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		expectErr   bool
+		check       func(t *testing.T, payload types.ProductPatchPayload)
+	}{
+		{
+			name:        "Should apply a zero quantity from a merge patch",
+			contentType: mergePatchContentType,
+			body:        `{"quantity": 0}`,
+			check: func(t *testing.T, payload types.ProductPatchPayload) {
+				if payload.Quantity == nil || *payload.Quantity != 0 {
+					t.Errorf("expected quantity to be set to 0 got %v", payload.Quantity)
+				}
+				if payload.Name != nil || payload.Price != nil {
+					t.Error("expected the missing fields to be left untouched")
+				}
+			},
+		},
+		{
+			name:        "Should treat application/json as a merge patch",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"description": ""}`,
+			check: func(t *testing.T, payload types.ProductPatchPayload) {
+				if payload.Description == nil || *payload.Description != "" {
+					t.Errorf("expected description to be set to empty got %v", payload.Description)
+				}
+			},
+		},
+		{
+			name:        "Should reject null in a merge patch",
+			contentType: mergePatchContentType,
+			body:        `{"name": null}`,
+			expectErr:   true,
+		},
+		{
+			name:        "Should reject unknown members",
+			contentType: mergePatchContentType,
+			body:        `{"id": 3}`,
+			expectErr:   true,
+		},
+		{
+			name:        "Should apply JSON Patch operations in order",
+			contentType: jsonPatchContentType,
+			body: `[
+				{"op": "test", "path": "/quantity", "value": 12},
+				{"op": "replace", "path": "/quantity", "value": 0},
+				{"op": "copy", "from": "/name", "path": "/description"}
+			]`,
+			check: func(t *testing.T, payload types.ProductPatchPayload) {
+				if payload.Quantity == nil || *payload.Quantity != 0 {
+					t.Errorf("expected quantity to be set to 0 got %v", payload.Quantity)
+				}
+				if payload.Description == nil || *payload.Description != "keyboard" {
+					t.Errorf("expected description to be copied from name got %v", payload.Description)
+				}
+				if payload.Price != nil {
+					t.Error("expected price to be left untouched")
+				}
+			},
+		},
+		{
+			name:        "Should reject remove operations",
+			contentType: jsonPatchContentType,
+			body:        `[{"op": "remove", "path": "/image"}]`,
+			expectErr:   true,
+		},
+		{
+			name:        "Should reject unsupported content types",
+			contentType: "text/plain",
+			body:        `{"quantity": 0}`,
+			expectErr:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPatch, "/products/1", bytes.NewBufferString(c.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", c.contentType)
+
+			payload, err := parseProductPatch(req, current)
+			if c.expectErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.check(t, payload)
+		})
+	}
+
+	t.Run("Should report failed test operations", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPatch, "/products/1", bytes.NewBufferString(`[{"op": "test", "path": "/price", "value": 10}]`))
+		req.Header.Set("Content-Type", jsonPatchContentType)
+
+		_, err := parseProductPatch(req, current)
+		if !errors.Is(err, errPatchTestFailed) {
+			t.Errorf("expected errPatchTestFailed got %v", err)
+		}
+	})
+}
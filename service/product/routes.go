@@ -1,32 +1,60 @@
 package product
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
 	"github.com/mohammadahmadkhader/golang-ecommerce/middlewares"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
 	"github.com/mohammadahmadkhader/golang-ecommerce/types"
 	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
 )
 
 type Handler struct {
-	store types.ProductStore
+	store          types.ProductStore
+	blobStorage    types.BlobStorage
+	warehouseStore types.WarehouseStore
+	maxImageSize   int64
 }
 
-func NewHandler(store types.ProductStore) *Handler {
+func NewHandler(store types.ProductStore, blobStorage types.BlobStorage, warehouseStore types.WarehouseStore) *Handler {
+	maxImageSize, err := strconv.ParseInt(config.Envs.MaxImageSizeInBytes, 10, 64)
+	if err != nil || maxImageSize <= 0 {
+		maxImageSize = 5 << 20
+	}
+
 	return &Handler{
-		store: store,
+		store:          store,
+		blobStorage:    blobStorage,
+		warehouseStore: warehouseStore,
+		maxImageSize:   maxImageSize,
 	}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/products", h.CreateProduct).Methods("POST")
+	router.HandleFunc("/products", auth.AdminMiddleware(h.CreateProduct)).Methods("POST")
 	router.HandleFunc("/products", middlewares.PaginationMiddleware(h.GetProducts)).Methods("GET")
+	router.HandleFunc("/products/import", auth.AdminMiddleware(h.ImportProducts)).Methods("POST")
+	router.HandleFunc("/products/export", auth.AdminMiddleware(h.ExportProducts)).Methods("GET")
 	router.HandleFunc("/products/{id}", h.GetSingleProduct).Methods("GET")
-	router.HandleFunc("/products/{id}", h.UpdateProduct).Methods("PUT")
-	router.HandleFunc("/products/{id}", h.DeleteProduct).Methods("DELETE")
+	router.HandleFunc("/products/{id}", auth.AdminMiddleware(h.UpdateProduct)).Methods("PUT")
+	router.HandleFunc("/products/{id}", auth.AdminMiddleware(h.PatchProduct)).Methods("PATCH")
+	router.HandleFunc("/products/{id}", auth.AdminMiddleware(h.DeleteProduct)).Methods("DELETE")
+	router.HandleFunc("/products/{id}/images", auth.AdminMiddleware(h.UploadProductImage)).Methods("POST")
+	router.HandleFunc("/products/{id}/images/order", auth.AdminMiddleware(h.ReorderProductImages)).Methods("PUT")
+	router.HandleFunc("/products/{id}/images/{imageId}", auth.AdminMiddleware(h.UpdateProductImage)).Methods("PATCH")
+	router.HandleFunc("/products/{id}/images/{imageId}", auth.AdminMiddleware(h.DeleteProductImage)).Methods("DELETE")
+
+	router.HandleFunc("/admin/products/trash", auth.AdminMiddleware(middlewares.PaginationMiddleware(h.GetTrashedProducts))).Methods("GET")
+	router.HandleFunc("/admin/products/trash", auth.AdminMiddleware(h.PurgeTrashedProducts)).Methods("DELETE")
+	router.HandleFunc("/admin/products/trash/{id}", auth.AdminMiddleware(h.PurgeProduct)).Methods("DELETE")
+	router.HandleFunc("/admin/products/{id}/restore", auth.AdminMiddleware(h.RestoreProduct)).Methods("POST")
 }
 
 func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
@@ -47,6 +75,7 @@ func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
+	setProductETag(w, *createdProd)
 
 	utils.WriteJSON(w, http.StatusCreated, map[string]any{
 		"message": "success",
@@ -64,6 +93,11 @@ func (h *Handler) GetProducts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.addStockLocations(r, products); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	utils.WriteJSON(w, http.StatusOK,
 		map[string]any{
 			"products": products,
@@ -90,10 +124,53 @@ func (h *Handler) GetSingleProduct(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
+	if product.DeletedAt != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("no product was found for id %v", id))
+		return
+	}
+
+	// admins get the stock locations in the same representation.
+	w.Header().Set("Vary", "Authorization")
+	setProductETag(w, product)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, productETag(product), true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	products := []types.Product{product}
+	if err := h.addStockLocations(r, products); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
 
-	utils.WriteJSON(w, http.StatusOK, map[string]any{"product": product})
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"product": products[0]})
 }
 
+// admins also see the stock of the products in every warehouse.
+func (h *Handler) addStockLocations(r *http.Request, products []types.Product) error {
+	if h.warehouseStore == nil || len(products) == 0 || !auth.IsAdminRequest(r) {
+		return nil
+	}
+
+	productIDs := make([]int, len(products))
+	for i, product := range products {
+		productIDs[i] = product.ID
+	}
+
+	levels, err := h.warehouseStore.GetProductsStockLevels(productIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range products {
+		products[i].Locations = levels[products[i].ID]
+	}
+
+	return nil
+}
+
+// UpdateProduct replaces the whole product, the required fields must be provided and the others are reset
+// when they're omitted.
 func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	var updatePayload types.ProductUpdatePayload
 	err := utils.ParseJSON(r, &updatePayload)
@@ -102,16 +179,11 @@ func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	isEmpty := IsProductUpdatePayloadEmpty(updatePayload)
-	if isEmpty {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("at least one field is required"))
-		return
-	}
-
 	if err := utils.Validate.Struct(updatePayload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
+	applyProductUpdateDefaults(&updatePayload)
 
 	idStr := mux.Vars(r)["id"]
 
@@ -121,15 +193,82 @@ func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updatedProducts, err := h.store.UpdateProduct(id, updatePayload)
+	product, err := h.store.GetProductById(id)
+	if err != nil || product.DeletedAt != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("no product was found for id %v", id))
+		return
+	}
+
+	version, err := checkIfMatch(r, product)
+	if err != nil {
+		writePreconditionError(w, err)
+		return
+	}
+
+	updatedProduct, err := h.store.UpdateProduct(id, version, types.ProductPatchPayload(updatePayload))
+	if err != nil {
+		writePreconditionError(w, err)
+		return
+	}
+
+	setProductETag(w, *updatedProduct)
+	utils.WriteJSON(w, http.StatusAccepted, map[string]any{
+		"message": "success",
+		"data":    updatedProduct,
+	})
+}
+
+// PatchProduct accepts a JSON Merge Patch (RFC 7386) or a JSON Patch (RFC 6902) body,
+// the fields present in the patch are applied even when they are zero values.
+func (h *Handler) PatchProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	product, err := h.store.GetProductById(id)
+	if err != nil || product.DeletedAt != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("no product was found for id %v", id))
+		return
+	}
+
+	version, err := checkIfMatch(r, product)
+	if err != nil {
+		writePreconditionError(w, err)
+		return
+	}
+
+	patchPayload, err := parseProductPatch(r, product)
+	if errors.Is(err, errPatchTestFailed) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
 	if err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
+	if IsProductPatchPayloadEmpty(patchPayload) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("at least one field is required"))
+		return
+	}
+
+	if err := utils.Validate.Struct(patchPayload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updatedProduct, err := h.store.UpdateProduct(id, version, patchPayload)
+	if err != nil {
+		writePreconditionError(w, err)
+		return
+	}
+
+	setProductETag(w, *updatedProduct)
 	utils.WriteJSON(w, http.StatusAccepted, map[string]any{
 		"message": "success",
-		"data":    updatedProducts,
+		"data":    updatedProduct,
 	})
 }
 
@@ -149,4 +288,306 @@ func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 	utils.WriteJSON(w, http.StatusNoContent, map[string]any{})
-}
\ No newline at end of file
+}
+
+func (h *Handler) GetTrashedProducts(w http.ResponseWriter, r *http.Request) {
+	pagination := middlewares.GetPagination(r)
+	offset := middlewares.CalculateOffset(pagination)
+
+	products, count, err := h.store.GetTrashedProducts(pagination.Limit, offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK,
+		map[string]any{
+			"products": products,
+			"page":     pagination.Page,
+			"limit":    pagination.Limit,
+			"count":    count,
+		})
+}
+
+func (h *Handler) RestoreProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	restoredProd, err := h.store.RestoreProduct(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    restoredProd,
+	})
+}
+
+func (h *Handler) PurgeProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	images, err := h.store.PurgeProduct(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	h.deleteImagesBlobs(images)
+
+	utils.WriteJSON(w, http.StatusNoContent, map[string]any{})
+}
+
+// purges every trashed product that is not referenced by any order.
+func (h *Handler) PurgeTrashedProducts(w http.ResponseWriter, r *http.Request) {
+	ids, err := h.store.GetPurgeableProductIDs()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	purgedIds := make([]int, 0, len(ids))
+	for _, id := range ids {
+		images, err := h.store.PurgeProduct(id)
+		if err != nil {
+			// the product got ordered or restored in the meantime.
+			continue
+		}
+		h.deleteImagesBlobs(images)
+		purgedIds = append(purgedIds, id)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message":   "success",
+		"purgedIds": purgedIds,
+	})
+}
+
+func (h *Handler) UploadProductImage(w http.ResponseWriter, r *http.Request) {
+	productId, err := getIdParam(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if product, err := h.store.GetProductById(productId); err != nil || product.DeletedAt != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("no product was found for id %v", productId))
+		return
+	}
+
+	// extra room is left for the other multipart fields and boundaries.
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxImageSize+(1<<20))
+	if err := r.ParseMultipartForm(h.maxImageSize); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("image size must not exceed %v bytes", h.maxImageSize))
+			return
+		}
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("image is required"))
+		return
+	}
+	defer file.Close()
+
+	if header.Size > h.maxImageSize {
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("image size must not exceed %v bytes", h.maxImageSize))
+		return
+	}
+
+	imagePayload := types.ProductImageUpdatePayload{AltText: r.FormValue("altText")}
+	if err := utils.Validate.Struct(imagePayload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	processed, err := processImage(file)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+
+	key, thumbnailKey, err := newImageKeys(productId, processed.extension)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	url, err := h.blobStorage.Put(key, bytes.NewReader(processed.original), processed.originalType)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	thumbnailUrl, err := h.blobStorage.Put(thumbnailKey, bytes.NewReader(processed.thumbnail), processed.thumbnailType)
+	if err != nil {
+		h.deleteBlobs(key)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	image, err := h.store.CreateProductImage(types.ProductImage{
+		ProductID:    productId,
+		URL:          url,
+		ThumbnailURL: thumbnailUrl,
+		StorageKey:   key,
+		ThumbnailKey: thumbnailKey,
+		AltText:      imagePayload.AltText,
+	})
+	if err != nil {
+		h.deleteBlobs(key, thumbnailKey)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    image,
+	})
+}
+
+func (h *Handler) UpdateProductImage(w http.ResponseWriter, r *http.Request) {
+	productId, err := getIdParam(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	imageId, err := getIdParam(r, "imageId")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var updatePayload types.ProductImageUpdatePayload
+	if err := utils.ParseJSON(r, &updatePayload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(updatePayload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	image, err := h.store.UpdateProductImageAltText(productId, imageId, updatePayload.AltText)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, map[string]any{
+		"message": "success",
+		"data":    image,
+	})
+}
+
+func (h *Handler) ReorderProductImages(w http.ResponseWriter, r *http.Request) {
+	productId, err := getIdParam(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var reorderPayload types.ProductImagesReorderPayload
+	if err := utils.ParseJSON(r, &reorderPayload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(reorderPayload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	images, err := h.store.ReorderProductImages(productId, reorderPayload.ImageIDs)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, map[string]any{
+		"message": "success",
+		"data":    images,
+	})
+}
+
+func (h *Handler) DeleteProductImage(w http.ResponseWriter, r *http.Request) {
+	productId, err := getIdParam(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	imageId, err := getIdParam(r, "imageId")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	image, err := h.store.GetProductImage(productId, imageId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.store.DeleteProductImage(productId, imageId); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	h.deleteBlobs(image.StorageKey, image.ThumbnailKey)
+
+	utils.WriteJSON(w, http.StatusNoContent, map[string]any{})
+}
+
+// failing to delete a blob only leaves an orphan file behind, so it's logged instead of returned.
+func (h *Handler) deleteBlobs(keys ...string) {
+	for _, key := range keys {
+		if err := h.blobStorage.Delete(key); err != nil {
+			log.Printf("failed to delete blob '%s': %v\n", key, err)
+		}
+	}
+}
+
+func (h *Handler) deleteImagesBlobs(images []types.ProductImage) {
+	for _, image := range images {
+		h.deleteBlobs(image.StorageKey, image.ThumbnailKey)
+	}
+}
+
+// maps the optimistic concurrency errors to 428 and 412, anything else is a bad request.
+func writePreconditionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errMissingIfMatch):
+		utils.WriteError(w, http.StatusPreconditionRequired, err)
+	case errors.Is(err, types.ErrProductVersionMismatch):
+		utils.WriteError(w, http.StatusPreconditionFailed, err)
+	default:
+		utils.WriteError(w, http.StatusBadRequest, err)
+	}
+}
+
+func getIdParam(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", name)
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("%s must be unsigned integer", name)
+	}
+
+	return id, nil
+}
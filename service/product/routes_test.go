@@ -0,0 +1,487 @@
+package product
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestProductServiceHandler(t *testing.T) {
+	productStore := &mockProductStore{product: types.Product{
+		ID:          1,
+		Name:        "keyboard",
+		Description: "mechanical keyboard",
+		Image:       "keyboard.png",
+		Price:       money.FromMinor(4999),
+		Quantity:    12,
+		Available:   12,
+		Version:     3,
+	}}
+	handler := NewHandler(productStore, nil, nil)
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	t.Run("Should return the product with its ETag", func(t *testing.T) {
+		recorder := serve(t, router, http.MethodGet, "/products/1", nil, nil)
+
+		if recorder.Code != http.StatusOK {
+			t.Errorf("expected status code %d got %d", http.StatusOK, recorder.Code)
+		}
+		if etag := recorder.Header().Get("ETag"); etag != `"3-12"` {
+			t.Errorf(`expected ETag "3-12" got %s`, etag)
+		}
+	})
+
+	t.Run("Should return 304 when If-None-Match matches", func(t *testing.T) {
+		recorder := serve(t, router, http.MethodGet, "/products/1", nil, map[string]string{"If-None-Match": `W/"3-12"`})
+
+		if recorder.Code != http.StatusNotModified {
+			t.Errorf("expected status code %d got %d", http.StatusNotModified, recorder.Code)
+		}
+	})
+
+	t.Run("Should not return 304 once the available quantity changed", func(t *testing.T) {
+		productStore.product.Available = 10
+		defer func() { productStore.product.Available = 12 }()
+
+		recorder := serve(t, router, http.MethodGet, "/products/1", nil, map[string]string{"If-None-Match": `"3-12"`})
+		if recorder.Code != http.StatusOK || recorder.Header().Get("ETag") != `"3-10"` {
+			t.Errorf(`expected status code %d with ETag "3-10" got %d with %s`, http.StatusOK, recorder.Code, recorder.Header().Get("ETag"))
+		}
+	})
+
+	t.Run("Should leave the product changes to the admins", func(t *testing.T) {
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), types.User{ID: 7, Role: types.UserRoleCustomer})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		requests := []struct{ method, path string }{
+			{http.MethodPost, "/products"},
+			{http.MethodPut, "/products/1"},
+			{http.MethodPatch, "/products/1"},
+			{http.MethodDelete, "/products/1"},
+			{http.MethodPost, "/products/1/images"},
+			{http.MethodPut, "/products/1/images/order"},
+			{http.MethodPatch, "/products/1/images/2"},
+			{http.MethodDelete, "/products/1/images/2"},
+		}
+		for _, request := range requests {
+			if recorder := serve(t, router, request.method, request.path, []byte(`{}`), nil); recorder.Code != http.StatusForbidden {
+				t.Errorf("expected status code %d for %s %s without a token got %d", http.StatusForbidden, request.method, request.path, recorder.Code)
+			}
+
+			recorder := serve(t, router, request.method, request.path, []byte(`{}`), map[string]string{"Authorization": token})
+			if recorder.Code != http.StatusForbidden {
+				t.Errorf("expected status code %d for %s %s from a customer got %d", http.StatusForbidden, request.method, request.path, recorder.Code)
+			}
+		}
+	})
+
+	t.Run("Should return 428 when If-Match is missing", func(t *testing.T) {
+		recorder := serve(t, router, http.MethodPatch, "/products/1", []byte(`{"quantity": 0}`), asAdmin(t, nil))
+
+		if recorder.Code != http.StatusPreconditionRequired {
+			t.Errorf("expected status code %d got %d", http.StatusPreconditionRequired, recorder.Code)
+		}
+	})
+
+	t.Run("Should return 412 when If-Match is stale", func(t *testing.T) {
+		recorder := serve(t, router, http.MethodPatch, "/products/1", []byte(`{"quantity": 0}`), asAdmin(t, map[string]string{"If-Match": `"2-12"`}))
+
+		if recorder.Code != http.StatusPreconditionFailed {
+			t.Errorf("expected status code %d got %d", http.StatusPreconditionFailed, recorder.Code)
+		}
+	})
+
+	t.Run("Should return 412 when the product changes between the read and the write", func(t *testing.T) {
+		productStore.concurrentUpdate = true
+		defer func() { productStore.concurrentUpdate = false }()
+
+		recorder := serve(t, router, http.MethodPatch, "/products/1", []byte(`{"quantity": 0}`), asAdmin(t, map[string]string{"If-Match": `"3-12"`}))
+
+		if recorder.Code != http.StatusPreconditionFailed {
+			t.Errorf("expected status code %d got %d", http.StatusPreconditionFailed, recorder.Code)
+		}
+	})
+
+	t.Run("Should set the quantity to 0 and bump the ETag", func(t *testing.T) {
+		recorder := serve(t, router, http.MethodPatch, "/products/1", []byte(`{"quantity": 0}`), asAdmin(t, map[string]string{"If-Match": `"3-12"`}))
+
+		if recorder.Code != http.StatusAccepted {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusAccepted, recorder.Code, recorder.Body.String())
+		}
+		if productStore.product.Quantity != 0 {
+			t.Errorf("expected quantity 0 got %d", productStore.product.Quantity)
+		}
+		if etag := recorder.Header().Get("ETag"); etag != `"4-0"` {
+			t.Errorf(`expected ETag "4-0" got %s`, etag)
+		}
+	})
+
+	t.Run("Should require every field on PUT", func(t *testing.T) {
+		recorder := serve(t, router, http.MethodPut, "/products/1", []byte(`{"quantity": 5}`), asAdmin(t, map[string]string{"If-Match": `"4-0"`}))
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Should reset the optional fields omitted on PUT", func(t *testing.T) {
+		body := []byte(`{"name": "keyboard", "description": "mechanical keyboard", "image": "keyboard.png", "price": 49.99, "quantity": 5}`)
+		recorder := serve(t, router, http.MethodPut, "/products/1", body, asAdmin(t, map[string]string{"If-Match": `"4-0"`}))
+		if recorder.Code != http.StatusAccepted {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusAccepted, recorder.Code, recorder.Body.String())
+		}
+
+		patched := productStore.patched
+		if patched.SKU == nil || *patched.SKU != "" || patched.TaxClass == nil || *patched.TaxClass != types.TaxClassStandard {
+			t.Errorf("expected the sku to be removed and the tax class to be standard got %v and %v", patched.SKU, patched.TaxClass)
+		}
+		for _, field := range []*int{patched.WeightGrams, patched.LengthMm, patched.WidthMm, patched.HeightMm, patched.CategoryID} {
+			if field == nil || *field != 0 {
+				t.Errorf("expected the weight, the dimensions and the category to be reset to 0 got %+v", patched)
+			}
+		}
+	})
+}
+
+func TestPatchProductSingleField(t *testing.T) {
+	cases := []struct {
+		name  string
+		body  string
+		check func(payload types.ProductPatchPayload) bool
+	}{
+		{"Should patch only the sku", `{"sku": "KB-1"}`, func(p types.ProductPatchPayload) bool { return p.SKU != nil && *p.SKU == "KB-1" }},
+		{"Should patch only the tax class", `{"taxClass": "reduced"}`, func(p types.ProductPatchPayload) bool { return p.TaxClass != nil && *p.TaxClass == "reduced" }},
+		{"Should patch only the weight", `{"weightGrams": 0}`, func(p types.ProductPatchPayload) bool { return p.WeightGrams != nil && *p.WeightGrams == 0 }},
+		{"Should patch only the length", `{"lengthMm": 450}`, func(p types.ProductPatchPayload) bool { return p.LengthMm != nil && *p.LengthMm == 450 }},
+		{"Should patch only the width", `{"widthMm": 140}`, func(p types.ProductPatchPayload) bool { return p.WidthMm != nil && *p.WidthMm == 140 }},
+		{"Should patch only the height", `{"heightMm": 40}`, func(p types.ProductPatchPayload) bool { return p.HeightMm != nil && *p.HeightMm == 40 }},
+		{"Should patch only the category", `{"categoryId": 2}`, func(p types.ProductPatchPayload) bool { return p.CategoryID != nil && *p.CategoryID == 2 }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			productStore := &mockProductStore{product: types.Product{ID: 1, Name: "keyboard", Price: money.FromMinor(4999), Version: 1}}
+			router := mux.NewRouter()
+			NewHandler(productStore, nil, nil).RegisterRoutes(router)
+
+			recorder := serve(t, router, http.MethodPatch, "/products/1", []byte(c.body), asAdmin(t, map[string]string{"If-Match": `"1-0"`}))
+			if recorder.Code != http.StatusAccepted {
+				t.Fatalf("expected status code %d got %d: %s", http.StatusAccepted, recorder.Code, recorder.Body.String())
+			}
+			if !c.check(productStore.patched) {
+				t.Errorf("expected the field to be patched got %+v", productStore.patched)
+			}
+		})
+	}
+
+	t.Run("Should refuse an empty patch", func(t *testing.T) {
+		router := mux.NewRouter()
+		NewHandler(&mockProductStore{product: types.Product{ID: 1, Version: 1}}, nil, nil).RegisterRoutes(router)
+
+		recorder := serve(t, router, http.MethodPatch, "/products/1", []byte(`{}`), asAdmin(t, map[string]string{"If-Match": `"1-0"`}))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+}
+
+func TestTrash(t *testing.T) {
+	newRouter := func(productStore *mockProductStore) (*mux.Router, *mockBlobStorage) {
+		blobStorage := &mockBlobStorage{}
+		router := mux.NewRouter()
+		NewHandler(productStore, blobStorage, nil).RegisterRoutes(router)
+		return router, blobStorage
+	}
+
+	t.Run("Should hide a deleted product until it's restored", func(t *testing.T) {
+		productStore := &mockProductStore{product: types.Product{ID: 1, Name: "keyboard", Version: 1}}
+		router, _ := newRouter(productStore)
+
+		if recorder := serve(t, router, http.MethodDelete, "/products/1", nil, asAdmin(t, nil)); recorder.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusNoContent, recorder.Code, recorder.Body)
+		}
+		if recorder := serve(t, router, http.MethodGet, "/products/1", nil, nil); recorder.Code != http.StatusNotFound {
+			t.Errorf("expected the deleted product to be not found got %d", recorder.Code)
+		}
+		if recorder := serve(t, router, http.MethodGet, "/products", nil, nil); recorder.Code != http.StatusOK || strings.Contains(recorder.Body.String(), "keyboard") {
+			t.Errorf("expected the deleted product to be left out of the listing got %d: %s", recorder.Code, recorder.Body)
+		}
+		if recorder := serve(t, router, http.MethodGet, "/admin/products/trash", nil, asAdmin(t, nil)); recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "keyboard") {
+			t.Errorf("expected the deleted product to be in the trash got %s", recorder.Body)
+		}
+
+		if recorder := serve(t, router, http.MethodPost, "/admin/products/1/restore", nil, asAdmin(t, nil)); recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+		if recorder := serve(t, router, http.MethodGet, "/products/1", nil, nil); recorder.Code != http.StatusOK {
+			t.Errorf("expected the restored product to be found got %d", recorder.Code)
+		}
+	})
+
+	t.Run("Should refuse to delete a product twice", func(t *testing.T) {
+		deletedAt := time.Now()
+		router, _ := newRouter(&mockProductStore{product: types.Product{ID: 1, DeletedAt: &deletedAt}})
+
+		if recorder := serve(t, router, http.MethodDelete, "/products/1", nil, asAdmin(t, nil)); recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Should refuse to restore a product that isn't deleted", func(t *testing.T) {
+		router, _ := newRouter(&mockProductStore{product: types.Product{ID: 1}})
+
+		if recorder := serve(t, router, http.MethodPost, "/admin/products/1/restore", nil, asAdmin(t, nil)); recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Should purge a product with its images", func(t *testing.T) {
+		productStore := &mockProductStore{}
+		router, blobStorage := newRouter(productStore)
+
+		if recorder := serve(t, router, http.MethodDelete, "/admin/products/trash/3", nil, asAdmin(t, nil)); recorder.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusNoContent, recorder.Code, recorder.Body)
+		}
+		if !slices.Equal(productStore.purged, []int{3}) || !slices.Equal(blobStorage.deleted, []string{"products/3/photo.png", "products/3/photo-thumb.png"}) {
+			t.Errorf("expected product 3 to be purged with its images got %v and %v", productStore.purged, blobStorage.deleted)
+		}
+	})
+
+	t.Run("Should refuse to purge an ordered product", func(t *testing.T) {
+		router, blobStorage := newRouter(&mockProductStore{ordered: []int{3}})
+
+		recorder := serve(t, router, http.MethodDelete, "/admin/products/trash/3", nil, asAdmin(t, nil))
+		if recorder.Code != http.StatusConflict || len(blobStorage.deleted) != 0 {
+			t.Errorf("expected status code %d without deleting the images got %d and %v", http.StatusConflict, recorder.Code, blobStorage.deleted)
+		}
+	})
+
+	t.Run("Should empty the trash but the products ordered meanwhile", func(t *testing.T) {
+		productStore := &mockProductStore{purgeable: []int{3, 4, 5}, ordered: []int{4}}
+		router, _ := newRouter(productStore)
+
+		recorder := serve(t, router, http.MethodDelete, "/admin/products/trash", nil, asAdmin(t, nil))
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"purgedIds":[3,5]`) {
+			t.Errorf("expected the products 3 and 5 to be purged got %d: %s", recorder.Code, recorder.Body)
+		}
+	})
+
+	t.Run("Should keep the trash to the admins", func(t *testing.T) {
+		router, _ := newRouter(&mockProductStore{})
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), types.User{ID: 7, Role: types.UserRoleCustomer})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, route := range [][2]string{{http.MethodGet, "/admin/products/trash"}, {http.MethodDelete, "/admin/products/trash"},
+			{http.MethodDelete, "/admin/products/trash/3"}, {http.MethodPost, "/admin/products/1/restore"}} {
+			recorder := serve(t, router, route[0], route[1], nil, map[string]string{"Authorization": token})
+			if recorder.Code != http.StatusForbidden {
+				t.Errorf("expected status code %d for %s %s got %d", http.StatusForbidden, route[0], route[1], recorder.Code)
+			}
+		}
+	})
+}
+
+func asAdmin(t *testing.T, headers map[string]string) map[string]string {
+	t.Helper()
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), types.User{ID: 1, Role: types.UserRoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := map[string]string{"Authorization": token}
+	for key, value := range headers {
+		admin[key] = value
+	}
+	return admin
+}
+
+func serve(t *testing.T, router *mux.Router, method, path string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewBuffer(body)
+	}
+
+	req, err := http.NewRequest(method, path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+type mockProductStore struct {
+	product          types.Product
+	concurrentUpdate bool
+	patched          types.ProductPatchPayload
+	upserted         []types.ProductCreatePayload
+	// purgeable are the trashed products never ordered, ordered can't be purged.
+	purgeable []int
+	ordered   []int
+	purged    []int
+}
+
+func (m *mockProductStore) GetProductById(id int) (types.Product, error) {
+	if id != m.product.ID {
+		return types.Product{}, fmt.Errorf("product was not found")
+	}
+	return m.product, nil
+}
+
+func (m *mockProductStore) GetProducts(limit, offset int) ([]types.Product, int, error) {
+	if m.product.DeletedAt != nil {
+		return []types.Product{}, 0, nil
+	}
+	return []types.Product{m.product}, 1, nil
+}
+
+func (m *mockProductStore) GetProductsByID(productIDs []int) ([]types.Product, error) {
+	if m.product.DeletedAt != nil {
+		return []types.Product{}, nil
+	}
+	return []types.Product{m.product}, nil
+}
+
+func (m *mockProductStore) CreateProduct(payload types.ProductCreatePayload) (*types.Product, error) {
+	return &m.product, nil
+}
+
+func (m *mockProductStore) UpdateProduct(id, version int, payload types.ProductPatchPayload) (*types.Product, error) {
+	if m.concurrentUpdate || version != m.product.Version {
+		return nil, types.ErrProductVersionMismatch
+	}
+
+	m.patched = payload
+	if payload.Quantity != nil {
+		m.product.Quantity = *payload.Quantity
+		m.product.Available = *payload.Quantity
+	}
+	m.product.Version++
+
+	return &m.product, nil
+}
+
+func (m *mockProductStore) DeleteProduct(id int) error {
+	if id != m.product.ID || m.product.DeletedAt != nil {
+		return fmt.Errorf("no product was found for id %v", id)
+	}
+
+	deletedAt := time.Now()
+	m.product.DeletedAt = &deletedAt
+	return nil
+}
+
+func (m *mockProductStore) GetTrashedProducts(limit, offset int) ([]types.Product, int, error) {
+	if m.product.DeletedAt == nil {
+		return []types.Product{}, 0, nil
+	}
+	return []types.Product{m.product}, 1, nil
+}
+
+func (m *mockProductStore) RestoreProduct(id int) (*types.Product, error) {
+	if id != m.product.ID || m.product.DeletedAt == nil {
+		return nil, fmt.Errorf("no trashed product was found for id %v", id)
+	}
+
+	m.product.DeletedAt = nil
+	return &m.product, nil
+}
+
+func (m *mockProductStore) GetPurgeableProductIDs() ([]int, error) {
+	return m.purgeable, nil
+}
+
+func (m *mockProductStore) PurgeProduct(id int) ([]types.ProductImage, error) {
+	if slices.Contains(m.ordered, id) {
+		return nil, fmt.Errorf("product with id %v is not in the trash or is referenced by an order", id)
+	}
+
+	m.purged = append(m.purged, id)
+	key := fmt.Sprintf("products/%d/photo", id)
+	return []types.ProductImage{{ProductID: id, StorageKey: key + ".png", ThumbnailKey: key + "-thumb.png"}}, nil
+}
+
+func (m *mockProductStore) GetProductImages(productID int) ([]types.ProductImage, error) {
+	return nil, nil
+}
+
+func (m *mockProductStore) GetProductImage(productID, imageID int) (*types.ProductImage, error) {
+	return nil, fmt.Errorf("image was not found")
+}
+
+func (m *mockProductStore) CreateProductImage(image types.ProductImage) (*types.ProductImage, error) {
+	return &image, nil
+}
+
+func (m *mockProductStore) UpdateProductImageAltText(productID, imageID int, altText string) (*types.ProductImage, error) {
+	return nil, nil
+}
+
+func (m *mockProductStore) ReorderProductImages(productID int, imageIDs []int) ([]types.ProductImage, error) {
+	return nil, nil
+}
+
+func (m *mockProductStore) DeleteProductImage(productID, imageID int) error {
+	return nil
+}
+
+func (m *mockProductStore) GetProductBySKU(sku string) (*types.Product, error) {
+	if m.product.SKU != nil && *m.product.SKU == sku {
+		return &m.product, nil
+	}
+	return nil, fmt.Errorf("%w for sku '%s'", types.ErrProductNotFound, sku)
+}
+
+func (m *mockProductStore) UpsertProductBySKU(payload types.ProductCreatePayload) (*types.Product, bool, error) {
+	m.upserted = append(m.upserted, payload)
+	_, err := m.GetProductBySKU(payload.SKU)
+	return &types.Product{Name: payload.Name}, err != nil, nil
+}
+
+func (m *mockProductStore) StreamProducts(fn func(product types.Product) error) error {
+	for _, product := range []types.Product{m.product, m.product} {
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type mockBlobStorage struct {
+	deleted []string
+}
+
+func (m *mockBlobStorage) Put(key string, body io.Reader, contentType string) (string, error) {
+	return "https://cdn.example.com/" + key, nil
+}
+
+func (m *mockBlobStorage) Delete(key string) error {
+	m.deleted = append(m.deleted, key)
+	return nil
+}
@@ -6,11 +6,21 @@ import (
 	"strings"
 	"time"
 
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/inventory"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/outbox"
 	"github.com/mohammadahmadkhader/golang-ecommerce/types"
 )
 
+// the available quantity is what can still be sold, the quantity on hand minus the active reservations.
+const selectProductsQuery = `SELECT products.*, GREATEST(0, products.quantity - (
+	SELECT COALESCE(SUM(r.quantity), 0) FROM stockReservations r
+	WHERE r.productId = products.id AND r.status = 'active' AND r.expiresAt > CURRENT_TIMESTAMP
+)) AS available FROM products`
+
 type Store struct {
-	db *sql.DB
+	db myDB.DBTX
 }
 
 func NewStore(db *sql.DB) *Store {
@@ -21,17 +31,23 @@ func NewStore(db *sql.DB) *Store {
 
 func (s *Store) GetProductById(id int) (types.Product, error) {
 	product := new(types.Product)
-	err := s.db.QueryRow("SELECT * FROM products where id = ?", id).Scan(productAllFieldsScanner(product))
+	err := s.db.QueryRow(selectProductsQuery+" WHERE id = ?", id).Scan(productAllFieldsScanner(product))
+
+	if err != nil {
+		return types.Product{}, err
+	}
 
+	images, err := s.GetProductImages(id)
 	if err != nil {
 		return types.Product{}, err
 	}
+	product.Images = images
 
 	return *product, nil
 }
 
 func (s *Store) GetProducts(limit, offset int) ([]types.Product, int, error) {
-	rows, err := s.db.Query("SELECT * FROM products LIMIT ? OFFSET ?", limit, offset)
+	rows, err := s.db.Query(selectProductsQuery+" WHERE deletedAt IS NULL LIMIT ? OFFSET ?", limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -52,7 +68,7 @@ func (s *Store) GetProducts(limit, offset int) ([]types.Product, int, error) {
 	}
 
 	var count int
-	err = s.db.QueryRow("SELECT COUNT(*) FROM products").Scan(&count)
+	err = s.db.QueryRow("SELECT COUNT(*) FROM products WHERE deletedAt IS NULL").Scan(&count)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -61,8 +77,12 @@ func (s *Store) GetProducts(limit, offset int) ([]types.Product, int, error) {
 }
 
 func (s *Store) GetProductsByID(productIDs []int) ([]types.Product, error) {
+	if len(productIDs) == 0 {
+		return []types.Product{}, nil
+	}
+
 	placeholders := strings.Repeat(",?", len(productIDs)-1)
-	query := fmt.Sprintf("SELECT * FROM products WHERE id IN (?%v)", placeholders)
+	query := fmt.Sprintf(selectProductsQuery+" WHERE id IN (?%v) AND deletedAt IS NULL", placeholders)
 
 	args := make([]interface{}, len(productIDs))
 	for i, val := range productIDs {
@@ -87,70 +107,346 @@ func (s *Store) GetProductsByID(productIDs []int) ([]types.Product, error) {
 	return products, nil
 }
 
+// the initial quantity is recorded as a restock so the stock ledger starts at the same value.
 func (s *Store) CreateProduct(payload types.ProductCreatePayload) (*types.Product, error) {
-	var query = "INSERT INTO products (name,description,image,price,quantity) VALUES(?,?,?,?,?)"
-	result, err := s.db.Exec(query, strings.TrimSpace(payload.Name), strings.TrimSpace(payload.Description), payload.Image, payload.Price, payload.Quantity)
+	var createdProd *types.Product
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		var query = "INSERT INTO products (sku,name,description,image,price,quantity,taxClass,weightGrams,lengthMm,widthMm,heightMm,categoryId) VALUES(?,?,?,?,?,0,?,?,?,?,?,?)"
+		result, err := tx.Exec(query, nullableSKU(payload.SKU), strings.TrimSpace(payload.Name), strings.TrimSpace(payload.Description), payload.Image, payload.Price,
+			taxClassOrStandard(payload.TaxClass), payload.WeightGrams, payload.LengthMm, payload.WidthMm, payload.HeightMm, nullableCategory(payload.CategoryID))
+		if err != nil {
+			return err
+		}
+
+		prodId, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		if err := moveStockTo(tx, int(prodId), 0, payload.Quantity, types.StockReasonRestock, "initial stock"); err != nil {
+			return err
+		}
+
+		var getProdQuery = selectProductsQuery+" WHERE id = ?"
+		createdProd, err = scanRowIntoProduct(tx.QueryRow(getProdQuery, prodId))
+		if err != nil {
+			return err
+		}
+
+		return outbox.Record(tx, types.AggregateProduct, createdProd.ID, types.EventProductCreated, createdProd)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	prodId, err := result.LastInsertId()
+	return createdProd, nil
+}
+
+// UpdateProduct only applies the changes when the product is still at the given version,
+// otherwise types.ErrProductVersionMismatch is returned. Every update bumps the version.
+// A new quantity is not overwritten, the difference is recorded as a stock adjustment.
+func (s *Store) UpdateProduct(id, version int, payload types.ProductPatchPayload) (*types.Product, error) {
+	var prodAfterUpdate *types.Product
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		var currentQuantity, currentVersion int
+		err := tx.QueryRow("SELECT quantity, version FROM products WHERE id = ? AND deletedAt IS NULL FOR UPDATE", id).
+			Scan(&currentQuantity, &currentVersion)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no product was found for id %v", id)
+		}
+		if err != nil {
+			return err
+		}
+		if currentVersion != version {
+			return types.ErrProductVersionMismatch
+		}
+
+		query := "UPDATE products SET"
+		updates, args := handleProductFields(payload)
+		updates = append(updates, "version = version + 1")
+
+		query += " " + strings.Join(updates, ", ") + " WHERE id = ?"
+		args = append(args, id)
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+
+		if payload.Quantity != nil {
+			err := moveStockTo(tx, id, currentQuantity, *payload.Quantity, types.StockReasonAdjustment, "quantity set through product update")
+			if err != nil {
+				return err
+			}
+		}
+
+		prodAfterUpdate, err = scanRowIntoProduct(tx.QueryRow(selectProductsQuery+" WHERE id = ?", id))
+		if err != nil {
+			return err
+		}
+
+		return outbox.Record(tx, types.AggregateProduct, id, types.EventProductUpdated, prodAfterUpdate)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var getProdQuery = "SELECT * FROM products WHERE id = ?"
-	row := s.db.QueryRow(getProdQuery, prodId)
-	if row.Err() != nil {
-		return nil, row.Err()
+	return prodAfterUpdate, nil
+}
+
+// DeleteProduct moves the product to the trash, it stays resolvable by id for the orders referencing it.
+func (s *Store) DeleteProduct(id int) error {
+	return myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		query := "UPDATE products SET deletedAt = CURRENT_TIMESTAMP WHERE id = ? AND deletedAt IS NULL"
+		result, err := tx.Exec(query, id)
+
+		if err != nil {
+			return err
+		}
+
+		RowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if RowsAffected == 0 {
+			return fmt.Errorf("no product was found for id %v", id)
+		}
+
+		return outbox.Record(tx, types.AggregateProduct, id, types.EventProductDeleted, types.ProductDeletedEvent{ProductID: id})
+	})
+}
+
+func (s *Store) GetTrashedProducts(limit, offset int) ([]types.Product, int, error) {
+	rows, err := s.db.Query(selectProductsQuery+" WHERE deletedAt IS NOT NULL ORDER BY deletedAt DESC LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	createdProd, err := scanRowIntoProduct(row)
+	defer rows.Close()
+
+	products := make([]types.Product, 0)
+	for rows.Next() {
+		prod, err := scanRowsIntoProducts(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		products = append(products, *prod)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM products WHERE deletedAt IS NOT NULL").Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return products, count, nil
+}
+
+func (s *Store) RestoreProduct(id int) (*types.Product, error) {
+	var product *types.Product
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		result, err := tx.Exec("UPDATE products SET deletedAt = NULL WHERE id = ? AND deletedAt IS NOT NULL", id)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("no trashed product was found for id %v", id)
+		}
+
+		product, err = scanRowIntoProduct(tx.QueryRow(selectProductsQuery+" WHERE id = ?", id))
+		if err != nil {
+			return err
+		}
+
+		return outbox.Record(tx, types.AggregateProduct, id, types.EventProductRestored, product)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return createdProd, nil
+	return product, nil
 }
 
-func (s *Store) UpdateProduct(id int, payload types.ProductUpdatePayload) (*types.Product, error) {
-	query := "UPDATE products SET"
-	updates, args := handleProductFields(payload)
+// returns the trashed products that no order item references, only those can be purged.
+func (s *Store) GetPurgeableProductIDs() ([]int, error) {
+	rows, err := s.db.Query(`
+	SELECT p.id FROM products p
+	WHERE p.deletedAt IS NOT NULL
+	AND NOT EXISTS (SELECT 1 FROM orderItems oi WHERE oi.productId = p.id)`)
+	if err != nil {
+		return nil, err
+	}
 
-	query += " " + strings.Join(updates, ", ") + " WHERE id = ?"
-	args = append(args, id)
+	defer rows.Close()
 
-	_, err := s.db.Exec(query, args...)
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// PurgeProduct permanently deletes a trashed product that was never ordered,
+// the removed images are returned so their files can be deleted too.
+func (s *Store) PurgeProduct(id int) ([]types.ProductImage, error) {
+	images, err := s.GetProductImages(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// the stock ledger and the warehouses stock go with the product, it was never ordered so nothing else refers to them.
+	err = myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		if _, err := tx.Exec("DELETE FROM warehouseStock WHERE productId = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM stockMovements WHERE productId = ?", id); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`
+		DELETE FROM products
+		WHERE id = ? AND deletedAt IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM orderItems WHERE productId = ?)`, id, id)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("product with id %v is not in the trash or is referenced by an order", id)
+		}
+
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	row := s.db.QueryRow("SELECT * FROM products WHERE id = ?", id)
-	prodAfterUpdate, err := scanRowIntoProduct(row)
+	return images, nil
+}
+
+func (s *Store) GetProductBySKU(sku string) (*types.Product, error) {
+	row := s.db.QueryRow(selectProductsQuery+" WHERE sku = ?", strings.TrimSpace(sku))
+	product, err := scanRowIntoProduct(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w for sku '%s'", types.ErrProductNotFound, sku)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return prodAfterUpdate, nil
+	return product, nil
 }
 
-func (s *Store) DeleteProduct(id int) error {
-	query := "DELETE FROM products WHERE id = ?"
-	result, err := s.db.Exec(query, id)
+// UpsertProductBySKU creates the product or replaces the one that has the same sku, the returned bool
+// reports whether it was created. Trashed products are updated but stay in the trash.
+func (s *Store) UpsertProductBySKU(payload types.ProductCreatePayload) (*types.Product, bool, error) {
+	var product *types.Product
+	created := false
+
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		var id, currentQuantity int
+		err := tx.QueryRow("SELECT id, quantity FROM products WHERE sku = ? FOR UPDATE", strings.TrimSpace(payload.SKU)).
+			Scan(&id, &currentQuantity)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		reason, note := types.StockReasonAdjustment, "quantity set through import"
+		if err == sql.ErrNoRows {
+			created = true
+			reason, note = types.StockReasonRestock, "initial stock"
+
+			result, err := tx.Exec(`
+			INSERT INTO products (sku, name, description, image, price, quantity, taxClass, weightGrams, lengthMm, widthMm, heightMm, categoryId)
+			VALUES (?,?,?,?,?,0,?,?,?,?,?,?)`,
+				nullableSKU(payload.SKU), strings.TrimSpace(payload.Name), strings.TrimSpace(payload.Description), payload.Image, payload.Price,
+				taxClassOrStandard(payload.TaxClass), payload.WeightGrams, payload.LengthMm, payload.WidthMm, payload.HeightMm,
+				nullableCategory(payload.CategoryID))
+			if err != nil {
+				return err
+			}
+
+			insertedId, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			id = int(insertedId)
+		} else {
+			_, err := tx.Exec(`
+			UPDATE products SET name = ?, description = ?, image = ?, price = ?, taxClass = ?, weightGrams = ?, lengthMm = ?, widthMm = ?,
+				heightMm = ?, categoryId = ?, version = version + 1
+			WHERE id = ?`,
+				strings.TrimSpace(payload.Name), strings.TrimSpace(payload.Description), payload.Image, payload.Price,
+				taxClassOrStandard(payload.TaxClass), payload.WeightGrams, payload.LengthMm, payload.WidthMm, payload.HeightMm,
+				nullableCategory(payload.CategoryID), id)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := moveStockTo(tx, id, currentQuantity, payload.Quantity, reason, note); err != nil {
+			return err
+		}
 
+		product, err = scanRowIntoProduct(tx.QueryRow(selectProductsQuery+" WHERE id = ?", id))
+		if err != nil {
+			return err
+		}
+
+		eventType := types.EventProductUpdated
+		if created {
+			eventType = types.EventProductCreated
+		}
+
+		return outbox.Record(tx, types.AggregateProduct, id, eventType, product)
+	})
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 
-	RowsAffected, err := result.RowsAffected()
+	return product, created, nil
+}
+
+// StreamProducts calls fn for every product that is not trashed, one row at a time,
+// so the catalog is never loaded into memory at once.
+func (s *Store) StreamProducts(fn func(product types.Product) error) error {
+	rows, err := s.db.Query(selectProductsQuery+" WHERE deletedAt IS NULL ORDER BY id")
 	if err != nil {
 		return err
 	}
-	if RowsAffected == 0 {
-		return fmt.Errorf("no product was found for id %v", id)
+
+	defer rows.Close()
+
+	for rows.Next() {
+		prod, err := scanRowsIntoProducts(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(*prod); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return rows.Err()
 }
 
 func scanRowsIntoProducts(rows *sql.Rows) (*types.Product, error) {
@@ -177,44 +473,290 @@ func scanRowIntoProduct(row *sql.Row) (*types.Product, error) {
 	return product, nil
 }
 
-func productAllFieldsScanner(product *types.Product) (*int, *string, *string, *string, *float64, *int, *time.Time, *time.Time) {
+// a product without a tax class is taxed at the standard rates.
+func taxClassOrStandard(taxClass string) string {
+	if taxClass = strings.TrimSpace(taxClass); taxClass == "" {
+		return types.TaxClassStandard
+	}
+
+	return taxClass
+}
+
+func productAllFieldsScanner(product *types.Product) (*int, *string, *string, *string, *money.Money, *int, *time.Time, *time.Time, **time.Time, *int, **string, **int, *string,
+	*int, *int, *int, *int, **int, *int) {
 	return &product.ID,
 		&product.Name,
 		&product.Description,
 		&product.Image, &product.Price,
 		&product.Quantity,
 		&product.CreatedAt,
-		&product.UpdatedAt
+		&product.UpdatedAt,
+		&product.DeletedAt,
+		&product.Version,
+		&product.SKU,
+		&product.LowStockThreshold,
+		&product.TaxClass,
+		&product.WeightGrams,
+		&product.LengthMm,
+		&product.WidthMm,
+		&product.HeightMm,
+		&product.CategoryID,
+		&product.Available
 }
 
-func handleProductFields(payload types.ProductUpdatePayload) ([]string, []any) {
+// every provided field is updated, even when it's a zero value. The quantity is left out
+// since it only changes through stock movements.
+func handleProductFields(payload types.ProductPatchPayload) ([]string, []any) {
 	var updates []string
 	var args []any
 
-	if payload.Name != "" {
+	if payload.SKU != nil {
+		updates = append(updates, "sku = ?")
+		args = append(args, nullableSKU(*payload.SKU))
+	}
+
+	if payload.Name != nil {
 		updates = append(updates, "name = ?")
-		args = append(args, strings.TrimSpace(payload.Name))
+		args = append(args, strings.TrimSpace(*payload.Name))
 	}
 
-	if payload.Description != "" {
+	if payload.Description != nil {
 		updates = append(updates, "description = ?")
-		args = append(args, strings.TrimSpace(payload.Description))
+		args = append(args, strings.TrimSpace(*payload.Description))
 	}
 
-	if payload.Image != "" {
+	if payload.Image != nil {
 		updates = append(updates, "image = ?")
-		args = append(args, payload.Image)
+		args = append(args, *payload.Image)
 	}
 
-	if payload.Price != 0 {
+	if payload.Price != nil {
 		updates = append(updates, "price = ?")
-		args = append(args, payload.Price)
+		args = append(args, *payload.Price)
+	}
+
+	if payload.TaxClass != nil {
+		updates = append(updates, "taxClass = ?")
+		args = append(args, taxClassOrStandard(*payload.TaxClass))
+	}
+
+	if payload.WeightGrams != nil {
+		updates = append(updates, "weightGrams = ?")
+		args = append(args, *payload.WeightGrams)
+	}
+
+	if payload.LengthMm != nil {
+		updates = append(updates, "lengthMm = ?")
+		args = append(args, *payload.LengthMm)
+	}
+
+	if payload.WidthMm != nil {
+		updates = append(updates, "widthMm = ?")
+		args = append(args, *payload.WidthMm)
 	}
 
-	if payload.Quantity != 0 {
-		updates = append(updates, "quantity = ?")
-		args = append(args, payload.Quantity)
+	if payload.HeightMm != nil {
+		updates = append(updates, "heightMm = ?")
+		args = append(args, *payload.HeightMm)
+	}
+
+	if payload.CategoryID != nil {
+		updates = append(updates, "categoryId = ?")
+		args = append(args, nullableCategory(*payload.CategoryID))
 	}
 
 	return updates, args
 }
+
+// records the movement that takes the product from the current to the target quantity, if any.
+func moveStockTo(tx myDB.DBTX, productID, currentQuantity, targetQuantity int, reason, note string) error {
+	if targetQuantity == currentQuantity {
+		return nil
+	}
+
+	_, err := inventory.ApplyMovement(tx, types.StockMovement{
+		ProductID:      productID,
+		QuantityChange: targetQuantity - currentQuantity,
+		Reason:         reason,
+		Note:           note,
+	})
+
+	return err
+}
+
+func (s *Store) GetProductImages(productID int) ([]types.ProductImage, error) {
+	rows, err := s.db.Query("SELECT * FROM productImages WHERE productId = ? ORDER BY position, id", productID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	images := make([]types.ProductImage, 0)
+	for rows.Next() {
+		image := new(types.ProductImage)
+		if err := rows.Scan(productImageAllFieldsScanner(image)); err != nil {
+			return nil, err
+		}
+
+		images = append(images, *image)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func (s *Store) GetProductImage(productID, imageID int) (*types.ProductImage, error) {
+	image := new(types.ProductImage)
+	err := s.db.QueryRow("SELECT * FROM productImages WHERE id = ? AND productId = ?", imageID, productID).
+		Scan(productImageAllFieldsScanner(image))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no image was found for id %v", imageID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// the new image is appended after the existing images of the product.
+func (s *Store) CreateProductImage(image types.ProductImage) (*types.ProductImage, error) {
+	var imageId int64
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		query := `
+		INSERT INTO productImages (productId, url, thumbnailUrl, storageKey, thumbnailKey, altText, position)
+		SELECT ?, ?, ?, ?, ?, ?, COALESCE(MAX(position) + 1, 0) FROM productImages WHERE productId = ?`
+		result, err := tx.Exec(query, image.ProductID, image.URL, image.ThumbnailURL, image.StorageKey,
+			image.ThumbnailKey, strings.TrimSpace(image.AltText), image.ProductID)
+		if err != nil {
+			return err
+		}
+
+		imageId, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		return bumpProductVersion(tx, image.ProductID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProductImage(image.ProductID, int(imageId))
+}
+
+func (s *Store) UpdateProductImageAltText(productID, imageID int, altText string) (*types.ProductImage, error) {
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		_, err := tx.Exec("UPDATE productImages SET altText = ? WHERE id = ? AND productId = ?",
+			strings.TrimSpace(altText), imageID, productID)
+		if err != nil {
+			return err
+		}
+
+		return bumpProductVersion(tx, productID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProductImage(productID, imageID)
+}
+
+// imageIDs must contain every image of the product exactly once, its order becomes the new positions.
+func (s *Store) ReorderProductImages(productID int, imageIDs []int) ([]types.ProductImage, error) {
+	images, err := s.GetProductImages(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[int]bool, len(images))
+	for _, image := range images {
+		existing[image.ID] = true
+	}
+
+	if len(imageIDs) != len(images) {
+		return nil, fmt.Errorf("expected %v image ids received %v", len(images), len(imageIDs))
+	}
+	for _, id := range imageIDs {
+		if !existing[id] {
+			return nil, fmt.Errorf("image with id %v does not belong to product %v or is duplicated", id, productID)
+		}
+		delete(existing, id)
+	}
+
+	err = myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		for position, id := range imageIDs {
+			_, err := tx.Exec("UPDATE productImages SET position = ? WHERE id = ? AND productId = ?", position, id, productID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return bumpProductVersion(tx, productID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProductImages(productID)
+}
+
+func (s *Store) DeleteProductImage(productID, imageID int) error {
+	return myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		result, err := tx.Exec("DELETE FROM productImages WHERE id = ? AND productId = ?", imageID, productID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("no image was found for id %v", imageID)
+		}
+
+		return bumpProductVersion(tx, productID)
+	})
+}
+
+// the images are part of the product representation, so changing them changes its ETag too.
+func bumpProductVersion(q myDB.DBTX, productID int) error {
+	_, err := q.Exec("UPDATE products SET version = version + 1 WHERE id = ?", productID)
+	return err
+}
+
+func productImageAllFieldsScanner(image *types.ProductImage) (*int, *int, *string, *string, *string, *string, *string, *int, *time.Time) {
+	return &image.ID,
+		&image.ProductID,
+		&image.URL,
+		&image.ThumbnailURL,
+		&image.StorageKey,
+		&image.ThumbnailKey,
+		&image.AltText,
+		&image.Position,
+		&image.CreatedAt
+}
+
+// empty skus are stored as NULL so they don't collide on the unique key.
+func nullableSKU(sku string) any {
+	sku = strings.TrimSpace(sku)
+	if sku == "" {
+		return nil
+	}
+
+	return sku
+}
+
+// a category id of 0 means the product has no category.
+func nullableCategory(categoryID int) any {
+	if categoryID == 0 {
+		return nil
+	}
+
+	return categoryID
+}
@@ -0,0 +1,225 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestSoftDelete(t *testing.T) {
+	t.Run("Should hide the trashed products from the listings and the checkout", func(t *testing.T) {
+		conn := &fakeConn{rows: map[string][][]driver.Value{"SELECT COUNT(*)": {{int64(0)}}}}
+		store := NewStore(sql.OpenDB(conn))
+
+		if _, _, err := store.GetProducts(10, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.GetProductsByID([]int{1, 2}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.StreamProducts(func(product types.Product) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, query := range conn.queries {
+			if strings.Contains(query, "FROM products") && !strings.Contains(query, "deletedAt IS NULL") {
+				t.Errorf("expected the trashed products to be left out of %q", query)
+			}
+		}
+	})
+
+	t.Run("Should move the product to the trash along with its event", func(t *testing.T) {
+		conn := &fakeConn{affected: map[string]int64{"UPDATE products": 1}}
+
+		if err := NewStore(sql.OpenDB(conn)).DeleteProduct(1); err != nil {
+			t.Fatal(err)
+		}
+		if !conn.ran("deletedAt = CURRENT_TIMESTAMP WHERE id = ? AND deletedAt IS NULL") || !conn.ran("INSERT INTO outbox") {
+			t.Errorf("expected the product to be trashed with its event got %v", conn.queries)
+		}
+		if !conn.committed {
+			t.Error("expected the transaction to be committed")
+		}
+	})
+
+	t.Run("Should refuse to trash a product twice", func(t *testing.T) {
+		conn := &fakeConn{}
+
+		if err := NewStore(sql.OpenDB(conn)).DeleteProduct(1); err == nil {
+			t.Fatal("expected an error")
+		}
+		if conn.ran("INSERT INTO outbox") || conn.committed {
+			t.Errorf("expected the transaction to be rolled back got %v", conn.queries)
+		}
+	})
+
+	t.Run("Should only restore a trashed product", func(t *testing.T) {
+		conn := &fakeConn{}
+
+		if _, err := NewStore(sql.OpenDB(conn)).RestoreProduct(1); err == nil {
+			t.Fatal("expected an error")
+		}
+		if !conn.ran("SET deletedAt = NULL WHERE id = ? AND deletedAt IS NOT NULL") || conn.committed {
+			t.Errorf("expected the restore to be rolled back got %v", conn.queries)
+		}
+	})
+}
+
+func TestGetProductsByID(t *testing.T) {
+	t.Run("Should not query the products of an empty list", func(t *testing.T) {
+		conn := &fakeConn{}
+
+		products, err := NewStore(sql.OpenDB(conn)).GetProductsByID([]int{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(products) != 0 || len(conn.queries) != 0 {
+			t.Errorf("expected no products and no query got %v and %v", products, conn.queries)
+		}
+	})
+}
+
+func TestPurge(t *testing.T) {
+	t.Run("Should list the trashed products that were never ordered", func(t *testing.T) {
+		conn := &fakeConn{rows: map[string][][]driver.Value{"SELECT p.id": {{int64(3)}, {int64(5)}}}}
+
+		ids, err := NewStore(sql.OpenDB(conn)).GetPurgeableProductIDs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids, []int{3, 5}) {
+			t.Errorf("expected the products 3 and 5 got %v", ids)
+		}
+		if !conn.ran("p.deletedAt IS NOT NULL") || !conn.ran("NOT EXISTS (SELECT 1 FROM orderItems") {
+			t.Errorf("expected only the trashed products never ordered got %v", conn.queries)
+		}
+	})
+
+	t.Run("Should delete the product with its stock", func(t *testing.T) {
+		conn := &fakeConn{affected: map[string]int64{"DELETE FROM products": 1}}
+
+		if _, err := NewStore(sql.OpenDB(conn)).PurgeProduct(3); err != nil {
+			t.Fatal(err)
+		}
+		if !conn.ran("DELETE FROM warehouseStock") || !conn.ran("DELETE FROM stockMovements") || !conn.committed {
+			t.Errorf("expected the stock to be deleted with the product got %v", conn.queries)
+		}
+	})
+
+	t.Run("Should keep a product that is not trashed or was ordered", func(t *testing.T) {
+		conn := &fakeConn{}
+
+		if _, err := NewStore(sql.OpenDB(conn)).PurgeProduct(3); err == nil {
+			t.Fatal("expected an error")
+		}
+		if !conn.ran("AND deletedAt IS NOT NULL") || conn.committed {
+			t.Errorf("expected the purge to be rolled back got %v", conn.queries)
+		}
+	})
+}
+
+// fakeConn is a database that records the statements it runs. Exec affects the rows of an
+// entry of affected found in the query and Query returns the rows of an entry of rows found in it.
+type fakeConn struct {
+	affected  map[string]int64
+	rows      map[string][][]driver.Value
+	queries   []string
+	committed bool
+}
+
+func (c *fakeConn) ran(fragment string) bool {
+	return slices.ContainsFunc(c.queries, func(query string) bool { return strings.Contains(query, fragment) })
+}
+
+func (c *fakeConn) Connect(ctx context.Context) (driver.Conn, error) {
+	return c, nil
+}
+
+func (c *fakeConn) Driver() driver.Driver {
+	return nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.committed = true
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+// the arguments aren't checked.
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.queries = append(s.conn.queries, s.query)
+	for fragment, affected := range s.conn.affected {
+		if strings.Contains(s.query, fragment) {
+			return driver.RowsAffected(affected), nil
+		}
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.queries = append(s.conn.queries, s.query)
+	for fragment, rows := range s.conn.rows {
+		if strings.Contains(s.query, fragment) {
+			return &fakeRows{values: rows}, nil
+		}
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.values) == 0 {
+		return nil
+	}
+	return make([]string, len(r.values[0]))
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -4,9 +4,28 @@ import (
 	"github.com/mohammadahmadkhader/golang-ecommerce/types"
 )
 
-func IsProductUpdatePayloadEmpty(payload types.ProductUpdatePayload) bool {
-	if payload.Name == "" && payload.Description == "" && payload.Image == "" && payload.Price == 0 && payload.Quantity == 0 {
+func IsProductPatchPayloadEmpty(payload types.ProductPatchPayload) bool {
+	if payload.SKU == nil && payload.Name == nil && payload.Description == nil && payload.Image == nil && payload.Price == nil &&
+		payload.Quantity == nil && payload.TaxClass == nil && payload.WeightGrams == nil && payload.LengthMm == nil &&
+		payload.WidthMm == nil && payload.HeightMm == nil && payload.CategoryID == nil {
 		return true
 	}
 	return false
+}
+
+// the optional fields of a PUT are reset to their defaults when they're omitted, so the product is fully replaced.
+func applyProductUpdateDefaults(payload *types.ProductUpdatePayload) {
+	zero := 0
+	if payload.SKU == nil {
+		payload.SKU = new(string)
+	}
+	if payload.TaxClass == nil {
+		taxClass := types.TaxClassStandard
+		payload.TaxClass = &taxClass
+	}
+	for _, field := range []**int{&payload.WeightGrams, &payload.LengthMm, &payload.WidthMm, &payload.HeightMm, &payload.CategoryID} {
+		if *field == nil {
+			*field = &zero
+		}
+	}
 }
\ No newline at end of file
@@ -0,0 +1,205 @@
+package promotion
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/middlewares"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+type Handler struct {
+	store types.PromotionStore
+}
+
+func NewHandler(store types.PromotionStore) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/promotions", auth.AdminMiddleware(middlewares.PaginationMiddleware(h.GetPromotions))).Methods("GET")
+	router.HandleFunc("/admin/promotions", auth.AdminMiddleware(h.CreatePromotion)).Methods("POST")
+	router.HandleFunc("/admin/promotions/{id}", auth.AdminMiddleware(h.GetPromotion)).Methods("GET")
+	router.HandleFunc("/admin/promotions/{id}", auth.AdminMiddleware(h.UpdatePromotion)).Methods("PUT")
+	router.HandleFunc("/admin/promotions/{id}", auth.AdminMiddleware(h.DeletePromotion)).Methods("DELETE")
+}
+
+func (h *Handler) GetPromotions(w http.ResponseWriter, r *http.Request) {
+	pagination := middlewares.GetPagination(r)
+	offset := middlewares.CalculateOffset(pagination)
+
+	promotions, count, err := h.store.GetPromotions(pagination.Limit, offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK,
+		map[string]any{
+			"promotions": promotions,
+			"page":       pagination.Page,
+			"limit":      pagination.Limit,
+			"count":      count,
+		})
+}
+
+func (h *Handler) GetPromotion(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	promotion, err := h.store.GetPromotionById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    promotion,
+	})
+}
+
+func (h *Handler) CreatePromotion(w http.ResponseWriter, r *http.Request) {
+	promotion, err := parsePromotionPayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	created, err := h.store.CreatePromotion(promotion)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    created,
+	})
+}
+
+// PUT replaces the promotion, carts already checked out keep the prices they got.
+func (h *Handler) UpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	promotion, err := parsePromotionPayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := h.store.UpdatePromotion(id, promotion)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    updated,
+	})
+}
+
+func (h *Handler) DeletePromotion(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DeletePromotion(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusNoContent, map[string]any{})
+}
+
+func parsePromotionPayload(r *http.Request) (types.Promotion, error) {
+	var payload types.PromotionPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return types.Promotion{}, err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return types.Promotion{}, err
+	}
+
+	if err := validateRules(payload.Type, payload.Rules); err != nil {
+		return types.Promotion{}, err
+	}
+	if payload.StartsAt != nil && payload.EndsAt != nil && !payload.EndsAt.After(*payload.StartsAt) {
+		return types.Promotion{}, fmt.Errorf("endsAt must be after startsAt")
+	}
+
+	active := true
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+
+	return types.Promotion{
+		Name:     payload.Name,
+		Type:     payload.Type,
+		Priority: payload.Priority,
+		Rules:    payload.Rules,
+		StartsAt: payload.StartsAt,
+		EndsAt:   payload.EndsAt,
+		Active:   active,
+	}, nil
+}
+
+// checks the rules the promotion type reads are set.
+func validateRules(promotionType string, rules types.PromotionRules) error {
+	switch promotionType {
+	case types.PromotionTypeBuyXGetY:
+		if len(rules.ProductIDs) == 0 || rules.BuyQuantity <= 0 || rules.GetQuantity <= 0 {
+			return fmt.Errorf("buy_x_get_y needs productIds, buyQuantity and getQuantity")
+		}
+	case types.PromotionTypeOrderPercentage:
+		if rules.Percentage <= 0 {
+			return fmt.Errorf("order_percentage needs a percentage")
+		}
+	case types.PromotionTypeBundle:
+		if len(rules.ProductIDs) < 2 {
+			return fmt.Errorf("a bundle needs at least 2 productIds")
+		}
+		seen := make(map[int]bool)
+		for _, productID := range rules.ProductIDs {
+			if seen[productID] {
+				return fmt.Errorf("product %d is in the bundle twice", productID)
+			}
+			seen[productID] = true
+		}
+	case types.PromotionTypeQuantityTier:
+		if len(rules.ProductIDs) == 0 || len(rules.Tiers) == 0 {
+			return fmt.Errorf("quantity_tier needs productIds and tiers")
+		}
+	}
+
+	return nil
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("promotion id must be unsigned integer")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,112 @@
+package promotion
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestCreatePromotion(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"Should create a bundle", `{"name":"Desk set","type":"bundle","rules":{"productIds":[1,2],"bundlePrice":20}}`, http.StatusCreated},
+		{"Should create a quantity tier", `{"name":"Bulk","type":"quantity_tier","rules":{"productIds":[1],"tiers":[{"minQuantity":3,"unitPrice":9}]}}`, http.StatusCreated},
+		{"Should refuse an unknown type", `{"name":"Sale","type":"clearance","rules":{"percentage":10}}`, http.StatusBadRequest},
+		{"Should refuse a bundle of one product", `{"name":"Desk set","type":"bundle","rules":{"productIds":[1],"bundlePrice":20}}`, http.StatusBadRequest},
+		{"Should refuse a product twice in a bundle", `{"name":"Desk set","type":"bundle","rules":{"productIds":[1,1],"bundlePrice":20}}`, http.StatusBadRequest},
+		{"Should refuse a buy x get y without quantities", `{"name":"2 for 1","type":"buy_x_get_y","rules":{"productIds":[1]}}`, http.StatusBadRequest},
+		{"Should refuse an order percentage without a percentage", `{"name":"Sale","type":"order_percentage","rules":{}}`, http.StatusBadRequest},
+		{"Should refuse a percentage above 100", `{"name":"Sale","type":"order_percentage","rules":{"percentage":150}}`, http.StatusBadRequest},
+		{"Should refuse a quantity tier without tiers", `{"name":"Bulk","type":"quantity_tier","rules":{"productIds":[1]}}`, http.StatusBadRequest},
+		{"Should refuse an end before the start", `{"name":"Sale","type":"order_percentage","rules":{"percentage":10},"startsAt":"2024-11-10T00:00:00Z","endsAt":"2024-11-09T00:00:00Z"}`, http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := &mockPromotionStore{}
+			recorder := send(t, NewHandler(store), http.MethodPost, "/admin/promotions", c.body, types.UserRoleAdmin)
+			if recorder.Code != c.status {
+				t.Fatalf("expected status code %d got %d: %s", c.status, recorder.Code, recorder.Body)
+			}
+			if c.status == http.StatusCreated && !store.saved.Active {
+				t.Errorf("expected the promotion to be active by default got %+v", store.saved)
+			}
+		})
+	}
+}
+
+func TestUpdatePromotion(t *testing.T) {
+	t.Run("Should replace the promotion", func(t *testing.T) {
+		store := &mockPromotionStore{}
+		body := `{"name":"Sale","type":"order_percentage","priority":2,"rules":{"percentage":10},"active":false}`
+
+		recorder := send(t, NewHandler(store), http.MethodPut, "/admin/promotions/3", body, types.UserRoleAdmin)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+		if store.saved.ID != 3 || store.saved.Priority != 2 || store.saved.Active {
+			t.Errorf("expected promotion 3 to be replaced got %+v", store.saved)
+		}
+	})
+
+	t.Run("Should refuse an invalid id", func(t *testing.T) {
+		recorder := send(t, NewHandler(&mockPromotionStore{}), http.MethodPut, "/admin/promotions/0", `{}`, types.UserRoleAdmin)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+}
+
+func TestPromotionsAreAdminOnly(t *testing.T) {
+	body := `{"name":"Sale","type":"order_percentage","rules":{"percentage":10}}`
+	recorder := send(t, NewHandler(&mockPromotionStore{}), http.MethodPost, "/admin/promotions", body, types.UserRoleCustomer)
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("expected status code %d got %d", http.StatusForbidden, recorder.Code)
+	}
+}
+
+func send(t *testing.T, handler *Handler, method, path, body, role string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), types.User{ID: 1, Role: role})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", token)
+
+	recorder := httptest.NewRecorder()
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+	router.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+type mockPromotionStore struct {
+	types.PromotionStore
+	saved types.Promotion
+}
+
+func (m *mockPromotionStore) CreatePromotion(promotion types.Promotion) (*types.Promotion, error) {
+	promotion.ID = 1
+	m.saved = promotion
+	return &promotion, nil
+}
+
+func (m *mockPromotionStore) UpdatePromotion(id int, promotion types.Promotion) (*types.Promotion, error) {
+	promotion.ID = id
+	m.saved = promotion
+	return &promotion, nil
+}
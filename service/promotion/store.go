@@ -0,0 +1,151 @@
+package promotion
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) GetPromotions(limit, offset int) ([]types.Promotion, int, error) {
+	rows, err := s.db.Query("SELECT * FROM promotions ORDER BY priority, id LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	promotions, err := scanPromotions(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM promotions").Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return promotions, count, nil
+}
+
+// returns the promotions running at the given time, by ascending priority then id.
+func (s *Store) GetActivePromotions(at time.Time) ([]types.Promotion, error) {
+	rows, err := s.db.Query(`
+	SELECT * FROM promotions WHERE active = TRUE
+	AND (startsAt IS NULL OR startsAt <= ?) AND (endsAt IS NULL OR endsAt > ?)
+	ORDER BY priority, id`, at, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPromotions(rows)
+}
+
+func (s *Store) GetPromotionById(id int) (*types.Promotion, error) {
+	rows, err := s.db.Query("SELECT * FROM promotions WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	promotions, err := scanPromotions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(promotions) == 0 {
+		return nil, fmt.Errorf("no promotion was found for id %v", id)
+	}
+
+	return &promotions[0], nil
+}
+
+func (s *Store) CreatePromotion(promotion types.Promotion) (*types.Promotion, error) {
+	rules, err := json.Marshal(promotion.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`
+	INSERT INTO promotions (name, type, priority, rules, startsAt, endsAt, active) VALUES (?,?,?,?,?,?,?)`,
+		promotion.Name, promotion.Type, promotion.Priority, rules, promotion.StartsAt, promotion.EndsAt, promotion.Active)
+	if err != nil {
+		return nil, err
+	}
+
+	promotionId, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetPromotionById(int(promotionId))
+}
+
+func (s *Store) UpdatePromotion(id int, promotion types.Promotion) (*types.Promotion, error) {
+	rules, err := json.Marshal(promotion.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	// MySQL reports no affected rows when nothing changed, a missing promotion is caught when it's read back.
+	_, err = s.db.Exec(`
+	UPDATE promotions SET name = ?, type = ?, priority = ?, rules = ?, startsAt = ?, endsAt = ?, active = ? WHERE id = ?`,
+		promotion.Name, promotion.Type, promotion.Priority, rules, promotion.StartsAt, promotion.EndsAt, promotion.Active, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetPromotionById(id)
+}
+
+func (s *Store) DeletePromotion(id int) error {
+	result, err := s.db.Exec("DELETE FROM promotions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no promotion was found for id %v", id)
+	}
+
+	return nil
+}
+
+func scanPromotions(rows *sql.Rows) ([]types.Promotion, error) {
+	defer rows.Close()
+
+	promotions := make([]types.Promotion, 0)
+	for rows.Next() {
+		promotion := new(types.Promotion)
+		var rules []byte
+		err := rows.Scan(&promotion.ID, &promotion.Name, &promotion.Type, &promotion.Priority, &rules,
+			&promotion.StartsAt, &promotion.EndsAt, &promotion.Active, &promotion.CreatedAt, &promotion.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(rules, &promotion.Rules); err != nil {
+			return nil, fmt.Errorf("promotion %d has invalid rules: %w", promotion.ID, err)
+		}
+
+		promotions = append(promotions, *promotion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return promotions, nil
+}
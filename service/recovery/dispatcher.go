@@ -0,0 +1,45 @@
+package recovery
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// an order placed within this long of a cart reminder is attributed to the reminder.
+const attributionWindow = 7 * 24 * time.Hour
+
+// Dispatcher is the broker that converts the carts of the completed orders, the relay publishes to it along
+// with the event broker. The cart is left as it is until the order is paid, so a declined or abandoned payment
+// doesn't lose it.
+type Dispatcher struct {
+	cartStore types.CartStore
+}
+
+func NewDispatcher(cartStore types.CartStore) *Dispatcher {
+	return &Dispatcher{
+		cartStore: cartStore,
+	}
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, event types.DomainEvent) error {
+	if event.Type != types.EventOrderStatusChanged {
+		return nil
+	}
+
+	var changed types.OrderStatusChangedEvent
+	if err := json.Unmarshal(event.Payload, &changed); err != nil {
+		return err
+	}
+	if changed.To != types.OrderStatusCompleted {
+		return nil
+	}
+
+	return d.cartStore.ConvertCart(changed.UserID, changed.OrderID, event.OccurredAt.Add(-attributionWindow))
+}
+
+func (d *Dispatcher) Close() error {
+	return nil
+}
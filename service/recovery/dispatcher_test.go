@@ -0,0 +1,52 @@
+package recovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestDispatcher(t *testing.T) {
+	occurredAt := time.Date(2024, 11, 11, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name      string
+		eventType string
+		payload   any
+		converted bool
+	}{
+		{"Should convert the cart of a completed order", types.EventOrderStatusChanged,
+			types.OrderStatusChangedEvent{OrderID: 7, UserID: 3, From: "pending", To: "completed"}, true},
+		{"Should keep the cart of a cancelled order", types.EventOrderStatusChanged,
+			types.OrderStatusChangedEvent{OrderID: 7, UserID: 3, From: "pending", To: "cancelled"}, false},
+		{"Should keep the cart of a placed order until it's paid", types.EventOrderPlaced,
+			types.OrderPlacedEvent{Order: types.Order{ID: 7, UserID: 3}}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cartStore := &mockCartStore{}
+			payload, _ := json.Marshal(c.payload)
+
+			err := NewDispatcher(cartStore).Publish(context.Background(), types.DomainEvent{ID: 9, Type: c.eventType, Payload: payload, OccurredAt: occurredAt})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !c.converted {
+				if len(cartStore.converted) != 0 {
+					t.Errorf("expected the cart to be kept got %v", cartStore.converted)
+				}
+				return
+			}
+
+			expected := fmt.Sprint(3, 7, occurredAt.Add(-attributionWindow))
+			if len(cartStore.converted) != 1 || cartStore.converted[0] != expected {
+				t.Errorf("expected the order 7 of user 3 to be converted got %v", cartStore.converted)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package recovery
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/outbox"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+const (
+	remindBatchSize  = 100
+	couponCodePrefix = "BACK"
+	couponCodeLength = 8
+	couponAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+var defaultThresholds = []time.Duration{time.Hour, 24 * time.Hour, 72 * time.Hour}
+
+// Reminder sends the reminders of the abandoned carts, the cart of a user gets the reminder of a step once
+// it has been idle for the threshold of the step. The last reminder comes with a one-time coupon unless the
+// coupon percentage is 0. A reminder is recorded along with its coupon and the cart abandoned event the
+// email is queued from, all in one transaction.
+type Reminder struct {
+	db             myDB.DBTX
+	cartStore      types.CartStore
+	couponStore    types.CouponStore
+	thresholds     []time.Duration
+	couponPercent  float64
+	couponValidity time.Duration
+	secret         string
+	baseURL        string
+	now            func() time.Time
+}
+
+func NewReminder(db myDB.DBTX, cartStore types.CartStore, couponStore types.CouponStore) *Reminder {
+	thresholds, err := ParseThresholds(config.Envs.AbandonedCartThresholds)
+	if err != nil {
+		thresholds = defaultThresholds
+	}
+
+	couponPercent, err := strconv.ParseFloat(config.Envs.AbandonedCartCouponPercent, 64)
+	if err != nil || couponPercent < 0 || couponPercent > 100 {
+		couponPercent = 0
+	}
+
+	validHours, err := strconv.Atoi(config.Envs.AbandonedCartCouponValidHours)
+	if err != nil || validHours <= 0 {
+		validHours = 72
+	}
+
+	return &Reminder{
+		db:             db,
+		cartStore:      cartStore,
+		couponStore:    couponStore,
+		thresholds:     thresholds,
+		couponPercent:  couponPercent,
+		couponValidity: time.Duration(validHours) * time.Hour,
+		secret:         config.Envs.JWTSecret,
+		baseURL:        config.Envs.PublicAPIURL,
+		now:            time.Now,
+	}
+}
+
+// ParseThresholds parses a comma separated list of increasing durations like 1h,24h,72h.
+func ParseThresholds(spec string) ([]time.Duration, error) {
+	thresholds := make([]time.Duration, 0)
+	for _, field := range strings.Split(spec, ",") {
+		threshold, err := time.ParseDuration(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		if threshold <= 0 || (len(thresholds) > 0 && threshold <= thresholds[len(thresholds)-1]) {
+			return nil, fmt.Errorf("the thresholds must be positive and increasing, got '%s'", spec)
+		}
+
+		thresholds = append(thresholds, threshold)
+	}
+
+	return thresholds, nil
+}
+
+// Remind sends the reminders that are due and returns how many were sent.
+func (r *Reminder) Remind(ctx context.Context) (int, error) {
+	now := r.now()
+	reminded := 0
+	for i, threshold := range r.thresholds {
+		step := i + 1
+		// the reminders of a cart that was idle for longer than several thresholds are spaced out as if
+		// they were sent on time.
+		remindedBefore := now
+		if i > 0 {
+			remindedBefore = now.Add(r.thresholds[i-1] - threshold)
+		}
+
+		for {
+			if ctx.Err() != nil {
+				return reminded, ctx.Err()
+			}
+
+			carts, err := r.cartStore.GetAbandonedCarts(step, now.Add(-threshold), remindedBefore, remindBatchSize)
+			if err != nil {
+				return reminded, err
+			}
+
+			sent := 0
+			for _, cart := range carts {
+				created, err := r.remind(cart, step, now)
+				if err != nil {
+					return reminded, fmt.Errorf("cart %d: %w", cart.ID, err)
+				}
+				if created {
+					sent++
+				}
+			}
+			reminded += sent
+
+			// the carts another worker reminded meanwhile would come back forever.
+			if len(carts) < remindBatchSize || sent == 0 {
+				break
+			}
+		}
+	}
+
+	return reminded, nil
+}
+
+// remind records the reminder of the step, it returns false when the cart was already reminded of it.
+func (r *Reminder) remind(cart types.Cart, step int, now time.Time) (bool, error) {
+	reminder := types.CartReminder{
+		CartID:     cart.ID,
+		UserID:     cart.UserID,
+		ActivityAt: cart.LastActivityAt,
+		Step:       step,
+		Items:      cart.Items,
+	}
+	if step == len(r.thresholds) && r.couponPercent > 0 {
+		code, err := couponCode()
+		if err != nil {
+			return false, err
+		}
+		reminder.CouponCode = &code
+	}
+
+	created := false
+	err := myDB.InTx(r.db, func(tx myDB.DBTX) error {
+		recorded, ok, err := r.cartStore.WithTx(tx).CreateCartReminder(reminder)
+		if err != nil || !ok {
+			return err
+		}
+
+		event := types.CartAbandonedEvent{
+			ReminderID: recorded.ID,
+			CartID:     cart.ID,
+			UserID:     cart.UserID,
+			Step:       step,
+			Items:      cart.Items,
+			RestoreURL: restoreURL(r.baseURL, r.secret, recorded.ID, now.Add(restoreTokenValidity)),
+		}
+
+		if reminder.CouponCode != nil {
+			one := 1
+			endsAt := now.Add(r.couponValidity)
+			coupon, err := r.couponStore.WithTx(tx).CreateCoupon(types.Coupon{
+				Code:         *reminder.CouponCode,
+				Type:         types.CouponTypePercentage,
+				Percentage:   r.couponPercent,
+				UsageLimit:   &one,
+				PerUserLimit: &one,
+				EndsAt:       &endsAt,
+				Active:       true,
+			})
+			if err != nil {
+				return err
+			}
+
+			event.CouponCode = coupon.Code
+			event.CouponPercentage = coupon.Percentage
+			event.CouponEndsAt = coupon.EndsAt
+		}
+
+		created = true
+		return outbox.Record(tx, types.AggregateCart, cart.ID, types.EventCartAbandoned, event)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return created, nil
+}
+
+// couponCode returns a random code that is hard to guess and easy to type.
+func couponCode() (string, error) {
+	var code strings.Builder
+	code.WriteString(couponCodePrefix)
+	for range couponCodeLength {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(couponAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(couponAlphabet[n.Int64()])
+	}
+
+	return code.String(), nil
+}
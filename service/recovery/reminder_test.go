@@ -0,0 +1,243 @@
+package recovery
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestParseThresholds(t *testing.T) {
+	thresholds, err := ParseThresholds("1h, 24h,72h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thresholds) != 3 || thresholds[0] != time.Hour || thresholds[2] != 72*time.Hour {
+		t.Errorf("expected 1h, 24h and 72h got %v", thresholds)
+	}
+
+	for _, spec := range []string{"", "1h,soon", "24h,1h", "0s,1h"} {
+		if _, err := ParseThresholds(spec); err == nil {
+			t.Errorf("expected an error for '%s'", spec)
+		}
+	}
+}
+
+func TestRemind(t *testing.T) {
+	now := time.Date(2024, 11, 9, 12, 0, 0, 0, time.UTC)
+	newReminder := func(cartStore *mockCartStore, couponPercent float64) (*Reminder, *mockCouponStore, *mockDB) {
+		couponStore := &mockCouponStore{}
+		db := &mockDB{}
+		return &Reminder{
+			db:             db,
+			cartStore:      cartStore,
+			couponStore:    couponStore,
+			thresholds:     []time.Duration{time.Hour, 24 * time.Hour, 72 * time.Hour},
+			couponPercent:  couponPercent,
+			couponValidity: 72 * time.Hour,
+			secret:         "secret",
+			baseURL:        "https://shop.example.com/api/v1",
+			now:            func() time.Time { return now },
+		}, couponStore, db
+	}
+
+	t.Run("Should remind each cart of the step it's due for", func(t *testing.T) {
+		cartStore := &mockCartStore{abandoned: map[int][]types.Cart{
+			1: {{ID: 1, UserID: 10, Items: []types.CartItem{{ProductID: 5, Quantity: 1}}}},
+			3: {{ID: 2, UserID: 20, Items: []types.CartItem{{ProductID: 6, Quantity: 2}}}},
+		}}
+		reminder, couponStore, db := newReminder(cartStore, 10)
+
+		reminded, err := reminder.Remind(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reminded != 2 || len(db.events) != 2 {
+			t.Fatalf("expected 2 reminders and events got %d and %d", reminded, len(db.events))
+		}
+
+		first := db.events[0]
+		if first.Step != 1 || first.UserID != 10 || first.CouponCode != "" {
+			t.Errorf("expected the first step without a coupon got %+v", first)
+		}
+		if !strings.HasPrefix(first.RestoreURL, "https://shop.example.com/api/v1/cart/restore?token=") {
+			t.Errorf("expected the restore link got %s", first.RestoreURL)
+		}
+		token := strings.TrimPrefix(first.RestoreURL, "https://shop.example.com/api/v1/cart/restore?token=")
+		if _, err := parseRestoreToken("secret", token, now.Add(restoreTokenValidity)); err != nil {
+			t.Errorf("expected the restore link to be valid until it expires got %v", err)
+		}
+		if _, err := parseRestoreToken("secret", token, now.Add(restoreTokenValidity+time.Second)); err != errRestoreTokenExpired {
+			t.Errorf("expected the restore link to expire got %v", err)
+		}
+
+		last := db.events[1]
+		if len(couponStore.created) != 1 || last.Step != 3 || last.CouponCode != couponStore.created[0].Code {
+			t.Fatalf("expected the last step to come with the coupon got %+v", last)
+		}
+		coupon := couponStore.created[0]
+		if coupon.Percentage != 10 || *coupon.UsageLimit != 1 || *coupon.PerUserLimit != 1 || !coupon.EndsAt.Equal(now.Add(72*time.Hour)) {
+			t.Errorf("expected a one-time coupon of 10%% for 72 hours got %+v", coupon)
+		}
+		if *cartStore.reminders[1].CouponCode != coupon.Code {
+			t.Errorf("expected the reminder to keep the code of the coupon got %v", cartStore.reminders[1].CouponCode)
+		}
+	})
+
+	t.Run("Should space out the reminders of a cart idle for long", func(t *testing.T) {
+		cartStore := &mockCartStore{}
+		reminder, _, _ := newReminder(cartStore, 10)
+
+		if _, err := reminder.Remind(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{
+			fmt.Sprint(1, now.Add(-time.Hour), now),
+			fmt.Sprint(2, now.Add(-24*time.Hour), now.Add(-23*time.Hour)),
+			fmt.Sprint(3, now.Add(-72*time.Hour), now.Add(-48*time.Hour)),
+		}
+		if strings.Join(cartStore.queries, "|") != strings.Join(expected, "|") {
+			t.Errorf("expected the queries %v got %v", expected, cartStore.queries)
+		}
+	})
+
+	t.Run("Should not remind a cart twice of a step", func(t *testing.T) {
+		cartStore := &mockCartStore{
+			abandoned: map[int][]types.Cart{3: {{ID: 2, UserID: 20}}},
+			recorded:  true,
+		}
+		reminder, couponStore, db := newReminder(cartStore, 10)
+
+		reminded, err := reminder.Remind(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reminded != 0 || len(db.events) != 0 || len(couponStore.created) != 0 {
+			t.Errorf("expected neither a reminder nor a coupon got %d reminders and %d coupons", reminded, len(couponStore.created))
+		}
+	})
+
+	t.Run("Should not offer a coupon when it's turned off", func(t *testing.T) {
+		cartStore := &mockCartStore{abandoned: map[int][]types.Cart{3: {{ID: 2, UserID: 20}}}}
+		reminder, couponStore, db := newReminder(cartStore, 0)
+
+		if _, err := reminder.Remind(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(couponStore.created) != 0 || len(db.events) != 1 || db.events[0].CouponCode != "" {
+			t.Errorf("expected the reminder without a coupon got %+v", db.events)
+		}
+	})
+}
+
+type mockCartStore struct {
+	types.CartStore
+	abandoned map[int][]types.Cart
+	// recorded makes every reminder look like it was already recorded.
+	recorded  bool
+	reminders []types.CartReminder
+	queries   []string
+	cart      *types.Cart
+	saved     []types.CartItem
+	clicked   []int
+	converted []string
+}
+
+func (m *mockCartStore) WithTx(tx myDB.DBTX) types.CartStore {
+	return m
+}
+
+func (m *mockCartStore) GetAbandonedCarts(step int, idleSince, remindedBefore time.Time, limit int) ([]types.Cart, error) {
+	m.queries = append(m.queries, fmt.Sprint(step, idleSince, remindedBefore))
+	return m.abandoned[step], nil
+}
+
+func (m *mockCartStore) CreateCartReminder(reminder types.CartReminder) (*types.CartReminder, bool, error) {
+	if m.recorded {
+		return nil, false, nil
+	}
+
+	reminder.ID = len(m.reminders) + 1
+	m.reminders = append(m.reminders, reminder)
+	return &reminder, true, nil
+}
+
+func (m *mockCartStore) GetCartReminderById(id int) (*types.CartReminder, error) {
+	if id < 1 || id > len(m.reminders) {
+		return nil, fmt.Errorf("cart reminder with id %v was not found", id)
+	}
+
+	return &m.reminders[id-1], nil
+}
+
+func (m *mockCartStore) MarkCartReminderClicked(id int) (bool, error) {
+	for _, clicked := range m.clicked {
+		if clicked == id {
+			return false, nil
+		}
+	}
+
+	m.clicked = append(m.clicked, id)
+	return true, nil
+}
+
+func (m *mockCartStore) ConvertCart(userID, orderID int, remindedSince time.Time) error {
+	m.converted = append(m.converted, fmt.Sprint(userID, orderID, remindedSince))
+	return nil
+}
+
+func (m *mockCartStore) GetCart(userID int) (*types.Cart, error) {
+	if m.cart != nil {
+		return m.cart, nil
+	}
+
+	return &types.Cart{UserID: userID, Items: make([]types.CartItem, 0)}, nil
+}
+
+func (m *mockCartStore) SaveCart(userID int, items []types.CartItem) (*types.Cart, error) {
+	m.saved = items
+	return &types.Cart{UserID: userID, Items: items}, nil
+}
+
+func (m *mockCartStore) GetCartRecoveryReport(from, to time.Time) (*types.CartRecoveryReport, error) {
+	return &types.CartRecoveryReport{From: from, To: to, Steps: make([]types.CartRecoveryStep, 0)}, nil
+}
+
+type mockCouponStore struct {
+	types.CouponStore
+	created []types.Coupon
+}
+
+func (m *mockCouponStore) WithTx(tx myDB.DBTX) types.CouponStore {
+	return m
+}
+
+func (m *mockCouponStore) CreateCoupon(coupon types.Coupon) (*types.Coupon, error) {
+	coupon.ID = len(m.created) + 1
+	m.created = append(m.created, coupon)
+	return &coupon, nil
+}
+
+// mockDB keeps the cart abandoned events recorded in the outbox.
+type mockDB struct {
+	myDB.DBTX
+	events []types.CartAbandonedEvent
+}
+
+func (m *mockDB) Exec(query string, args ...any) (sql.Result, error) {
+	var event types.CartAbandonedEvent
+	if err := json.Unmarshal(args[len(args)-1].([]byte), &event); err != nil {
+		return nil, err
+	}
+
+	m.events = append(m.events, event)
+	return driver.RowsAffected(1), nil
+}
@@ -0,0 +1,169 @@
+package recovery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+// the report covers this many days when no dates are given.
+const defaultReportDays = 30
+
+type Handler struct {
+	cartStore     types.CartStore
+	productStore  types.ProductStore
+	secret        string
+	storefrontURL string
+}
+
+func NewHandler(cartStore types.CartStore, productStore types.ProductStore) *Handler {
+	return &Handler{
+		cartStore:     cartStore,
+		productStore:  productStore,
+		secret:        config.Envs.JWTSecret,
+		storefrontURL: config.Envs.StorefrontURL,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/cart/restore", h.RestoreCart).Methods("GET")
+
+	router.HandleFunc("/admin/reports/abandoned-carts", auth.AdminMiddleware(h.GetReport)).Methods("GET")
+}
+
+// RestoreCart is the link of a reminder, it records the click and sends the user to their cart on the storefront
+// with the coupon of the reminder. On the first click the cart is put back as it was reminded of unless the user
+// filled it since, the later clicks only send the user to their cart.
+func (h *Handler) RestoreCart(w http.ResponseWriter, r *http.Request) {
+	reminderId, err := parseRestoreToken(h.secret, r.URL.Query().Get("token"), time.Now())
+	if errors.Is(err, errRestoreTokenExpired) {
+		utils.WriteError(w, http.StatusGone, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reminder, err := h.cartStore.GetCartReminderById(reminderId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	first, err := h.cartStore.MarkCartReminderClicked(reminder.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if first {
+		if err := h.restoreItems(reminder); err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	location := strings.TrimSuffix(h.storefrontURL, "/") + "/cart"
+	if reminder.CouponCode != nil {
+		location += "?coupon=" + url.QueryEscape(*reminder.CouponCode)
+	}
+
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+// GetReport returns the recovery of the carts reminded between ?from= and ?to=, both dates like 2024-11-01 and
+// included. The report covers the last 30 days by default.
+func (h *Handler) GetReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -defaultReportDays+1), today
+	var err error
+	if query.Get("from") != "" {
+		if from, err = time.Parse(time.DateOnly, query.Get("from")); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("from must be a date like 2024-11-01"))
+			return
+		}
+	}
+	if query.Get("to") != "" {
+		if to, err = time.Parse(time.DateOnly, query.Get("to")); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("to must be a date like 2024-11-01"))
+			return
+		}
+	}
+	if to.Before(from) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("to must not be before from"))
+		return
+	}
+
+	report, err := h.cartStore.GetCartRecoveryReport(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    report,
+	})
+}
+
+// restoreItems puts the items of the reminder back in the cart of the user, unless the user filled it since.
+func (h *Handler) restoreItems(reminder *types.CartReminder) error {
+	cart, err := h.cartStore.GetCart(reminder.UserID)
+	if err != nil {
+		return err
+	}
+	if len(cart.Items) > 0 {
+		return nil
+	}
+
+	items, err := h.availableItems(reminder.Items)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+
+	_, err = h.cartStore.SaveCart(reminder.UserID, items)
+	return err
+}
+
+// availableItems leaves out the products that were deleted since the cart was reminded of.
+func (h *Handler) availableItems(items []types.CartItem) ([]types.CartItem, error) {
+	if len(items) == 0 {
+		return items, nil
+	}
+
+	productIds := make([]int, 0, len(items))
+	for _, item := range items {
+		productIds = append(productIds, item.ProductID)
+	}
+
+	products, err := h.productStore.GetProductsByID(productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[int]bool, len(products))
+	for _, product := range products {
+		exists[product.ID] = true
+	}
+
+	available := make([]types.CartItem, 0, len(items))
+	for _, item := range items {
+		if exists[item.ProductID] {
+			available = append(available, item)
+		}
+	}
+
+	return available, nil
+}
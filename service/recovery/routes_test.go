@@ -0,0 +1,174 @@
+package recovery
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestRestoreCart(t *testing.T) {
+	code := "BACK7K2QM9XA"
+	token := RestoreToken("secret", 1, time.Now().Add(time.Hour))
+	newRouter := func(cart *types.Cart) (*mux.Router, *mockCartStore) {
+		cartStore := &mockCartStore{
+			cart: cart,
+			reminders: []types.CartReminder{{
+				ID:         1,
+				UserID:     10,
+				Step:       3,
+				Items:      []types.CartItem{{ProductID: 5, Quantity: 1}, {ProductID: 6, Quantity: 2}},
+				CouponCode: &code,
+			}},
+		}
+		productStore := &mockProductStore{products: []types.Product{{ID: 5}}}
+
+		router := mux.NewRouter()
+		handler := NewHandler(cartStore, productStore)
+		handler.secret = "secret"
+		handler.storefrontURL = "https://shop.example.com/"
+		handler.RegisterRoutes(router)
+
+		return router, cartStore
+	}
+
+	t.Run("Should put the cart back and send the user to it with the coupon", func(t *testing.T) {
+		router, cartStore := newRouter(nil)
+
+		recorder := request(t, router, "/cart/restore?token="+token, nil)
+		if recorder.Code != http.StatusFound || recorder.Header().Get("Location") != "https://shop.example.com/cart?coupon="+code {
+			t.Fatalf("expected the redirect to the cart got %d to %s", recorder.Code, recorder.Header().Get("Location"))
+		}
+		if len(cartStore.clicked) != 1 || cartStore.clicked[0] != 1 {
+			t.Errorf("expected the click to be recorded got %v", cartStore.clicked)
+		}
+		// product 6 was deleted since.
+		if len(cartStore.saved) != 1 || cartStore.saved[0].ProductID != 5 {
+			t.Errorf("expected the cart to be restored without the deleted products got %+v", cartStore.saved)
+		}
+	})
+
+	t.Run("Should keep the cart the user filled since", func(t *testing.T) {
+		router, cartStore := newRouter(&types.Cart{UserID: 10, Items: []types.CartItem{{ProductID: 7, Quantity: 1}}})
+
+		recorder := request(t, router, "/cart/restore?token="+token, nil)
+		if recorder.Code != http.StatusFound || cartStore.saved != nil {
+			t.Errorf("expected the redirect without restoring the cart got %d and %+v", recorder.Code, cartStore.saved)
+		}
+	})
+
+	t.Run("Should only restore the cart on the first click", func(t *testing.T) {
+		router, cartStore := newRouter(nil)
+
+		request(t, router, "/cart/restore?token="+token, nil)
+		cartStore.saved = nil
+		recorder := request(t, router, "/cart/restore?token="+token, nil)
+		if recorder.Code != http.StatusFound || cartStore.saved != nil || len(cartStore.clicked) != 1 {
+			t.Errorf("expected the redirect without restoring the cart again got %d, %+v and %v clicks",
+				recorder.Code, cartStore.saved, cartStore.clicked)
+		}
+	})
+
+	t.Run("Should refuse a tampered token", func(t *testing.T) {
+		router, cartStore := newRouter(nil)
+		_, rest, _ := strings.Cut(token, ".")
+
+		recorder := request(t, router, "/cart/restore?token=2."+rest, nil)
+		if recorder.Code != http.StatusBadRequest || len(cartStore.clicked) != 0 {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Should refuse a token with a later expiry than it was signed with", func(t *testing.T) {
+		router, cartStore := newRouter(nil)
+		expired := strings.Split(RestoreToken("secret", 1, time.Now().Add(-time.Hour)), ".")
+		expired[1] = fmt.Sprint(time.Now().Add(time.Hour).Unix())
+
+		recorder := request(t, router, "/cart/restore?token="+strings.Join(expired, "."), nil)
+		if recorder.Code != http.StatusBadRequest || len(cartStore.clicked) != 0 {
+			t.Errorf("expected status code %d got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Should refuse an expired token", func(t *testing.T) {
+		router, cartStore := newRouter(nil)
+
+		recorder := request(t, router, "/cart/restore?token="+RestoreToken("secret", 1, time.Now().Add(-time.Minute)), nil)
+		if recorder.Code != http.StatusGone || len(cartStore.clicked) != 0 {
+			t.Errorf("expected status code %d got %d", http.StatusGone, recorder.Code)
+		}
+	})
+}
+
+func TestGetReport(t *testing.T) {
+	router := mux.NewRouter()
+	NewHandler(&mockCartStore{}, &mockProductStore{}).RegisterRoutes(router)
+	admin := &types.User{ID: 1, Role: types.UserRoleAdmin}
+
+	cases := []struct {
+		name     string
+		path     string
+		user     *types.User
+		expected int
+	}{
+		{"Should return the report of the dates", "/admin/reports/abandoned-carts?from=2024-11-01&to=2024-11-30", admin, http.StatusOK},
+		{"Should default to the last days", "/admin/reports/abandoned-carts", admin, http.StatusOK},
+		{"Should refuse an invalid date", "/admin/reports/abandoned-carts?from=yesterday", admin, http.StatusBadRequest},
+		{"Should refuse dates in the wrong order", "/admin/reports/abandoned-carts?from=2024-11-30&to=2024-11-01", admin, http.StatusBadRequest},
+		{"Should refuse the customers", "/admin/reports/abandoned-carts", &types.User{ID: 7, Role: types.UserRoleCustomer}, http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := request(t, router, c.path, c.user)
+			if recorder.Code != c.expected {
+				t.Errorf("expected status code %d got %d: %s", c.expected, recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
+
+func request(t *testing.T, router *mux.Router, path string, user *types.User) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user != nil {
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), *user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", token)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+type mockProductStore struct {
+	types.ProductStore
+	products []types.Product
+}
+
+func (m *mockProductStore) GetProductsByID(productIDs []int) ([]types.Product, error) {
+	products := make([]types.Product, 0)
+	for _, product := range m.products {
+		for _, id := range productIDs {
+			if product.ID == id {
+				products = append(products, product)
+			}
+		}
+	}
+
+	return products, nil
+}
@@ -0,0 +1,64 @@
+package recovery
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the restore link of a reminder can be used for this long after it's sent.
+const restoreTokenValidity = 7 * 24 * time.Hour
+
+var errRestoreTokenExpired = errors.New("the restore link has expired")
+
+// RestoreToken lets the user get back the cart of a reminder from its link without logging in, it's the
+// reminder id and the expiry of the link signed with secret.
+func RestoreToken(secret string, reminderID int, expiresAt time.Time) string {
+	message := strconv.Itoa(reminderID) + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return message + "." + signToken(secret, message)
+}
+
+// parseRestoreToken returns the reminder of a token signed with secret, errRestoreTokenExpired is returned
+// once the token expired.
+func parseRestoreToken(secret, token string, now time.Time) (int, error) {
+	separator := strings.LastIndex(token, ".")
+	if separator < 0 {
+		return 0, fmt.Errorf("invalid restore token")
+	}
+
+	message, signature := token[:separator], token[separator+1:]
+	if !hmac.Equal([]byte(signature), []byte(signToken(secret, message))) {
+		return 0, fmt.Errorf("invalid restore token")
+	}
+
+	id, expires, _ := strings.Cut(message, ".")
+	reminderId, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("invalid restore token")
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid restore token")
+	}
+	if now.Unix() > expiresAt {
+		return 0, errRestoreTokenExpired
+	}
+
+	return reminderId, nil
+}
+
+func restoreURL(baseURL, secret string, reminderID int, expiresAt time.Time) string {
+	return strings.TrimSuffix(baseURL, "/") + "/cart/restore?token=" + url.QueryEscape(RestoreToken(secret, reminderID, expiresAt))
+}
+
+func signToken(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte("cart-restore:"+secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,456 @@
+package returns
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/middlewares"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+type Handler struct {
+	store            types.ReturnStore
+	orderStore       types.OrderStore
+	paymentStore     types.PaymentStore
+	paymentProcessor types.PaymentProcessor
+	returnWindow     time.Duration
+}
+
+func NewHandler(store types.ReturnStore, orderStore types.OrderStore, paymentStore types.PaymentStore,
+	paymentProcessor types.PaymentProcessor) *Handler {
+	windowInDays, err := strconv.Atoi(config.Envs.ReturnWindowInDays)
+	if err != nil || windowInDays <= 0 {
+		windowInDays = 30
+	}
+
+	return &Handler{
+		store:            store,
+		orderStore:       orderStore,
+		paymentStore:     paymentStore,
+		paymentProcessor: paymentProcessor,
+		returnWindow:     time.Duration(windowInDays) * 24 * time.Hour,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/orders/{id}/returns", auth.AuthenticationMiddleware(h.RequestReturn)).Methods("POST")
+	router.HandleFunc("/returns", auth.AuthenticationMiddleware(middlewares.PaginationMiddleware(h.GetMyReturns))).Methods("GET")
+	router.HandleFunc("/returns/{id}", auth.AuthenticationMiddleware(h.GetMyReturn)).Methods("GET")
+	router.HandleFunc("/returns/{id}/cancel", auth.AuthenticationMiddleware(h.CancelReturn)).Methods("POST")
+
+	router.HandleFunc("/admin/returns", auth.AdminMiddleware(middlewares.PaginationMiddleware(h.GetReturns))).Methods("GET")
+	router.HandleFunc("/admin/returns/{id}", auth.AdminMiddleware(h.GetReturn)).Methods("GET")
+	router.HandleFunc("/admin/returns/{id}/approve", auth.AdminMiddleware(h.ApproveReturn)).Methods("POST")
+	router.HandleFunc("/admin/returns/{id}/reject", auth.AdminMiddleware(h.RejectReturn)).Methods("POST")
+	router.HandleFunc("/admin/returns/{id}/receive", auth.AdminMiddleware(h.ReceiveReturn)).Methods("POST")
+	router.HandleFunc("/admin/returns/{id}/refund", auth.AdminMiddleware(h.RefundReturn)).Methods("POST")
+}
+
+// RequestReturn opens a return for some delivered items of a completed order of the customer,
+// within the return window from their delivery.
+func (h *Handler) RequestReturn(w http.ResponseWriter, r *http.Request) {
+	orderId, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.ReturnRequestPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	order, err := h.orderStore.GetOrderById(orderId)
+	if err == nil && order.UserID != tokenPayload.UserId {
+		err = fmt.Errorf("no order was found for id %v", orderId)
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if order.Status != types.OrderStatusCompleted {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order with id %v is %s, only completed orders can be returned", orderId, order.Status))
+		return
+	}
+
+	orderItems, err := h.orderStore.GetOrderItems(orderId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	items, err := returnItemsFromPayload(order, orderItems, payload.Items)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	shipments, err := h.orderStore.GetOrderShipments(orderId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := checkDelivered(items, shipments, h.returnWindow, time.Now()); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ret, err := h.store.CreateReturn(types.Return{
+		OrderID: orderId,
+		UserID:  tokenPayload.UserId,
+		Reason:  payload.Reason,
+		Items:   items,
+	})
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    ret,
+	})
+}
+
+func (h *Handler) GetMyReturns(w http.ResponseWriter, r *http.Request) {
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	h.writeReturns(w, r, tokenPayload.UserId, "")
+}
+
+func (h *Handler) GetMyReturn(w http.ResponseWriter, r *http.Request) {
+	ret, ok := h.getOwnReturn(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    ret,
+	})
+}
+
+// CancelReturn lets the customer take back a return that wasn't decided yet.
+func (h *Handler) CancelReturn(w http.ResponseWriter, r *http.Request) {
+	ret, ok := h.getOwnReturn(w, r)
+	if !ok {
+		return
+	}
+
+	h.moveReturn(w, ret.ID, []string{types.ReturnStatusRequested}, types.ReturnStatusCancelled, ret.StaffNote)
+}
+
+// GetReturns lists all the returns, ?status=received lists the ones waiting for a refund.
+func (h *Handler) GetReturns(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	err := utils.Validate.Var(status, "omitempty,oneof=requested approved rejected cancelled received partially_refunded refunded")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("status must be one of requested, approved, rejected, cancelled, received, partially_refunded or refunded"))
+		return
+	}
+
+	h.writeReturns(w, r, 0, status)
+}
+
+func (h *Handler) GetReturn(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ret, err := h.store.GetReturnById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    ret,
+	})
+}
+
+func (h *Handler) ApproveReturn(w http.ResponseWriter, r *http.Request) {
+	h.decideReturn(w, r, types.ReturnStatusApproved)
+}
+
+func (h *Handler) RejectReturn(w http.ResponseWriter, r *http.Request) {
+	h.decideReturn(w, r, types.ReturnStatusRejected)
+}
+
+// ReceiveReturn records what came back of an approved return, every item of the return is either
+// restocked or written off. The refundable total of the return is taken from the received quantities.
+func (h *Handler) ReceiveReturn(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.ReturnReceiptPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	ret, err := h.store.GetReturnById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	order, err := h.orderStore.GetOrderById(ret.OrderID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	shipments, err := h.orderStore.GetOrderShipments(order.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	items, err := receivedItemsFromPayload(ret, shipments, payload.Items)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	received, err := h.store.ReceiveReturn(id, items, tokenPayload.UserId)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    received,
+	})
+}
+
+// RefundReturn refunds the given amount of a received return through the payment of its order,
+// or what's left to refund of it without one.
+func (h *Handler) RefundReturn(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.PaymentRefundPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ret, err := h.store.GetReturnById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	amount := ret.RefundableTotal.Sub(ret.RefundedTotal)
+	if payload.Amount != nil {
+		amount = *payload.Amount
+	}
+	if !amount.IsPositive() {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("the refund must be more than 0"))
+		return
+	}
+
+	payment, err := h.refundablePayment(ret.OrderID, amount)
+	if err != nil {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+
+	// the refund is claimed on the return before the money is sent, so two refunds of the same
+	// return can't both go through, and given back when the payment refuses it.
+	if _, err := h.store.AddReturnRefund(id, amount); err != nil {
+		writeReturnError(w, err)
+		return
+	}
+
+	if err := h.paymentProcessor.Refund(payment, amount); err != nil {
+		if _, revertErr := h.store.AddReturnRefund(id, money.Zero().Sub(amount)); revertErr != nil {
+			log.Printf("refund of %s couldn't be taken back from return %d: %v", amount, id, revertErr)
+		}
+		writeReturnError(w, err)
+		return
+	}
+
+	refunded, err := h.store.GetReturnById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    refunded,
+		"payment": payment,
+	})
+}
+
+func (h *Handler) decideReturn(w http.ResponseWriter, r *http.Request, status string) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.ReturnDecisionPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	h.moveReturn(w, id, []string{types.ReturnStatusRequested}, status, payload.StaffNote)
+}
+
+func (h *Handler) moveReturn(w http.ResponseWriter, id int, from []string, status, staffNote string) {
+	if err := h.store.UpdateReturnStatus(id, from, status, staffNote); err != nil {
+		writeReturnError(w, err)
+		return
+	}
+
+	ret, err := h.store.GetReturnById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    ret,
+	})
+}
+
+func (h *Handler) writeReturns(w http.ResponseWriter, r *http.Request, userId int, status string) {
+	pagination := middlewares.GetPagination(r)
+	offset := middlewares.CalculateOffset(pagination)
+
+	returns, count, err := h.store.GetReturns(userId, status, pagination.Limit, offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK,
+		map[string]any{
+			"returns": returns,
+			"page":    pagination.Page,
+			"limit":   pagination.Limit,
+			"count":   count,
+		})
+}
+
+// getOwnReturn writes the error itself when the return isn't one of the customer's.
+func (h *Handler) getOwnReturn(w http.ResponseWriter, r *http.Request) (*types.Return, bool) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	tokenPayload, err := auth.GetTokenPayload(r.Context())
+	if err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return nil, false
+	}
+
+	ret, err := h.store.GetReturnById(id)
+	if err == nil && ret.UserID != tokenPayload.UserId {
+		err = fmt.Errorf("no return was found for id %v", id)
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return nil, false
+	}
+
+	return ret, true
+}
+
+// refundablePayment returns the captured payment of the order that can give back the amount.
+func (h *Handler) refundablePayment(orderId int, amount money.Money) (*types.Payment, error) {
+	payments, err := h.paymentStore.GetOrderPayments(orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, payment := range payments {
+		if payment.Status != types.PaymentStatusCaptured && payment.Status != types.PaymentStatusPartiallyRefunded {
+			continue
+		}
+		if payment.CapturedAmount.Sub(payment.RefundedAmount).Cmp(amount) >= 0 {
+			return &payment, nil
+		}
+	}
+
+	return nil, fmt.Errorf("order with id %v has no captured payment that can refund %s", orderId, amount)
+}
+
+func writeReturnError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrReturnQuantity), errors.Is(err, types.ErrReturnStatus), errors.Is(err, types.ErrReturnRefund):
+		utils.WriteError(w, http.StatusConflict, err)
+	case errors.Is(err, types.ErrPaymentDeclined):
+		utils.WriteError(w, http.StatusPaymentRequired, err)
+	case errors.Is(err, types.ErrPaymentUnavailable):
+		utils.WriteError(w, http.StatusServiceUnavailable, err)
+	default:
+		utils.WriteError(w, http.StatusBadRequest, err)
+	}
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("id must be unsigned integer")
+	}
+
+	return id, nil
+}
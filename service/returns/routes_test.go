@@ -0,0 +1,275 @@
+package returns
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestRequestReturn(t *testing.T) {
+	delivered := func(age time.Duration) []types.Shipment {
+		deliveredAt := time.Now().Add(-age)
+		return []types.Shipment{{
+			ID:          1,
+			OrderID:     1,
+			Status:      types.ShipmentStatusDelivered,
+			DeliveredAt: &deliveredAt,
+			Items:       []types.ShipmentItem{{ProductID: 5, Quantity: 2}},
+		}}
+	}
+
+	cases := []struct {
+		name      string
+		userId    int
+		order     types.Order
+		shipments []types.Shipment
+		expected  int
+	}{
+		{
+			name:      "Should open a return for a completed order",
+			userId:    7,
+			order:     types.Order{ID: 1, UserID: 7, Status: types.OrderStatusCompleted, UpdatedAt: time.Now()},
+			shipments: delivered(24 * time.Hour),
+			expected:  http.StatusCreated,
+		},
+		{
+			name:      "Should not find the order of another customer",
+			userId:    8,
+			order:     types.Order{ID: 1, UserID: 7, Status: types.OrderStatusCompleted, UpdatedAt: time.Now()},
+			shipments: delivered(24 * time.Hour),
+			expected:  http.StatusNotFound,
+		},
+		{
+			name:      "Should refuse an order that wasn't completed",
+			userId:    7,
+			order:     types.Order{ID: 1, UserID: 7, Status: types.OrderStatusPending, UpdatedAt: time.Now()},
+			shipments: delivered(24 * time.Hour),
+			expected:  http.StatusBadRequest,
+		},
+		{
+			name:      "Should refuse items that weren't delivered",
+			userId:    7,
+			order:     types.Order{ID: 1, UserID: 7, Status: types.OrderStatusCompleted, UpdatedAt: time.Now()},
+			shipments: []types.Shipment{{ID: 1, OrderID: 1, Status: types.ShipmentStatusShipped, Items: []types.ShipmentItem{{ProductID: 5, Quantity: 2}}}},
+			expected:  http.StatusBadRequest,
+		},
+		{
+			name:      "Should refuse items past the return window from their delivery",
+			userId:    7,
+			order:     types.Order{ID: 1, UserID: 7, Status: types.OrderStatusCompleted, UpdatedAt: time.Now()},
+			shipments: delivered(31 * 24 * time.Hour),
+			expected:  http.StatusBadRequest,
+		},
+		{
+			name:   "Should start the return window at the delivery, not when the order was updated",
+			userId: 7,
+			order: types.Order{ID: 1, UserID: 7, Status: types.OrderStatusCompleted,
+				UpdatedAt: time.Now().Add(-40 * 24 * time.Hour)},
+			shipments: delivered(24 * time.Hour),
+			expected:  http.StatusCreated,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := &mockReturnStore{}
+			orderStore := &mockOrderStore{
+				order:     c.order,
+				items:     []types.OrderItem{{ID: 10, OrderID: 1, ProductID: 5, Quantity: 2, Price: money.FromMinor(1000)}},
+				shipments: c.shipments,
+			}
+			handler := &Handler{store: store, orderStore: orderStore, returnWindow: 30 * 24 * time.Hour}
+
+			body := `{"reason":"too small","items":[{"orderItemId":10,"quantity":1}]}`
+			recorder := send(t, handler.RequestReturn, "/orders/{id}/returns", "/orders/1/returns", body, c.userId)
+			if recorder.Code != c.expected {
+				t.Fatalf("expected status code %d got %d: %s", c.expected, recorder.Code, recorder.Body)
+			}
+
+			if c.expected == http.StatusCreated && (store.created == nil || store.created.UserID != 7 || store.created.Items[0].ProductID != 5) {
+				t.Errorf("expected the return to be created for the customer got %+v", store.created)
+			}
+		})
+	}
+}
+
+func TestRefundReturn(t *testing.T) {
+	newHandler := func(refundErr error) (*Handler, *mockReturnStore, *mockPaymentProcessor) {
+		store := &mockReturnStore{ret: types.Return{
+			ID:              1,
+			OrderID:         1,
+			Status:          types.ReturnStatusReceived,
+			RefundableTotal: money.FromMinor(2000),
+			RefundedTotal:   money.Zero(),
+		}}
+		paymentStore := &mockPaymentStore{payments: []types.Payment{
+			{ID: 1, OrderID: 1, Status: types.PaymentStatusFailed, CapturedAmount: money.Zero(), RefundedAmount: money.Zero()},
+			{ID: 2, OrderID: 1, Status: types.PaymentStatusCaptured, CapturedAmount: money.FromMinor(5000), RefundedAmount: money.Zero()},
+		}}
+		processor := &mockPaymentProcessor{err: refundErr}
+
+		return &Handler{store: store, paymentStore: paymentStore, paymentProcessor: processor}, store, processor
+	}
+
+	t.Run("Should refund what's left of the return through the captured payment", func(t *testing.T) {
+		handler, store, processor := newHandler(nil)
+
+		recorder := send(t, handler.RefundReturn, "/admin/returns/{id}/refund", "/admin/returns/1/refund", `{}`, 1)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+
+		if processor.paymentId != 2 || processor.amount.Amount != 2000 {
+			t.Errorf("expected 2000 refunded from payment 2 got %d from %d", processor.amount.Amount, processor.paymentId)
+		}
+		if store.ret.Status != types.ReturnStatusRefunded {
+			t.Errorf("expected the return to be refunded got %s", store.ret.Status)
+		}
+	})
+
+	t.Run("Should refund part of the return", func(t *testing.T) {
+		handler, store, _ := newHandler(nil)
+
+		recorder := send(t, handler.RefundReturn, "/admin/returns/{id}/refund", "/admin/returns/1/refund", `{"amount":5}`, 1)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+
+		if store.ret.Status != types.ReturnStatusPartiallyRefunded || store.ret.RefundedTotal.Amount != 500 {
+			t.Errorf("expected 500 refunded got %s and %s", store.ret.Status, store.ret.RefundedTotal)
+		}
+	})
+
+	t.Run("Should refuse more than the return is worth", func(t *testing.T) {
+		handler, _, processor := newHandler(nil)
+
+		recorder := send(t, handler.RefundReturn, "/admin/returns/{id}/refund", "/admin/returns/1/refund", `{"amount":21}`, 1)
+		if recorder.Code != http.StatusConflict || processor.paymentId != 0 {
+			t.Errorf("expected status code %d and no refund got %d", http.StatusConflict, recorder.Code)
+		}
+	})
+
+	t.Run("Should give the refund back to the return when the payment refuses it", func(t *testing.T) {
+		handler, store, _ := newHandler(types.ErrPaymentUnavailable)
+
+		recorder := send(t, handler.RefundReturn, "/admin/returns/{id}/refund", "/admin/returns/1/refund", `{}`, 1)
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status code %d got %d", http.StatusServiceUnavailable, recorder.Code)
+		}
+
+		if store.ret.Status != types.ReturnStatusReceived || !store.ret.RefundedTotal.IsZero() {
+			t.Errorf("expected nothing refunded got %s and %s", store.ret.Status, store.ret.RefundedTotal)
+		}
+	})
+}
+
+func send(t *testing.T, handlerFunc http.HandlerFunc, route, path, body string, userId int) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), types.User{ID: userId, Role: types.UserRoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", token)
+
+	recorder := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc(route, auth.AuthenticationMiddleware(handlerFunc))
+	router.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+type mockReturnStore struct {
+	types.ReturnStore
+	created *types.Return
+	ret     types.Return
+}
+
+func (m *mockReturnStore) CreateReturn(ret types.Return) (*types.Return, error) {
+	ret.ID = 1
+	ret.Status = types.ReturnStatusRequested
+	m.created = &ret
+	return &ret, nil
+}
+
+func (m *mockReturnStore) GetReturnById(id int) (*types.Return, error) {
+	ret := m.ret
+	return &ret, nil
+}
+
+func (m *mockReturnStore) AddReturnRefund(id int, amount money.Money) (*types.Return, error) {
+	refunded := m.ret.RefundedTotal.Add(amount)
+	if refunded.IsNegative() || refunded.Cmp(m.ret.RefundableTotal) > 0 {
+		return nil, types.ErrReturnRefund
+	}
+
+	m.ret.RefundedTotal = refunded
+	switch {
+	case refunded.IsZero():
+		m.ret.Status = types.ReturnStatusReceived
+	case refunded.Cmp(m.ret.RefundableTotal) == 0:
+		m.ret.Status = types.ReturnStatusRefunded
+	default:
+		m.ret.Status = types.ReturnStatusPartiallyRefunded
+	}
+	ret := m.ret
+	return &ret, nil
+}
+
+type mockOrderStore struct {
+	types.OrderStore
+	order     types.Order
+	items     []types.OrderItem
+	shipments []types.Shipment
+}
+
+func (m *mockOrderStore) GetOrderById(id int) (*types.Order, error) {
+	order := m.order
+	return &order, nil
+}
+
+func (m *mockOrderStore) GetOrderItems(orderID int) ([]types.OrderItem, error) {
+	return m.items, nil
+}
+
+func (m *mockOrderStore) GetOrderShipments(orderID int) ([]types.Shipment, error) {
+	return m.shipments, nil
+}
+
+type mockPaymentStore struct {
+	types.PaymentStore
+	payments []types.Payment
+}
+
+func (m *mockPaymentStore) GetOrderPayments(orderID int) ([]types.Payment, error) {
+	return m.payments, nil
+}
+
+type mockPaymentProcessor struct {
+	types.PaymentProcessor
+	err       error
+	paymentId int
+	amount    money.Money
+}
+
+func (m *mockPaymentProcessor) Refund(payment *types.Payment, amount money.Money) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.paymentId = payment.ID
+	m.amount = amount
+	return nil
+}
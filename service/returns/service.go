@@ -0,0 +1,181 @@
+package returns
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// returnItemsFromPayload checks the requested items belong to the order and aren't more than were
+// ordered, what the other returns of the order already claim is checked by the store.
+func returnItemsFromPayload(order *types.Order, orderItems []types.OrderItem, payload []types.ReturnItemPayload) ([]types.ReturnItem, error) {
+	refundables := lineRefundables(order, orderItems)
+	orderItemsMap := make(map[int]types.OrderItem)
+	for _, orderItem := range orderItems {
+		orderItemsMap[orderItem.ID] = orderItem
+	}
+
+	items := make([]types.ReturnItem, 0, len(payload))
+	seen := make(map[int]bool)
+	for _, item := range payload {
+		orderItem, ok := orderItemsMap[item.OrderItemID]
+		if !ok {
+			return nil, fmt.Errorf("order item with id %v isn't part of order %v", item.OrderItemID, order.ID)
+		}
+		if seen[item.OrderItemID] {
+			return nil, fmt.Errorf("order item with id %v is returned more than once", item.OrderItemID)
+		}
+		seen[item.OrderItemID] = true
+
+		if item.Quantity > orderItem.Quantity {
+			return nil, fmt.Errorf("%w, only %v of order item %v were ordered", types.ErrReturnQuantity, orderItem.Quantity, item.OrderItemID)
+		}
+
+		items = append(items, types.ReturnItem{
+			OrderItemID:    orderItem.ID,
+			ProductID:      orderItem.ProductID,
+			Quantity:       item.Quantity,
+			LineRefundable: refundables[orderItem.ID],
+		})
+	}
+
+	return items, nil
+}
+
+// checkDelivered needs the returned units of every product to have been delivered, and the return to be
+// requested within the window from the last delivery of the product.
+func checkDelivered(items []types.ReturnItem, shipments []types.Shipment, window time.Duration, now time.Time) error {
+	delivered := make(map[int]int)
+	deliveredAt := make(map[int]time.Time)
+	for _, shipment := range shipments {
+		if shipment.Status != types.ShipmentStatusDelivered || shipment.DeliveredAt == nil {
+			continue
+		}
+
+		for _, shipmentItem := range shipment.Items {
+			delivered[shipmentItem.ProductID] += shipmentItem.Quantity
+			if shipment.DeliveredAt.After(deliveredAt[shipmentItem.ProductID]) {
+				deliveredAt[shipmentItem.ProductID] = *shipment.DeliveredAt
+			}
+		}
+	}
+
+	for _, item := range items {
+		if delivered[item.ProductID] < item.Quantity {
+			return fmt.Errorf("%w, only %v units of product %v were delivered", types.ErrReturnQuantity, delivered[item.ProductID], item.ProductID)
+		}
+		if now.Sub(deliveredAt[item.ProductID]) > window {
+			return fmt.Errorf("product %v can't be returned more than %d days after it was delivered", item.ProductID, int(window.Hours()/24))
+		}
+	}
+
+	return nil
+}
+
+// receivedItemsFromPayload needs every item of the return to be accounted for. The restocked items
+// without a warehouse go back to the one they were shipped from.
+func receivedItemsFromPayload(ret *types.Return, shipments []types.Shipment, payload []types.ReturnReceiptItemPayload) ([]types.ReturnItem, error) {
+	itemsMap := make(map[int]types.ReturnItem)
+	for _, item := range ret.Items {
+		itemsMap[item.ID] = item
+	}
+
+	shippedFrom := make(map[int]int)
+	for _, shipment := range shipments {
+		for _, shipmentItem := range shipment.Items {
+			if _, ok := shippedFrom[shipmentItem.ProductID]; !ok {
+				shippedFrom[shipmentItem.ProductID] = shipment.WarehouseID
+			}
+		}
+	}
+
+	items := make([]types.ReturnItem, 0, len(payload))
+	for _, receipt := range payload {
+		item, ok := itemsMap[receipt.ReturnItemID]
+		if !ok {
+			return nil, fmt.Errorf("item with id %v isn't part of return %v, or is given more than once", receipt.ReturnItemID, ret.ID)
+		}
+		delete(itemsMap, receipt.ReturnItemID)
+
+		if receipt.ReceivedQuantity > item.Quantity {
+			return nil, fmt.Errorf("%v units of item %v were received but only %v were returned", receipt.ReceivedQuantity, item.ID, item.Quantity)
+		}
+
+		disposition := receipt.Disposition
+		item.ReceivedQuantity = receipt.ReceivedQuantity
+		item.Disposition = &disposition
+		item.WarehouseID = nil
+		if disposition == types.ReturnDispositionRestock {
+			if receipt.WarehouseID != 0 {
+				warehouseId := receipt.WarehouseID
+				item.WarehouseID = &warehouseId
+			} else if warehouseId, ok := shippedFrom[item.ProductID]; ok {
+				item.WarehouseID = &warehouseId
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	for id := range itemsMap {
+		return nil, fmt.Errorf("item with id %v of return %v wasn't received", id, ret.ID)
+	}
+
+	return items, nil
+}
+
+// lineRefundables returns what every order item was paid, its line total after the promotions and its share of
+// the coupon discounts, plus its taxes when they weren't included in the prices. The orders without a price
+// breakdown paid the prices.
+func lineRefundables(order *types.Order, orderItems []types.OrderItem) map[int]money.Money {
+	couponDiscounts := lineCouponDiscounts(order.Pricing)
+
+	refundables := make(map[int]money.Money)
+	for i, orderItem := range orderItems {
+		// the lines of the breakdown follow the order items.
+		if order.Pricing == nil || i >= len(order.Pricing.Lines) {
+			refundables[orderItem.ID] = orderItem.Price.Mul(int64(orderItem.Quantity))
+			continue
+		}
+
+		line := order.Pricing.Lines[i]
+		refundable := money.Max(money.Zero(), line.Total.Sub(couponDiscounts[i]))
+		if !order.Pricing.TaxInclusive {
+			for _, tax := range line.Taxes {
+				refundable = refundable.Add(tax.Amount)
+			}
+		}
+		refundables[orderItem.ID] = refundable
+	}
+
+	return refundables
+}
+
+// lineCouponDiscounts returns the share of the coupon discounts of every line. The breakdowns priced before the
+// shares were kept on the lines get the coupon discounts spread over all their lines.
+func lineCouponDiscounts(pricing *types.PriceBreakdown) []money.Money {
+	if pricing == nil {
+		return nil
+	}
+
+	discounts := make([]money.Money, len(pricing.Lines))
+	kept := money.Zero()
+	weights := make([]int64, len(pricing.Lines))
+	for i, line := range pricing.Lines {
+		discounts[i] = line.CouponDiscount
+		kept = kept.Add(discounts[i])
+		weights[i] = money.Max(money.Zero(), line.Total).Amount
+	}
+
+	couponsDiscount := money.Zero()
+	for _, applied := range pricing.Coupons {
+		couponsDiscount = couponsDiscount.Add(applied.Discount)
+	}
+	if kept.IsZero() && couponsDiscount.IsPositive() {
+		return couponsDiscount.Allocate(weights)
+	}
+
+	return discounts
+}
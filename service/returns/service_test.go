@@ -0,0 +1,229 @@
+package returns
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestRefundShare(t *testing.T) {
+	line := money.FromMinor(1000)
+
+	cases := []struct {
+		name   string
+		splits []int
+	}{
+		{name: "Should refund the whole line at once", splits: []int{3}},
+		{name: "Should refund the whole line unit by unit", splits: []int{1, 1, 1}},
+		{name: "Should refund the whole line in uneven returns", splits: []int{2, 1}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			total := money.Zero()
+			already := 0
+			for _, quantity := range c.splits {
+				total = total.Add(RefundShare(line, 3, already, quantity))
+				already += quantity
+			}
+
+			if total.Cmp(line) != 0 {
+				t.Errorf("expected the refunds to add up to %s got %s", line, total)
+			}
+		})
+	}
+
+	t.Run("Should round the shares down until the last unit", func(t *testing.T) {
+		if share := RefundShare(line, 3, 0, 1); share.Amount != 333 {
+			t.Errorf("expected 333 got %d", share.Amount)
+		}
+		if share := RefundShare(line, 3, 2, 1); share.Amount != 334 {
+			t.Errorf("expected 334 got %d", share.Amount)
+		}
+	})
+
+	t.Run("Should refund nothing for nothing received", func(t *testing.T) {
+		if share := RefundShare(line, 3, 1, 0); !share.IsZero() {
+			t.Errorf("expected 0 got %s", share)
+		}
+	})
+}
+
+func TestLineRefundables(t *testing.T) {
+	orderItems := []types.OrderItem{
+		{ID: 10, Quantity: 2, Price: money.FromMinor(1000)},
+		{ID: 11, Quantity: 1, Price: money.FromMinor(500)},
+	}
+	pricing := &types.PriceBreakdown{
+		Lines: []types.PriceLine{
+			{Total: money.FromMinor(1800), Taxes: []types.TaxLine{{Amount: money.FromMinor(180)}}},
+			{Total: money.FromMinor(500), Taxes: []types.TaxLine{{Amount: money.FromMinor(50)}}},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		order    *types.Order
+		expected map[int]int64
+	}{
+		{
+			name:     "Should refund the discounted lines with their taxes",
+			order:    &types.Order{Pricing: pricing},
+			expected: map[int]int64{10: 1980, 11: 550},
+		},
+		{
+			name:     "Should not add the taxes included in the prices",
+			order:    &types.Order{Pricing: &types.PriceBreakdown{Lines: pricing.Lines, TaxInclusive: true}},
+			expected: map[int]int64{10: 1800, 11: 500},
+		},
+		{
+			name: "Should take the share of the coupons off the lines",
+			order: &types.Order{Pricing: &types.PriceBreakdown{
+				Lines: []types.PriceLine{
+					{Total: money.FromMinor(1800), CouponDiscount: money.FromMinor(180), Taxes: []types.TaxLine{{Amount: money.FromMinor(162)}}},
+					{Total: money.FromMinor(500), CouponDiscount: money.FromMinor(50), Taxes: []types.TaxLine{{Amount: money.FromMinor(45)}}},
+				},
+				Coupons: []types.AppliedCoupon{{Code: "TEN", Discount: money.FromMinor(230)}},
+			}},
+			expected: map[int]int64{10: 1782, 11: 495},
+		},
+		{
+			name: "Should spread the coupons of a breakdown without the shares on its lines",
+			order: &types.Order{Pricing: &types.PriceBreakdown{
+				Lines:   []types.PriceLine{{Total: money.FromMinor(1800)}, {Total: money.FromMinor(500)}},
+				Coupons: []types.AppliedCoupon{{Code: "TEN", Discount: money.FromMinor(230)}},
+			}},
+			expected: map[int]int64{10: 1620, 11: 450},
+		},
+		{
+			name:     "Should refund the prices of an order without a breakdown",
+			order:    &types.Order{},
+			expected: map[int]int64{10: 2000, 11: 500},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			refundables := lineRefundables(c.order, orderItems)
+			for id, expected := range c.expected {
+				if refundables[id].Amount != expected {
+					t.Errorf("expected order item %d to refund %d got %s", id, expected, refundables[id])
+				}
+			}
+		})
+	}
+}
+
+func TestReturnItemsFromPayload(t *testing.T) {
+	order := &types.Order{ID: 1}
+	orderItems := []types.OrderItem{{ID: 10, ProductID: 5, Quantity: 2, Price: money.FromMinor(1000)}}
+
+	cases := []struct {
+		name    string
+		payload []types.ReturnItemPayload
+		fails   bool
+		err     error
+	}{
+		{name: "Should return items of the order", payload: []types.ReturnItemPayload{{OrderItemID: 10, Quantity: 2}}},
+		{name: "Should refuse items of another order", payload: []types.ReturnItemPayload{{OrderItemID: 11, Quantity: 1}}, fails: true},
+		{
+			name:    "Should refuse an item given twice",
+			payload: []types.ReturnItemPayload{{OrderItemID: 10, Quantity: 1}, {OrderItemID: 10, Quantity: 1}},
+			fails:   true,
+		},
+		{
+			name:    "Should refuse more than was ordered",
+			payload: []types.ReturnItemPayload{{OrderItemID: 10, Quantity: 3}},
+			fails:   true,
+			err:     types.ErrReturnQuantity,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			items, err := returnItemsFromPayload(order, orderItems, c.payload)
+			if c.fails != (err != nil) {
+				t.Fatalf("expected the items to fail to be %v got %v", c.fails, err)
+			}
+			if c.err != nil && !errors.Is(err, c.err) {
+				t.Errorf("expected %v got %v", c.err, err)
+			}
+			if err == nil && (items[0].ProductID != 5 || items[0].LineRefundable.Amount != 2000) {
+				t.Errorf("expected the item to be taken from the order got %+v", items[0])
+			}
+		})
+	}
+}
+
+func TestReceivedItemsFromPayload(t *testing.T) {
+	ret := &types.Return{ID: 1, Items: []types.ReturnItem{
+		{ID: 1, ProductID: 5, Quantity: 2},
+		{ID: 2, ProductID: 6, Quantity: 1},
+	}}
+	shipments := []types.Shipment{{WarehouseID: 3, Items: []types.ShipmentItem{{ProductID: 5}, {ProductID: 6}}}}
+
+	t.Run("Should restock to the warehouse the items were shipped from", func(t *testing.T) {
+		items, err := receivedItemsFromPayload(ret, shipments, []types.ReturnReceiptItemPayload{
+			{ReturnItemID: 1, ReceivedQuantity: 2, Disposition: types.ReturnDispositionRestock},
+			{ReturnItemID: 2, ReceivedQuantity: 1, Disposition: types.ReturnDispositionWriteOff, WarehouseID: 4},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if items[0].WarehouseID == nil || *items[0].WarehouseID != 3 {
+			t.Errorf("expected the restocked item to go back to warehouse 3 got %v", items[0].WarehouseID)
+		}
+		if items[1].WarehouseID != nil || *items[1].Disposition != types.ReturnDispositionWriteOff {
+			t.Errorf("expected the written off item not to go to a warehouse got %v", items[1].WarehouseID)
+		}
+	})
+
+	t.Run("Should restock to the given warehouse", func(t *testing.T) {
+		items, err := receivedItemsFromPayload(ret, shipments, []types.ReturnReceiptItemPayload{
+			{ReturnItemID: 1, ReceivedQuantity: 1, Disposition: types.ReturnDispositionRestock, WarehouseID: 4},
+			{ReturnItemID: 2, ReceivedQuantity: 0, Disposition: types.ReturnDispositionWriteOff},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if *items[0].WarehouseID != 4 {
+			t.Errorf("expected the item to go to warehouse 4 got %v", *items[0].WarehouseID)
+		}
+	})
+
+	failing := []struct {
+		name    string
+		payload []types.ReturnReceiptItemPayload
+	}{
+		{
+			name:    "Should refuse a receipt missing an item",
+			payload: []types.ReturnReceiptItemPayload{{ReturnItemID: 1, ReceivedQuantity: 2, Disposition: types.ReturnDispositionRestock}},
+		},
+		{
+			name: "Should refuse more than was returned",
+			payload: []types.ReturnReceiptItemPayload{
+				{ReturnItemID: 1, ReceivedQuantity: 3, Disposition: types.ReturnDispositionRestock},
+				{ReturnItemID: 2, ReceivedQuantity: 1, Disposition: types.ReturnDispositionRestock},
+			},
+		},
+		{
+			name: "Should refuse an item given twice",
+			payload: []types.ReturnReceiptItemPayload{
+				{ReturnItemID: 1, ReceivedQuantity: 1, Disposition: types.ReturnDispositionRestock},
+				{ReturnItemID: 1, ReceivedQuantity: 1, Disposition: types.ReturnDispositionRestock},
+			},
+		},
+	}
+
+	for _, c := range failing {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := receivedItemsFromPayload(ret, shipments, c.payload); err == nil {
+				t.Error("expected the receipt to fail")
+			}
+		})
+	}
+}
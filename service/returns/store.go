@@ -0,0 +1,417 @@
+package returns
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/inventory"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// the statuses in which the items of a return are still claimed against the order.
+var claimingStatuses = []string{
+	types.ReturnStatusRequested,
+	types.ReturnStatusApproved,
+	types.ReturnStatusReceived,
+	types.ReturnStatusPartiallyRefunded,
+	types.ReturnStatusRefunded,
+}
+
+// the statuses in which the items of a return are back and counted in the refunds.
+var receivedStatuses = []string{
+	types.ReturnStatusReceived,
+	types.ReturnStatusPartiallyRefunded,
+	types.ReturnStatusRefunded,
+}
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// CreateReturn stores the requested return with its items. The order is locked so the returns of
+// the same order are created one after the other, and an item can't be claimed beyond its quantity.
+func (s *Store) CreateReturn(ret types.Return) (*types.Return, error) {
+	var returnId int64
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		if err := lockOrder(tx, ret.OrderID); err != nil {
+			return err
+		}
+
+		for i, item := range ret.Items {
+			ordered, claimed, err := returnedQuantities(tx, item.OrderItemID, 0, claimingStatuses)
+			if err != nil {
+				return err
+			}
+			if claimed+item.Quantity > ordered {
+				return fmt.Errorf("%w, %v of order item %v can still be returned", types.ErrReturnQuantity, ordered-claimed, item.OrderItemID)
+			}
+
+			ret.Items[i].RefundAmount = RefundShare(item.LineRefundable, ordered, claimed, item.Quantity)
+		}
+
+		result, err := tx.Exec("INSERT INTO returns (orderId, userId, status, reason, refundableTotal) VALUES (?,?,?,?,?)",
+			ret.OrderID, ret.UserID, types.ReturnStatusRequested, ret.Reason, sumRefundAmounts(ret.Items))
+		if err != nil {
+			return err
+		}
+
+		returnId, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		for _, item := range ret.Items {
+			_, err := tx.Exec(`
+			INSERT INTO returnItems (returnId, orderItemId, productId, quantity, lineRefundable, refundAmount)
+			VALUES (?,?,?,?,?,?)`,
+				returnId, item.OrderItemID, item.ProductID, item.Quantity, item.LineRefundable, item.RefundAmount)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetReturnById(int(returnId))
+}
+
+func (s *Store) GetReturnById(id int) (*types.Return, error) {
+	ret := new(types.Return)
+	err := s.db.QueryRow("SELECT * FROM returns WHERE id = ?", id).Scan(returnAllFieldsScanner(ret))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no return was found for id %v", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret.Items, err = getReturnItems(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// GetReturns lists the returns of the user, or of everyone with a userID of 0, the newest first.
+func (s *Store) GetReturns(userID int, status string, limit, offset int) ([]types.Return, int, error) {
+	rows, err := s.db.Query(`
+	SELECT * FROM returns WHERE (? = 0 OR userId = ?) AND (? = '' OR status = ?) ORDER BY id DESC LIMIT ? OFFSET ?`,
+		userID, userID, status, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	returns := make([]types.Return, 0)
+	for rows.Next() {
+		ret := new(types.Return)
+		if err := rows.Scan(returnAllFieldsScanner(ret)); err != nil {
+			return nil, 0, err
+		}
+
+		returns = append(returns, *ret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	for i := range returns {
+		returns[i].Items, err = getReturnItems(s.db, returns[i].ID)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var count int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM returns WHERE (? = 0 OR userId = ?) AND (? = '' OR status = ?)",
+		userID, userID, status, status).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return returns, count, nil
+}
+
+// UpdateReturnStatus moves the return to status when it's in one of the from statuses,
+// otherwise ErrReturnStatus is returned and nothing changes.
+func (s *Store) UpdateReturnStatus(id int, from []string, status, staffNote string) error {
+	args := []any{status, staffNote, id}
+	for _, fromStatus := range from {
+		args = append(args, fromStatus)
+	}
+
+	result, err := s.db.Exec(fmt.Sprintf("UPDATE returns SET status = ?, staffNote = ? WHERE id = ? AND status IN (%s)",
+		placeholders(len(from))), args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w, return with id %v isn't %s", types.ErrReturnStatus, id, strings.Join(from, " or "))
+	}
+
+	return nil
+}
+
+// ReceiveReturn records what came back of an approved return. The restocked units go back to the
+// stock with a return movement, the written off ones never re-enter the stock so they're only recorded
+// on the return item with their quantity and disposition. The refund amounts are taken
+// again from the received quantities, so once every unit of an order line is received back its
+// refunds add up to exactly what the line was paid.
+func (s *Store) ReceiveReturn(id int, items []types.ReturnItem, actorID int) (*types.Return, error) {
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		var orderId int
+		var status string
+		err := tx.QueryRow("SELECT orderId, status FROM returns WHERE id = ?", id).Scan(&orderId, &status)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no return was found for id %v", id)
+		}
+		if err != nil {
+			return err
+		}
+
+		// the order is locked before the return, like when the returns are created.
+		if err := lockOrder(tx, orderId); err != nil {
+			return err
+		}
+		err = tx.QueryRow("SELECT status FROM returns WHERE id = ? FOR UPDATE", id).Scan(&status)
+		if err != nil {
+			return err
+		}
+		if status != types.ReturnStatusApproved {
+			return fmt.Errorf("%w, return with id %v is %s", types.ErrReturnStatus, id, status)
+		}
+
+		stored, err := getReturnItems(tx, id)
+		if err != nil {
+			return err
+		}
+		storedIndex := make(map[int]int)
+		for i, item := range stored {
+			storedIndex[item.ID] = i
+		}
+
+		refundableTotal := money.Zero()
+		for _, item := range items {
+			index, ok := storedIndex[item.ID]
+			if !ok {
+				return fmt.Errorf("no item was found for id %v in return %v", item.ID, id)
+			}
+			storedItem := stored[index]
+			if item.ReceivedQuantity > storedItem.Quantity {
+				return fmt.Errorf("%v units of item %v were received but only %v were returned", item.ReceivedQuantity, item.ID, storedItem.Quantity)
+			}
+
+			ordered, received, err := returnedQuantities(tx, storedItem.OrderItemID, id, receivedStatuses)
+			if err != nil {
+				return err
+			}
+			refundAmount := RefundShare(storedItem.LineRefundable, ordered, received, item.ReceivedQuantity)
+			refundableTotal = refundableTotal.Add(refundAmount)
+
+			_, err = tx.Exec("UPDATE returnItems SET receivedQuantity = ?, disposition = ?, warehouseId = ?, refundAmount = ? WHERE id = ?",
+				item.ReceivedQuantity, item.Disposition, item.WarehouseID, refundAmount, item.ID)
+			if err != nil {
+				return err
+			}
+
+			if item.ReceivedQuantity > 0 && item.Disposition != nil && *item.Disposition == types.ReturnDispositionRestock {
+				_, err := inventory.ApplyMovement(tx, types.StockMovement{
+					ProductID:      storedItem.ProductID,
+					QuantityChange: item.ReceivedQuantity,
+					Reason:         types.StockReasonReturn,
+					ActorID:        &actorID,
+					OrderID:        &orderId,
+					Note:           fmt.Sprintf("return %d", id),
+					WarehouseID:    item.WarehouseID,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// nothing is owed for a return worth nothing, e.g. the items of a free order.
+		status = types.ReturnStatusReceived
+		if refundableTotal.IsZero() {
+			status = types.ReturnStatusRefunded
+		}
+
+		_, err = tx.Exec("UPDATE returns SET status = ?, refundableTotal = ? WHERE id = ?", status, refundableTotal, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetReturnById(id)
+}
+
+// AddReturnRefund adds the amount to what was refunded for a received return, a negative amount takes
+// back a refund that didn't go through. The return ends up refunded once nothing is left to refund,
+// and ErrReturnRefund is returned when the amount doesn't fit what's left.
+func (s *Store) AddReturnRefund(id int, amount money.Money) (*types.Return, error) {
+	// the status is set first so it's worked out from the refunded total before the amount is added.
+	result, err := s.db.Exec(fmt.Sprintf(`
+	UPDATE returns SET
+		status = CASE
+			WHEN refundedTotal + ? <= 0 THEN ?
+			WHEN refundedTotal + ? >= refundableTotal THEN ?
+			ELSE ? END,
+		refundedTotal = refundedTotal + ?
+	WHERE id = ? AND status IN (%s) AND refundedTotal + ? BETWEEN 0 AND refundableTotal`, placeholders(len(receivedStatuses))),
+		amount, types.ReturnStatusReceived, amount, types.ReturnStatusRefunded, types.ReturnStatusPartiallyRefunded,
+		amount, id, receivedStatuses[0], receivedStatuses[1], receivedStatuses[2], amount)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("%w of return with id %v", types.ErrReturnRefund, id)
+	}
+
+	return s.GetReturnById(id)
+}
+
+// RefundShare is what quantity units of an order line are worth when already units of it were returned
+// before. It's taken as the difference of the rounded down shares of the line, so the shares of all
+// the units add up to exactly the line amount whichever way they are split.
+func RefundShare(lineRefundable money.Money, ordered, already, quantity int) money.Money {
+	if ordered <= 0 {
+		return money.Zero()
+	}
+
+	upTo := lineRefundable.MulDiv(int64(already+quantity), int64(ordered), money.RoundDown)
+	before := lineRefundable.MulDiv(int64(already), int64(ordered), money.RoundDown)
+
+	return upTo.Sub(before)
+}
+
+func lockOrder(tx myDB.DBTX, orderID int) error {
+	var id int
+	err := tx.QueryRow("SELECT id FROM orders WHERE id = ? FOR UPDATE", orderID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no order was found for id %v", orderID)
+	}
+
+	return err
+}
+
+// returnedQuantities returns the ordered quantity of the order item and how much of it the other
+// returns in the given statuses claim, with the requested quantity or the received one once received.
+func returnedQuantities(tx myDB.DBTX, orderItemID, exceptReturnID int, statuses []string) (int, int, error) {
+	var ordered int
+	err := tx.QueryRow("SELECT quantity FROM orderItems WHERE id = ?", orderItemID).Scan(&ordered)
+	if err == sql.ErrNoRows {
+		return 0, 0, fmt.Errorf("no order item was found for id %v", orderItemID)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	args := []any{types.ReturnStatusRequested, types.ReturnStatusApproved, orderItemID, exceptReturnID}
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+
+	var returned int
+	err = tx.QueryRow(fmt.Sprintf(`
+	SELECT COALESCE(SUM(IF(returns.status IN (?, ?), returnItems.quantity, returnItems.receivedQuantity)), 0)
+	FROM returnItems JOIN returns ON returns.id = returnItems.returnId
+	WHERE returnItems.orderItemId = ? AND returns.id != ? AND returns.status IN (%s)`, placeholders(len(statuses))),
+		args...).Scan(&returned)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return ordered, returned, nil
+}
+
+func getReturnItems(q myDB.DBTX, returnID int) ([]types.ReturnItem, error) {
+	rows, err := q.Query("SELECT * FROM returnItems WHERE returnId = ? ORDER BY id", returnID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	items := make([]types.ReturnItem, 0)
+	for rows.Next() {
+		item := new(types.ReturnItem)
+		if err := rows.Scan(returnItemAllFieldsScanner(item)); err != nil {
+			return nil, err
+		}
+
+		items = append(items, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func sumRefundAmounts(items []types.ReturnItem) money.Money {
+	total := money.Zero()
+	for _, item := range items {
+		total = total.Add(item.RefundAmount)
+	}
+
+	return total
+}
+
+func placeholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", count), ",")
+}
+
+func returnAllFieldsScanner(ret *types.Return) (*int, *int, *int, *string, *string, *string, *money.Money, *money.Money,
+	*time.Time, *time.Time) {
+	return &ret.ID,
+		&ret.OrderID,
+		&ret.UserID,
+		&ret.Status,
+		&ret.Reason,
+		&ret.StaffNote,
+		&ret.RefundableTotal,
+		&ret.RefundedTotal,
+		&ret.CreatedAt,
+		&ret.UpdatedAt
+}
+
+func returnItemAllFieldsScanner(item *types.ReturnItem) (*int, *int, *int, *int, *int, *money.Money, *money.Money, *int,
+	**string, **int) {
+	return &item.ID,
+		&item.ReturnID,
+		&item.OrderItemID,
+		&item.ProductID,
+		&item.Quantity,
+		&item.LineRefundable,
+		&item.RefundAmount,
+		&item.ReceivedQuantity,
+		&item.Disposition,
+		&item.WarehouseID
+}
@@ -0,0 +1,47 @@
+package shipping
+
+import (
+	"fmt"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+const FakeCarrierName = "fake"
+
+// a parcel with a side longer than this is charged the oversize fee.
+const oversizeMm = 1200
+
+// FakeCarrier quotes predictable rates without calling anyone, it stands in for a real carrier
+// in development and tests. The standard service is 4.00 plus 1.00 per started kilogram, express
+// costs twice as much and an oversize parcel adds 10.00.
+type FakeCarrier struct{}
+
+// NewCarriers returns the carriers the carrier methods can name.
+func NewCarriers() map[string]types.ShippingCarrier {
+	return map[string]types.ShippingCarrier{
+		FakeCarrierName: FakeCarrier{},
+	}
+}
+
+func (FakeCarrier) Rate(service string, address types.ShippingAddress, parcel types.Parcel) (money.Money, error) {
+	kilograms := int64((parcel.WeightGrams + 999) / 1000)
+	cost := money.FromMinor(400).Add(money.FromMinor(100).Mul(kilograms))
+
+	switch service {
+	case "", "standard":
+	case "express":
+		cost = cost.Mul(2)
+	default:
+		return money.Money{}, fmt.Errorf("%w: the %s carrier has no '%s' service", types.ErrShippingMethodUnavailable, FakeCarrierName, service)
+	}
+
+	for _, item := range parcel.Items {
+		if max(item.LengthMm, item.WidthMm, item.HeightMm) > oversizeMm {
+			cost = cost.Add(money.FromMinor(1000))
+			break
+		}
+	}
+
+	return cost, nil
+}
@@ -0,0 +1,164 @@
+package shipping
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// RateCalculator prices the active methods of the zone an address belongs to,
+// the carrier methods are quoted by the carrier they name.
+type RateCalculator struct {
+	store    types.ShippingStore
+	carriers map[string]types.ShippingCarrier
+}
+
+func NewRateCalculator(store types.ShippingStore, carriers map[string]types.ShippingCarrier) *RateCalculator {
+	return &RateCalculator{
+		store:    store,
+		carriers: carriers,
+	}
+}
+
+// Quote returns the rates of every method that can ship the parcel, cheapest first.
+// A carrier that can't be reached only hides its own methods.
+func (c *RateCalculator) Quote(address types.ShippingAddress, parcel types.Parcel) ([]types.ShippingRate, error) {
+	zone, err := c.zoneFor(address)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make([]types.ShippingRate, 0)
+	for _, method := range zone.Methods {
+		if !method.Active {
+			continue
+		}
+
+		rate, err := c.rate(method, address, parcel)
+		if errors.Is(err, types.ErrShippingMethodUnavailable) {
+			continue
+		}
+		if errors.Is(err, types.ErrCarrierUnavailable) {
+			log.Printf("shipping method %d can't be quoted: %v", method.ID, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, rate)
+	}
+
+	slices.SortStableFunc(rates, func(a, b types.ShippingRate) int {
+		if byCost := a.Cost.Cmp(b.Cost); byCost != 0 {
+			return byCost
+		}
+		return cmp.Compare(a.MethodID, b.MethodID)
+	})
+
+	return rates, nil
+}
+
+// QuoteMethod prices the method chosen at checkout, it must be active and belong to the zone of the address.
+func (c *RateCalculator) QuoteMethod(methodID int, address types.ShippingAddress, parcel types.Parcel) (types.ShippingRate, error) {
+	method, err := c.store.GetShippingMethodById(methodID)
+	if err != nil {
+		return types.ShippingRate{}, fmt.Errorf("%w: %v", types.ErrShippingMethodUnavailable, err)
+	}
+
+	zone, err := c.zoneFor(address)
+	if err != nil {
+		return types.ShippingRate{}, err
+	}
+	if !method.Active || method.ZoneID != zone.ID {
+		return types.ShippingRate{}, fmt.Errorf("%w: %s doesn't ship to %s", types.ErrShippingMethodUnavailable,
+			method.Name, formatLocation(address.Country, address.Region))
+	}
+
+	return c.rate(*method, address, parcel)
+}
+
+func (c *RateCalculator) zoneFor(address types.ShippingAddress) (*types.ShippingZone, error) {
+	zone, err := c.store.GetShippingZoneFor(address.Country, address.Region)
+	if err != nil {
+		return nil, err
+	}
+	if zone == nil {
+		return nil, fmt.Errorf("%w: we don't ship to %s", types.ErrShippingMethodUnavailable, formatLocation(address.Country, address.Region))
+	}
+
+	return zone, nil
+}
+
+func (c *RateCalculator) rate(method types.ShippingMethod, address types.ShippingAddress, parcel types.Parcel) (types.ShippingRate, error) {
+	if method.MaxWeightGrams != nil && parcel.WeightGrams > *method.MaxWeightGrams {
+		return types.ShippingRate{}, fmt.Errorf("%w: %s can't ship parcels over %d g", types.ErrShippingMethodUnavailable,
+			method.Name, *method.MaxWeightGrams)
+	}
+
+	rate := types.ShippingRate{
+		MethodID: method.ID,
+		Name:     method.Name,
+		Type:     method.Type,
+		Cost:     money.Zero(),
+	}
+	if method.Type == types.ShippingMethodCarrier {
+		rate.Carrier = method.Carrier
+	}
+
+	if method.FreeOver != nil && parcel.Goods.Cmp(*method.FreeOver) >= 0 {
+		return rate, nil
+	}
+
+	switch method.Type {
+	case types.ShippingMethodFlatRate, types.ShippingMethodFreeOver:
+		rate.Cost = method.Rate
+	case types.ShippingMethodWeightBased:
+		// every started kilogram is charged.
+		kilograms := (parcel.WeightGrams + 999) / 1000
+		rate.Cost = method.Rate.Add(method.RatePerKg.Mul(int64(kilograms)))
+	case types.ShippingMethodCarrier:
+		carrier, ok := c.carriers[method.Carrier]
+		if !ok {
+			return types.ShippingRate{}, fmt.Errorf("%w: unknown carrier '%s'", types.ErrShippingMethodUnavailable, method.Carrier)
+		}
+
+		cost, err := carrier.Rate(method.CarrierService, address, parcel)
+		if err != nil {
+			return types.ShippingRate{}, err
+		}
+		rate.Cost = cost
+	default:
+		return types.ShippingRate{}, fmt.Errorf("unknown shipping method type '%s'", method.Type)
+	}
+
+	return rate, nil
+}
+
+// NewParcel packs the cart lines, the weight and dimensions come from the products.
+func NewParcel(cartItems []types.CartCheckoutItem, productsMap map[int]types.Product, goods money.Money) types.Parcel {
+	parcel := types.Parcel{
+		Goods: goods,
+		Items: make([]types.ParcelItem, 0, len(cartItems)),
+	}
+
+	for _, cartItem := range cartItems {
+		product := productsMap[cartItem.ProductID]
+		parcel.WeightGrams += product.WeightGrams * cartItem.Quantity
+		parcel.Items = append(parcel.Items, types.ParcelItem{
+			ProductID:   cartItem.ProductID,
+			Quantity:    cartItem.Quantity,
+			WeightGrams: product.WeightGrams,
+			LengthMm:    product.LengthMm,
+			WidthMm:     product.WidthMm,
+			HeightMm:    product.HeightMm,
+		})
+	}
+
+	return parcel
+}
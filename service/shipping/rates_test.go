@@ -0,0 +1,184 @@
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestRateCalculator(t *testing.T) {
+	cents := money.FromMinor
+	maxWeight := 2000
+	freeOver := cents(5000)
+
+	europe := types.ShippingZone{ID: 1, Name: "Europe", Methods: []types.ShippingMethod{
+		{ID: 1, ZoneID: 1, Name: "Standard", Type: types.ShippingMethodFlatRate, Rate: cents(500), Active: true},
+		{ID: 2, ZoneID: 1, Name: "By weight", Type: types.ShippingMethodWeightBased, Rate: cents(200), RatePerKg: cents(150), Active: true},
+		{ID: 3, ZoneID: 1, Name: "Free over 50", Type: types.ShippingMethodFreeOver, Rate: cents(800), FreeOver: &freeOver, Active: true},
+		{ID: 4, ZoneID: 1, Name: "Letter", Type: types.ShippingMethodFlatRate, Rate: cents(100), MaxWeightGrams: &maxWeight, Active: true},
+		{ID: 5, ZoneID: 1, Name: "Express", Type: types.ShippingMethodCarrier, Carrier: FakeCarrierName, CarrierService: "express", Active: true},
+		{ID: 6, ZoneID: 1, Name: "Retired", Type: types.ShippingMethodFlatRate, Rate: cents(50), Active: false},
+	}}
+	us := types.ShippingZone{ID: 2, Name: "United States", Methods: []types.ShippingMethod{
+		{ID: 7, ZoneID: 2, Name: "Ground", Type: types.ShippingMethodFlatRate, Rate: cents(900), Active: true},
+	}}
+
+	calculator := NewRateCalculator(&mockShippingStore{
+		zones: map[string]*types.ShippingZone{"DE": &europe, "US": &us},
+	}, NewCarriers())
+	germany := types.ShippingAddress{Line1: "1 Hauptstrasse", City: "Berlin", Country: "DE"}
+
+	cases := []struct {
+		name     string
+		methodID int
+		address  types.ShippingAddress
+		parcel   types.Parcel
+		cost     int64
+		err      error
+	}{
+		{
+			name:     "Should charge the flat rate",
+			methodID: 1,
+			address:  germany,
+			parcel:   types.Parcel{WeightGrams: 3500, Goods: cents(2000)},
+			cost:     500,
+		},
+		{
+			name:     "Should charge every started kilogram",
+			methodID: 2,
+			address:  germany,
+			parcel:   types.Parcel{WeightGrams: 2001, Goods: cents(2000)},
+			cost:     650,
+		},
+		{
+			name:     "Should charge the rate under the free shipping threshold",
+			methodID: 3,
+			address:  germany,
+			parcel:   types.Parcel{WeightGrams: 500, Goods: cents(4999)},
+			cost:     800,
+		},
+		{
+			name:     "Should ship for free from the threshold",
+			methodID: 3,
+			address:  germany,
+			parcel:   types.Parcel{WeightGrams: 500, Goods: cents(5000)},
+			cost:     0,
+		},
+		{
+			name:     "Should refuse a parcel over the weight limit of the method",
+			methodID: 4,
+			address:  germany,
+			parcel:   types.Parcel{WeightGrams: 2001, Goods: cents(2000)},
+			err:      types.ErrShippingMethodUnavailable,
+		},
+		{
+			name:     "Should ask the carrier for the rate",
+			methodID: 5,
+			address:  germany,
+			parcel: types.Parcel{WeightGrams: 1500, Goods: cents(2000), Items: []types.ParcelItem{
+				{ProductID: 1, Quantity: 1, WeightGrams: 1500, LengthMm: 1300, WidthMm: 200, HeightMm: 100},
+			}},
+			// (4.00 + 2 kg) x 2 for express + 10.00 oversize.
+			cost: 2200,
+		},
+		{
+			name:     "Should refuse an inactive method",
+			methodID: 6,
+			address:  germany,
+			parcel:   types.Parcel{WeightGrams: 500, Goods: cents(2000)},
+			err:      types.ErrShippingMethodUnavailable,
+		},
+		{
+			name:     "Should refuse a method of another zone",
+			methodID: 7,
+			address:  germany,
+			parcel:   types.Parcel{WeightGrams: 500, Goods: cents(2000)},
+			err:      types.ErrShippingMethodUnavailable,
+		},
+		{
+			name:     "Should refuse an address that isn't in any zone",
+			methodID: 1,
+			address:  types.ShippingAddress{Line1: "1 Rue de Rivoli", City: "Paris", Country: "FR"},
+			parcel:   types.Parcel{WeightGrams: 500, Goods: cents(2000)},
+			err:      types.ErrShippingMethodUnavailable,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rate, err := calculator.QuoteMethod(c.methodID, c.address, c.parcel)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expected %v got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rate.MethodID != c.methodID || rate.Cost.Amount != c.cost {
+				t.Errorf("expected method %d to cost %d got method %d costing %s", c.methodID, c.cost, rate.MethodID, rate.Cost)
+			}
+		})
+	}
+
+	t.Run("Should quote the active methods that can ship the parcel cheapest first", func(t *testing.T) {
+		rates, err := calculator.Quote(germany, types.Parcel{WeightGrams: 2500, Goods: cents(6000)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []int{3, 1, 2, 5}
+		if len(rates) != len(expected) {
+			t.Fatalf("expected %d rates got %+v", len(expected), rates)
+		}
+		for i, methodID := range expected {
+			if rates[i].MethodID != methodID {
+				t.Errorf("expected rate %d to be method %d got %d", i, methodID, rates[i].MethodID)
+			}
+		}
+	})
+}
+
+func TestNewParcel(t *testing.T) {
+	productsMap := map[int]types.Product{
+		1: {ID: 1, WeightGrams: 250, LengthMm: 100, WidthMm: 50, HeightMm: 20},
+		2: {ID: 2, WeightGrams: 1200},
+	}
+
+	parcel := NewParcel([]types.CartCheckoutItem{{ProductID: 1, Quantity: 4}, {ProductID: 2, Quantity: 1}}, productsMap, money.FromMinor(3000))
+	if parcel.WeightGrams != 2200 {
+		t.Errorf("expected the parcel to weigh 2200 g got %d", parcel.WeightGrams)
+	}
+	if len(parcel.Items) != 2 || parcel.Items[0].LengthMm != 100 || parcel.Items[0].Quantity != 4 {
+		t.Errorf("expected the items to carry the products dimensions got %+v", parcel.Items)
+	}
+	if parcel.Goods.Amount != 3000 {
+		t.Errorf("expected the goods to be worth 3000 got %s", parcel.Goods)
+	}
+}
+
+type mockShippingStore struct {
+	types.ShippingStore
+	zones map[string]*types.ShippingZone
+}
+
+func (m *mockShippingStore) GetShippingZoneFor(country, region string) (*types.ShippingZone, error) {
+	return m.zones[country], nil
+}
+
+func (m *mockShippingStore) GetShippingMethodById(id int) (*types.ShippingMethod, error) {
+	for _, zone := range m.zones {
+		for _, method := range zone.Methods {
+			if method.ID == id {
+				return &method, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no shipping method was found for id %v", id)
+}
@@ -0,0 +1,379 @@
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+type Handler struct {
+	store        types.ShippingStore
+	productStore types.ProductStore
+	quoter       types.ShippingQuoter
+}
+
+func NewHandler(store types.ShippingStore, productStore types.ProductStore, quoter types.ShippingQuoter) *Handler {
+	return &Handler{
+		store:        store,
+		productStore: productStore,
+		quoter:       quoter,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/shipping/rates", h.GetRates).Methods("GET")
+
+	router.HandleFunc("/admin/shipping/zones", auth.AdminMiddleware(h.GetZones)).Methods("GET")
+	router.HandleFunc("/admin/shipping/zones", auth.AdminMiddleware(h.CreateZone)).Methods("POST")
+	router.HandleFunc("/admin/shipping/zones/{id}", auth.AdminMiddleware(h.GetZone)).Methods("GET")
+	router.HandleFunc("/admin/shipping/zones/{id}", auth.AdminMiddleware(h.UpdateZone)).Methods("PUT")
+	router.HandleFunc("/admin/shipping/zones/{id}", auth.AdminMiddleware(h.DeleteZone)).Methods("DELETE")
+	router.HandleFunc("/admin/shipping/zones/{id}/methods", auth.AdminMiddleware(h.CreateMethod)).Methods("POST")
+	router.HandleFunc("/admin/shipping/methods/{id}", auth.AdminMiddleware(h.GetMethod)).Methods("GET")
+	router.HandleFunc("/admin/shipping/methods/{id}", auth.AdminMiddleware(h.UpdateMethod)).Methods("PUT")
+	router.HandleFunc("/admin/shipping/methods/{id}", auth.AdminMiddleware(h.DeleteMethod)).Methods("DELETE")
+}
+
+// GetRates quotes the methods that can ship the cart to the address, the cart is given as
+// items=productId:quantity,... and the address as country, region and postalCode, e.g.
+// /shipping/rates?country=US&region=OR&items=1:2,7:1. The free shipping thresholds are
+// checked against the cart before its discounts.
+func (h *Handler) GetRates(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	address := types.ShippingAddress{
+		Country:    strings.ToUpper(strings.TrimSpace(query.Get("country"))),
+		Region:     strings.TrimSpace(query.Get("region")),
+		PostalCode: strings.TrimSpace(query.Get("postalCode")),
+	}
+	if err := utils.Validate.Var(address.Country, "required,iso3166_1_alpha2"); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("country must be an ISO 3166-1 alpha-2 code"))
+		return
+	}
+
+	cartItems, err := parseRateItems(query["items"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	productsIds := make([]int, len(cartItems))
+	for i, cartItem := range cartItems {
+		productsIds[i] = cartItem.ProductID
+	}
+	products, err := h.productStore.GetProductsByID(productsIds)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	productsMap := make(map[int]types.Product, len(products))
+	for _, product := range products {
+		productsMap[product.ID] = product
+	}
+
+	goods := money.Zero()
+	for _, cartItem := range cartItems {
+		product, ok := productsMap[cartItem.ProductID]
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("product with %v id does not exist", cartItem.ProductID))
+			return
+		}
+		goods = goods.Add(product.Price.Mul(int64(cartItem.Quantity)))
+	}
+
+	rates, err := h.quoter.Quote(address, NewParcel(cartItems, productsMap, goods))
+	if errors.Is(err, types.ErrShippingMethodUnavailable) {
+		utils.WriteError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    rates,
+	})
+}
+
+func (h *Handler) GetZones(w http.ResponseWriter, r *http.Request) {
+	zones, err := h.store.GetShippingZones()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    zones,
+	})
+}
+
+func (h *Handler) GetZone(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	zone, err := h.store.GetShippingZoneById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    zone,
+	})
+}
+
+func (h *Handler) CreateZone(w http.ResponseWriter, r *http.Request) {
+	zone, err := parseZonePayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	created, err := h.store.CreateShippingZone(zone)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    created,
+	})
+}
+
+// PUT renames the zone and replaces its locations.
+func (h *Handler) UpdateZone(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	zone, err := parseZonePayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := h.store.UpdateShippingZone(id, zone)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    updated,
+	})
+}
+
+func (h *Handler) DeleteZone(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DeleteShippingZone(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusNoContent, map[string]any{})
+}
+
+func (h *Handler) GetMethod(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	method, err := h.store.GetShippingMethodById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    method,
+	})
+}
+
+func (h *Handler) CreateMethod(w http.ResponseWriter, r *http.Request) {
+	zoneId, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	method, err := parseMethodPayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	method.ZoneID = zoneId
+
+	created, err := h.store.CreateShippingMethod(method)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    created,
+	})
+}
+
+func (h *Handler) UpdateMethod(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	method, err := parseMethodPayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := h.store.UpdateShippingMethod(id, method)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    updated,
+	})
+}
+
+func (h *Handler) DeleteMethod(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DeleteShippingMethod(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusNoContent, map[string]any{})
+}
+
+// every value holds comma separated productId:quantity pairs, a product given twice is counted once with both quantities.
+func parseRateItems(values []string) ([]types.CartCheckoutItem, error) {
+	cartItems := make([]types.CartCheckoutItem, 0)
+	itemsIndex := make(map[int]int)
+	for _, value := range values {
+		for _, pair := range strings.Split(value, ",") {
+			productPart, quantityPart, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			productId, productErr := strconv.Atoi(productPart)
+			quantity, quantityErr := strconv.Atoi(quantityPart)
+			if !ok || productErr != nil || quantityErr != nil || productId < 1 || quantity < 1 {
+				return nil, fmt.Errorf("invalid item '%s', items must be productId:quantity", pair)
+			}
+
+			if index, ok := itemsIndex[productId]; ok {
+				cartItems[index].Quantity += quantity
+				continue
+			}
+			itemsIndex[productId] = len(cartItems)
+			cartItems = append(cartItems, types.CartCheckoutItem{ProductID: productId, Quantity: quantity})
+		}
+	}
+
+	if len(cartItems) == 0 {
+		return nil, fmt.Errorf("items are required")
+	}
+
+	return cartItems, nil
+}
+
+func parseZonePayload(r *http.Request) (types.ShippingZone, error) {
+	var payload types.ShippingZonePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return types.ShippingZone{}, err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return types.ShippingZone{}, err
+	}
+
+	return types.ShippingZone{
+		Name:      strings.TrimSpace(payload.Name),
+		Locations: payload.Locations,
+	}, nil
+}
+
+func parseMethodPayload(r *http.Request) (types.ShippingMethod, error) {
+	var payload types.ShippingMethodPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return types.ShippingMethod{}, err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return types.ShippingMethod{}, err
+	}
+
+	switch payload.Type {
+	case types.ShippingMethodWeightBased:
+		if !payload.RatePerKg.IsPositive() {
+			return types.ShippingMethod{}, fmt.Errorf("weight_based needs a ratePerKg")
+		}
+	case types.ShippingMethodFreeOver:
+		if payload.FreeOver == nil {
+			return types.ShippingMethod{}, fmt.Errorf("free_over needs a freeOver amount")
+		}
+	}
+
+	active := true
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+
+	return types.ShippingMethod{
+		Name:           strings.TrimSpace(payload.Name),
+		Type:           payload.Type,
+		Rate:           payload.Rate,
+		RatePerKg:      payload.RatePerKg,
+		FreeOver:       payload.FreeOver,
+		Carrier:        strings.TrimSpace(payload.Carrier),
+		CarrierService: strings.TrimSpace(payload.CarrierService),
+		MaxWeightGrams: payload.MaxWeightGrams,
+		Active:         active,
+	}, nil
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("id must be unsigned integer")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,340 @@
+package shipping
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) GetShippingZones() ([]types.ShippingZone, error) {
+	rows, err := s.db.Query("SELECT * FROM shippingZones ORDER BY name, id")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.scanZones(rows)
+}
+
+func (s *Store) GetShippingZoneById(id int) (*types.ShippingZone, error) {
+	rows, err := s.db.Query("SELECT * FROM shippingZones WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := s.scanZones(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("no shipping zone was found for id %v", id)
+	}
+
+	return &zones[0], nil
+}
+
+// returns the zone of the region when there's one, otherwise the zone of the whole country.
+// It's nil when the address isn't in any zone.
+func (s *Store) GetShippingZoneFor(country, region string) (*types.ShippingZone, error) {
+	var zoneId int
+	err := s.db.QueryRow(`
+	SELECT zoneId FROM shippingZoneLocations WHERE country = ? AND region IN ('', ?)
+	ORDER BY region DESC LIMIT 1`, strings.ToUpper(country), strings.TrimSpace(region)).Scan(&zoneId)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetShippingZoneById(zoneId)
+}
+
+func (s *Store) CreateShippingZone(zone types.ShippingZone) (*types.ShippingZone, error) {
+	var zoneId int64
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		result, err := tx.Exec("INSERT INTO shippingZones (name) VALUES (?)", zone.Name)
+		if err != nil {
+			return err
+		}
+
+		zoneId, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		return setZoneLocations(tx, int(zoneId), zone.Locations)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetShippingZoneById(int(zoneId))
+}
+
+// UpdateShippingZone renames the zone and replaces its locations, its methods are left as they are.
+func (s *Store) UpdateShippingZone(id int, zone types.ShippingZone) (*types.ShippingZone, error) {
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		var exists bool
+		err := tx.QueryRow("SELECT COUNT(*) > 0 FROM shippingZones WHERE id = ? FOR UPDATE", id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("no shipping zone was found for id %v", id)
+		}
+
+		if _, err := tx.Exec("UPDATE shippingZones SET name = ? WHERE id = ?", zone.Name, id); err != nil {
+			return err
+		}
+
+		return setZoneLocations(tx, id, zone.Locations)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetShippingZoneById(id)
+}
+
+// a zone can only be deleted once it has no methods, the locations go with it.
+func (s *Store) DeleteShippingZone(id int) error {
+	var methods int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM shippingMethods WHERE zoneId = ?", id).Scan(&methods)
+	if err != nil {
+		return err
+	}
+	if methods > 0 {
+		return fmt.Errorf("shipping zone with id %v still has %d method(s), delete them first", id, methods)
+	}
+
+	result, err := s.db.Exec("DELETE FROM shippingZones WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no shipping zone was found for id %v", id)
+	}
+
+	return nil
+}
+
+func (s *Store) GetShippingMethodById(id int) (*types.ShippingMethod, error) {
+	method := new(types.ShippingMethod)
+	err := s.db.QueryRow("SELECT * FROM shippingMethods WHERE id = ?", id).Scan(shippingMethodAllFieldsScanner(method))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no shipping method was found for id %v", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return method, nil
+}
+
+func (s *Store) CreateShippingMethod(method types.ShippingMethod) (*types.ShippingMethod, error) {
+	if _, err := s.GetShippingZoneById(method.ZoneID); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`
+	INSERT INTO shippingMethods (zoneId, name, type, rate, ratePerKg, freeOver, carrier, carrierService, maxWeightGrams, active)
+	VALUES (?,?,?,?,?,?,?,?,?,?)`,
+		method.ZoneID, method.Name, method.Type, method.Rate, method.RatePerKg, method.FreeOver, method.Carrier, method.CarrierService,
+		method.MaxWeightGrams, method.Active)
+	if err != nil {
+		return nil, err
+	}
+
+	methodId, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetShippingMethodById(int(methodId))
+}
+
+// UpdateShippingMethod replaces the method, it stays in its zone.
+func (s *Store) UpdateShippingMethod(id int, method types.ShippingMethod) (*types.ShippingMethod, error) {
+	// MySQL reports no affected rows when nothing changed, a missing method is caught when it's read back.
+	_, err := s.db.Exec(`
+	UPDATE shippingMethods SET name = ?, type = ?, rate = ?, ratePerKg = ?, freeOver = ?, carrier = ?, carrierService = ?,
+	maxWeightGrams = ?, active = ? WHERE id = ?`,
+		method.Name, method.Type, method.Rate, method.RatePerKg, method.FreeOver, method.Carrier, method.CarrierService,
+		method.MaxWeightGrams, method.Active, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetShippingMethodById(id)
+}
+
+// a method orders were shipped with stays for the order history, it can only be deactivated.
+func (s *Store) DeleteShippingMethod(id int) error {
+	var used bool
+	err := s.db.QueryRow("SELECT COUNT(*) > 0 FROM orders WHERE shippingMethodId = ?", id).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used {
+		return fmt.Errorf("shipping method with id %v was used by orders, deactivate it instead", id)
+	}
+
+	result, err := s.db.Exec("DELETE FROM shippingMethods WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no shipping method was found for id %v", id)
+	}
+
+	return nil
+}
+
+// a location belongs to a single zone, taking one from another zone is refused.
+func setZoneLocations(q myDB.DBTX, zoneID int, locations []types.ShippingLocation) error {
+	if _, err := q.Exec("DELETE FROM shippingZoneLocations WHERE zoneId = ?", zoneID); err != nil {
+		return err
+	}
+
+	for _, location := range locations {
+		country, region := strings.ToUpper(location.Country), strings.TrimSpace(location.Region)
+
+		var otherZoneId int
+		err := q.QueryRow("SELECT zoneId FROM shippingZoneLocations WHERE country = ? AND region = ?", country, region).Scan(&otherZoneId)
+		if err == nil {
+			return fmt.Errorf("%s already belongs to shipping zone %d", formatLocation(country, region), otherZoneId)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		_, err = q.Exec("INSERT INTO shippingZoneLocations (zoneId, country, region) VALUES (?,?,?)", zoneID, country, region)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatLocation(country, region string) string {
+	if region == "" {
+		return country
+	}
+
+	return country + "/" + region
+}
+
+// scans the zones then loads their locations and methods.
+func (s *Store) scanZones(rows *sql.Rows) ([]types.ShippingZone, error) {
+	defer rows.Close()
+
+	zones := make([]types.ShippingZone, 0)
+	zonesIndex := make(map[int]int)
+	for rows.Next() {
+		zone := types.ShippingZone{
+			Locations: make([]types.ShippingLocation, 0),
+			Methods:   make([]types.ShippingMethod, 0),
+		}
+		if err := rows.Scan(&zone.ID, &zone.Name, &zone.CreatedAt, &zone.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		zonesIndex[zone.ID] = len(zones)
+		zones = append(zones, zone)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(zones) == 0 {
+		return zones, nil
+	}
+
+	placeholders := strings.Repeat(",?", len(zones)-1)
+	args := make([]interface{}, len(zones))
+	for i, zone := range zones {
+		args[i] = zone.ID
+	}
+
+	locationRows, err := s.db.Query(fmt.Sprintf(
+		"SELECT zoneId, country, region FROM shippingZoneLocations WHERE zoneId IN (?%v) ORDER BY country, region", placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer locationRows.Close()
+
+	for locationRows.Next() {
+		var zoneId int
+		var location types.ShippingLocation
+		if err := locationRows.Scan(&zoneId, &location.Country, &location.Region); err != nil {
+			return nil, err
+		}
+
+		index := zonesIndex[zoneId]
+		zones[index].Locations = append(zones[index].Locations, location)
+	}
+	if err := locationRows.Err(); err != nil {
+		return nil, err
+	}
+
+	methodRows, err := s.db.Query(fmt.Sprintf("SELECT * FROM shippingMethods WHERE zoneId IN (?%v) ORDER BY id", placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer methodRows.Close()
+
+	for methodRows.Next() {
+		method := new(types.ShippingMethod)
+		if err := methodRows.Scan(shippingMethodAllFieldsScanner(method)); err != nil {
+			return nil, err
+		}
+
+		index := zonesIndex[method.ZoneID]
+		zones[index].Methods = append(zones[index].Methods, *method)
+	}
+
+	return zones, methodRows.Err()
+}
+
+func shippingMethodAllFieldsScanner(method *types.ShippingMethod) (*int, *int, *string, *string, *money.Money, *money.Money, **money.Money, *string, *string, **int, *bool, *time.Time, *time.Time) {
+	return &method.ID,
+		&method.ZoneID,
+		&method.Name,
+		&method.Type,
+		&method.Rate,
+		&method.RatePerKg,
+		&method.FreeOver,
+		&method.Carrier,
+		&method.CarrierService,
+		&method.MaxWeightGrams,
+		&method.Active,
+		&method.CreatedAt,
+		&method.UpdatedAt
+}
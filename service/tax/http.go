@@ -0,0 +1,130 @@
+package tax
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+// HTTPProvider delegates the calculation to an external tax service. The lines are posted as JSON
+// and the service answers with the taxes of every line:
+//
+//	POST {url} {"currency":"USD","pricesIncludeTax":false,"address":{...},"lines":[{"productId":1,"taxClass":"standard","amount":{...}}]}
+//	200 {"taxes":[{"productId":1,"name":"VAT","rate":20,"taxable":{...},"amount":{...}}]}
+//
+// A service that can't be reached or answers with an error makes the checkout fail with types.ErrTaxUnavailable.
+type HTTPProvider struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+type httpTaxRequest struct {
+	Currency         string            `json:"currency"`
+	PricesIncludeTax bool              `json:"pricesIncludeTax"`
+	Address          httpTaxAddress    `json:"address"`
+	Lines            []httpTaxableLine `json:"lines"`
+}
+
+type httpTaxAddress struct {
+	Country    string `json:"country"`
+	Region     string `json:"region"`
+	City       string `json:"city"`
+	PostalCode string `json:"postalCode"`
+}
+
+type httpTaxableLine struct {
+	ProductID int         `json:"productId"`
+	TaxClass  string      `json:"taxClass"`
+	Amount    money.Money `json:"amount"`
+}
+
+type httpTaxResponse struct {
+	Taxes []types.TaxLine `json:"taxes"`
+}
+
+func NewHTTPProvider(url, apiKey string) (*HTTPProvider, error) {
+	if url == "" {
+		return nil, fmt.Errorf("the tax provider url is required")
+	}
+
+	return &HTTPProvider{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *HTTPProvider) Calculate(request types.TaxRequest) ([]types.TaxLine, error) {
+	if request.Address == nil || len(request.Lines) == 0 {
+		return []types.TaxLine{}, nil
+	}
+
+	body := httpTaxRequest{
+		Currency:         money.DefaultCurrency,
+		PricesIncludeTax: request.Inclusive,
+		Address: httpTaxAddress{
+			Country:    request.Address.Country,
+			Region:     request.Address.Region,
+			City:       request.Address.City,
+			PostalCode: request.Address.PostalCode,
+		},
+		Lines: make([]httpTaxableLine, 0, len(request.Lines)),
+	}
+	products := make(map[int]bool, len(request.Lines))
+	for _, line := range request.Lines {
+		body.Lines = append(body.Lines, httpTaxableLine{ProductID: line.ProductID, TaxClass: line.TaxClass, Amount: line.Amount})
+		products[line.ProductID] = true
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", types.ErrTaxUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("%w: the tax provider answered %d: %s", types.ErrTaxUnavailable, res.StatusCode, bytes.TrimSpace(message))
+	}
+
+	var response httpTaxResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("%w: invalid tax provider response: %v", types.ErrTaxUnavailable, err)
+	}
+
+	// the taxes are stored on the order items, one for a product that isn't in the cart can't be kept.
+	for _, tax := range response.Taxes {
+		if !products[tax.ProductID] {
+			return nil, fmt.Errorf("%w: the tax provider charged product %d which isn't in the cart", types.ErrTaxUnavailable, tax.ProductID)
+		}
+		if tax.Amount.IsNegative() {
+			return nil, fmt.Errorf("%w: the tax provider charged a negative tax on product %d", types.ErrTaxUnavailable, tax.ProductID)
+		}
+	}
+	if response.Taxes == nil {
+		response.Taxes = []types.TaxLine{}
+	}
+
+	return response.Taxes, nil
+}
@@ -0,0 +1,99 @@
+package tax
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	request := types.TaxRequest{
+		Address: &types.ShippingAddress{Line1: "1 Main Street", City: "Portland", Region: "OR", PostalCode: "97201", Country: "US"},
+		Lines: []types.TaxableLine{
+			{ProductID: 1, TaxClass: types.TaxClassStandard, Amount: money.FromMinor(1000)},
+			{ProductID: 2, TaxClass: "reduced", Amount: money.FromMinor(500)},
+		},
+	}
+
+	t.Run("Should post the lines and return the taxes of the service", func(t *testing.T) {
+		var received httpTaxRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			w.Write([]byte(`{"taxes":[{"productId":1,"name":"State tax","rate":7.5,"taxable":{"amount":"10.00","currency":"USD"},"amount":{"amount":"0.75","currency":"USD"}}]}`))
+		}))
+		defer server.Close()
+
+		provider, err := NewHTTPProvider(server.URL, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		taxes, err := provider.Calculate(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if received.Address.Region != "OR" || received.Address.PostalCode != "97201" || len(received.Lines) != 2 {
+			t.Errorf("expected the address and the 2 lines to be sent got %+v", received)
+		}
+		if received.Lines[1].TaxClass != "reduced" || received.Lines[1].Amount.String() != "5.00" {
+			t.Errorf("expected the second line to be 5.00 of reduced got %s of %s", received.Lines[1].Amount, received.Lines[1].TaxClass)
+		}
+		if len(taxes) != 1 || taxes[0].ProductID != 1 || taxes[0].Amount.String() != "0.75" {
+			t.Errorf("expected 0.75 of tax on product 1 got %+v", taxes)
+		}
+	})
+
+	t.Run("Should report the service as unavailable when it fails", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		provider, _ := NewHTTPProvider(server.URL, "")
+		_, err := provider.Calculate(request)
+		if !errors.Is(err, types.ErrTaxUnavailable) {
+			t.Errorf("expected ErrTaxUnavailable got %v", err)
+		}
+	})
+
+	t.Run("Should reject taxes on products that aren't in the cart", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"taxes":[{"productId":9,"name":"State tax","rate":7.5,"taxable":10,"amount":0.75}]}`))
+		}))
+		defer server.Close()
+
+		provider, _ := NewHTTPProvider(server.URL, "")
+		_, err := provider.Calculate(request)
+		if !errors.Is(err, types.ErrTaxUnavailable) {
+			t.Errorf("expected ErrTaxUnavailable got %v", err)
+		}
+	})
+
+	t.Run("Should not call the service without an address", func(t *testing.T) {
+		called := false
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+		defer server.Close()
+
+		provider, _ := NewHTTPProvider(server.URL, "")
+		taxes, err := provider.Calculate(types.TaxRequest{Lines: request.Lines})
+		if err != nil || len(taxes) != 0 || called {
+			t.Errorf("expected no taxes and no call got %v, %v and called %v", taxes, err, called)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package tax
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+const (
+	TableDriver = "table"
+	HTTPDriver  = "http"
+)
+
+// the rates have 3 decimals, they're handled in thousandths of a percent so 100% is 100000.
+const fullRate = 100 * 1000
+
+// returns the tax provider selected by the TAX_PROVIDER env variable.
+func NewProviderFromConfig(cfg config.Config, store types.TaxRateStore) (types.TaxProvider, error) {
+	switch cfg.TaxProvider {
+	case TableDriver:
+		return NewTableProvider(store), nil
+	case HTTPDriver:
+		return NewHTTPProvider(cfg.TaxProviderURL, cfg.TaxProviderAPIKey)
+	default:
+		return nil, fmt.Errorf("unknown tax provider '%s'", cfg.TaxProvider)
+	}
+}
+
+// TableProvider charges the rates of the taxRates table matching the country and region of the address
+// and the tax class of the product. Without an address nothing is charged.
+type TableProvider struct {
+	store types.TaxRateStore
+}
+
+func NewTableProvider(store types.TaxRateStore) *TableProvider {
+	return &TableProvider{
+		store: store,
+	}
+}
+
+func (p *TableProvider) Calculate(request types.TaxRequest) ([]types.TaxLine, error) {
+	taxes := make([]types.TaxLine, 0)
+	if request.Address == nil {
+		return taxes, nil
+	}
+
+	rates, err := p.store.GetTaxRatesFor(request.Address.Country, request.Address.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range request.Lines {
+		taxClass := line.TaxClass
+		if taxClass == "" {
+			taxClass = types.TaxClassStandard
+		}
+
+		classRates := make([]types.TaxRate, 0)
+		for _, rate := range rates {
+			if rate.TaxClass == taxClass {
+				classRates = append(classRates, rate)
+			}
+		}
+
+		taxes = append(taxes, lineTaxes(line, classRates, request.Inclusive)...)
+	}
+
+	return taxes, nil
+}
+
+// every rate is charged on the line amount, with inclusive prices the taxes are taken out of
+// the amount using the combined rate, e.g. 12.00 at 20% inclusive holds 2.00 of tax.
+func lineTaxes(line types.TaxableLine, rates []types.TaxRate, inclusive bool) []types.TaxLine {
+	if !line.Amount.IsPositive() || len(rates) == 0 {
+		return nil
+	}
+
+	base := int64(fullRate)
+	if inclusive {
+		for _, rate := range rates {
+			base += thousandths(rate.Rate)
+		}
+	}
+
+	taxes := make([]types.TaxLine, 0, len(rates))
+	charged := money.Zero()
+	for _, rate := range rates {
+		amount := line.Amount.MulDiv(thousandths(rate.Rate), base, money.TaxRounding)
+		charged = charged.Add(amount)
+		taxes = append(taxes, types.TaxLine{
+			ProductID: line.ProductID,
+			Name:      rate.Name,
+			Rate:      rate.Rate,
+			Amount:    amount,
+		})
+	}
+
+	taxable := line.Amount
+	if inclusive {
+		taxable = line.Amount.Sub(charged)
+	}
+	for i := range taxes {
+		taxes[i].Taxable = taxable
+	}
+
+	return taxes
+}
+
+func thousandths(rate float64) int64 {
+	return int64(math.Round(rate * 1000))
+}
@@ -0,0 +1,116 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestTableProvider(t *testing.T) {
+	provider := NewTableProvider(&mockTaxRateStore{rates: []types.TaxRate{
+		{Country: "GB", TaxClass: types.TaxClassStandard, Name: "VAT", Rate: 20},
+		{Country: "GB", TaxClass: "reduced", Name: "VAT", Rate: 5},
+		{Country: "CA", TaxClass: types.TaxClassStandard, Name: "GST", Rate: 5},
+		{Country: "CA", Region: "BC", TaxClass: types.TaxClassStandard, Name: "PST", Rate: 7},
+		{Country: "US", Region: "NY", TaxClass: types.TaxClassStandard, Name: "Sales tax", Rate: 8.875},
+	}})
+	cents := money.FromMinor
+
+	cases := []struct {
+		name      string
+		address   *types.ShippingAddress
+		inclusive bool
+		lines     []types.TaxableLine
+		// every tax is a product id, an amount and a taxable amount in cents.
+		taxes [][3]int64
+	}{
+		{
+			name:    "Should add the rate of the country on top of the amount",
+			address: &types.ShippingAddress{Country: "GB"},
+			lines:   []types.TaxableLine{{ProductID: 1, TaxClass: types.TaxClassStandard, Amount: cents(1000)}},
+			taxes:   [][3]int64{{1, 200, 1000}},
+		},
+		{
+			name:      "Should take the tax out of inclusive prices",
+			address:   &types.ShippingAddress{Country: "GB"},
+			inclusive: true,
+			lines:     []types.TaxableLine{{ProductID: 1, TaxClass: types.TaxClassStandard, Amount: cents(1200)}},
+			taxes:     [][3]int64{{1, 200, 1000}},
+		},
+		{
+			name:    "Should charge every product at the rate of its class",
+			address: &types.ShippingAddress{Country: "GB"},
+			lines: []types.TaxableLine{
+				{ProductID: 1, TaxClass: "reduced", Amount: cents(1000)},
+				{ProductID: 2, Amount: cents(1000)},
+				{ProductID: 3, TaxClass: "zero", Amount: cents(1000)},
+			},
+			taxes: [][3]int64{{1, 50, 1000}, {2, 200, 1000}},
+		},
+		{
+			name:    "Should stack the country and region rates",
+			address: &types.ShippingAddress{Country: "CA", Region: "BC"},
+			lines:   []types.TaxableLine{{ProductID: 1, Amount: cents(1000)}},
+			taxes:   [][3]int64{{1, 50, 1000}, {1, 70, 1000}},
+		},
+		{
+			name:      "Should split inclusive taxes by the combined rate",
+			address:   &types.ShippingAddress{Country: "CA", Region: "BC"},
+			inclusive: true,
+			lines:     []types.TaxableLine{{ProductID: 1, Amount: cents(1120)}},
+			taxes:     [][3]int64{{1, 50, 1000}, {1, 70, 1000}},
+		},
+		{
+			name:    "Should round the tax half up",
+			address: &types.ShippingAddress{Country: "US", Region: "NY"},
+			lines:   []types.TaxableLine{{ProductID: 1, Amount: cents(1000)}},
+			taxes:   [][3]int64{{1, 89, 1000}},
+		},
+		{
+			name:    "Should not charge a region on another one",
+			address: &types.ShippingAddress{Country: "US", Region: "OR"},
+			lines:   []types.TaxableLine{{ProductID: 1, Amount: cents(1000)}},
+		},
+		{
+			name:  "Should not charge anything without an address",
+			lines: []types.TaxableLine{{ProductID: 1, Amount: cents(1000)}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			taxes, err := provider.Calculate(types.TaxRequest{Address: c.address, Inclusive: c.inclusive, Lines: c.lines})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(taxes) != len(c.taxes) {
+				t.Fatalf("expected %d taxes got %d", len(c.taxes), len(taxes))
+			}
+			for i, expected := range c.taxes {
+				tax := taxes[i]
+				if tax.ProductID != int(expected[0]) || tax.Amount.Amount != expected[1] || tax.Taxable.Amount != expected[2] {
+					t.Errorf("expected tax %d to be %d on product %d taxable %d got %s on product %d taxable %s",
+						i, expected[1], expected[0], expected[2], tax.Amount, tax.ProductID, tax.Taxable)
+				}
+			}
+		})
+	}
+}
+
+type mockTaxRateStore struct {
+	types.TaxRateStore
+	rates []types.TaxRate
+}
+
+func (m *mockTaxRateStore) GetTaxRatesFor(country, region string) ([]types.TaxRate, error) {
+	rates := make([]types.TaxRate, 0)
+	for _, rate := range m.rates {
+		if rate.Country == country && (rate.Region == "" || rate.Region == region) {
+			rates = append(rates, rate)
+		}
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,160 @@
+package tax
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/middlewares"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+type Handler struct {
+	store types.TaxRateStore
+}
+
+func NewHandler(store types.TaxRateStore) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/tax-rates", auth.AdminMiddleware(middlewares.PaginationMiddleware(h.GetTaxRates))).Methods("GET")
+	router.HandleFunc("/admin/tax-rates", auth.AdminMiddleware(h.CreateTaxRate)).Methods("POST")
+	router.HandleFunc("/admin/tax-rates/{id}", auth.AdminMiddleware(h.GetTaxRate)).Methods("GET")
+	router.HandleFunc("/admin/tax-rates/{id}", auth.AdminMiddleware(h.UpdateTaxRate)).Methods("PUT")
+	router.HandleFunc("/admin/tax-rates/{id}", auth.AdminMiddleware(h.DeleteTaxRate)).Methods("DELETE")
+}
+
+func (h *Handler) GetTaxRates(w http.ResponseWriter, r *http.Request) {
+	pagination := middlewares.GetPagination(r)
+	offset := middlewares.CalculateOffset(pagination)
+
+	rates, count, err := h.store.GetTaxRates(pagination.Limit, offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK,
+		map[string]any{
+			"taxRates": rates,
+			"page":     pagination.Page,
+			"limit":    pagination.Limit,
+			"count":    count,
+		})
+}
+
+func (h *Handler) GetTaxRate(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rate, err := h.store.GetTaxRateById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    rate,
+	})
+}
+
+func (h *Handler) CreateTaxRate(w http.ResponseWriter, r *http.Request) {
+	rate, err := parseTaxRatePayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	created, err := h.store.CreateTaxRate(rate)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    created,
+	})
+}
+
+func (h *Handler) UpdateTaxRate(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rate, err := parseTaxRatePayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := h.store.UpdateTaxRate(id, rate)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    updated,
+	})
+}
+
+func (h *Handler) DeleteTaxRate(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DeleteTaxRate(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusNoContent, map[string]any{})
+}
+
+func parseTaxRatePayload(r *http.Request) (types.TaxRate, error) {
+	var payload types.TaxRatePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return types.TaxRate{}, err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return types.TaxRate{}, err
+	}
+
+	return types.TaxRate{
+		Country:  strings.ToUpper(payload.Country),
+		Region:   strings.TrimSpace(payload.Region),
+		TaxClass: strings.TrimSpace(payload.TaxClass),
+		Name:     strings.TrimSpace(payload.Name),
+		Rate:     payload.Rate,
+	}, nil
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("tax rate id must be unsigned integer")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,130 @@
+package tax
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) GetTaxRates(limit, offset int) ([]types.TaxRate, int, error) {
+	rows, err := s.db.Query("SELECT * FROM taxRates ORDER BY country, region, taxClass, id LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rates, err := scanTaxRates(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM taxRates").Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return rates, count, nil
+}
+
+// returns the rates of the whole country and the ones of the region, regions are case insensitive.
+func (s *Store) GetTaxRatesFor(country, region string) ([]types.TaxRate, error) {
+	rows, err := s.db.Query("SELECT * FROM taxRates WHERE country = ? AND (region = '' OR region = ?) ORDER BY region, id",
+		strings.ToUpper(country), strings.TrimSpace(region))
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTaxRates(rows)
+}
+
+func (s *Store) GetTaxRateById(id int) (*types.TaxRate, error) {
+	rows, err := s.db.Query("SELECT * FROM taxRates WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := scanTaxRates(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no tax rate was found for id %v", id)
+	}
+
+	return &rates[0], nil
+}
+
+func (s *Store) CreateTaxRate(rate types.TaxRate) (*types.TaxRate, error) {
+	result, err := s.db.Exec("INSERT INTO taxRates (country, region, taxClass, name, rate) VALUES (?,?,?,?,?)",
+		rate.Country, rate.Region, rate.TaxClass, rate.Name, rate.Rate)
+	if err != nil {
+		return nil, err
+	}
+
+	rateId, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTaxRateById(int(rateId))
+}
+
+func (s *Store) UpdateTaxRate(id int, rate types.TaxRate) (*types.TaxRate, error) {
+	// MySQL reports no affected rows when nothing changed, a missing rate is caught when it's read back.
+	_, err := s.db.Exec("UPDATE taxRates SET country = ?, region = ?, taxClass = ?, name = ?, rate = ? WHERE id = ?",
+		rate.Country, rate.Region, rate.TaxClass, rate.Name, rate.Rate, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTaxRateById(id)
+}
+
+func (s *Store) DeleteTaxRate(id int) error {
+	result, err := s.db.Exec("DELETE FROM taxRates WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no tax rate was found for id %v", id)
+	}
+
+	return nil
+}
+
+func scanTaxRates(rows *sql.Rows) ([]types.TaxRate, error) {
+	defer rows.Close()
+
+	rates := make([]types.TaxRate, 0)
+	for rows.Next() {
+		rate := new(types.TaxRate)
+		err := rows.Scan(&rate.ID, &rate.Country, &rate.Region, &rate.TaxClass, &rate.Name, &rate.Rate, &rate.CreatedAt, &rate.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, *rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
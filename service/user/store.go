@@ -6,6 +6,8 @@ import (
 	"strings"
 	"time"
 
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/outbox"
 	"github.com/mohammadahmadkhader/golang-ecommerce/types"
 )
 
@@ -72,23 +74,37 @@ func (s *Store) GetUserByID(id int) (*types.User, error) {
 	return user, nil
 }
 
+// CreateUser records a user registered event along with the user.
 func (s *Store) CreateUser(user types.User) error {
-	_, err := s.db.Exec(`
-	INSERT INTO users (firstName, lastName, email, password)
-	VALUES (?,?,?,?)`, strings.TrimSpace(user.FirstName), strings.TrimSpace(user.LastName), strings.ToLower(strings.TrimSpace(user.Email)), user.Password)
-	if err != nil {
-		return err
-	}
+	return myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		result, err := tx.Exec(`
+		INSERT INTO users (firstName, lastName, email, password)
+		VALUES (?,?,?,?)`, strings.TrimSpace(user.FirstName), strings.TrimSpace(user.LastName), strings.ToLower(strings.TrimSpace(user.Email)), user.Password)
+		if err != nil {
+			return err
+		}
+
+		userId, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		createdUser := new(types.User)
+		if err := tx.QueryRow("SELECT * FROM users WHERE id = ?", userId).Scan(userAllFieldsScanner(createdUser)); err != nil {
+			return err
+		}
 
-	return nil
+		return outbox.Record(tx, types.AggregateUser, createdUser.ID, types.EventUserRegistered, createdUser)
+	})
 }
 
-func userAllFieldsScanner(user *types.User) (*int, *string, *string, *string, *string, *time.Time, *time.Time) {
+func userAllFieldsScanner(user *types.User) (*int, *string, *string, *string, *string, *time.Time, *time.Time, *string) {
 	return &user.ID,
 		&user.FirstName,
 		&user.LastName,
 		&user.Email,
 		&user.Password,
 		&user.CreatedAt,
-		&user.UpdatedAt
+		&user.UpdatedAt,
+		&user.Role
 }
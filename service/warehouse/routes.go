@@ -0,0 +1,144 @@
+package warehouse
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+type Handler struct {
+	store types.WarehouseStore
+}
+
+func NewHandler(store types.WarehouseStore) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/warehouses", auth.AdminMiddleware(h.GetWarehouses)).Methods("GET")
+	router.HandleFunc("/admin/warehouses", auth.AdminMiddleware(h.CreateWarehouse)).Methods("POST")
+	router.HandleFunc("/admin/warehouses/{id}", auth.AdminMiddleware(h.GetWarehouse)).Methods("GET")
+	router.HandleFunc("/admin/warehouses/{id}", auth.AdminMiddleware(h.UpdateWarehouse)).Methods("PUT")
+}
+
+func (h *Handler) GetWarehouses(w http.ResponseWriter, r *http.Request) {
+	warehouses, err := h.store.GetWarehouses()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    warehouses,
+	})
+}
+
+func (h *Handler) GetWarehouse(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	warehouse, err := h.store.GetWarehouseById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    warehouse,
+	})
+}
+
+func (h *Handler) CreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	warehouse, err := parseWarehousePayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	created, err := h.store.CreateWarehouse(warehouse)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    created,
+	})
+}
+
+// PUT replaces the warehouse, its stock is managed through the stock movements.
+func (h *Handler) UpdateWarehouse(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	warehouse, err := parseWarehousePayload(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := h.store.UpdateWarehouse(id, warehouse)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    updated,
+	})
+}
+
+func parseWarehousePayload(r *http.Request) (types.Warehouse, error) {
+	var payload types.WarehousePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return types.Warehouse{}, err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return types.Warehouse{}, err
+	}
+
+	active := true
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+
+	return types.Warehouse{
+		Name:      payload.Name,
+		Code:      payload.Code,
+		Country:   payload.Country,
+		Latitude:  payload.Latitude,
+		Longitude: payload.Longitude,
+		Priority:  payload.Priority,
+		Active:    active,
+	}, nil
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("warehouse id must be unsigned integer")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,168 @@
+package warehouse
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) GetWarehouses() ([]types.Warehouse, error) {
+	rows, err := s.db.Query("SELECT * FROM warehouses ORDER BY priority, id")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	warehouses := make([]types.Warehouse, 0)
+	for rows.Next() {
+		warehouse := new(types.Warehouse)
+		if err := rows.Scan(warehouseAllFieldsScanner(warehouse)); err != nil {
+			return nil, err
+		}
+
+		warehouses = append(warehouses, *warehouse)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return warehouses, nil
+}
+
+func (s *Store) GetWarehouseById(id int) (*types.Warehouse, error) {
+	warehouse := new(types.Warehouse)
+	err := s.db.QueryRow("SELECT * FROM warehouses WHERE id = ?", id).Scan(warehouseAllFieldsScanner(warehouse))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no warehouse was found for id %v", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return warehouse, nil
+}
+
+func (s *Store) CreateWarehouse(warehouse types.Warehouse) (*types.Warehouse, error) {
+	if err := s.checkCodeIsFree(warehouse.Code, 0); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`
+	INSERT INTO warehouses (name, code, country, latitude, longitude, priority, active) VALUES (?,?,?,?,?,?,?)`,
+		warehouse.Name, warehouse.Code, warehouse.Country, warehouse.Latitude, warehouse.Longitude, warehouse.Priority, warehouse.Active)
+	if err != nil {
+		return nil, err
+	}
+
+	warehouseId, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetWarehouseById(int(warehouseId))
+}
+
+func (s *Store) UpdateWarehouse(id int, warehouse types.Warehouse) (*types.Warehouse, error) {
+	if _, err := s.GetWarehouseById(id); err != nil {
+		return nil, err
+	}
+	if err := s.checkCodeIsFree(warehouse.Code, id); err != nil {
+		return nil, err
+	}
+
+	_, err := s.db.Exec(`
+	UPDATE warehouses SET name = ?, code = ?, country = ?, latitude = ?, longitude = ?, priority = ?, active = ? WHERE id = ?`,
+		warehouse.Name, warehouse.Code, warehouse.Country, warehouse.Latitude, warehouse.Longitude, warehouse.Priority, warehouse.Active, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetWarehouseById(id)
+}
+
+// the available stock of a location is its quantity minus the active reservations made on it.
+func (s *Store) GetProductsStockLevels(productIDs []int) (map[int][]types.ProductStockLevel, error) {
+	levels := make(map[int][]types.ProductStockLevel, len(productIDs))
+	if len(productIDs) == 0 {
+		return levels, nil
+	}
+
+	placeholders := strings.Repeat(",?", len(productIDs)-1)
+	query := fmt.Sprintf(`
+	SELECT ws.productId, w.id, w.code, ws.quantity, GREATEST(0, CAST(ws.quantity AS SIGNED) - (
+		SELECT COALESCE(SUM(r.quantity), 0) FROM stockReservations r
+		WHERE r.productId = ws.productId AND r.warehouseId = ws.warehouseId
+		AND r.status = 'active' AND r.expiresAt > CURRENT_TIMESTAMP
+	))
+	FROM warehouseStock ws JOIN warehouses w ON w.id = ws.warehouseId
+	WHERE w.active AND ws.productId IN (?%v)
+	ORDER BY w.priority, w.id`, placeholders)
+
+	args := make([]interface{}, len(productIDs))
+	for i, val := range productIDs {
+		args[i] = val
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var level types.ProductStockLevel
+		err := rows.Scan(&level.ProductID, &level.WarehouseID, &level.WarehouseCode, &level.Quantity, &level.Available)
+		if err != nil {
+			return nil, err
+		}
+
+		levels[level.ProductID] = append(levels[level.ProductID], level)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return levels, nil
+}
+
+// excludeId is the warehouse being updated, it may keep its own code.
+func (s *Store) checkCodeIsFree(code string, excludeId int) error {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM warehouses WHERE code = ? AND id <> ?", code, excludeId).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("warehouse with code %s already exists", code)
+	}
+
+	return nil
+}
+
+func warehouseAllFieldsScanner(warehouse *types.Warehouse) (*int, *string, *string, *string, **float64, **float64, *int, *bool, *time.Time, *time.Time) {
+	return &warehouse.ID,
+		&warehouse.Name,
+		&warehouse.Code,
+		&warehouse.Country,
+		&warehouse.Latitude,
+		&warehouse.Longitude,
+		&warehouse.Priority,
+		&warehouse.Active,
+		&warehouse.CreatedAt,
+		&warehouse.UpdatedAt
+}
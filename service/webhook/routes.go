@@ -0,0 +1,289 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/middlewares"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+	"github.com/mohammadahmadkhader/golang-ecommerce/utils"
+)
+
+// how long the deliveries are still signed with the previous secret after a rotation, the partner
+// switches to the new secret meanwhile.
+const secretRotationGrace = 24 * time.Hour
+
+type Handler struct {
+	store types.PartnerWebhookStore
+	now   func() time.Time
+}
+
+func NewHandler(store types.PartnerWebhookStore) *Handler {
+	return &Handler{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/webhook-endpoints", auth.AdminMiddleware(h.CreateEndpoint)).Methods("POST")
+	router.HandleFunc("/admin/webhook-endpoints", auth.AdminMiddleware(middlewares.PaginationMiddleware(h.GetEndpoints))).Methods("GET")
+	router.HandleFunc("/admin/webhook-endpoints/{id}", auth.AdminMiddleware(h.GetEndpoint)).Methods("GET")
+	router.HandleFunc("/admin/webhook-endpoints/{id}", auth.AdminMiddleware(h.UpdateEndpoint)).Methods("PATCH")
+	router.HandleFunc("/admin/webhook-endpoints/{id}", auth.AdminMiddleware(h.DeleteEndpoint)).Methods("DELETE")
+	router.HandleFunc("/admin/webhook-endpoints/{id}/rotate-secret", auth.AdminMiddleware(h.RotateSecret)).Methods("POST")
+	router.HandleFunc("/admin/webhook-endpoints/{id}/deliveries", auth.AdminMiddleware(middlewares.PaginationMiddleware(h.GetDeliveries))).Methods("GET")
+	router.HandleFunc("/admin/webhook-deliveries/{id}", auth.AdminMiddleware(h.GetDelivery)).Methods("GET")
+	router.HandleFunc("/admin/webhook-deliveries/{id}/redeliver", auth.AdminMiddleware(h.Redeliver)).Methods("POST")
+}
+
+// CreateEndpoint registers the endpoint of a partner, its secret is only returned here and when it's rotated.
+func (h *Handler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	var payload types.WebhookEndpointPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	endpoint, err := h.store.CreateWebhookEndpoint(types.WebhookEndpoint{
+		URL:         payload.URL,
+		Description: payload.Description,
+		EventTypes:  payload.EventTypes,
+		Secret:      secret,
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"data":    endpoint,
+		"secret":  secret,
+	})
+}
+
+func (h *Handler) GetEndpoints(w http.ResponseWriter, r *http.Request) {
+	pagination := middlewares.GetPagination(r)
+	offset := middlewares.CalculateOffset(pagination)
+
+	endpoints, count, err := h.store.GetWebhookEndpoints(pagination.Limit, offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK,
+		map[string]any{
+			"endpoints": endpoints,
+			"page":      pagination.Page,
+			"limit":     pagination.Limit,
+			"count":     count,
+		})
+}
+
+func (h *Handler) GetEndpoint(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	endpoint, err := h.store.GetWebhookEndpointById(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    endpoint,
+	})
+}
+
+// UpdateEndpoint changes the endpoint, an inactive endpoint gets no new deliveries and its pending ones wait.
+func (h *Handler) UpdateEndpoint(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.WebhookEndpointPatchPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := h.store.GetWebhookEndpointById(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	endpoint, err := h.store.UpdateWebhookEndpoint(id, payload)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    endpoint,
+	})
+}
+
+func (h *Handler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DeleteWebhookEndpoint(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusNoContent, map[string]any{})
+}
+
+// RotateSecret gives the endpoint a new secret, the deliveries are signed with both secrets for
+// secretRotationGrace so the partner can switch without missing any.
+func (h *Handler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	endpoint, err := h.store.RotateWebhookSecret(id, secret, h.now().Add(secretRotationGrace))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    endpoint,
+		"secret":  secret,
+	})
+}
+
+// GetDeliveries lists the deliveries of an endpoint, ?status=dead lists the ones to redeliver.
+func (h *Handler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if err := utils.Validate.Var(status, "omitempty,oneof=pending delivered dead"); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("status must be one of pending, delivered or dead"))
+		return
+	}
+
+	pagination := middlewares.GetPagination(r)
+	offset := middlewares.CalculateOffset(pagination)
+
+	deliveries, count, err := h.store.GetWebhookDeliveries(id, status, pagination.Limit, offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK,
+		map[string]any{
+			"deliveries": deliveries,
+			"page":       pagination.Page,
+			"limit":      pagination.Limit,
+			"count":      count,
+		})
+}
+
+// GetDelivery returns the delivery with every attempt made, and what the endpoint responded to each.
+func (h *Handler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	delivery, err := h.store.GetWebhookDeliveryById(int64(id))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    delivery,
+	})
+}
+
+// Redeliver sends the delivery again whatever its status, e.g. once the partner fixed what made it dead.
+func (h *Handler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, err := getIdParam(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	delivery, err := h.store.RedeliverWebhook(int64(id))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, map[string]any{
+		"message": "success",
+		"data":    delivery,
+	})
+}
+
+func newSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+func getIdParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("id must be an integer")
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("id must be unsigned integer")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/auth"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestCreateEndpoint(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"Should register the endpoint", `{"url":"https://3pl.example.com/hooks","eventTypes":["order.placed","order.status_changed"]}`, http.StatusCreated},
+		{"Should refuse an unknown event type", `{"url":"https://3pl.example.com/hooks","eventTypes":["order.shipped"]}`, http.StatusBadRequest},
+		{"Should refuse an endpoint without event types", `{"url":"https://3pl.example.com/hooks","eventTypes":[]}`, http.StatusBadRequest},
+		{"Should refuse a url that isn't http", `{"url":"ftp://3pl.example.com","eventTypes":["order.placed"]}`, http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := &mockEndpointStore{}
+			recorder := send(t, NewHandler(store), http.MethodPost, "/admin/webhook-endpoints", c.body)
+			if recorder.Code != c.status {
+				t.Fatalf("expected status code %d got %d: %s", c.status, recorder.Code, recorder.Body)
+			}
+			if c.status != http.StatusCreated {
+				return
+			}
+
+			var response struct {
+				Data   map[string]any `json:"data"`
+				Secret string         `json:"secret"`
+			}
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(response.Secret, "whsec_") || response.Secret != store.endpoint.Secret {
+				t.Errorf("expected the stored secret to be returned got %q", response.Secret)
+			}
+			if _, ok := response.Data["secret"]; ok {
+				t.Error("expected the endpoint not to carry its secret")
+			}
+		})
+	}
+}
+
+func TestRotateSecret(t *testing.T) {
+	store := &mockEndpointStore{endpoint: types.WebhookEndpoint{ID: 1, Secret: "whsec_old"}}
+	handler := NewHandler(store)
+	now := time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC)
+	handler.now = func() time.Time { return now }
+
+	recorder := send(t, handler, http.MethodPost, "/admin/webhook-endpoints/1/rotate-secret", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+
+	if store.endpoint.PreviousSecret != "whsec_old" || store.endpoint.Secret == "whsec_old" {
+		t.Errorf("expected the old secret to become the previous one got %+v", store.endpoint)
+	}
+	if !store.endpoint.PreviousSecretExpiresAt.Equal(now.Add(secretRotationGrace)) {
+		t.Errorf("expected the previous secret to expire after the grace got %s", store.endpoint.PreviousSecretExpiresAt)
+	}
+	if !strings.Contains(recorder.Body.String(), store.endpoint.Secret) {
+		t.Error("expected the new secret to be returned")
+	}
+}
+
+func send(t *testing.T, handler *Handler, method, path, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), types.User{ID: 1, Role: types.UserRoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", token)
+
+	recorder := httptest.NewRecorder()
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+	router.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+type mockEndpointStore struct {
+	types.PartnerWebhookStore
+	endpoint types.WebhookEndpoint
+}
+
+func (m *mockEndpointStore) CreateWebhookEndpoint(endpoint types.WebhookEndpoint) (*types.WebhookEndpoint, error) {
+	endpoint.ID = 1
+	endpoint.Active = true
+	m.endpoint = endpoint
+	return &endpoint, nil
+}
+
+func (m *mockEndpointStore) RotateWebhookSecret(id int, secret string, previousExpiresAt time.Time) (*types.WebhookEndpoint, error) {
+	m.endpoint.PreviousSecret = m.endpoint.Secret
+	m.endpoint.PreviousSecretExpiresAt = &previousExpiresAt
+	m.endpoint.Secret = secret
+	endpoint := m.endpoint
+	return &endpoint, nil
+}
@@ -0,0 +1,225 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/service/job"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+const (
+	// SignatureHeader carries t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">, with one v1 per secret
+	// while a rotated secret is still valid. The partners accept the delivery when one of them matches.
+	SignatureHeader = "Webhook-Signature"
+	// EventIDHeader is the same on every attempt and redelivery of an event, the partners tell the
+	// duplicates apart with it.
+	EventIDHeader   = "Webhook-Id"
+	EventTypeHeader = "Webhook-Event"
+)
+
+const (
+	deliveryBatchSize = 20
+	deliveryTimeout   = 10 * time.Second
+	// a claimed delivery isn't picked up by another sender for this long, it's longer than a request can take.
+	deliveryLease = time.Minute
+	// how much of the response is kept on the attempt.
+	maxResponseBody = 1024
+)
+
+var retryBackoff = job.Backoff{First: 30 * time.Second, Max: 6 * time.Hour}
+
+// Body is what the partners receive, Data is the payload of the domain event.
+type Body struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Dispatcher is the broker that queues the domain events for the endpoints subscribed to them,
+// the relay publishes to it along with the event broker.
+type Dispatcher struct {
+	store types.PartnerWebhookStore
+}
+
+func NewDispatcher(store types.PartnerWebhookStore) *Dispatcher {
+	return &Dispatcher{
+		store: store,
+	}
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, event types.DomainEvent) error {
+	body, err := json.Marshal(Body{ID: event.ID, Type: event.Type, OccurredAt: event.OccurredAt, Data: event.Payload})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.store.EnqueueWebhookDeliveries(event, body)
+	return err
+}
+
+func (d *Dispatcher) Close() error {
+	return nil
+}
+
+// Sender posts the queued deliveries to their endpoints. A delivery that doesn't get a 2xx response is
+// tried again later, waiting twice as long after every attempt, and is dead once maxAttempts failed.
+type Sender struct {
+	store       types.PartnerWebhookStore
+	client      *http.Client
+	maxAttempts int
+	now         func() time.Time
+}
+
+func NewSender(store types.PartnerWebhookStore, maxAttempts int) *Sender {
+	return &Sender{
+		store: store,
+		client: &http.Client{
+			Timeout: deliveryTimeout,
+			// a redirect is answered like any other failure, the partner fixes the url of the endpoint.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		maxAttempts: maxAttempts,
+		now:         time.Now,
+	}
+}
+
+// DeliverAll sends the due deliveries batch after batch until none is left, it returns how many were
+// attempted.
+func (s *Sender) DeliverAll(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		sent, err := s.Deliver(ctx)
+		total += sent
+		if err != nil || sent < deliveryBatchSize || ctx.Err() != nil {
+			return total, err
+		}
+	}
+}
+
+// Deliver sends a batch of due deliveries at once and returns how many were attempted.
+func (s *Sender) Deliver(ctx context.Context) (int, error) {
+	deliveries, err := s.store.ClaimDueWebhookDeliveries(deliveryBatchSize, deliveryLease)
+	if err != nil {
+		return 0, err
+	}
+
+	endpoints := make(map[int]*types.WebhookEndpoint)
+	for _, delivery := range deliveries {
+		if _, ok := endpoints[delivery.EndpointID]; ok {
+			continue
+		}
+
+		endpoint, err := s.store.GetWebhookEndpointById(delivery.EndpointID)
+		if err != nil {
+			return 0, err
+		}
+		endpoints[delivery.EndpointID] = endpoint
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery types.WebhookDelivery) {
+			defer wg.Done()
+
+			attempt := s.send(ctx, endpoints[delivery.EndpointID], delivery)
+			status, nextAttemptAt := s.outcome(delivery, attempt)
+			if err := s.store.RecordWebhookAttempt(attempt, status, nextAttemptAt); err != nil {
+				log.Printf("webhook sender: delivery %d: %v", delivery.ID, err)
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+func (s *Sender) send(ctx context.Context, endpoint *types.WebhookEndpoint, delivery types.WebhookDelivery) types.WebhookDeliveryAttempt {
+	attempt := types.WebhookDeliveryAttempt{DeliveryID: delivery.ID}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	now := s.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, strconv.FormatInt(delivery.EventID, 10))
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+	req.Header.Set(SignatureHeader, Sign(signingSecrets(endpoint, now), now, delivery.Payload))
+
+	started := time.Now()
+	res, err := s.client.Do(req)
+	attempt.DurationMs = int(time.Since(started).Milliseconds())
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxResponseBody))
+	attempt.ResponseStatus = &res.StatusCode
+	attempt.ResponseBody = string(body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("the endpoint responded with status %d", res.StatusCode)
+	}
+
+	return attempt
+}
+
+// outcome returns the status the delivery moves to after the attempt and when it's tried again.
+func (s *Sender) outcome(delivery types.WebhookDelivery, attempt types.WebhookDeliveryAttempt) (string, *time.Time) {
+	if attempt.Error == "" {
+		return types.WebhookDeliveryStatusDelivered, nil
+	}
+
+	attempts := delivery.Attempts + 1
+	if attempts >= s.maxAttempts {
+		return types.WebhookDeliveryStatusDead, nil
+	}
+
+	nextAttemptAt := s.now().Add(retryBackoff.Delay(attempts))
+	return types.WebhookDeliveryStatusPending, &nextAttemptAt
+}
+
+// the previous secret signs the deliveries too until it expires.
+func signingSecrets(endpoint *types.WebhookEndpoint, now time.Time) []string {
+	secrets := []string{endpoint.Secret}
+	if endpoint.PreviousSecret != "" && endpoint.PreviousSecretExpiresAt != nil && endpoint.PreviousSecretExpiresAt.After(now) {
+		secrets = append(secrets, endpoint.PreviousSecret)
+	}
+
+	return secrets
+}
+
+// Sign returns the signature header of a body, with a v1 per secret.
+func Sign(secrets []string, timestamp time.Time, body []byte) string {
+	parts := []string{fmt.Sprintf("t=%d", timestamp.Unix())}
+	for _, secret := range secrets {
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "%d.", timestamp.Unix())
+		mac.Write(body)
+
+		parts = append(parts, "v1="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	return strings.Join(parts, ",")
+}
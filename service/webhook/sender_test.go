@@ -0,0 +1,244 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+func TestSender(t *testing.T) {
+	now := time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC)
+	payload := json.RawMessage(`{"id":1,"type":"order.placed","occurredAt":"2024-11-06T09:00:00Z","data":{"order":{"id":7}}}`)
+
+	newSender := func(url string, attempts int) (*Sender, *mockWebhookStore) {
+		store := &mockWebhookStore{
+			endpoint: types.WebhookEndpoint{ID: 1, URL: url, Secret: "whsec_new", Active: true},
+			deliveries: []types.WebhookDelivery{{
+				ID: 3, EndpointID: 1, EventID: 1, EventType: types.EventOrderPlaced, Payload: payload,
+				Status: types.WebhookDeliveryStatusPending, Attempts: attempts,
+			}},
+		}
+		sender := NewSender(store, 3)
+		sender.now = func() time.Time { return now }
+
+		return sender, store
+	}
+
+	t.Run("Should post the signed payload and mark the delivery as delivered", func(t *testing.T) {
+		var received *http.Request
+		var body []byte
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			body, _ = io.ReadAll(r.Body)
+			w.Write([]byte("ok"))
+		}))
+		defer receiver.Close()
+
+		sender, store := newSender(receiver.URL, 0)
+		if _, err := sender.Deliver(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(body) != string(payload) {
+			t.Errorf("expected the stored payload to be posted got %s", body)
+		}
+		if received.Header.Get(EventIDHeader) != "1" || received.Header.Get(EventTypeHeader) != types.EventOrderPlaced {
+			t.Errorf("expected the event headers got %v", received.Header)
+		}
+		if signatures := verify(received.Header.Get(SignatureHeader), body, "whsec_new"); signatures != 1 {
+			t.Errorf("expected a signature matching the secret got %s", received.Header.Get(SignatureHeader))
+		}
+
+		attempt := store.attempts[0]
+		if attempt.status != types.WebhookDeliveryStatusDelivered || attempt.nextAttemptAt != nil {
+			t.Errorf("expected the delivery to be delivered got %+v", attempt)
+		}
+		if *attempt.ResponseStatus != http.StatusOK || attempt.ResponseBody != "ok" {
+			t.Errorf("expected the response to be recorded got %+v", attempt.WebhookDeliveryAttempt)
+		}
+	})
+
+	t.Run("Should sign with the previous secret until it expires", func(t *testing.T) {
+		var header string
+		var body []byte
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Get(SignatureHeader)
+			body, _ = io.ReadAll(r.Body)
+		}))
+		defer receiver.Close()
+
+		sender, store := newSender(receiver.URL, 0)
+		expiresAt := now.Add(time.Hour)
+		store.endpoint.PreviousSecret = "whsec_old"
+		store.endpoint.PreviousSecretExpiresAt = &expiresAt
+
+		if _, err := sender.Deliver(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if verify(header, body, "whsec_new") != 1 || verify(header, body, "whsec_old") != 1 {
+			t.Errorf("expected a signature per secret got %s", header)
+		}
+
+		sender.now = func() time.Time { return expiresAt.Add(time.Second) }
+		if _, err := sender.Deliver(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if verify(header, body, "whsec_old") != 0 {
+			t.Errorf("expected the expired secret to be dropped got %s", header)
+		}
+	})
+
+	t.Run("Should retry a failed delivery later", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer receiver.Close()
+
+		sender, store := newSender(receiver.URL, 1)
+		if _, err := sender.Deliver(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		attempt := store.attempts[0]
+		if attempt.status != types.WebhookDeliveryStatusPending || !attempt.nextAttemptAt.Equal(now.Add(time.Minute)) {
+			t.Errorf("expected the delivery to be retried in a minute got %+v", attempt)
+		}
+		if !strings.Contains(attempt.Error, "503") {
+			t.Errorf("expected the status to be the error got %s", attempt.Error)
+		}
+	})
+
+	t.Run("Should not follow redirects", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		}))
+		defer receiver.Close()
+
+		sender, store := newSender(receiver.URL, 0)
+		if _, err := sender.Deliver(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if attempt := store.attempts[0]; attempt.status != types.WebhookDeliveryStatusPending || *attempt.ResponseStatus != http.StatusFound {
+			t.Errorf("expected the redirect to fail the attempt got %+v", attempt)
+		}
+	})
+
+	t.Run("Should move the delivery to dead after the last attempt", func(t *testing.T) {
+		sender, store := newSender("http://127.0.0.1:1", 2)
+		if _, err := sender.Deliver(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		attempt := store.attempts[0]
+		if attempt.status != types.WebhookDeliveryStatusDead || attempt.nextAttemptAt != nil || attempt.ResponseStatus != nil {
+			t.Errorf("expected the delivery to be dead got %+v", attempt)
+		}
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		10: 256 * time.Minute,
+		11: retryBackoff.Max,
+		40: retryBackoff.Max,
+	}
+	for attempts, expected := range cases {
+		if delay := retryBackoff.Delay(attempts); delay != expected {
+			t.Errorf("expected a delay of %s after %d attempts got %s", expected, attempts, delay)
+		}
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	store := &mockWebhookStore{}
+	event := types.DomainEvent{ID: 4, Type: types.EventProductUpdated, Payload: json.RawMessage(`{"id":2}`),
+		OccurredAt: time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC)}
+
+	if err := NewDispatcher(store).Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"id":4,"type":"product.updated","occurredAt":"2024-11-06T09:00:00Z","data":{"id":2}}`
+	if string(store.enqueued) != expected {
+		t.Errorf("expected the body %s got %s", expected, store.enqueued)
+	}
+}
+
+// verify returns how many of the signatures of the header match the secret.
+func verify(header string, body []byte, secret string) int {
+	var timestamp string
+	matches := 0
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			mac := hmac.New(sha256.New, []byte(secret))
+			fmt.Fprintf(mac, "%s.", timestamp)
+			mac.Write(body)
+			if hmac.Equal([]byte(value), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+				matches++
+			}
+		}
+	}
+
+	return matches
+}
+
+type recordedAttempt struct {
+	types.WebhookDeliveryAttempt
+	status        string
+	nextAttemptAt *time.Time
+}
+
+type mockWebhookStore struct {
+	types.PartnerWebhookStore
+	mu         sync.Mutex
+	endpoint   types.WebhookEndpoint
+	deliveries []types.WebhookDelivery
+	attempts   []recordedAttempt
+	enqueued   []byte
+}
+
+func (m *mockWebhookStore) GetWebhookEndpointById(id int) (*types.WebhookEndpoint, error) {
+	if id != m.endpoint.ID {
+		return nil, fmt.Errorf("no webhook endpoint was found for id %v", id)
+	}
+
+	endpoint := m.endpoint
+	return &endpoint, nil
+}
+
+func (m *mockWebhookStore) ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]types.WebhookDelivery, error) {
+	return m.deliveries, nil
+}
+
+func (m *mockWebhookStore) RecordWebhookAttempt(attempt types.WebhookDeliveryAttempt, status string, nextAttemptAt *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.attempts = append(m.attempts, recordedAttempt{attempt, status, nextAttemptAt})
+	return nil
+}
+
+func (m *mockWebhookStore) EnqueueWebhookDeliveries(event types.DomainEvent, body []byte) (int, error) {
+	m.enqueued = body
+	return 1, nil
+}
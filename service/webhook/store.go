@@ -0,0 +1,366 @@
+package webhook
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+type Store struct {
+	db myDB.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) CreateWebhookEndpoint(endpoint types.WebhookEndpoint) (*types.WebhookEndpoint, error) {
+	eventTypes, err := json.Marshal(endpoint.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec("INSERT INTO webhookEndpoints (url, description, eventTypes, secret) VALUES (?,?,?,?)",
+		strings.TrimSpace(endpoint.URL), strings.TrimSpace(endpoint.Description), eventTypes, endpoint.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetWebhookEndpointById(int(id))
+}
+
+func (s *Store) GetWebhookEndpointById(id int) (*types.WebhookEndpoint, error) {
+	endpoint, err := scanIntoWebhookEndpoint(s.db.QueryRow("SELECT * FROM webhookEndpoints WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no webhook endpoint was found for id %v", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+func (s *Store) GetWebhookEndpoints(limit, offset int) ([]types.WebhookEndpoint, int, error) {
+	rows, err := s.db.Query("SELECT * FROM webhookEndpoints ORDER BY id LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	endpoints := make([]types.WebhookEndpoint, 0)
+	for rows.Next() {
+		endpoint, err := scanIntoWebhookEndpoint(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		endpoints = append(endpoints, *endpoint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM webhookEndpoints").Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	return endpoints, count, nil
+}
+
+func (s *Store) UpdateWebhookEndpoint(id int, payload types.WebhookEndpointPatchPayload) (*types.WebhookEndpoint, error) {
+	updates := make([]string, 0)
+	args := make([]any, 0)
+
+	if payload.URL != nil {
+		updates = append(updates, "url = ?")
+		args = append(args, strings.TrimSpace(*payload.URL))
+	}
+
+	if payload.Description != nil {
+		updates = append(updates, "description = ?")
+		args = append(args, strings.TrimSpace(*payload.Description))
+	}
+
+	if payload.EventTypes != nil {
+		eventTypes, err := json.Marshal(payload.EventTypes)
+		if err != nil {
+			return nil, err
+		}
+
+		updates = append(updates, "eventTypes = ?")
+		args = append(args, eventTypes)
+	}
+
+	if payload.Active != nil {
+		updates = append(updates, "active = ?")
+		args = append(args, *payload.Active)
+	}
+
+	if len(updates) > 0 {
+		args = append(args, id)
+		if _, err := s.db.Exec("UPDATE webhookEndpoints SET "+strings.Join(updates, ", ")+" WHERE id = ?", args...); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetWebhookEndpointById(id)
+}
+
+// DeleteWebhookEndpoint deletes the endpoint along with its deliveries.
+func (s *Store) DeleteWebhookEndpoint(id int) error {
+	result, err := s.db.Exec("DELETE FROM webhookEndpoints WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no webhook endpoint was found for id %v", id)
+	}
+
+	return nil
+}
+
+// RotateWebhookSecret replaces the secret, the current one is kept as the previous secret until previousExpiresAt.
+func (s *Store) RotateWebhookSecret(id int, secret string, previousExpiresAt time.Time) (*types.WebhookEndpoint, error) {
+	result, err := s.db.Exec(`
+	UPDATE webhookEndpoints SET previousSecret = secret, previousSecretExpiresAt = ?, secret = ? WHERE id = ?`,
+		previousExpiresAt, secret, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("no webhook endpoint was found for id %v", id)
+	}
+
+	return s.GetWebhookEndpointById(id)
+}
+
+func (s *Store) EnqueueWebhookDeliveries(event types.DomainEvent, body []byte) (int, error) {
+	result, err := s.db.Exec(`
+	INSERT IGNORE INTO webhookDeliveries (endpointId, eventId, eventType, payload, status)
+	SELECT id, ?, ?, ?, ? FROM webhookEndpoints
+	WHERE active = TRUE AND JSON_CONTAINS(eventTypes, JSON_QUOTE(?))`,
+		event.ID, event.Type, body, types.WebhookDeliveryStatusPending, event.Type)
+	if err != nil {
+		return 0, err
+	}
+
+	enqueued, err := result.RowsAffected()
+	return int(enqueued), err
+}
+
+func (s *Store) ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]types.WebhookDelivery, error) {
+	deliveries := make([]types.WebhookDelivery, 0)
+	err := myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		rows, err := tx.Query(`
+		SELECT d.* FROM webhookDeliveries d
+		INNER JOIN webhookEndpoints e ON e.id = d.endpointId
+		WHERE d.status = ? AND d.nextAttemptAt <= CURRENT_TIMESTAMP AND e.active = TRUE
+		ORDER BY d.nextAttemptAt, d.id
+		LIMIT ?
+		FOR UPDATE OF d SKIP LOCKED`, types.WebhookDeliveryStatusPending, limit)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			delivery := new(types.WebhookDelivery)
+			if err := rows.Scan(webhookDeliveryAllFieldsScanner(delivery)); err != nil {
+				return err
+			}
+
+			deliveries = append(deliveries, *delivery)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		args := []any{int(lease.Seconds())}
+		for _, delivery := range deliveries {
+			args = append(args, delivery.ID)
+		}
+
+		_, err = tx.Exec(`
+		UPDATE webhookDeliveries SET nextAttemptAt = DATE_ADD(CURRENT_TIMESTAMP, INTERVAL ? SECOND)
+		WHERE id IN (?`+strings.Repeat(",?", len(deliveries)-1)+")", args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// RecordWebhookAttempt stores the attempt and moves the delivery to status, nextAttemptAt is when
+// a pending delivery is tried again.
+func (s *Store) RecordWebhookAttempt(attempt types.WebhookDeliveryAttempt, status string, nextAttemptAt *time.Time) error {
+	return myDB.InTx(s.db, func(tx myDB.DBTX) error {
+		_, err := tx.Exec(`
+		INSERT INTO webhookDeliveryAttempts (deliveryId, responseStatus, responseBody, error, durationMs)
+		VALUES (?,?,LEFT(?, 1024),LEFT(?, 1024),?)`,
+			attempt.DeliveryID, attempt.ResponseStatus, attempt.ResponseBody, attempt.Error, attempt.DurationMs)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+		UPDATE webhookDeliveries SET
+			status = ?, attempts = attempts + 1, nextAttemptAt = ?, lastError = LEFT(?, 1024),
+			deliveredAt = IF(? = ?, CURRENT_TIMESTAMP, deliveredAt)
+		WHERE id = ?`,
+			status, nextAttemptAt, attempt.Error, status, types.WebhookDeliveryStatusDelivered, attempt.DeliveryID)
+		return err
+	})
+}
+
+// GetWebhookDeliveryById returns the delivery with the history of its attempts.
+func (s *Store) GetWebhookDeliveryById(id int64) (*types.WebhookDelivery, error) {
+	delivery := new(types.WebhookDelivery)
+	err := s.db.QueryRow("SELECT * FROM webhookDeliveries WHERE id = ?", id).Scan(webhookDeliveryAllFieldsScanner(delivery))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no webhook delivery was found for id %v", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT * FROM webhookDeliveryAttempts WHERE deliveryId = ? ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	delivery.History = make([]types.WebhookDeliveryAttempt, 0)
+	for rows.Next() {
+		var attempt types.WebhookDeliveryAttempt
+		err := rows.Scan(&attempt.ID, &attempt.DeliveryID, &attempt.ResponseStatus, &attempt.ResponseBody, &attempt.Error,
+			&attempt.DurationMs, &attempt.AttemptedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		delivery.History = append(delivery.History, attempt)
+	}
+
+	return delivery, rows.Err()
+}
+
+// returns the deliveries of the endpoint with the given status, or all of them when it's empty, the latest first.
+func (s *Store) GetWebhookDeliveries(endpointID int, status string, limit, offset int) ([]types.WebhookDelivery, int, error) {
+	rows, err := s.db.Query(`
+	SELECT * FROM webhookDeliveries WHERE endpointId = ? AND (? = '' OR status = ?) ORDER BY id DESC LIMIT ? OFFSET ?`,
+		endpointID, status, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	deliveries := make([]types.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery := new(types.WebhookDelivery)
+		if err := rows.Scan(webhookDeliveryAllFieldsScanner(delivery)); err != nil {
+			return nil, 0, err
+		}
+
+		deliveries = append(deliveries, *delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM webhookDeliveries WHERE endpointId = ? AND (? = '' OR status = ?)",
+		endpointID, status, status).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, count, nil
+}
+
+// RedeliverWebhook queues the delivery again with a fresh set of attempts, the earlier attempts stay in its history.
+func (s *Store) RedeliverWebhook(id int64) (*types.WebhookDelivery, error) {
+	result, err := s.db.Exec(`
+	UPDATE webhookDeliveries SET status = ?, attempts = 0, nextAttemptAt = CURRENT_TIMESTAMP, lastError = '', deliveredAt = NULL
+	WHERE id = ?`, types.WebhookDeliveryStatusPending, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("no webhook delivery was found for id %v", id)
+	}
+
+	return s.GetWebhookDeliveryById(id)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanIntoWebhookEndpoint(row scanner) (*types.WebhookEndpoint, error) {
+	endpoint := new(types.WebhookEndpoint)
+	var eventTypes []byte
+	err := row.Scan(&endpoint.ID, &endpoint.URL, &endpoint.Description, &eventTypes, &endpoint.Active, &endpoint.Secret,
+		&endpoint.PreviousSecret, &endpoint.PreviousSecretExpiresAt, &endpoint.CreatedAt, &endpoint.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(eventTypes, &endpoint.EventTypes); err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+func webhookDeliveryAllFieldsScanner(delivery *types.WebhookDelivery) (*int64, *int, *int64, *string, *[]byte, *string, *int,
+	**time.Time, *string, **time.Time, *time.Time) {
+	return &delivery.ID,
+		&delivery.EndpointID,
+		&delivery.EventID,
+		&delivery.EventType,
+		(*[]byte)(&delivery.Payload),
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastError,
+		&delivery.DeliveredAt,
+		&delivery.CreatedAt
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type LocalStorage struct {
+	baseDir string
+	baseURL string
+}
+
+func NewLocalStorage(baseDir, baseURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{
+		baseDir: baseDir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+func (s *LocalStorage) Put(key string, body io.Reader, contentType string) (string, error) {
+	path, err := s.pathFor(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, body); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	return s.baseURL + "/" + key, nil
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	path, err := s.pathFor(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// BaseDir is the directory the files are written to, it's used to serve them over http.
+func (s *LocalStorage) BaseDir() string {
+	return s.baseDir
+}
+
+// resolves the key inside the base directory and rejects keys that escape it.
+func (s *LocalStorage) pathFor(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid storage key '%s'", key)
+	}
+
+	return filepath.Join(s.baseDir, cleaned), nil
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat  = "20060102T150405Z"
+	amzShortFormat = "20060102"
+)
+
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// optional, used instead of the endpoint when building the urls returned to clients (e.g. a CDN).
+	PublicURL string
+}
+
+// S3Storage talks to any S3 compatible service (AWS, MinIO, ...) using path-style urls
+// and AWS signature version 4.
+type S3Storage struct {
+	cfg    S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3Storage(cfg S3Config) (*S3Storage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 endpoint and bucket are required")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("s3 access key and secret key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	cfg.PublicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+
+	return &S3Storage{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+		now:    time.Now,
+	}, nil
+}
+
+func (s *S3Storage) Put(key string, body io.Reader, contentType string) (string, error) {
+	payload, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key), bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, payload)
+
+	if err := s.do(req); err != nil {
+		return "", err
+	}
+
+	if s.cfg.PublicURL != "" {
+		return s.cfg.PublicURL + "/" + escapeKey(key), nil
+	}
+
+	return s.objectURL(key), nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	req, err := http.NewRequest(http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	s.sign(req, nil)
+
+	return s.do(req)
+}
+
+func (s *S3Storage) do(req *http.Request) error {
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		resBody, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("s3 %s request failed with status %d: %s", req.Method, res.StatusCode, strings.TrimSpace(string(resBody)))
+	}
+
+	return nil
+}
+
+func (s *S3Storage) objectURL(key string) string {
+	return fmt.Sprintf("%s/%s/%s", s.cfg.Endpoint, s.cfg.Bucket, escapeKey(key))
+}
+
+// sign adds the AWS signature version 4 headers to the request.
+func (s *S3Storage) sign(req *http.Request, payload []byte) {
+	now := s.now().UTC()
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signature, signedHeaders := s.signature(req, payloadHash, now)
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", now.Format(amzShortFormat), s.cfg.Region)
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func (s *S3Storage) signature(req *http.Request, payloadHash string, now time.Time) (string, string) {
+	headerNames := make([]string, 0, len(req.Header))
+	canonicalHeaders := make(map[string]string, len(req.Header))
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower != "host" && lower != "content-type" && !strings.HasPrefix(lower, "x-amz-") {
+			continue
+		}
+		headerNames = append(headerNames, lower)
+		canonicalHeaders[lower] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	sort.Strings(headerNames)
+
+	var headersBuilder strings.Builder
+	for _, name := range headerNames {
+		headersBuilder.WriteString(name + ":" + canonicalHeaders[name] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		headersBuilder.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", now.Format(amzShortFormat), s.cfg.Region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format(amzDateFormat),
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), now.Format(amzShortFormat))
+	signingKey = hmacSHA256(signingKey, s.cfg.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign)), signedHeaders
+}
+
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
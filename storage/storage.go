@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/config"
+	"github.com/mohammadahmadkhader/golang-ecommerce/types"
+)
+
+const (
+	LocalDriver = "local"
+	S3Driver    = "s3"
+)
+
+// returns the blob storage selected by the STORAGE_DRIVER env variable.
+func NewFromConfig(cfg config.Config) (types.BlobStorage, error) {
+	switch cfg.StorageDriver {
+	case LocalDriver:
+		return NewLocalStorage(cfg.LocalStoragePath, cfg.LocalStorageURL)
+	case S3Driver:
+		return NewS3Storage(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PublicURL: cfg.S3PublicURL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver '%s'", cfg.StorageDriver)
+	}
+}
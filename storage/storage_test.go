@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStorage(dir, "http://localhost:8080/uploads/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Should write the file and return its public url", func(t *testing.T) {
+		url, err := store.Put("products/1/image.png", strings.NewReader("png-bytes"), "image/png")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if url != "http://localhost:8080/uploads/products/1/image.png" {
+			t.Errorf("unexpected url %s", url)
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, "products", "1", "image.png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "png-bytes" {
+			t.Errorf("expected file content to be 'png-bytes' got '%s'", content)
+		}
+	})
+
+	t.Run("Should keep keys inside the base directory", func(t *testing.T) {
+		_, err := store.Put("../../escape.png", strings.NewReader("x"), "image/png")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "escape.png")); err != nil {
+			t.Errorf("expected file to be written inside the base directory: %v", err)
+		}
+	})
+
+	t.Run("Should delete the file and ignore missing ones", func(t *testing.T) {
+		if err := store.Delete("products/1/image.png"); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Delete("products/1/image.png"); err != nil {
+			t.Errorf("expected deleting a missing file to succeed got %v", err)
+		}
+	})
+}
+
+func TestS3Storage(t *testing.T) {
+	fake := newFakeS3("access", "secret", "us-east-1")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := NewS3Storage(S3Config{
+		Endpoint:  server.URL,
+		Region:    "us-east-1",
+		Bucket:    "products",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Should upload a signed object", func(t *testing.T) {
+		url, err := store.Put("products/1/main image.jpg", bytes.NewReader([]byte("jpeg-bytes")), "image/jpeg")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if url != server.URL+"/products/products/1/main%20image.jpg" {
+			t.Errorf("unexpected url %s", url)
+		}
+
+		object, ok := fake.get("/products/products/1/main image.jpg")
+		if !ok {
+			t.Fatal("expected object to be stored")
+		}
+		if string(object.body) != "jpeg-bytes" || object.contentType != "image/jpeg" {
+			t.Errorf("unexpected stored object %q (%s)", object.body, object.contentType)
+		}
+	})
+
+	t.Run("Should delete the object", func(t *testing.T) {
+		if err := store.Delete("products/1/main image.jpg"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := fake.get("/products/products/1/main image.jpg"); ok {
+			t.Error("expected object to be deleted")
+		}
+	})
+
+	t.Run("Should fail when the credentials are wrong", func(t *testing.T) {
+		badStore, _ := NewS3Storage(S3Config{
+			Endpoint:  server.URL,
+			Bucket:    "products",
+			AccessKey: "access",
+			SecretKey: "wrong",
+		})
+
+		_, err := badStore.Put("products/1/a.jpg", strings.NewReader("x"), "image/jpeg")
+		if err == nil {
+			t.Error("expected signature mismatch error")
+		}
+	})
+
+	t.Run("Should use the public url when configured", func(t *testing.T) {
+		cdnStore, _ := NewS3Storage(S3Config{
+			Endpoint:  server.URL,
+			Bucket:    "products",
+			AccessKey: "access",
+			SecretKey: "secret",
+			PublicURL: "https://cdn.example.com/",
+		})
+
+		url, err := cdnStore.Put("products/2/a.jpg", strings.NewReader("x"), "image/jpeg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if url != "https://cdn.example.com/products/2/a.jpg" {
+			t.Errorf("unexpected url %s", url)
+		}
+	})
+}
+
+type fakeObject struct {
+	body        []byte
+	contentType string
+}
+
+// fakeS3 is a minimal MinIO-like stand-in that verifies signature v4 requests.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	signer  *S3Storage
+}
+
+func newFakeS3(accessKey, secretKey, region string) *fakeS3 {
+	return &fakeS3{
+		objects: map[string]fakeObject{},
+		signer: &S3Storage{cfg: S3Config{
+			Region:    region,
+			AccessKey: accessKey,
+			SecretKey: secretKey,
+		}},
+	}
+}
+
+func (f *fakeS3) get(path string) (fakeObject, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	object, ok := f.objects[path]
+	return object, ok
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	signedAt, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil || r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	r.Header.Set("Host", r.Host)
+	expected, _ := f.signer.signature(r, sha256Hex(body), signedAt)
+	if !strings.HasSuffix(r.Header.Get("Authorization"), "Signature="+expected) {
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = fakeObject{body: body, contentType: r.Header.Get("Content-Type")}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -1,7 +1,14 @@
 package types
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"time"
+
+	"github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
 )
 
 // Product types
@@ -11,35 +18,1467 @@ type ProductStore interface {
 	GetProducts(page, offset int) ([]Product, int, error)
 	GetProductsByID(productIDs []int) ([]Product, error)
 	CreateProduct(payload ProductCreatePayload) (*Product, error)
-	UpdateProduct(id int, payload ProductUpdatePayload) (*Product, error)
+	UpdateProduct(id, version int, payload ProductPatchPayload) (*Product, error)
 	DeleteProduct(id int) error
+	GetTrashedProducts(limit, offset int) ([]Product, int, error)
+	RestoreProduct(id int) (*Product, error)
+	GetPurgeableProductIDs() ([]int, error)
+	PurgeProduct(id int) ([]ProductImage, error)
+	GetProductImages(productID int) ([]ProductImage, error)
+	GetProductImage(productID, imageID int) (*ProductImage, error)
+	CreateProductImage(image ProductImage) (*ProductImage, error)
+	UpdateProductImageAltText(productID, imageID int, altText string) (*ProductImage, error)
+	ReorderProductImages(productID int, imageIDs []int) ([]ProductImage, error)
+	DeleteProductImage(productID, imageID int) error
+	GetProductBySKU(sku string) (*Product, error)
+	UpsertProductBySKU(payload ProductCreatePayload) (*Product, bool, error)
+	StreamProducts(fn func(product Product) error) error
 }
 
 type Product struct {
+	ID                int                 `json:"id"`
+	Name              string              `json:"name"`
+	Description       string              `json:"description"`
+	Image             string              `json:"image"`
+	Price             money.Money         `json:"price"`
+	Quantity          int                 `json:"quantity"`
+	CreatedAt         time.Time           `json:"createdAt"`
+	UpdatedAt         time.Time           `json:"updatedAt"`
+	DeletedAt         *time.Time          `json:"deletedAt,omitempty"`
+	Version           int                 `json:"version"`
+	SKU               *string             `json:"sku"`
+	LowStockThreshold *int                `json:"lowStockThreshold"`
+	TaxClass          string              `json:"taxClass"`
+	WeightGrams       int                 `json:"weightGrams"`
+	LengthMm          int                 `json:"lengthMm"`
+	WidthMm           int                 `json:"widthMm"`
+	HeightMm          int                 `json:"heightMm"`
+	CategoryID        *int                `json:"categoryId"`
+	Available         int                 `json:"available"`
+	Locations         []ProductStockLevel `json:"locations,omitempty"`
+	Images            []ProductImage      `json:"images,omitempty"`
+}
+
+// ErrProductNotFound is wrapped by the lookups that need to tell a missing product apart from other failures.
+var ErrProductNotFound = errors.New("product was not found")
+
+// ErrProductVersionMismatch is returned when a product was changed since the version the caller has read.
+var ErrProductVersionMismatch = errors.New("product was modified by another request, fetch it again and retry")
+
+type ProductCreatePayload struct {
+	SKU         string      `json:"sku" validate:"omitempty,max=64"`
+	Name        string      `json:"name" validate:"required,min=3,max=256"`
+	Description string      `json:"description" validate:"required,max=3000"`
+	Image       string      `json:"image" validate:"required"`
+	Price       money.Money `json:"price" validate:"required,gt=0"`
+	Quantity    int         `json:"quantity" validate:"required,gte=0"`
+	TaxClass    string      `json:"taxClass" validate:"omitempty,max=32"`
+	WeightGrams int         `json:"weightGrams" validate:"gte=0"`
+	LengthMm    int         `json:"lengthMm" validate:"gte=0"`
+	WidthMm     int         `json:"widthMm" validate:"gte=0"`
+	HeightMm    int         `json:"heightMm" validate:"gte=0"`
+	CategoryID  int         `json:"categoryId" validate:"gte=0"`
+}
+
+// ProductImportRow is a product of a bulk import, unlike on create a quantity of 0 is accepted.
+type ProductImportRow struct {
+	SKU         string      `json:"sku" validate:"omitempty,max=64"`
+	Name        string      `json:"name" validate:"required,min=3,max=256"`
+	Description string      `json:"description" validate:"required,max=3000"`
+	Image       string      `json:"image" validate:"required"`
+	Price       money.Money `json:"price" validate:"required,gt=0"`
+	Quantity    int         `json:"quantity" validate:"gte=0"`
+	TaxClass    string      `json:"taxClass" validate:"omitempty,max=32"`
+	WeightGrams int         `json:"weightGrams" validate:"gte=0"`
+	LengthMm    int         `json:"lengthMm" validate:"gte=0"`
+	WidthMm     int         `json:"widthMm" validate:"gte=0"`
+	HeightMm    int         `json:"heightMm" validate:"gte=0"`
+	CategoryID  int         `json:"categoryId" validate:"gte=0"`
+}
+
+// ProductUpdatePayload is the body of PUT, it replaces the whole product so the optional fields that are
+// omitted are reset to their defaults.
+type ProductUpdatePayload struct {
+	SKU         *string      `json:"sku" validate:"omitnil,max=64"`
+	Name        *string      `json:"name" validate:"required,min=3,max=256"`
+	Description *string      `json:"description" validate:"required,max=3000"`
+	Image       *string      `json:"image" validate:"required,min=1"`
+	Price       *money.Money `json:"price" validate:"required,gt=0"`
+	Quantity    *int         `json:"quantity" validate:"required,gte=0"`
+	TaxClass    *string      `json:"taxClass" validate:"omitnil,min=1,max=32"`
+	WeightGrams *int         `json:"weightGrams" validate:"omitnil,gte=0"`
+	LengthMm    *int         `json:"lengthMm" validate:"omitnil,gte=0"`
+	WidthMm     *int         `json:"widthMm" validate:"omitnil,gte=0"`
+	HeightMm    *int         `json:"heightMm" validate:"omitnil,gte=0"`
+	CategoryID  *int         `json:"categoryId" validate:"omitnil,gte=0"`
+}
+
+// ProductPatchPayload holds the fields to change, nil fields are left untouched
+// and the validation only runs on the provided ones. A categoryId of 0 takes the product out of its category.
+type ProductPatchPayload struct {
+	SKU         *string      `json:"sku" validate:"omitnil,max=64"`
+	Name        *string      `json:"name" validate:"omitnil,min=3,max=256"`
+	Description *string      `json:"description" validate:"omitnil,max=3000"`
+	Image       *string      `json:"image" validate:"omitnil,min=1"`
+	Price       *money.Money `json:"price" validate:"omitnil,gt=0"`
+	Quantity    *int         `json:"quantity" validate:"omitnil,gte=0"`
+	TaxClass    *string      `json:"taxClass" validate:"omitnil,min=1,max=32"`
+	WeightGrams *int         `json:"weightGrams" validate:"omitnil,gte=0"`
+	LengthMm    *int         `json:"lengthMm" validate:"omitnil,gte=0"`
+	WidthMm     *int         `json:"widthMm" validate:"omitnil,gte=0"`
+	HeightMm    *int         `json:"heightMm" validate:"omitnil,gte=0"`
+	CategoryID  *int         `json:"categoryId" validate:"omitnil,gte=0"`
+}
+
+// ProductImportResult is the per-row report of a bulk import, with dry runs nothing is written.
+type ProductImportResult struct {
+	DryRun  bool                 `json:"dryRun"`
+	Total   int                  `json:"total"`
+	Created int                  `json:"created"`
+	Updated int                  `json:"updated"`
+	Failed  int                  `json:"failed"`
+	Errors  []ProductImportError `json:"errors"`
+}
+
+type ProductImportError struct {
+	Row   int    `json:"row"`
+	SKU   string `json:"sku"`
+	Error string `json:"error"`
+}
+
+// Product images types
+
+type ProductImage struct {
+	ID           int       `json:"id"`
+	ProductID    int       `json:"productId"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnailUrl"`
+	StorageKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	AltText      string    `json:"altText"`
+	Position     int       `json:"position"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type ProductImageUpdatePayload struct {
+	AltText string `json:"altText" validate:"max=255"`
+}
+
+type ProductImagesReorderPayload struct {
+	ImageIDs []int `json:"imageIds" validate:"required,min=1"`
+}
+
+// BlobStorage stores uploaded files and returns the public url they can be fetched from.
+type BlobStorage interface {
+	Put(key string, body io.Reader, contentType string) (string, error)
+	Delete(key string) error
+}
+
+// Inventory types
+
+type InventoryStore interface {
+	WithTx(tx db.DBTX) InventoryStore
+	RecordMovement(movement StockMovement) (*StockMovement, error)
+	GetProductMovements(productID, limit, offset int) ([]StockMovement, int, error)
+	ReconcileProduct(productID int, fix bool) (*StockReconciliation, error)
+	ReserveStock(orderID, userID int, allocations []StockAllocation, ttl time.Duration) ([]StockReservation, error)
+	CommitReservations(orderID int) error
+	ReleaseReservations(orderID int) error
+	ReleaseExpiredReservations() ([]int, error)
+	SetLowStockThreshold(productID int, threshold *int) error
+}
+
+// StockNotificationStore keeps the stock alerts for staff and the customers waiting for a product.
+type StockNotificationStore interface {
+	GetPendingStockAlerts(limit int) ([]StockAlert, error)
+	SetStockAlertStatus(alertID int, status string) error
+	GetLastSentStockAlert(productID int, kind string) (*StockAlert, error)
+	Subscribe(productID, userID int, email string) (*StockSubscription, bool, error)
+	GetWaitingSubscriptions(productID int) ([]StockSubscription, error)
+	MarkSubscriptionsNotified(subscriptionIDs []int) error
+}
+
+// the reasons a product stock can change for, sales and damages decrease it.
+const (
+	StockReasonSale         = "sale"
+	StockReasonCancellation = "cancellation"
+	StockReasonRestock      = "restock"
+	StockReasonAdjustment   = "adjustment"
+	StockReasonReturn       = "return"
+	StockReasonDamage       = "damage"
+)
+
+// ErrInsufficientStock is returned when a movement would take the quantity below zero.
+var ErrInsufficientStock = errors.New("not enough stock")
+
+// StockMovement is an append-only ledger entry, the product quantity is the sum of its movements.
+type StockMovement struct {
+	ID               int       `json:"id"`
+	ProductID        int       `json:"productId"`
+	QuantityChange   int       `json:"quantityChange"`
+	QuantityAfter    int       `json:"quantityAfter"`
+	Reason           string    `json:"reason"`
+	ActorID          *int      `json:"actorId"`
+	OrderID          *int      `json:"orderId"`
+	PurchaseOrderRef *string   `json:"purchaseOrderRef"`
+	Note             string    `json:"note"`
+	CreatedAt        time.Time `json:"createdAt"`
+	WarehouseID      *int      `json:"warehouseId"`
+}
+
+// StockMovementPayload is what staff can record by hand, sales and cancellations come from orders.
+type StockMovementPayload struct {
+	QuantityChange   int    `json:"quantityChange" validate:"required"`
+	Reason           string `json:"reason" validate:"required,oneof=restock adjustment return damage"`
+	OrderID          int    `json:"orderId" validate:"omitempty,gt=0"`
+	PurchaseOrderRef string `json:"purchaseOrderRef" validate:"max=64"`
+	Note             string `json:"note" validate:"max=255"`
+	// defaults to the warehouse with the highest priority.
+	WarehouseID int `json:"warehouseId" validate:"omitempty,gt=0"`
+}
+
+const (
+	ReservationStatusActive    = "active"
+	ReservationStatusCommitted = "committed"
+	ReservationStatusReleased  = "released"
+	ReservationStatusExpired   = "expired"
+)
+
+// ErrReservationExpired is returned when an order is paid after its stock was released.
+var ErrReservationExpired = errors.New("stock reservation has expired, please checkout again")
+
+// StockReservation holds units for an order until it's paid or the reservation expires.
+type StockReservation struct {
 	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Image       string    `json:"image"`
-	Price       float64   `json:"price"`
+	ProductID   int       `json:"productId"`
+	OrderID     int       `json:"orderId"`
+	UserID      int       `json:"userId"`
 	Quantity    int       `json:"quantity"`
+	Status      string    `json:"status"`
+	ExpiresAt   time.Time `json:"expiresAt"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
+	WarehouseID *int      `json:"warehouseId"`
 }
 
-type ProductCreatePayload struct {
-	Name        string  `json:"name" validate:"required,min=3,max=256"`
-	Description string  `json:"description" validate:"required,max=3000"`
-	Image       string  `json:"image" validate:"required"`
-	Price       float64 `json:"price" validate:"required,gt=0"`
-	Quantity    int     `json:"quantity" validate:"required,gte=0"`
+// StockReconciliation compares the product quantity and the sum of its warehouses stock with the ledger.
+// the kinds of stock alerts, low and out of stock go to staff, back in stock to the subscribed customers.
+const (
+	StockAlertLowStock    = "low_stock"
+	StockAlertOutOfStock  = "out_of_stock"
+	StockAlertBackInStock = "back_in_stock"
+)
+
+const (
+	StockAlertStatusPending    = "pending"
+	StockAlertStatusSent       = "sent"
+	StockAlertStatusSuppressed = "suppressed"
+	StockAlertStatusSkipped    = "skipped"
+)
+
+// ErrProductInStock is returned when subscribing to a product that can still be bought.
+var ErrProductInStock = errors.New("product is in stock")
+
+// StockAlert is recorded with the movement that crossed a threshold and sent later by the notifier.
+type StockAlert struct {
+	ID        int        `json:"id"`
+	ProductID int        `json:"productId"`
+	Kind      string     `json:"kind"`
+	Quantity  int        `json:"quantity"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"createdAt"`
+	SentAt    *time.Time `json:"sentAt"`
 }
 
-type ProductUpdatePayload struct {
-	Name        string  `json:"name" validate:"min=3,max=256"`
-	Description string  `json:"description" validate:"min=4,max=3000"`
-	Image       string  `json:"image"`
-	Price       float64 `json:"price" validate:"gt=0"`
-	Quantity    int     `json:"quantity" validate:"gte=0"`
+type StockSubscription struct {
+	ID         int        `json:"id"`
+	ProductID  int        `json:"productId"`
+	UserID     int        `json:"userId"`
+	Email      string     `json:"email"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	NotifiedAt *time.Time `json:"notifiedAt"`
+}
+
+type LowStockThresholdPayload struct {
+	// null disables the low stock alerts, out of stock alerts are always sent.
+	Threshold *int `json:"threshold" validate:"omitnil,gte=0"`
+}
+
+type StockReconciliation struct {
+	ProductID         int  `json:"productId"`
+	Quantity          int  `json:"quantity"`
+	LedgerQuantity    int  `json:"ledgerQuantity"`
+	WarehouseQuantity int  `json:"warehouseQuantity"`
+	Difference        int  `json:"difference"`
+	Fixed             bool `json:"fixed"`
+}
+
+// Category types
+
+type CategoryStore interface {
+	GetCategories() ([]Category, error)
+	GetCategoryById(id int) (*Category, error)
+	CreateCategory(name string) (*Category, error)
+	UpdateCategory(id int, name string) (*Category, error)
+	// DeleteCategory takes the category off its products, a category coupons are restricted to can't be deleted.
+	DeleteCategory(id int) error
+}
+
+// Category groups the products, a product belongs to one category at most.
+type Category struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type CategoryPayload struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// Coupon types
+
+type CouponStore interface {
+	WithTx(tx db.DBTX) CouponStore
+	GetCoupons(limit, offset int) ([]Coupon, int, error)
+	GetCouponById(id int) (*Coupon, error)
+	GetCouponsByCodes(codes []string) ([]Coupon, error)
+	CreateCoupon(coupon Coupon) (*Coupon, error)
+	UpdateCoupon(id int, coupon Coupon) (*Coupon, error)
+	DeleteCoupon(id int) error
+	RedeemCoupons(orderID, userID int, applied []AppliedCoupon) error
+}
+
+const (
+	CouponTypePercentage   = "percentage"
+	CouponTypeFixed        = "fixed"
+	CouponTypeFreeShipping = "free_shipping"
+)
+
+// ErrCouponNotApplicable is wrapped by every reason a coupon can't be used on a cart.
+var ErrCouponNotApplicable = errors.New("coupon can't be applied")
+
+// Coupon is a discount code, it applies to the products of ProductIDs and the products of CategoryIDs,
+// or to the whole cart when it has neither.
+type Coupon struct {
+	ID           int          `json:"id"`
+	Code         string       `json:"code"`
+	Type         string       `json:"type"`
+	Percentage   float64      `json:"percentage"`
+	Amount       money.Money  `json:"amount"`
+	MinSubtotal  *money.Money `json:"minSubtotal"`
+	UsageLimit   *int         `json:"usageLimit"`
+	PerUserLimit *int         `json:"perUserLimit"`
+	StartsAt     *time.Time   `json:"startsAt"`
+	EndsAt       *time.Time   `json:"endsAt"`
+	Stackable    bool         `json:"stackable"`
+	Active       bool         `json:"active"`
+	CreatedAt    time.Time    `json:"createdAt"`
+	UpdatedAt    time.Time    `json:"updatedAt"`
+	ProductIDs   []int        `json:"productIds"`
+	CategoryIDs  []int        `json:"categoryIds"`
+}
+
+type CouponPayload struct {
+	Code         string       `json:"code" validate:"required,max=64,alphanumunicode"`
+	Type         string       `json:"type" validate:"required,oneof=percentage fixed free_shipping"`
+	Percentage   float64      `json:"percentage" validate:"gte=0,lte=100,required_if=Type percentage"`
+	Amount       money.Money  `json:"amount" validate:"gte=0,required_if=Type fixed"`
+	MinSubtotal  *money.Money `json:"minSubtotal" validate:"omitnil,gte=0"`
+	UsageLimit   *int         `json:"usageLimit" validate:"omitnil,gt=0"`
+	PerUserLimit *int         `json:"perUserLimit" validate:"omitnil,gt=0"`
+	StartsAt     *time.Time   `json:"startsAt"`
+	EndsAt       *time.Time   `json:"endsAt"`
+	Stackable    bool         `json:"stackable"`
+	Active       *bool        `json:"active"`
+	ProductIDs   []int        `json:"productIds" validate:"omitempty,dive,gt=0"`
+	CategoryIDs  []int        `json:"categoryIds" validate:"omitempty,dive,gt=0"`
+}
+
+// AppliedCoupon is the discount a coupon gave on a cart.
+type AppliedCoupon struct {
+	CouponID     int         `json:"-"`
+	Code         string      `json:"code"`
+	Type         string      `json:"type"`
+	Discount     money.Money `json:"discount"`
+	FreeShipping bool        `json:"freeShipping,omitempty"`
+}
+
+// Promotion types
+
+type PromotionStore interface {
+	GetPromotions(limit, offset int) ([]Promotion, int, error)
+	GetActivePromotions(at time.Time) ([]Promotion, error)
+	GetPromotionById(id int) (*Promotion, error)
+	CreatePromotion(promotion Promotion) (*Promotion, error)
+	UpdatePromotion(id int, promotion Promotion) (*Promotion, error)
+	DeletePromotion(id int) error
+}
+
+const (
+	PromotionTypeBuyXGetY        = "buy_x_get_y"
+	PromotionTypeOrderPercentage = "order_percentage"
+	PromotionTypeBundle          = "bundle"
+	PromotionTypeQuantityTier    = "quantity_tier"
+)
+
+// Promotion applies to every cart matching its rules without a code,
+// the promotions run by ascending priority then id.
+type Promotion struct {
+	ID        int            `json:"id"`
+	Name      string         `json:"name"`
+	Type      string         `json:"type"`
+	Priority  int            `json:"priority"`
+	Rules     PromotionRules `json:"rules"`
+	StartsAt  *time.Time     `json:"startsAt"`
+	EndsAt    *time.Time     `json:"endsAt"`
+	Active    bool           `json:"active"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// PromotionRules holds the settings of every promotion type, each type only reads its own:
+// buy_x_get_y uses ProductIDs, BuyQuantity and GetQuantity, order_percentage uses MinSubtotal and Percentage,
+// bundle uses ProductIDs and BundlePrice, quantity_tier uses ProductIDs and Tiers.
+type PromotionRules struct {
+	ProductIDs  []int       `json:"productIds,omitempty" validate:"omitempty,dive,gt=0"`
+	BuyQuantity int         `json:"buyQuantity,omitempty" validate:"gte=0"`
+	GetQuantity int         `json:"getQuantity,omitempty" validate:"gte=0"`
+	MinSubtotal money.Money `json:"minSubtotal" validate:"gte=0"`
+	Percentage  float64     `json:"percentage,omitempty" validate:"gte=0,lte=100"`
+	BundlePrice money.Money `json:"bundlePrice" validate:"gte=0"`
+	Tiers       []PriceTier `json:"tiers,omitempty" validate:"omitempty,dive"`
+}
+
+// PriceTier is the unit price of a product once at least MinQuantity units are bought.
+type PriceTier struct {
+	MinQuantity int         `json:"minQuantity" validate:"gt=1"`
+	UnitPrice   money.Money `json:"unitPrice" validate:"gte=0"`
+}
+
+type PromotionPayload struct {
+	Name     string         `json:"name" validate:"required,max=255"`
+	Type     string         `json:"type" validate:"required,oneof=buy_x_get_y order_percentage bundle quantity_tier"`
+	Priority int            `json:"priority" validate:"gte=0"`
+	Rules    PromotionRules `json:"rules"`
+	StartsAt *time.Time     `json:"startsAt"`
+	EndsAt   *time.Time     `json:"endsAt"`
+	Active   *bool          `json:"active"`
+}
+
+// LineAdjustment is what a promotion took off a cart line and why.
+type LineAdjustment struct {
+	PromotionID int         `json:"promotionId"`
+	Name        string      `json:"name"`
+	Amount      money.Money `json:"amount"`
+	Reason      string      `json:"reason"`
+}
+
+// AppliedPromotion is the discount a promotion gave on the whole cart.
+type AppliedPromotion struct {
+	PromotionID int         `json:"promotionId"`
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Discount    money.Money `json:"discount"`
+}
+
+// Tax types
+
+type TaxRateStore interface {
+	GetTaxRates(limit, offset int) ([]TaxRate, int, error)
+	GetTaxRatesFor(country, region string) ([]TaxRate, error)
+	GetTaxRateById(id int) (*TaxRate, error)
+	CreateTaxRate(rate TaxRate) (*TaxRate, error)
+	UpdateTaxRate(id int, rate TaxRate) (*TaxRate, error)
+	DeleteTaxRate(id int) error
+}
+
+// TaxClassStandard is the class of the products that weren't given one.
+const TaxClassStandard = "standard"
+
+// ErrTaxUnavailable is wrapped by the providers when the taxes of a cart can't be calculated right now.
+var ErrTaxUnavailable = errors.New("taxes can't be calculated")
+
+// TaxRate is charged on the products of TaxClass delivered to Country, an empty Region covers the whole country.
+// Every rate matching an address is charged, e.g. a federal rate and a provincial one.
+type TaxRate struct {
+	ID        int       `json:"id"`
+	Country   string    `json:"country"`
+	Region    string    `json:"region"`
+	TaxClass  string    `json:"taxClass"`
+	Name      string    `json:"name"`
+	Rate      float64   `json:"rate"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type TaxRatePayload struct {
+	Country  string  `json:"country" validate:"required,iso3166_1_alpha2"`
+	Region   string  `json:"region" validate:"max=100"`
+	TaxClass string  `json:"taxClass" validate:"required,max=32"`
+	Name     string  `json:"name" validate:"required,max=64"`
+	Rate     float64 `json:"rate" validate:"gte=0,lte=100"`
+}
+
+// TaxProvider calculates the taxes of the cart lines for the address they're delivered to.
+type TaxProvider interface {
+	Calculate(request TaxRequest) ([]TaxLine, error)
+}
+
+// TaxRequest holds the lines after their discounts, with Inclusive the amounts already contain the taxes.
+type TaxRequest struct {
+	Address   *ShippingAddress
+	Inclusive bool
+	Lines     []TaxableLine
+}
+
+type TaxableLine struct {
+	ProductID int
+	TaxClass  string
+	Amount    money.Money
+}
+
+// TaxLine is a tax charged on a cart line, Taxable is the amount of the line without any tax.
+type TaxLine struct {
+	ProductID int         `json:"productId"`
+	Name      string      `json:"name"`
+	Rate      float64     `json:"rate"`
+	Taxable   money.Money `json:"taxable"`
+	Amount    money.Money `json:"amount"`
+}
+
+// TaxSummary is a tax added up over all the lines of the order.
+type TaxSummary struct {
+	Name    string      `json:"name"`
+	Rate    float64     `json:"rate"`
+	Taxable money.Money `json:"taxable"`
+	Amount  money.Money `json:"amount"`
+}
+
+// Shipping types
+
+type ShippingStore interface {
+	GetShippingZones() ([]ShippingZone, error)
+	GetShippingZoneById(id int) (*ShippingZone, error)
+	GetShippingZoneFor(country, region string) (*ShippingZone, error)
+	CreateShippingZone(zone ShippingZone) (*ShippingZone, error)
+	UpdateShippingZone(id int, zone ShippingZone) (*ShippingZone, error)
+	DeleteShippingZone(id int) error
+	GetShippingMethodById(id int) (*ShippingMethod, error)
+	CreateShippingMethod(method ShippingMethod) (*ShippingMethod, error)
+	UpdateShippingMethod(id int, method ShippingMethod) (*ShippingMethod, error)
+	DeleteShippingMethod(id int) error
+}
+
+const (
+	ShippingMethodFlatRate    = "flat_rate"
+	ShippingMethodWeightBased = "weight_based"
+	ShippingMethodFreeOver    = "free_over"
+	ShippingMethodCarrier     = "carrier"
+)
+
+// ErrShippingMethodUnavailable is wrapped when a method can't ship the parcel to the address.
+var ErrShippingMethodUnavailable = errors.New("shipping method is not available")
+
+// ErrCarrierUnavailable is wrapped by the carriers when they can't quote a rate right now.
+var ErrCarrierUnavailable = errors.New("carrier can't be reached")
+
+// ShippingZone groups the countries, or regions of a country, that are shipped with the same methods.
+// A location belongs to a single zone, a region is matched before its whole country.
+type ShippingZone struct {
+	ID        int                `json:"id"`
+	Name      string             `json:"name"`
+	Locations []ShippingLocation `json:"locations"`
+	Methods   []ShippingMethod   `json:"methods"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+// ShippingLocation is a country, or a region of it when Region is set.
+type ShippingLocation struct {
+	Country string `json:"country" validate:"required,iso3166_1_alpha2"`
+	Region  string `json:"region" validate:"max=100"`
+}
+
+type ShippingZonePayload struct {
+	Name      string             `json:"name" validate:"required,max=100"`
+	Locations []ShippingLocation `json:"locations" validate:"required,min=1,dive"`
+}
+
+// ShippingMethod is priced by its type: flat_rate and free_over charge Rate, weight_based charges Rate
+// plus RatePerKg for every started kilogram and carrier asks the Carrier for a rate of its CarrierService.
+// Any method is free once the goods reach FreeOver and can't ship parcels heavier than MaxWeightGrams.
+type ShippingMethod struct {
+	ID             int          `json:"id"`
+	ZoneID         int          `json:"zoneId"`
+	Name           string       `json:"name"`
+	Type           string       `json:"type"`
+	Rate           money.Money  `json:"rate"`
+	RatePerKg      money.Money  `json:"ratePerKg"`
+	FreeOver       *money.Money `json:"freeOver"`
+	Carrier        string       `json:"carrier"`
+	CarrierService string       `json:"carrierService"`
+	MaxWeightGrams *int         `json:"maxWeightGrams"`
+	Active         bool         `json:"active"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	UpdatedAt      time.Time    `json:"updatedAt"`
+}
+
+type ShippingMethodPayload struct {
+	Name           string       `json:"name" validate:"required,max=100"`
+	Type           string       `json:"type" validate:"required,oneof=flat_rate weight_based free_over carrier"`
+	Rate           money.Money  `json:"rate" validate:"gte=0"`
+	RatePerKg      money.Money  `json:"ratePerKg" validate:"gte=0"`
+	FreeOver       *money.Money `json:"freeOver" validate:"omitnil,gte=0"`
+	Carrier        string       `json:"carrier" validate:"max=32,required_if=Type carrier"`
+	CarrierService string       `json:"carrierService" validate:"max=64"`
+	MaxWeightGrams *int         `json:"maxWeightGrams" validate:"omitnil,gt=0"`
+	Active         *bool        `json:"active"`
+}
+
+// Parcel is what's shipped, Goods is the value of the goods used by the free shipping thresholds.
+type Parcel struct {
+	WeightGrams int
+	Goods       money.Money
+	Items       []ParcelItem
+}
+
+type ParcelItem struct {
+	ProductID   int
+	Quantity    int
+	WeightGrams int
+	LengthMm    int
+	WidthMm     int
+	HeightMm    int
+}
+
+// ShippingRate is the cost of shipping a parcel with a method.
+type ShippingRate struct {
+	MethodID int         `json:"methodId"`
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Carrier  string      `json:"carrier,omitempty"`
+	Cost     money.Money `json:"cost"`
+}
+
+// ShippingQuoter prices the shipping methods of the zone an address belongs to.
+type ShippingQuoter interface {
+	Quote(address ShippingAddress, parcel Parcel) ([]ShippingRate, error)
+	QuoteMethod(methodID int, address ShippingAddress, parcel Parcel) (ShippingRate, error)
+}
+
+// ShippingCarrier quotes the rate of one of its services for a parcel.
+type ShippingCarrier interface {
+	Rate(service string, address ShippingAddress, parcel Parcel) (money.Money, error)
+}
+
+// Payment types
+
+type PaymentStore interface {
+	CreatePayment(payment Payment) (*Payment, error)
+	GetPaymentById(id int) (*Payment, error)
+	GetPaymentByProviderRef(provider, providerRef string) (*Payment, error)
+	GetOrderPayments(orderID int) ([]Payment, error)
+	UpdatePayment(payment Payment) error
+}
+
+const (
+	PaymentStatusPending           = "pending"
+	PaymentStatusRequiresAction    = "requires_action"
+	PaymentStatusAuthorized        = "authorized"
+	PaymentStatusCaptured          = "captured"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+	PaymentStatusVoided            = "voided"
+	PaymentStatusFailed            = "failed"
+)
+
+var (
+	// ErrPaymentDeclined is wrapped by the providers when the customer's payment method was refused.
+	ErrPaymentDeclined = errors.New("the payment was declined")
+	// ErrPaymentRequiresAction is returned while the payment waits for the customer to confirm it, e.g. a 3DS challenge.
+	ErrPaymentRequiresAction = errors.New("the payment must be confirmed")
+	// ErrPaymentUnavailable is wrapped by the providers when they can't be reached or timed out.
+	ErrPaymentUnavailable = errors.New("the payment provider is unavailable")
+)
+
+// Payment is the money collected for an order through a provider, ProviderRef is the id of its intent there.
+type Payment struct {
+	ID             int         `json:"id"`
+	OrderID        int         `json:"orderId"`
+	Provider       string      `json:"provider"`
+	ProviderRef    string      `json:"providerRef"`
+	Status         string      `json:"status"`
+	Amount         money.Money `json:"amount"`
+	CapturedAmount money.Money `json:"capturedAmount"`
+	RefundedAmount money.Money `json:"refundedAmount"`
+	NextAction     string      `json:"nextAction,omitempty"`
+	FailureReason  string      `json:"failureReason,omitempty"`
+	CreatedAt      time.Time   `json:"createdAt"`
+	UpdatedAt      time.Time   `json:"updatedAt"`
+}
+
+type PaymentConfirmationPayload struct {
+	ChallengeResponse string `json:"challengeResponse" validate:"required,max=64"`
+}
+
+// PaymentRefundPayload refunds Amount, without it what's left of the captured amount is refunded.
+type PaymentRefundPayload struct {
+	Amount *money.Money `json:"amount"`
+}
+
+// PaymentIntent is a payment as the provider sees it, its Status uses the PaymentStatus values.
+// NextAction tells the customer what to do while it requires action, e.g. the challenge to answer.
+type PaymentIntent struct {
+	ID         string
+	Status     string
+	Amount     money.Money
+	Captured   money.Money
+	Refunded   money.Money
+	NextAction string
+}
+
+type PaymentIntentRequest struct {
+	OrderID int
+	Amount  money.Money
+}
+
+// PaymentAuthorization is the payment method of the customer, or their answer to the challenge
+// when the payment required action.
+type PaymentAuthorization struct {
+	Method            string
+	ChallengeResponse string
+}
+
+// PaymentProvider collects the money through a payment gateway. A declined payment is reported
+// with ErrPaymentDeclined and a gateway that can't be reached with ErrPaymentUnavailable.
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(request PaymentIntentRequest) (PaymentIntent, error)
+	Authorize(intentID string, authorization PaymentAuthorization) (PaymentIntent, error)
+	Capture(intentID string, amount money.Money) (PaymentIntent, error)
+	Void(intentID string) (PaymentIntent, error)
+	Refund(intentID string, amount money.Money) (PaymentIntent, error)
+}
+
+// PaymentProcessor takes the payment of a new order and moves the order along with it,
+// and gives back the money of the captured payments.
+type PaymentProcessor interface {
+	Pay(order *Order, method string) (*Payment, error)
+	Refund(payment *Payment, amount money.Money) error
+}
+
+// WebhookEventStore keeps the events received from the payment providers, an event is
+// stored once per provider so its replays can be told apart.
+type WebhookEventStore interface {
+	CreateWebhookEvent(event WebhookEvent) (*WebhookEvent, bool, error)
+	GetWebhookEventById(id int) (*WebhookEvent, error)
+	GetWebhookEvents(status string, limit, offset int) ([]WebhookEvent, int, error)
+	// ClaimWebhookEvent marks a pending or failed event as processing, claimed is false when it's
+	// neither so another request is applying it or already did.
+	ClaimWebhookEvent(id int) (claimed bool, err error)
+	UpdateWebhookEvent(event WebhookEvent) error
+}
+
+const (
+	WebhookEventStatusPending    = "pending"
+	WebhookEventStatusProcessing = "processing"
+	WebhookEventStatusProcessed  = "processed"
+	WebhookEventStatusIgnored    = "ignored"
+	WebhookEventStatusFailed     = "failed"
+)
+
+// the events the payment providers report, they're translated to these by the webhook.
+const (
+	PaymentEventAuthorized = "payment.authorized"
+	PaymentEventCaptured   = "payment.captured"
+	PaymentEventFailed     = "payment.failed"
+	PaymentEventVoided     = "payment.voided"
+	PaymentEventRefunded   = "payment.refunded"
+)
+
+// ErrWebhookSignature is returned when a webhook isn't signed by the provider or is too old.
+var ErrWebhookSignature = errors.New("invalid webhook signature")
+
+type WebhookEvent struct {
+	ID          int             `json:"id"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"eventId"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"lastError,omitempty"`
+	ReceivedAt  time.Time       `json:"receivedAt"`
+	ProcessedAt *time.Time      `json:"processedAt"`
+}
+
+// PaymentEvent is the body of a payment webhook. Amount is the captured amount of the capture
+// events and the total refunded of the refund ones.
+type PaymentEvent struct {
+	ID       string       `json:"id" validate:"required,max=255"`
+	Type     string       `json:"type" validate:"required,max=64"`
+	IntentID string       `json:"intentId" validate:"required,max=255"`
+	Amount   *money.Money `json:"amount"`
+	Reason   string       `json:"reason"`
+}
+
+// Idempotency types
+
+// IdempotencyStore keeps the responses of the requests sent with an Idempotency-Key, a key
+// belongs to a user and is forgotten once it expires.
+type IdempotencyStore interface {
+	StartIdempotentRequest(userID int, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error)
+	CompleteIdempotentRequest(userID int, key string, responseStatus int, contentType string, responseBody []byte) error
+	DeleteIdempotencyKey(userID int, key string) error
+	DeleteExpiredIdempotencyKeys() (int, error)
+}
+
+const (
+	IdempotencyStatusInFlight  = "in_flight"
+	IdempotencyStatusCompleted = "completed"
+)
+
+// IdempotencyRecord is a request sent with an Idempotency-Key, Fingerprint tells the retries
+// apart from other requests reusing the key. The response is kept once it's completed.
+type IdempotencyRecord struct {
+	UserID         int
+	Key            string
+	Fingerprint    string
+	Status         string
+	ResponseStatus *int
+	ContentType    string
+	ResponseBody   []byte
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+}
+
+// Return types
+
+// ReturnStore keeps the return requests (RMA) of the delivered orders. The returned quantities of an
+// order item never add up to more than what was ordered, and the refunds never to more than what
+// was received back.
+type ReturnStore interface {
+	CreateReturn(ret Return) (*Return, error)
+	GetReturnById(id int) (*Return, error)
+	GetReturns(userID int, status string, limit, offset int) ([]Return, int, error)
+	UpdateReturnStatus(id int, from []string, status, staffNote string) error
+	ReceiveReturn(id int, items []ReturnItem, actorID int) (*Return, error)
+	AddReturnRefund(id int, amount money.Money) (*Return, error)
+}
+
+// a return is requested by the customer, approved or rejected by staff, then received and refunded.
+// the customer can cancel it until it's decided.
+const (
+	ReturnStatusRequested         = "requested"
+	ReturnStatusApproved          = "approved"
+	ReturnStatusRejected          = "rejected"
+	ReturnStatusCancelled         = "cancelled"
+	ReturnStatusReceived          = "received"
+	ReturnStatusPartiallyRefunded = "partially_refunded"
+	ReturnStatusRefunded          = "refunded"
+)
+
+// what happens to the received items, restocked items go back to a warehouse and the written off ones don't.
+const (
+	ReturnDispositionRestock  = "restock"
+	ReturnDispositionWriteOff = "write_off"
+)
+
+var (
+	// ErrReturnQuantity is returned when more units of an order item would be returned than were ordered.
+	ErrReturnQuantity = errors.New("more items are returned than were ordered")
+	// ErrReturnStatus is returned when a return is moved from a status it can't leave that way.
+	ErrReturnStatus = errors.New("the return can't be moved to this status")
+	// ErrReturnRefund is returned when a refund would give back more than what the return is worth.
+	ErrReturnRefund = errors.New("the refund is more than what's left to refund")
+)
+
+// Return is a request to send back some items of an order. RefundableTotal is what the received items
+// are worth once the return is received, until then it's what the requested ones would be.
+type Return struct {
+	ID              int          `json:"id"`
+	OrderID         int          `json:"orderId"`
+	UserID          int          `json:"userId"`
+	Status          string       `json:"status"`
+	Reason          string       `json:"reason"`
+	StaffNote       string       `json:"staffNote"`
+	RefundableTotal money.Money  `json:"refundableTotal"`
+	RefundedTotal   money.Money  `json:"refundedTotal"`
+	CreatedAt       time.Time    `json:"createdAt"`
+	UpdatedAt       time.Time    `json:"updatedAt"`
+	Items           []ReturnItem `json:"items"`
+}
+
+// ReturnItem is a quantity of an order item sent back. LineRefundable is what the whole order line was
+// paid with its discounts and taxes, the RefundAmount is the share of it the item is worth.
+type ReturnItem struct {
+	ID               int         `json:"id"`
+	ReturnID         int         `json:"returnId"`
+	OrderItemID      int         `json:"orderItemId"`
+	ProductID        int         `json:"productId"`
+	Quantity         int         `json:"quantity"`
+	LineRefundable   money.Money `json:"-"`
+	RefundAmount     money.Money `json:"refundAmount"`
+	ReceivedQuantity int         `json:"receivedQuantity"`
+	Disposition      *string     `json:"disposition"`
+	WarehouseID      *int        `json:"warehouseId"`
+}
+
+type ReturnRequestPayload struct {
+	Reason string              `json:"reason" validate:"required,max=255"`
+	Items  []ReturnItemPayload `json:"items" validate:"required,min=1,max=100,dive"`
+}
+
+type ReturnItemPayload struct {
+	OrderItemID int `json:"orderItemId" validate:"required,gt=0"`
+	Quantity    int `json:"quantity" validate:"required,gt=0"`
+}
+
+type ReturnDecisionPayload struct {
+	StaffNote string `json:"staffNote" validate:"max=255"`
+}
+
+// ReturnReceiptPayload tells what came back for every item of the return, the restocked items go to
+// WarehouseID or to the warehouse they were shipped from.
+type ReturnReceiptPayload struct {
+	Items []ReturnReceiptItemPayload `json:"items" validate:"required,min=1,dive"`
+}
+
+type ReturnReceiptItemPayload struct {
+	ReturnItemID     int    `json:"returnItemId" validate:"required,gt=0"`
+	ReceivedQuantity int    `json:"receivedQuantity" validate:"gte=0"`
+	Disposition      string `json:"disposition" validate:"required,oneof=restock write_off"`
+	WarehouseID      int    `json:"warehouseId" validate:"omitempty,gt=0"`
+}
+
+// Invoice types
+
+// InvoiceStore keeps the issued invoices and credit notes, they're numbered without gaps per kind and
+// year and never change once issued.
+type InvoiceStore interface {
+	IssueInvoice(invoice Invoice) (*Invoice, error)
+	GetInvoiceById(id int) (*Invoice, error)
+	GetOrderInvoice(orderID int) (*Invoice, error)
+	GetCreditNotes(invoiceID int) ([]Invoice, error)
+}
+
+const (
+	InvoiceKindInvoice    = "invoice"
+	InvoiceKindCreditNote = "credit_note"
+)
+
+// ErrInvoiceCredit is returned when the credit notes of an invoice would credit more than its total.
+var ErrInvoiceCredit = errors.New("the credit notes can't credit more than the invoice total")
+
+// Invoice is an issued invoice or credit note, everything it shows is kept as it was when it was
+// issued. The credit notes correct the invoice of CreditedInvoiceID by the amounts they list.
+type Invoice struct {
+	ID                int           `json:"id"`
+	Kind              string        `json:"kind"`
+	Number            string        `json:"number"`
+	Year              int           `json:"year"`
+	Sequence          int           `json:"sequence"`
+	OrderID           int           `json:"orderId"`
+	UserID            int           `json:"userId"`
+	CreditedInvoiceID *int          `json:"creditedInvoiceId"`
+	CreditedNumber    string        `json:"creditedNumber,omitempty"`
+	Reason            string        `json:"reason,omitempty"`
+	Seller            InvoiceParty  `json:"seller"`
+	Buyer             InvoiceParty  `json:"buyer"`
+	Lines             []InvoiceLine `json:"lines"`
+	TaxInclusive      bool          `json:"taxInclusive"`
+	Taxes             []TaxSummary  `json:"taxes"`
+	Subtotal          money.Money   `json:"subtotal"`
+	DiscountTotal     money.Money   `json:"discountTotal"`
+	ShippingTotal     money.Money   `json:"shippingTotal"`
+	ShippingMethod    string        `json:"shippingMethod,omitempty"`
+	TaxTotal          money.Money   `json:"taxTotal"`
+	Total             money.Money   `json:"total"`
+	IssuedAt          time.Time     `json:"issuedAt"`
+}
+
+type InvoiceParty struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Email   string `json:"email,omitempty"`
+	TaxID   string `json:"taxId,omitempty"`
+}
+
+// InvoiceLine is an order item as it was invoiced, Total is the Subtotal less the Discount, plus the Tax
+// unless the prices include it.
+type InvoiceLine struct {
+	OrderItemID *int        `json:"orderItemId"`
+	ProductID   *int        `json:"productId"`
+	Description string      `json:"description"`
+	Quantity    int         `json:"quantity"`
+	UnitPrice   money.Money `json:"unitPrice"`
+	Subtotal    money.Money `json:"subtotal"`
+	Discount    money.Money `json:"discount"`
+	Tax         money.Money `json:"tax"`
+	Total       money.Money `json:"total"`
+}
+
+// CreditNotePayload credits the items received back by a return, or an amount of the invoice.
+type CreditNotePayload struct {
+	Reason   string       `json:"reason" validate:"required,max=255"`
+	ReturnID int          `json:"returnId" validate:"required_without=Amount,excluded_with=Amount,omitempty,gt=0"`
+	Amount   *money.Money `json:"amount" validate:"required_without=ReturnID"`
+}
+
+// Event types
+
+// OutboxStore reads the domain events recorded in the outbox, in the order they were recorded, so the
+// relay can publish them. The events are recorded by the stores themselves, see outbox.Record.
+type OutboxStore interface {
+	GetUnpublishedEvents(limit int) ([]DomainEvent, error)
+	MarkEventsPublished(ids []int64) error
+	MarkEventFailed(id int64, reason string) error
+	// WithLock runs fn unless another relay holds the lock, the returned bool reports whether it ran.
+	WithLock(fn func() error) (bool, error)
+}
+
+// EventBroker delivers the domain events outside the API, Publish returns once the broker has the event.
+type EventBroker interface {
+	Publish(ctx context.Context, event DomainEvent) error
+	Close() error
+}
+
+const (
+	AggregateOrder   = "order"
+	AggregateProduct = "product"
+	AggregateUser    = "user"
+	AggregateCart    = "cart"
+)
+
+const (
+	EventOrderPlaced           = "order.placed"
+	EventOrderStatusChanged    = "order.status_changed"
+	EventShipmentStatusChanged = "shipment.status_changed"
+	EventProductCreated        = "product.created"
+	EventProductUpdated        = "product.updated"
+	EventProductDeleted        = "product.deleted"
+	EventProductRestored       = "product.restored"
+	EventUserRegistered        = "user.registered"
+	EventCartAbandoned         = "cart.abandoned"
+)
+
+// DomainEvent is a change of an aggregate, the events of an aggregate are published in the order of their ids.
+type DomainEvent struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregateType"`
+	AggregateID   string          `json:"aggregateId"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	OccurredAt    time.Time       `json:"occurredAt"`
+	PublishedAt   *time.Time      `json:"-"`
+	Attempts      int             `json:"-"`
+	LastError     string          `json:"-"`
+}
+
+type OrderPlacedEvent struct {
+	Order Order       `json:"order"`
+	Items []OrderItem `json:"items"`
+}
+
+type OrderStatusChangedEvent struct {
+	OrderID int    `json:"orderId"`
+	UserID  int    `json:"userId"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// ShipmentStatusChangedEvent is recorded on the order of the shipment, so it's published in order with the order's events.
+type ShipmentStatusChangedEvent struct {
+	ShipmentID int    `json:"shipmentId"`
+	OrderID    int    `json:"orderId"`
+	UserID     int    `json:"userId"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+}
+
+type ProductDeletedEvent struct {
+	ProductID int `json:"productId"`
+}
+
+// CartAbandonedEvent is recorded with every reminder of an abandoned cart, Items is the cart as it was
+// reminded of and RestoreURL the signed link that puts it back.
+type CartAbandonedEvent struct {
+	ReminderID       int        `json:"reminderId"`
+	CartID           int        `json:"cartId"`
+	UserID           int        `json:"userId"`
+	Step             int        `json:"step"`
+	Items            []CartItem `json:"items"`
+	CouponCode       string     `json:"couponCode,omitempty"`
+	CouponPercentage float64    `json:"couponPercentage,omitempty"`
+	CouponEndsAt     *time.Time `json:"couponEndsAt,omitempty"`
+	RestoreURL       string     `json:"restoreUrl"`
+}
+
+// Partner webhook types
+
+// PartnerWebhookStore keeps the webhook endpoints the partners registered and the deliveries of the
+// domain events they subscribed to, with every attempt made to deliver them.
+type PartnerWebhookStore interface {
+	CreateWebhookEndpoint(endpoint WebhookEndpoint) (*WebhookEndpoint, error)
+	GetWebhookEndpointById(id int) (*WebhookEndpoint, error)
+	GetWebhookEndpoints(limit, offset int) ([]WebhookEndpoint, int, error)
+	UpdateWebhookEndpoint(id int, payload WebhookEndpointPatchPayload) (*WebhookEndpoint, error)
+	DeleteWebhookEndpoint(id int) error
+	RotateWebhookSecret(id int, secret string, previousExpiresAt time.Time) (*WebhookEndpoint, error)
+	// EnqueueWebhookDeliveries queues the body for every active endpoint subscribed to the event, an event
+	// is queued once per endpoint however many times it's enqueued.
+	EnqueueWebhookDeliveries(event DomainEvent, body []byte) (int, error)
+	// ClaimDueWebhookDeliveries returns the pending deliveries that are due and puts their next attempt
+	// lease later, so another sender doesn't pick them up meanwhile.
+	ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]WebhookDelivery, error)
+	RecordWebhookAttempt(attempt WebhookDeliveryAttempt, status string, nextAttemptAt *time.Time) error
+	GetWebhookDeliveryById(id int64) (*WebhookDelivery, error)
+	GetWebhookDeliveries(endpointID int, status string, limit, offset int) ([]WebhookDelivery, int, error)
+	RedeliverWebhook(id int64) (*WebhookDelivery, error)
+}
+
+const (
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusDelivered = "delivered"
+	WebhookDeliveryStatusDead      = "dead"
+)
+
+// WebhookEndpoint is where a partner receives the events of EventTypes. The deliveries are signed with
+// Secret, and with PreviousSecret too until PreviousSecretExpiresAt so a rotation doesn't break them.
+type WebhookEndpoint struct {
+	ID                      int        `json:"id"`
+	URL                     string     `json:"url"`
+	Description             string     `json:"description"`
+	EventTypes              []string   `json:"eventTypes"`
+	Active                  bool       `json:"active"`
+	Secret                  string     `json:"-"`
+	PreviousSecret          string     `json:"-"`
+	PreviousSecretExpiresAt *time.Time `json:"previousSecretExpiresAt"`
+	CreatedAt               time.Time  `json:"createdAt"`
+	UpdatedAt               time.Time  `json:"updatedAt"`
+}
+
+// WebhookDelivery is an event sent to an endpoint, Payload is the exact body sent on every attempt.
+// A delivery that failed every attempt is dead until it's redelivered.
+type WebhookDelivery struct {
+	ID            int64                    `json:"id"`
+	EndpointID    int                      `json:"endpointId"`
+	EventID       int64                    `json:"eventId"`
+	EventType     string                   `json:"eventType"`
+	Payload       json.RawMessage          `json:"payload"`
+	Status        string                   `json:"status"`
+	Attempts      int                      `json:"attempts"`
+	NextAttemptAt *time.Time               `json:"nextAttemptAt"`
+	LastError     string                   `json:"lastError"`
+	DeliveredAt   *time.Time               `json:"deliveredAt"`
+	CreatedAt     time.Time                `json:"createdAt"`
+	History       []WebhookDeliveryAttempt `json:"history,omitempty"`
+}
+
+// WebhookDeliveryAttempt is a request made to deliver a webhook, ResponseStatus is nil when no
+// response came back.
+type WebhookDeliveryAttempt struct {
+	ID             int64     `json:"id"`
+	DeliveryID     int64     `json:"deliveryId"`
+	ResponseStatus *int      `json:"responseStatus"`
+	ResponseBody   string    `json:"responseBody"`
+	Error          string    `json:"error"`
+	DurationMs     int       `json:"durationMs"`
+	AttemptedAt    time.Time `json:"attemptedAt"`
+}
+
+type WebhookEndpointPayload struct {
+	URL         string   `json:"url" validate:"required,http_url,max=2048"`
+	Description string   `json:"description" validate:"max=255"`
+	EventTypes  []string `json:"eventTypes" validate:"required,min=1,unique,dive,oneof=order.placed order.status_changed shipment.status_changed product.created product.updated product.deleted product.restored user.registered"`
+}
+
+type WebhookEndpointPatchPayload struct {
+	URL         *string  `json:"url" validate:"omitempty,http_url,max=2048"`
+	Description *string  `json:"description" validate:"omitempty,max=255"`
+	EventTypes  []string `json:"eventTypes" validate:"omitempty,min=1,unique,dive,oneof=order.placed order.status_changed shipment.status_changed product.created product.updated product.deleted product.restored user.registered"`
+	Active      *bool    `json:"active"`
+}
+
+// Notification types
+
+// NotificationStore queues the emails sent to the users and keeps their notification preferences.
+type NotificationStore interface {
+	// EnqueueNotification queues the notification once per event and template, enqueued is false when
+	// the event was already queued.
+	EnqueueNotification(notification Notification) (bool, error)
+	// ClaimDueNotifications returns the queued notifications that are due and puts their next attempt
+	// lease later, so another sender doesn't pick them up meanwhile.
+	ClaimDueNotifications(limit int, lease time.Duration) ([]Notification, error)
+	UpdateNotification(notification Notification) error
+	GetNotifications(status string, limit, offset int) ([]Notification, int, error)
+	// GetNotificationPreferences returns the defaults when the user never changed them.
+	GetNotificationPreferences(userID int) (*NotificationPreferences, error)
+	SaveNotificationPreferences(preferences NotificationPreferences) error
+}
+
+const (
+	NotificationStatusQueued  = "queued"
+	NotificationStatusSent    = "sent"
+	NotificationStatusSkipped = "skipped"
+	NotificationStatusFailed  = "failed"
+)
+
+// the kinds of emails a user can opt out of.
+const (
+	NotificationCategoryAccount   = "account"
+	NotificationCategoryOrders    = "orders"
+	NotificationCategoryShipping  = "shipping"
+	NotificationCategoryMarketing = "marketing"
+)
+
+// Notification is an email queued for a user, it's rendered when it's sent with the version of the
+// template it was queued with. Payload is the payload of the event it notifies of.
+type Notification struct {
+	ID              int64           `json:"id"`
+	UserID          int             `json:"userId"`
+	Template        string          `json:"template"`
+	TemplateVersion int             `json:"templateVersion"`
+	EventID         *int64          `json:"eventId"`
+	Payload         json.RawMessage `json:"payload"`
+	Status          string          `json:"status"`
+	Attempts        int             `json:"attempts"`
+	NextAttemptAt   *time.Time      `json:"nextAttemptAt"`
+	LastError       string          `json:"lastError"`
+	SentAt          *time.Time      `json:"sentAt"`
+	CreatedAt       time.Time       `json:"createdAt"`
+}
+
+type NotificationPreferences struct {
+	UserID    int    `json:"-"`
+	Locale    string `json:"locale"`
+	Account   bool   `json:"account"`
+	Orders    bool   `json:"orders"`
+	Shipping  bool   `json:"shipping"`
+	Marketing bool   `json:"marketing"`
+}
+
+type NotificationPreferencesPayload struct {
+	Locale    *string `json:"locale" validate:"omitempty,oneof=en ar"`
+	Account   *bool   `json:"account"`
+	Orders    *bool   `json:"orders"`
+	Shipping  *bool   `json:"shipping"`
+	Marketing *bool   `json:"marketing"`
+}
+
+// Job types
+
+type JobStore interface {
+	// EnqueueJob queues the job, when a job with the same unique key is still queued or running that job is
+	// returned instead and enqueued is false.
+	EnqueueJob(job Job) (*Job, bool, error)
+	// ClaimJobs marks the due jobs as running until lease is over and counts the attempt, the running jobs
+	// whose lease is over are claimed again since their worker is gone.
+	ClaimJobs(limit int, lease time.Duration) ([]Job, error)
+	CompleteJob(id int64) error
+	// FailJob queues the job again at retryAt, or marks it as failed when retryAt is nil.
+	FailJob(id int64, reason string, retryAt *time.Time) error
+	GetJobs(status string, limit, offset int) ([]Job, int, error)
+	GetJobById(id int64) (*Job, error)
+	// RetryJob queues a failed job again with a fresh set of attempts, ErrJobNotFailed is returned for the other jobs.
+	RetryJob(id int64) (*Job, error)
+	DeleteFinishedJobs(before time.Time) (int, error)
+	// EnsureJobSchedule adds the recurring job schedule when it doesn't exist yet.
+	EnsureJobSchedule(name string, nextRunAt time.Time) error
+	// AdvanceJobSchedule queues the job of the schedule when it's due at now and moves it to next, advanced
+	// is false when the schedule isn't due or another worker advanced it first.
+	AdvanceJobSchedule(name string, now, next time.Time, job Job) (bool, error)
+}
+
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// the types of the jobs the workers run.
+const (
+	JobTypePruneJobs            = "jobs.prune"
+	JobTypeSweepIdempotencyKeys = "idempotency.sweep_keys"
+	JobTypeRemindAbandonedCarts = "carts.remind_abandoned"
+	JobTypeSweepReservations    = "inventory.sweep_reservations"
+	JobTypeDispatchStockAlerts  = "inventory.dispatch_stock_alerts"
+	JobTypeRelayOutbox          = "outbox.relay"
+	JobTypeDeliverWebhooks      = "webhooks.deliver"
+	JobTypeSendNotifications    = "notifications.send"
+)
+
+// ErrJobNotFailed is returned when a job that didn't fail is retried.
+var ErrJobNotFailed = errors.New("only failed jobs can be retried")
+
+// Job is a unit of background work, Payload is decoded by the handler of its type. UniqueKey is only kept
+// while the job is queued or running, so the same work can be queued again once it's done.
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"maxAttempts"`
+	UniqueKey   *string         `json:"uniqueKey"`
+	RunAt       time.Time       `json:"runAt"`
+	LockedUntil *time.Time      `json:"lockedUntil"`
+	LastError   string          `json:"lastError"`
+	FinishedAt  *time.Time      `json:"finishedAt"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// Cart types
+
+type CartStore interface {
+	WithTx(tx db.DBTX) CartStore
+	// GetCart returns the cart of the user, an empty one when they never saved one.
+	GetCart(userID int) (*Cart, error)
+	// SaveCart replaces the items of the user's cart, it counts as activity on the cart.
+	SaveCart(userID int, items []CartItem) (*Cart, error)
+	// ConvertCart takes the ordered items out of the user's cart once the order completed and attributes
+	// the order to the last reminder they were sent since remindedSince. An order is only converted once.
+	ConvertCart(userID, orderID int, remindedSince time.Time) error
+	// GetAbandonedCarts returns the carts idle since before idleSince that were sent the reminders before step,
+	// all of them before remindedBefore, but not step itself. The users who ordered since and the ones who opted
+	// out of marketing are left out.
+	GetAbandonedCarts(step int, idleSince, remindedBefore time.Time, limit int) ([]Cart, error)
+	// CreateCartReminder records the reminder once per step of the cart's activity, created is false when
+	// it was already recorded.
+	CreateCartReminder(reminder CartReminder) (*CartReminder, bool, error)
+	GetCartReminderById(id int) (*CartReminder, error)
+	// MarkCartReminderClicked reports whether the click was the first, the later ones aren't recorded.
+	MarkCartReminderClicked(id int) (bool, error)
+	GetCartRecoveryReport(from, to time.Time) (*CartRecoveryReport, error)
+}
+
+type Cart struct {
+	ID             int        `json:"id"`
+	UserID         int        `json:"userId"`
+	Items          []CartItem `json:"items"`
+	LastActivityAt time.Time  `json:"lastActivityAt"`
+}
+
+type CartItem struct {
+	ProductID int `json:"productId" validate:"required,gt=0"`
+	Quantity  int `json:"quantity" validate:"required,gt=0"`
+}
+
+type CartPayload struct {
+	Items []CartItem `json:"items" validate:"max=100,dive"`
+}
+
+// CartReminder is an email of the sequence that reminds a user of their abandoned cart. ActivityAt is the last
+// activity of the cart it reminds of, the sequence starts over when the cart changes. Items is the cart as it
+// was reminded of, the restore link puts it back.
+type CartReminder struct {
+	ID          int        `json:"id"`
+	CartID      int        `json:"cartId"`
+	UserID      int        `json:"userId"`
+	ActivityAt  time.Time  `json:"activityAt"`
+	Step        int        `json:"step"`
+	Items       []CartItem `json:"items"`
+	CouponCode  *string    `json:"couponCode"`
+	ClickedAt   *time.Time `json:"clickedAt"`
+	OrderID     *int       `json:"orderId"`
+	ConvertedAt *time.Time `json:"convertedAt"`
+	SentAt      time.Time  `json:"sentAt"`
+}
+
+// CartRecoveryReport covers the reminders sent from From until To, a cart is recovered when one of the
+// reminders of its abandonment was followed by an order that completed and wasn't cancelled since.
+// ConversionRate is the percentage of the reminded carts that were recovered.
+type CartRecoveryReport struct {
+	From             time.Time          `json:"from"`
+	To               time.Time          `json:"to"`
+	CartsReminded    int                `json:"cartsReminded"`
+	CartsRecovered   int                `json:"cartsRecovered"`
+	ConversionRate   float64            `json:"conversionRate"`
+	RecoveredRevenue money.Money        `json:"recoveredRevenue"`
+	CouponsRedeemed  int                `json:"couponsRedeemed"`
+	Steps            []CartRecoveryStep `json:"steps"`
+}
+
+type CartRecoveryStep struct {
+	Step      int `json:"step"`
+	Sent      int `json:"sent"`
+	Clicked   int `json:"clicked"`
+	Converted int `json:"converted"`
+}
+
+// Mail types
+
+type Mailer interface {
+	Send(email Email) error
+}
+
+// Email is sent as plain text, or with HTMLBody as the alternative when it has one.
+type Email struct {
+	To       []string
+	Subject  string
+	Body     string
+	HTMLBody string
+	Headers  map[string]string
+}
+
+// Warehouse types
+
+type WarehouseStore interface {
+	GetWarehouses() ([]Warehouse, error)
+	GetWarehouseById(id int) (*Warehouse, error)
+	CreateWarehouse(warehouse Warehouse) (*Warehouse, error)
+	UpdateWarehouse(id int, warehouse Warehouse) (*Warehouse, error)
+	// returns the stock of the products in the active warehouses, keyed by product id.
+	GetProductsStockLevels(productIDs []int) (map[int][]ProductStockLevel, error)
+}
+
+// Warehouse is a location the stock is shipped from, lower priorities are preferred when allocating.
+type Warehouse struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Code      string    `json:"code"`
+	Country   string    `json:"country"`
+	Latitude  *float64  `json:"latitude"`
+	Longitude *float64  `json:"longitude"`
+	Priority  int       `json:"priority"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type WarehousePayload struct {
+	Name      string   `json:"name" validate:"required,max=100"`
+	Code      string   `json:"code" validate:"required,max=32"`
+	Country   string   `json:"country" validate:"omitempty,iso3166_1_alpha2"`
+	Latitude  *float64 `json:"latitude" validate:"omitnil,gte=-90,lte=90,required_with=Longitude"`
+	Longitude *float64 `json:"longitude" validate:"omitnil,gte=-180,lte=180,required_with=Latitude"`
+	Priority  int      `json:"priority" validate:"gte=0"`
+	Active    *bool    `json:"active"`
+}
+
+// ProductStockLevel is the stock of a product in one warehouse, available excludes the active reservations.
+type ProductStockLevel struct {
+	ProductID     int    `json:"-"`
+	WarehouseID   int    `json:"warehouseId"`
+	WarehouseCode string `json:"warehouseCode"`
+	Quantity      int    `json:"quantity"`
+	Available     int    `json:"available"`
+}
+
+// StockAllocation is the part of an order line fulfilled from one warehouse.
+type StockAllocation struct {
+	ProductID   int `json:"productId"`
+	WarehouseID int `json:"warehouseId"`
+	Quantity    int `json:"quantity"`
+}
+
+// AllocationStrategy decides which warehouses fulfil the order lines,
+// it fails with ErrInsufficientStock when the warehouses can't cover a line.
+type AllocationStrategy interface {
+	Allocate(items []CartCheckoutItem, warehouses []Warehouse, stock map[int][]ProductStockLevel, destination *ShippingAddress) ([]StockAllocation, error)
 }
 
 // User types
@@ -64,8 +1503,14 @@ type User struct {
 	Password  string    `json:"-"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+	Role      string    `json:"role"`
 }
 
+const (
+	UserRoleCustomer = "customer"
+	UserRoleAdmin    = "admin"
+)
+
 type UserStore interface {
 	GetUserByEmail(email string) (*User, error)
 	GetUserByID(id int) (*User, error)
@@ -74,29 +1519,87 @@ type UserStore interface {
 
 // order types
 
+// Order keeps the totals of its price breakdown as columns and the breakdown itself in Pricing.
 type Order struct {
-	ID        int `json:"id"`
-	UserID    int `json:"userId"`
-	Total     float64 `json:"total"`
-	Status    string    `json:"status"`
-	Address   string    `json:"address"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID               int             `json:"id"`
+	UserID           int             `json:"userId"`
+	Total            money.Money     `json:"total"`
+	Status           string          `json:"status"`
+	Address          string          `json:"address"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	UpdatedAt        time.Time       `json:"updatedAt"`
+	Subtotal         money.Money     `json:"subtotal"`
+	DiscountTotal    money.Money     `json:"discountTotal"`
+	ShippingTotal    money.Money     `json:"shippingTotal"`
+	TaxTotal         money.Money     `json:"taxTotal"`
+	Pricing          *PriceBreakdown `json:"pricing,omitempty"`
+	ShippingMethodID *int            `json:"shippingMethodId"`
+	ShippingMethod   string          `json:"shippingMethod"`
 }
 
 type OrderStore interface {
+	WithTx(tx db.DBTX) OrderStore
 	CreateOrder(order Order) (Order ,error)
 	CreateOrderItem(orderItem OrderItem) (OrderItem ,error)
+	CreateOrderItemTaxes(orderItemID int, taxes []TaxLine) error
+	GetOrderById(id int) (*Order, error)
+	GetOrderItems(orderID int) ([]OrderItem, error)
+	UpdateOrderStatus(orderID int, status string) error
+	CreateShipment(shipment Shipment) (*Shipment, error)
+	GetOrderShipments(orderID int) ([]Shipment, error)
+	UpdateShipmentStatus(shipmentID int, status string) (*Shipment, error)
+}
+
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusCompleted = "completed"
+	OrderStatusCancelled = "cancelled"
+)
+
+// shipment types
+
+const (
+	ShipmentStatusPending   = "pending"
+	ShipmentStatusShipped   = "shipped"
+	ShipmentStatusDelivered = "delivered"
+	ShipmentStatusCancelled = "cancelled"
+)
+
+// ErrShipmentStatus is returned when a shipment can't move to a status from the one it's in.
+var ErrShipmentStatus = errors.New("invalid shipment status change")
+
+// Shipment is the part of an order sent from one warehouse.
+type Shipment struct {
+	ID          int            `json:"id"`
+	OrderID     int            `json:"orderId"`
+	WarehouseID int            `json:"warehouseId"`
+	Status      string         `json:"status"`
+	DeliveredAt *time.Time     `json:"deliveredAt"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	Items       []ShipmentItem `json:"items"`
+}
+
+type ShipmentStatusPayload struct {
+	Status string `json:"status" validate:"required,oneof=shipped delivered cancelled"`
+}
+
+type ShipmentItem struct {
+	ID         int `json:"id"`
+	ShipmentID int `json:"shipmentId"`
+	ProductID  int `json:"productId"`
+	Quantity   int `json:"quantity"`
 }
 
 // order items types
 
 type OrderItem struct {
-	ID        int       `json:"id"`
-	OrderID   int       `json:"orderId"`
-	ProductID int       `json:"productId"`
-	Quantity  int       `json:"quantity" validate:"gte=0"`
-	Price     float64   `json:"price" validate:"gte=0"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID        int         `json:"id"`
+	OrderID   int         `json:"orderId"`
+	ProductID int         `json:"productId"`
+	Quantity  int         `json:"quantity" validate:"gte=0"`
+	Price     money.Money `json:"price" validate:"gte=0"`
+	CreatedAt time.Time   `json:"createdAt"`
 }
 
 // checkout type
@@ -107,5 +1610,53 @@ type CartCheckoutItem struct {
 }
 
 type CartCheckoutItems struct {
-	CartItems []CartCheckoutItem `json:"cartItems" validate:"required"`
+	CartItems        []CartCheckoutItem `json:"cartItems" validate:"required,min=1"`
+	ShippingAddress  *ShippingAddress   `json:"shippingAddress" validate:"required"`
+	ShippingMethodID int                `json:"shippingMethodId" validate:"required,gt=0"`
+	CouponCodes      []string           `json:"couponCodes" validate:"max=5,dive,required,max=64"`
+	PaymentMethod    string             `json:"paymentMethod" validate:"required,max=255"`
+}
+
+// PriceLine is a cart line with its prices, Total is the Subtotal less the promotions adjustments.
+// CouponDiscount is the line's share of the coupon discounts, what's paid for the line is Total less it.
+type PriceLine struct {
+	ProductID      int              `json:"productId"`
+	CategoryID     *int             `json:"categoryId,omitempty"`
+	Quantity       int              `json:"quantity"`
+	UnitPrice      money.Money      `json:"unitPrice"`
+	Subtotal       money.Money      `json:"subtotal"`
+	Discount       money.Money      `json:"discount"`
+	Total          money.Money      `json:"total"`
+	CouponDiscount money.Money      `json:"couponDiscount"`
+	Adjustments    []LineAdjustment `json:"adjustments"`
+	Taxes          []TaxLine        `json:"taxes"`
+}
+
+// PriceBreakdown explains how the total of the order was reached, Total is the Subtotal less the DiscountTotal
+// plus the Shipping and the Tax. With TaxInclusive the prices already contain the Tax so it isn't added again.
+type PriceBreakdown struct {
+	Lines         []PriceLine        `json:"lines"`
+	Subtotal      money.Money        `json:"subtotal"`
+	Promotions    []AppliedPromotion `json:"promotions"`
+	Coupons       []AppliedCoupon    `json:"coupons"`
+	DiscountTotal money.Money        `json:"discountTotal"`
+	FreeShipping  bool               `json:"freeShipping"`
+	Shipping      money.Money        `json:"shipping"`
+	ShippingRate  *ShippingRate      `json:"shippingRate"`
+	TaxInclusive  bool               `json:"taxInclusive"`
+	Taxes         []TaxSummary       `json:"taxes"`
+	Tax           money.Money        `json:"tax"`
+	Total         money.Money        `json:"total"`
+}
+
+// ShippingAddress is where the order is delivered, the coordinates are optional
+// and let the nearest warehouse be picked more precisely than by country.
+type ShippingAddress struct {
+	Line1      string   `json:"line1" validate:"required,max=255"`
+	City       string   `json:"city" validate:"required,max=100"`
+	Region     string   `json:"region" validate:"max=100"`
+	PostalCode string   `json:"postalCode" validate:"max=20"`
+	Country    string   `json:"country" validate:"required,iso3166_1_alpha2"`
+	Latitude   *float64 `json:"latitude" validate:"omitnil,gte=-90,lte=90,required_with=Longitude"`
+	Longitude  *float64 `json:"longitude" validate:"omitnil,gte=-180,lte=180,required_with=Latitude"`
 }
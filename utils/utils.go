@@ -15,9 +15,20 @@ import (
 	"github.com/go-sql-driver/mysql"
 	"github.com/mohammadahmadkhader/golang-ecommerce/config"
 	myDB "github.com/mohammadahmadkhader/golang-ecommerce/db"
+	"github.com/mohammadahmadkhader/golang-ecommerce/money"
 )
 const GenericErrMessage = "An unexpected error has occurred, please try again later!"
-var Validate = validator.New()
+var Validate = newValidator()
+
+// amounts are validated on their minor units, so tags like gt=0 work on money.Money.
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(money.Money).Amount
+	}, money.Money{})
+
+	return validate
+}
 
 func ParseJSON(r *http.Request, payload any) error {
 	if r.Body == nil {
@@ -120,6 +131,23 @@ func logCaptureStackTrace() string {
 	return filteredStackTraceAsString
 }
 
+// returns a message that is safe to show to the client for errors that are reported inside a response body
+// (e.g. per row errors) instead of through WriteError.
+func ErrorMessage(err error) string {
+	var mySqlError *mysql.MySQLError
+	var validationErrs validator.ValidationErrors
+
+	if errors.As(err, &validationErrs) {
+		return validationErrMsgHandler(validationErrs)
+	}
+	if errors.As(err, &mySqlError) && config.Envs.Env == "production" {
+		log.Println(err.Error())
+		return GenericErrMessage
+	}
+
+	return err.Error()
+}
+
 // returns first validation error, it's responsible for handling validator validation errors.
 func validationErrMsgHandler(errors validator.ValidationErrors) string {
 		var message string;